@@ -25,22 +25,64 @@ var DefaultBuiltins = [...]*Builtin{
 	GreaterThan, GreaterThanEq, LessThan, LessThanEq, NotEqual,
 
 	// Arithmetic
-	Plus, Minus, Multiply, Divide, Round, Abs,
+	Plus, Minus, Multiply, Divide, Round, Abs, EuclideanDistance, Floor, Ceil, Rem,
+	NumbersRange, Pow, Sqrt,
 
 	// Aggregates
-	Count, Sum, Max,
+	Count, Sum, Product, Max, Min, Median, Histogram, HistogramClamped, Sort,
+	All, Any, MaxBy, MinBy, SortBy,
 
 	// Casting
-	ToNumber,
+	ToNumber, ToBoolean, ParseIntAuto, ToString,
+
+	// Arrays
+	Duplicates, CountEq, Rotate, AtMostOne, FindIndex, CommonElements, CumMax,
+	Distinct, Flatten,
+
+	// Collections
+	IsOneOf, Member,
+
+	// Objects
+	ObjectValuesSum, ObjectGet, ObjectKeySymDiff, CollectPath, ObjectMatches, KeysAbove,
+	PathAllowed, EntriesSortedByValue, ChangedValues, ObjectUnion, ObjectRemove,
 
 	// Regular Expressions
-	RegexMatch,
+	RegexMatch, RegexFind, RegexReplace,
+
+	// Crypto
+	MD5, SHA1, SHA256,
+
+	// Encoding
+	JSONMarshal, JSONUnmarshal, ValueSizeBytes,
+
+	// Globs
+	GlobMatchAny,
+
+	// Graphs
+	EffectivePermissions,
+
+	// Sampling
+	WeightedSample, LabelBucket,
 
 	// Sets
-	SetDiff,
+	SetDiff, SetComplement, Jaccard,
 
 	// Strings
 	Concat, FormatInt, IndexOf, Substring, Lower, Upper, Contains, StartsWith, EndsWith,
+	ParseKV, ParseKVLenient, Replace, Trim, TrimPrefixAny, Sprintf, CanonicalHost,
+
+	// Time
+	InBusinessHours,
+
+	// Types
+	IsString, IsNumber, IsBoolean, IsArray, IsSet, IsObject, IsNull, TypeNameBuiltin,
+	ValidateAll,
+
+	// Values
+	MaxDepth, LeafCount, WalkBuiltin,
+
+	// Tracing
+	Trace,
 }
 
 // BuiltinMap provides a convenient mapping of built-in names to
@@ -144,6 +186,62 @@ var Abs = &Builtin{
 	TargetPos: []int{1},
 }
 
+// EuclideanDistance returns the Euclidean (L2) distance between two arrays of
+// numbers of equal length.
+var EuclideanDistance = &Builtin{
+	Name:      Var("euclidean_distance"),
+	NumArgs:   3,
+	TargetPos: []int{2},
+}
+
+// Floor rounds the number down to the nearest integer.
+var Floor = &Builtin{
+	Name:      Var("floor"),
+	NumArgs:   2,
+	TargetPos: []int{1},
+}
+
+// Ceil rounds the number up to the nearest integer.
+var Ceil = &Builtin{
+	Name:      Var("ceil"),
+	NumArgs:   2,
+	TargetPos: []int{1},
+}
+
+// Rem returns the remainder of dividing the first integer by the second
+// integer. The result has the same sign as the first integer.
+var Rem = &Builtin{
+	Name:      Var("rem"),
+	NumArgs:   3,
+	TargetPos: []int{2},
+}
+
+// NumbersRange returns an array of the integers from the first integer to the
+// second integer (inclusive). If the first integer is greater than the second
+// integer, the array is in descending order. Ranges containing more than one
+// million integers are rejected.
+var NumbersRange = &Builtin{
+	Name:      Var("numbers_range"),
+	NumArgs:   3,
+	TargetPos: []int{2},
+}
+
+// Pow raises the first number to the power of the second number. Integer
+// powers of integers are computed exactly unless the result would be too large,
+// in which case they are computed with floating point.
+var Pow = &Builtin{
+	Name:      Var("pow"),
+	NumArgs:   3,
+	TargetPos: []int{2},
+}
+
+// Sqrt returns the square root of a non-negative number.
+var Sqrt = &Builtin{
+	Name:      Var("sqrt"),
+	NumArgs:   2,
+	TargetPos: []int{1},
+}
+
 /**
  * Aggregates
  */
@@ -162,6 +260,13 @@ var Sum = &Builtin{
 	TargetPos: []int{1},
 }
 
+// Product takes an array of numbers and multiplies them.
+var Product = &Builtin{
+	Name:      Var("product"),
+	NumArgs:   2,
+	TargetPos: []int{1},
+}
+
 // Max returns the maximum value in a collection.
 var Max = &Builtin{
 	Name:      Var("max"),
@@ -169,6 +274,88 @@ var Max = &Builtin{
 	TargetPos: []int{1},
 }
 
+// Min returns the minimum value in a collection.
+var Min = &Builtin{
+	Name:      Var("min"),
+	NumArgs:   2,
+	TargetPos: []int{1},
+}
+
+// MaxBy returns the element of a collection with the maximum key. The key of
+// each element is obtained by applying a keypath to it. Elements that do not
+// contain the keypath are ignored.
+var MaxBy = &Builtin{
+	Name:      Var("max_by"),
+	NumArgs:   3,
+	TargetPos: []int{2},
+}
+
+// MinBy returns the element of a collection with the minimum key. See MaxBy
+// for how keys are obtained.
+var MinBy = &Builtin{
+	Name:      Var("min_by"),
+	NumArgs:   3,
+	TargetPos: []int{2},
+}
+
+// Median returns the median of an array or set of numbers. If the collection
+// contains an even number of elements, the median is the mean of the two
+// middle elements.
+var Median = &Builtin{
+	Name:      Var("median"),
+	NumArgs:   2,
+	TargetPos: []int{1},
+}
+
+// Histogram takes an array of numbers and an array of sorted bin edges and
+// returns an array containing the number of values that fall into each bin.
+// Values outside of the edges are dropped.
+var Histogram = &Builtin{
+	Name:      Var("histogram"),
+	NumArgs:   3,
+	TargetPos: []int{2},
+}
+
+// HistogramClamped is the same as Histogram except that values below the
+// first edge are counted in the first bin and values above the last edge are
+// counted in the last bin.
+var HistogramClamped = &Builtin{
+	Name:      Var("histogram_clamped"),
+	NumArgs:   3,
+	TargetPos: []int{2},
+}
+
+// Sort returns a sorted array containing the elements of an array or set.
+var Sort = &Builtin{
+	Name:      Var("sort"),
+	NumArgs:   2,
+	TargetPos: []int{1},
+}
+
+// SortBy returns an array containing the elements of an array or set sorted by
+// key. See MaxBy for how keys are obtained. The sort is stable.
+var SortBy = &Builtin{
+	Name:      Var("sort_by"),
+	NumArgs:   3,
+	TargetPos: []int{2},
+}
+
+// All returns true if all of the elements in a collection are true. An element
+// is true unless it is false.
+var All = &Builtin{
+	Name:      Var("all"),
+	NumArgs:   2,
+	TargetPos: []int{1},
+}
+
+// Any returns true if any of the elements in a collection are true. An element
+// is true unless it is false.
+var Any = &Builtin{
+	Name:      Var("any"),
+	NumArgs:   2,
+	TargetPos: []int{1},
+}
+
 /**
  * Casting
  */
@@ -182,6 +369,230 @@ var ToNumber = &Builtin{
 	TargetPos: []int{1},
 }
 
+// ToBoolean takes a boolean, string, or number value and converts it to a
+// boolean. The strings "true" and "false" and the numbers 1 and 0 are
+// converted to true and false respectively. All other values are errors.
+var ToBoolean = &Builtin{
+	Name:      Var("to_boolean"),
+	NumArgs:   2,
+	TargetPos: []int{1},
+}
+
+// ParseIntAuto takes a string representing an integer and converts it to a
+// number. The string may begin with a sign. The base is inferred from the
+// prefix: "0x" for hexadecimal, "0o" or a leading "0" for octal, "0b" for
+// binary, and decimal otherwise.
+var ParseIntAuto = &Builtin{
+	Name:      Var("parse_int_auto"),
+	NumArgs:   2,
+	TargetPos: []int{1},
+}
+
+// ToString takes a string, number, boolean, or null value and converts it to
+// a string. Numbers, booleans, and null are converted to their JSON
+// representation. Strings are returned unchanged.
+var ToString = &Builtin{
+	Name:      Var("to_string"),
+	NumArgs:   2,
+	TargetPos: []int{1},
+}
+
+/**
+ * Arrays
+ */
+
+// Duplicates returns the set of values that occur more than once in an array.
+var Duplicates = &Builtin{
+	Name:      Var("duplicates"),
+	NumArgs:   2,
+	TargetPos: []int{1},
+}
+
+// CountEq returns the number of elements in an array that are equal to a
+// value.
+var CountEq = &Builtin{
+	Name:      Var("count_eq"),
+	NumArgs:   3,
+	TargetPos: []int{2},
+}
+
+// Rotate returns an array rotated to the left by n positions. If n is
+// negative, the array is rotated to the right.
+var Rotate = &Builtin{
+	Name:      Var("rotate"),
+	NumArgs:   3,
+	TargetPos: []int{2},
+}
+
+// AtMostOne takes an array of booleans and returns true if at most one of the
+// elements is true.
+var AtMostOne = &Builtin{
+	Name:      Var("at_most_one"),
+	NumArgs:   2,
+	TargetPos: []int{1},
+}
+
+// FindIndex returns the index of the first element in an array that is equal
+// to a value. If no element is equal to the value, the result is undefined.
+var FindIndex = &Builtin{
+	Name:      Var("find_index"),
+	NumArgs:   3,
+	TargetPos: []int{2},
+}
+
+// CommonElements takes an array of arrays and returns the set of values that
+// occur in every array.
+var CommonElements = &Builtin{
+	Name:      Var("common_elements"),
+	NumArgs:   2,
+	TargetPos: []int{1},
+}
+
+// CumMax returns an array where each element is the maximum of the elements of
+// the input array up to and including the same index.
+var CumMax = &Builtin{
+	Name:      Var("cummax"),
+	NumArgs:   2,
+	TargetPos: []int{1},
+}
+
+// Distinct returns a copy of an array with duplicate values removed. The first
+// occurrence of each value is kept.
+var Distinct = &Builtin{
+	Name:      Var("distinct"),
+	NumArgs:   2,
+	TargetPos: []int{1},
+}
+
+// Flatten returns the elements of nested arrays as a single array. Objects and
+// sets are not flattened.
+var Flatten = &Builtin{
+	Name:      Var("flatten"),
+	NumArgs:   2,
+	TargetPos: []int{1},
+}
+
+/**
+ * Collections
+ */
+
+// IsOneOf takes a value and an array or set of allowed values and returns
+// true if the value is equal to one of the allowed values.
+var IsOneOf = &Builtin{
+	Name:      Var("is_one_of"),
+	NumArgs:   3,
+	TargetPos: []int{2},
+}
+
+// Member takes a value and an array, set, or object and returns true if the
+// value is an element of the array or set or a value of the object.
+var Member = &Builtin{
+	Name:      Var("member"),
+	NumArgs:   3,
+	TargetPos: []int{2},
+}
+
+/**
+ * Objects
+ */
+
+// ObjectValuesSum takes an object with number values and sums the values.
+var ObjectValuesSum = &Builtin{
+	Name:      Var("object_values_sum"),
+	NumArgs:   2,
+	TargetPos: []int{1},
+}
+
+// ObjectGet returns the value of a key in an object or a default value if the
+// key does not exist.
+var ObjectGet = &Builtin{
+	Name:      Var("object_get"),
+	NumArgs:   4,
+	TargetPos: []int{3},
+}
+
+// ObjectKeySymDiff returns the set of keys that exist in exactly one of two
+// objects.
+var ObjectKeySymDiff = &Builtin{
+	Name:      Var("object_key_symdiff"),
+	NumArgs:   3,
+	TargetPos: []int{2},
+}
+
+// CollectPath takes a value and a path pattern and returns the set of values
+// in the value at paths matching the pattern. The pattern is an array of keys
+// and indices. The string "*" in the pattern matches any key or index.
+var CollectPath = &Builtin{
+	Name:      Var("collect_path"),
+	NumArgs:   3,
+	TargetPos: []int{2},
+}
+
+// ObjectMatches takes a value and a pattern object and returns true if the
+// value is an object with the same keys as the pattern and values that match
+// the pattern values. The string "*" in the pattern matches any value. Nested
+// pattern objects are matched recursively.
+var ObjectMatches = &Builtin{
+	Name:      Var("object_matches"),
+	NumArgs:   3,
+	TargetPos: []int{2},
+}
+
+// KeysAbove takes an object and a number and returns the sorted array of keys
+// whose values are numbers greater than the threshold. Keys with non-numeric
+// values are skipped.
+var KeysAbove = &Builtin{
+	Name:      Var("keys_above"),
+	NumArgs:   3,
+	TargetPos: []int{2},
+}
+
+// PathAllowed takes a path and an array or set of allowed keys and returns
+// true if every segment of the path is an allowed key. Numeric segments (array
+// indices) are always allowed.
+var PathAllowed = &Builtin{
+	Name:      Var("path_allowed"),
+	NumArgs:   3,
+	TargetPos: []int{2},
+}
+
+// EntriesSortedByValue returns the entries of an object as an array of
+// [key, value] pairs sorted by value. Entries with equal values are sorted by
+// key.
+var EntriesSortedByValue = &Builtin{
+	Name:      Var("entries_sorted_by_value"),
+	NumArgs:   2,
+	TargetPos: []int{1},
+}
+
+// ChangedValues takes two objects and returns an object containing the keys
+// that exist in both objects with different values. Each key maps to an array
+// containing the value from the first object and the value from the second
+// object.
+var ChangedValues = &Builtin{
+	Name:      Var("changed_values"),
+	NumArgs:   3,
+	TargetPos: []int{2},
+}
+
+// ObjectUnion takes two objects and returns an object containing the keys of
+// both. If a key exists in both objects and both values are objects, the values
+// are merged recursively. Otherwise, the value from the second object is used.
+var ObjectUnion = &Builtin{
+	Name:      Var("object_union"),
+	NumArgs:   3,
+	TargetPos: []int{2},
+}
+
+// ObjectRemove takes an object and an array or set of keys and returns a copy
+// of the object without the keys. Keys that do not exist in the object are
+// ignored.
+var ObjectRemove = &Builtin{
+	Name:      Var("object_remove"),
+	NumArgs:   3,
+	TargetPos: []int{2},
+}
+
 /**
  * Regular Expressions
  */
@@ -193,6 +604,124 @@ var RegexMatch = &Builtin{
 	NumArgs: 2,
 }
 
+// RegexFind takes a pattern and a string and returns an array containing the
+// leftmost match of the pattern in the string followed by the matches of the
+// capture groups. If the pattern does not match, the array is empty.
+var RegexFind = &Builtin{
+	Name:      Var("re_find"),
+	NumArgs:   3,
+	TargetPos: []int{2},
+}
+
+// RegexReplace takes a pattern, a string, and a replacement and returns the
+// string with all matches of the pattern replaced. The replacement may refer
+// to capture groups with $1, $2, etc.
+var RegexReplace = &Builtin{
+	Name:      Var("re_replace"),
+	NumArgs:   4,
+	TargetPos: []int{3},
+}
+
+/**
+ * Crypto
+ */
+
+// MD5 returns the MD5 digest of a string as a lower case hex string.
+var MD5 = &Builtin{
+	Name:      Var("md5"),
+	NumArgs:   2,
+	TargetPos: []int{1},
+}
+
+// SHA1 returns the SHA-1 digest of a string as a lower case hex string.
+var SHA1 = &Builtin{
+	Name:      Var("sha1"),
+	NumArgs:   2,
+	TargetPos: []int{1},
+}
+
+// SHA256 returns the SHA-256 digest of a string as a lower case hex string.
+var SHA256 = &Builtin{
+	Name:      Var("sha256"),
+	NumArgs:   2,
+	TargetPos: []int{1},
+}
+
+/**
+ * Encoding
+ */
+
+// JSONMarshal serializes the input value to a JSON string. Sets are serialized
+// as arrays.
+var JSONMarshal = &Builtin{
+	Name:      Var("json_marshal"),
+	NumArgs:   2,
+	TargetPos: []int{1},
+}
+
+// JSONUnmarshal deserializes a JSON string to a value.
+var JSONUnmarshal = &Builtin{
+	Name:      Var("json_unmarshal"),
+	NumArgs:   2,
+	TargetPos: []int{1},
+}
+
+// ValueSizeBytes returns the number of bytes in the JSON serialization of a
+// value. The serialization is the same as the one produced by JSONMarshal.
+var ValueSizeBytes = &Builtin{
+	Name:      Var("value_size_bytes"),
+	NumArgs:   2,
+	TargetPos: []int{1},
+}
+
+/**
+ * Globs
+ */
+
+// GlobMatchAny takes an array or set of glob patterns and a string and returns
+// true if the string matches any of the patterns. Patterns use the syntax
+// supported by path.Match.
+var GlobMatchAny = &Builtin{
+	Name:      Var("glob_match_any"),
+	NumArgs:   3,
+	TargetPos: []int{2},
+}
+
+/**
+ * Graphs
+ */
+
+// EffectivePermissions takes an object mapping roles to the roles they
+// inherit, an object mapping roles to permissions, and the roles assigned to a
+// user and returns the set of permissions granted by the assigned roles and
+// all of the roles they inherit, directly or indirectly.
+var EffectivePermissions = &Builtin{
+	Name:      Var("effective_permissions"),
+	NumArgs:   4,
+	TargetPos: []int{3},
+}
+
+/**
+ * Sampling
+ */
+
+// WeightedSample takes an array of items, an array of weights, and an integer
+// seed and selects one item with probability proportional to its weight. The
+// selection is deterministic for a given seed.
+var WeightedSample = &Builtin{
+	Name:      Var("weighted_sample"),
+	NumArgs:   4,
+	TargetPos: []int{3},
+}
+
+// LabelBucket takes a string and an array of labels and selects one label by
+// hashing the string. The same string and labels always select the same label.
+var LabelBucket = &Builtin{
+	Name:      Var("label_bucket"),
+	NumArgs:   3,
+	TargetPos: []int{2},
+}
+
 /**
  * Sets
  */
@@ -205,6 +734,23 @@ var SetDiff = &Builtin{
 	TargetPos: []int{2},
 }
 
+// SetComplement returns the complement of a set relative to a universe. The
+// complement is all of the elements in the universe that are not in the set.
+var SetComplement = &Builtin{
+	Name:      Var("set_complement"),
+	NumArgs:   3,
+	TargetPos: []int{2},
+}
+
+// Jaccard returns the Jaccard similarity of two sets, i.e., the size of the
+// intersection divided by the size of the union. If both sets are empty, the
+// similarity is 1.
+var Jaccard = &Builtin{
+	Name:      Var("jaccard"),
+	NumArgs:   3,
+	TargetPos: []int{2},
+}
+
 /**
  * Strings
  */
@@ -270,6 +816,182 @@ var Upper = &Builtin{
 	TargetPos: []int{1},
 }
 
+// Replace returns the input string with all non-overlapping instances of
+// old replaced by new.
+var Replace = &Builtin{
+	Name:      Var("replace"),
+	NumArgs:   4,
+	TargetPos: []int{3},
+}
+
+// Trim returns the input string with all leading and trailing characters
+// contained in the cutset removed.
+var Trim = &Builtin{
+	Name:      Var("trim"),
+	NumArgs:   3,
+	TargetPos: []int{2},
+}
+
+// TrimPrefixAny returns the input string with the longest matching prefix
+// from an array or set of prefixes removed. If none of the prefixes match, the
+// input string is returned unchanged.
+var TrimPrefixAny = &Builtin{
+	Name:      Var("trim_prefix_any"),
+	NumArgs:   3,
+	TargetPos: []int{2},
+}
+
+// Sprintf returns the string produced by substituting an array of values into
+// a Go-style format string.
+var Sprintf = &Builtin{
+	Name:      Var("sprintf"),
+	NumArgs:   3,
+	TargetPos: []int{2},
+}
+
+// CanonicalHost returns the canonical form of a host string for comparison.
+// The host is converted to lower case, the trailing dot is removed, and the
+// default ports 80 and 443 are removed.
+var CanonicalHost = &Builtin{
+	Name:      Var("canonical_host"),
+	NumArgs:   2,
+	TargetPos: []int{1},
+}
+
+// ParseKV splits a string into key/value pairs using a pair separator and a
+// key/value separator and returns an object. Pairs that do not contain the
+// key/value separator are errors. If a key occurs more than once, the last
+// value wins.
+var ParseKV = &Builtin{
+	Name:      Var("parse_kv"),
+	NumArgs:   4,
+	TargetPos: []int{3},
+}
+
+// ParseKVLenient is the same as ParseKV except that pairs that do not contain
+// the key/value separator are skipped.
+var ParseKVLenient = &Builtin{
+	Name:      Var("parse_kv_lenient"),
+	NumArgs:   4,
+	TargetPos: []int{3},
+}
+
+/**
+ * Time
+ */
+
+// InBusinessHours takes a timestamp in nanoseconds since the epoch and a start
+// and end hour and returns true if the hour of the timestamp in UTC is in the
+// range [start, end).
+var InBusinessHours = &Builtin{
+	Name:      Var("in_business_hours"),
+	NumArgs:   4,
+	TargetPos: []int{3},
+}
+
+/**
+ * Types
+ */
+
+// IsString returns true if the input value is a string.
+var IsString = &Builtin{
+	Name:    Var("is_string"),
+	NumArgs: 1,
+}
+
+// IsNumber returns true if the input value is a number.
+var IsNumber = &Builtin{
+	Name:    Var("is_number"),
+	NumArgs: 1,
+}
+
+// IsBoolean returns true if the input value is a boolean.
+var IsBoolean = &Builtin{
+	Name:    Var("is_boolean"),
+	NumArgs: 1,
+}
+
+// IsArray returns true if the input value is an array.
+var IsArray = &Builtin{
+	Name:    Var("is_array"),
+	NumArgs: 1,
+}
+
+// IsSet returns true if the input value is a set.
+var IsSet = &Builtin{
+	Name:    Var("is_set"),
+	NumArgs: 1,
+}
+
+// IsObject returns true if the input value is an object.
+var IsObject = &Builtin{
+	Name:    Var("is_object"),
+	NumArgs: 1,
+}
+
+// IsNull returns true if the input value is null.
+var IsNull = &Builtin{
+	Name:    Var("is_null"),
+	NumArgs: 1,
+}
+
+// TypeNameBuiltin returns the type of the input value as a string.
+var TypeNameBuiltin = &Builtin{
+	Name:      Var("type_name"),
+	NumArgs:   2,
+	TargetPos: []int{1},
+}
+
+// ValidateAll takes a value and an array of [path, type name] rules and
+// returns an array of descriptions of the rules that the value violates. A
+// rule is violated if the path does not exist in the value or the value at the
+// path is not of the named type.
+var ValidateAll = &Builtin{
+	Name:      Var("validate_all"),
+	NumArgs:   3,
+	TargetPos: []int{2},
+}
+
+/**
+ * Values
+ */
+
+// MaxDepth returns the maximum nesting depth of a value. Scalars have a depth
+// of 0 and each level of array, object, or set nesting adds 1.
+var MaxDepth = &Builtin{
+	Name:      Var("max_depth"),
+	NumArgs:   2,
+	TargetPos: []int{1},
+}
+
+// LeafCount returns the number of scalar values nested inside a value. A
+// scalar value has a leaf count of 1.
+var LeafCount = &Builtin{
+	Name:      Var("leaf_count"),
+	NumArgs:   2,
+	TargetPos: []int{1},
+}
+
+// WalkBuiltin enumerates the nodes of a value. For each node, the output is an array
+// containing the path to the node and the node itself. The path is an array of
+// the object keys, array indices, and set elements leading to the node.
+var WalkBuiltin = &Builtin{
+	Name:      Var("walk"),
+	NumArgs:   2,
+	TargetPos: []int{1},
+}
+
+/**
+ * Tracing
+ */
+
+// Trace emits a note event containing a message if tracing is enabled. Trace
+// is always true.
+var Trace = &Builtin{
+	Name:    Var("trace"),
+	NumArgs: 1,
+}
+
 // Builtin represents a built-in function supported by OPA. Every
 // built-in function is uniquely identified by a name.
 type Builtin struct {
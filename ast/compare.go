@@ -22,7 +22,8 @@ import (
 // are sorted as follows:
 //
 // nil < Null < Boolean < Number < String < Var < Ref < Array < Object < Set <
-// ArrayComprehension < Expr < Body < Rule < Import < Package < Module.
+// ArrayComprehension < ObjectComprehension < SetComprehension < Expr < Body <
+// Rule < Import < Package < Module.
 //
 // Arrays and Refs are equal iff both a and b have the same length and all
 // corresponding elements are equal. If one element is not equal, the return
@@ -152,6 +153,21 @@ func Compare(a, b interface{}) int {
 			return cmp
 		}
 		return Compare(a.Body, b.Body)
+	case *ObjectComprehension:
+		b := b.(*ObjectComprehension)
+		if cmp := Compare(a.Key, b.Key); cmp != 0 {
+			return cmp
+		}
+		if cmp := Compare(a.Value, b.Value); cmp != 0 {
+			return cmp
+		}
+		return Compare(a.Body, b.Body)
+	case *SetComprehension:
+		b := b.(*SetComprehension)
+		if cmp := Compare(a.Term, b.Term); cmp != 0 {
+			return cmp
+		}
+		return Compare(a.Body, b.Body)
 	case *Expr:
 		b := b.(*Expr)
 		return a.Compare(b)
@@ -202,6 +218,10 @@ func sortOrder(x interface{}) int {
 		return 8
 	case *ArrayComprehension:
 		return 9
+	case *ObjectComprehension:
+		return 10
+	case *SetComprehension:
+		return 11
 	case *Expr:
 		return 100
 	case Body:
@@ -67,6 +67,7 @@ type Compiler struct {
 	RuleGraph map[*Rule]map[*Rule]struct{}
 
 	moduleLoader ModuleLoader
+	ruleIndices  map[*RuleTreeNode]*RuleIndex
 	stages       []stage
 }
 
@@ -142,9 +143,11 @@ func NewCompiler() *Compiler {
 		stage{c.rewriteRefsInHead, "rewriteRefsInHead"},
 		stage{c.checkRuleConflicts, "checkRuleConflicts"},
 		stage{c.checkBuiltins, "checkBuiltins"},
+		stage{c.checkWithModifiers, "checkWithModifiers"},
 		stage{c.checkSafetyRuleHeads, "checkSafetyRuleHeads"},
 		stage{c.checkSafetyRuleBodies, "checkSafetyRuleBodies"},
 		stage{c.checkRecursion, "checkRecursion"},
+		stage{c.buildRuleIndices, "buildRuleIndices"},
 	}
 
 	return c
@@ -167,6 +170,30 @@ func (c *Compiler) Compile(modules map[string]*Module) {
 	c.compile()
 }
 
+// DependencyGraph returns a graph of the dependencies between the documents
+// defined by rules in the compiled modules. The graph is derived from the
+// RuleGraph so it should only be called after compilation succeeds.
+func (c *Compiler) DependencyGraph() *Graph {
+
+	g := newGraph()
+	paths := map[*Rule]Ref{}
+
+	for _, m := range c.Modules {
+		for _, r := range m.Rules {
+			paths[r] = r.Path(m.Package.Path)
+			g.addNode(paths[r])
+		}
+	}
+
+	for u, edges := range c.RuleGraph {
+		for v := range edges {
+			g.addEdge(paths[u], paths[v])
+		}
+	}
+
+	return g
+}
+
 // Failed returns true if a compilation error has been encountered.
 func (c *Compiler) Failed() bool {
 	return len(c.Errors) > 0
@@ -271,6 +298,22 @@ func (c *Compiler) GetRulesWithPrefix(ref Ref) (rules []*Rule) {
 	return rules
 }
 
+// GetRuleIndex returns the RuleIndex for the rules that produce the virtual
+// document referred to by the reference. If the rules are not indexed, nil is
+// returned.
+func (c *Compiler) GetRuleIndex(ref Ref) *RuleIndex {
+	node := c.RuleTree
+
+	for _, x := range ref {
+		node = node.Children[x.Value]
+		if node == nil {
+			return nil
+		}
+	}
+
+	return c.ruleIndices[node]
+}
+
 // ModuleLoader defines the interface that callers can implement to enable lazy
 // loading of modules during compilation.
 type ModuleLoader func(resolved map[string]*Module) (parsed map[string]*Module, err error)
@@ -288,6 +331,28 @@ func (c *Compiler) WithModuleLoader(f ModuleLoader) *Compiler {
 	return c
 }
 
+// buildRuleIndices builds indices for rules that compare request fields
+// against constants. See RuleIndex for details.
+func (c *Compiler) buildRuleIndices() {
+
+	c.ruleIndices = map[*RuleTreeNode]*RuleIndex{}
+
+	var build func(node *RuleTreeNode)
+
+	build = func(node *RuleTreeNode) {
+		if len(node.Rules) > 1 {
+			if index := buildRuleIndex(node.Rules); index != nil {
+				c.ruleIndices[node] = index
+			}
+		}
+		for _, child := range node.Children {
+			build(child)
+		}
+	}
+
+	build(c.RuleTree)
+}
+
 // checkBuiltins ensures that built-in functions are specified correctly.
 func (c *Compiler) checkBuiltins() {
 	for _, mod := range c.Modules {
@@ -298,6 +363,17 @@ func (c *Compiler) checkBuiltins() {
 	}
 }
 
+// checkWithModifiers ensures that with modifiers only replace the request
+// document or documents under data.
+func (c *Compiler) checkWithModifiers() {
+	for _, mod := range c.Modules {
+		wc := newWithChecker()
+		for _, err := range wc.Check(mod) {
+			c.err(err)
+		}
+	}
+}
+
 // checkRecursion ensures that there are no recursive rule definitions, i.e., there are
 // no cycles in the RuleGraph.
 func (c *Compiler) checkRecursion() {
@@ -324,8 +400,15 @@ func (c *Compiler) checkRuleConflicts() {
 		}
 
 		kinds := map[DocKind]struct{}{}
+		defaults := 0
 		for _, rule := range node.Rules {
 			kinds[rule.DocKind()] = struct{}{}
+			if rule.Default {
+				defaults++
+				if !isConstant(rule.Value) {
+					c.err(NewError(CompileErr, rule.Loc(), "%v: illegal default rule (value must be ground)", rule.Name))
+				}
+			}
 		}
 
 		if len(kinds) > 1 {
@@ -333,6 +416,11 @@ func (c *Compiler) checkRuleConflicts() {
 			c.err(NewError(CompileErr, node.Rules[0].Loc(), "%v: conflicting rule types (all definitions of %v must have the same type)", name, name))
 		}
 
+		if defaults > 1 {
+			name := Var(node.Key.(String))
+			c.err(NewError(CompileErr, node.Rules[0].Loc(), "%v: multiple default rules (only one default rule named %v may be defined)", name, name))
+		}
+
 		return false
 	})
 
@@ -351,22 +439,41 @@ func (c *Compiler) checkRuleConflicts() {
 	})
 }
 
+// isConstant returns true if the term is ground and does not contain any
+// references. Ground references are not constant because they refer to
+// documents that are only known at evaluation time.
+func isConstant(term *Term) bool {
+	if !term.IsGround() {
+		return false
+	}
+	constant := true
+	WalkRefs(term, func(Ref) bool {
+		constant = false
+		return true
+	})
+	return constant
+}
+
 // checkSafetyRuleBodies ensures that variables appearing in negated expressions or non-target
 // positions of built-in expressions will be bound when evaluating the rule from left
 // to right, re-ordering as necessary.
 func (c *Compiler) checkSafetyRuleBodies() {
 	for _, m := range c.Modules {
 		safe := ReservedVars.Copy()
-		for _, r := range m.Rules {
+		WalkRules(m, func(r *Rule) bool {
 			reordered, unsafe := reorderBodyForSafety(safe, r.Body)
 			if len(unsafe) != 0 {
-				for v := range unsafe.Vars() {
-					c.err(NewError(UnsafeVarErr, r.Location, "%v: %v is unsafe (variable %v must appear in the output position of at least one non-negated expression)", r.Name, v, v))
+				for v, loc := range unsafe.Locations() {
+					if loc == nil {
+						loc = r.Location
+					}
+					c.err(NewError(UnsafeVarErr, loc, "%v: %v is unsafe (variable %v must appear in the output position of at least one non-negated expression)", r.Name, v, v))
 				}
 			} else {
 				r.Body = reordered
 			}
-		}
+			return false
+		})
 	}
 }
 
@@ -379,12 +486,13 @@ var safetyCheckVarVisitorParams = VarVisitorParams{
 // rule also appear in the body.
 func (c *Compiler) checkSafetyRuleHeads() {
 	for _, m := range c.Modules {
-		for _, r := range m.Rules {
+		WalkRules(m, func(r *Rule) bool {
 			unsafe := r.HeadVars().Diff(r.Body.Vars(safetyCheckVarVisitorParams))
 			for v := range unsafe {
 				c.err(NewError(UnsafeVarErr, r.Location, "%v: %v is unsafe (variable %v must appear in at least one expression within the body of %v)", r.Name, v, v, r.Name))
 			}
-		}
+			return false
+		})
 	}
 }
 
@@ -447,7 +555,7 @@ func (c *Compiler) resolveAllRefs() {
 
 		globals := getGlobals(mod.Package, exportsForPackage, mod.Imports)
 
-		for _, rule := range mod.Rules {
+		WalkRules(mod, func(rule *Rule) bool {
 			if rule.Key != nil {
 				rule.Key = resolveRefsInTerm(globals, rule.Key)
 			}
@@ -455,7 +563,8 @@ func (c *Compiler) resolveAllRefs() {
 				rule.Value = resolveRefsInTerm(globals, rule.Value)
 			}
 			rule.Body = resolveRefsInBody(globals, rule.Body)
-		}
+			return false
+		})
 
 		// Once imports have been resolved, they are no longer needed.
 		mod.Imports = nil
@@ -496,7 +605,12 @@ func (c *Compiler) resolveAllRefs() {
 func (c *Compiler) rewriteRefsInHead() {
 	for _, mod := range c.Modules {
 		generator := newLocalVarGenerator(mod)
-		for _, rule := range mod.Rules {
+		WalkRules(mod, func(rule *Rule) bool {
+			// Default rule values are checked for groundness and are never
+			// evaluated through the rule body.
+			if rule.Default {
+				return false
+			}
 			if rule.Key != nil {
 				found := false
 				WalkRefs(rule.Key, func(Ref) bool {
@@ -529,7 +643,8 @@ func (c *Compiler) rewriteRefsInHead() {
 					rule.Body = append(rule.Body, expr)
 				}
 			}
-		}
+			return false
+		})
 	}
 }
 
@@ -582,6 +697,7 @@ func (qc *queryCompiler) Compile(query Body) (Body, error) {
 		qc.resolveRefs,
 		qc.checkSafety,
 		qc.checkBuiltins,
+		qc.checkWithModifiers,
 	}
 
 	qctx := qc.qctx.Copy()
@@ -619,8 +735,11 @@ func (qc *queryCompiler) checkSafety(qctx *QueryContext, body Body) (Body, error
 
 	if len(unsafe) != 0 {
 		var err Errors
-		for v := range unsafe.Vars() {
-			err = append(err, NewError(UnsafeVarErr, body.Loc(), "%v is unsafe (variable %v must appear in the output position of at least one non-negated expression)", v, v))
+		for v, loc := range unsafe.Locations() {
+			if loc == nil {
+				loc = body.Loc()
+			}
+			err = append(err, NewError(UnsafeVarErr, loc, "%v is unsafe (variable %v must appear in the output position of at least one non-negated expression)", v, v))
 		}
 		return nil, err
 	}
@@ -636,6 +755,14 @@ func (qc *queryCompiler) checkBuiltins(qctx *QueryContext, body Body) (Body, err
 	return body, nil
 }
 
+func (qc *queryCompiler) checkWithModifiers(qctx *QueryContext, body Body) (Body, error) {
+	wc := newWithChecker()
+	if errs := wc.Check(body); len(errs) != 0 {
+		return nil, errs
+	}
+	return body, nil
+}
+
 // ModuleTreeNode represents a node in the module tree. The module
 // tree is keyed by the package path.
 type ModuleTreeNode struct {
@@ -788,6 +915,69 @@ func (bc *builtinChecker) err(code ErrCode, loc *Location, f string, a ...interf
 	*(bc.errors) = append(*(bc.errors), NewError(code, loc, f, a...))
 }
 
+type withChecker struct {
+	errors *Errors
+	prefix string
+}
+
+func newWithChecker() *withChecker {
+	return &withChecker{
+		errors: &Errors{},
+	}
+}
+
+// Check returns with modifier errors underneath the AST node x.
+func (wc *withChecker) Check(x interface{}) Errors {
+	Walk(wc, x)
+	return *(wc.errors)
+}
+
+func (wc *withChecker) Visit(x interface{}) Visitor {
+	switch x := x.(type) {
+	case *Rule:
+		cpy := *wc
+		cpy.prefix = string(x.Name)
+		return &cpy
+	case *With:
+		if !isValidWithTarget(x.Target) {
+			wc.err(CompileErr, x.Location, "with keyword target must refer to request or data (%v is not allowed)", x.Target)
+		}
+		return nil
+	}
+	return wc
+}
+
+func (wc *withChecker) err(code ErrCode, loc *Location, f string, a ...interface{}) {
+	if wc.prefix != "" {
+		f = wc.prefix + ": " + f
+	}
+	*(wc.errors) = append(*(wc.errors), NewError(code, loc, f, a...))
+}
+
+// isValidWithTarget returns true if the term refers to the request document
+// or a document under data. Only string keys are allowed so that the target
+// can be replaced without knowledge of the surrounding documents.
+func isValidWithTarget(term *Term) bool {
+	switch v := term.Value.(type) {
+	case Var:
+		return v.Equal(RequestRootDocument.Value)
+	case Ref:
+		if !v[0].Equal(RequestRootDocument) && !v[0].Equal(DefaultRootDocument) {
+			return false
+		}
+		if v[0].Equal(DefaultRootDocument) && len(v) == 1 {
+			return false
+		}
+		for _, x := range v[1:] {
+			if _, ok := x.Value.(String); !ok {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
 type ruleGraphBuilder struct {
 	moduleTree *ModuleTreeNode
 	edges      map[*Rule]struct{}
@@ -853,14 +1043,98 @@ func (vs unsafeVars) Update(o unsafeVars) {
 	}
 }
 
-func (vs unsafeVars) Vars() VarSet {
-	r := VarSet{}
-	for _, s := range vs {
-		r.Update(s)
+// Locations returns the location of the first occurrence of each unsafe
+// variable. If the location of a variable is unknown, the variable is mapped
+// to nil.
+func (vs unsafeVars) Locations() map[Var]*Location {
+	r := map[Var]*Location{}
+	for e, s := range vs {
+		for v := range s {
+			loc := findVarLocation(e, v)
+			if curr, ok := r[v]; !ok || curr == nil || (loc != nil && locationBefore(loc, curr)) {
+				r[v] = loc
+			}
+		}
 	}
 	return r
 }
 
+// findVarLocation returns the location of the first term under x that
+// contains the variable v or nil if no term with a location contains v.
+func findVarLocation(x interface{}, v Var) *Location {
+	switch x := x.(type) {
+	case *Expr:
+		if loc := findVarLocation(x.Terms, v); loc != nil {
+			return loc
+		}
+		for _, w := range x.With {
+			if loc := findVarLocation(w, v); loc != nil {
+				return loc
+			}
+		}
+	case *With:
+		if loc := findVarLocation(x.Target, v); loc != nil {
+			return loc
+		}
+		return findVarLocation(x.Value, v)
+	case Body:
+		for _, e := range x {
+			if loc := findVarLocation(e, v); loc != nil {
+				return loc
+			}
+		}
+	case *Term:
+		if x.Value.Equal(v) && x.Location != nil {
+			return x.Location
+		}
+		return findVarLocation(x.Value, v)
+	case []*Term:
+		for _, t := range x {
+			if loc := findVarLocation(t, v); loc != nil {
+				return loc
+			}
+		}
+	case Ref:
+		return findVarLocation([]*Term(x), v)
+	case Array:
+		return findVarLocation([]*Term(x), v)
+	case *Set:
+		return findVarLocation([]*Term(*x), v)
+	case Object:
+		for _, item := range x {
+			if loc := findVarLocation(item[:], v); loc != nil {
+				return loc
+			}
+		}
+	case *ArrayComprehension:
+		if loc := findVarLocation(x.Term, v); loc != nil {
+			return loc
+		}
+		return findVarLocation(x.Body, v)
+	case *SetComprehension:
+		if loc := findVarLocation(x.Term, v); loc != nil {
+			return loc
+		}
+		return findVarLocation(x.Body, v)
+	case *ObjectComprehension:
+		if loc := findVarLocation(x.Key, v); loc != nil {
+			return loc
+		}
+		if loc := findVarLocation(x.Value, v); loc != nil {
+			return loc
+		}
+		return findVarLocation(x.Body, v)
+	}
+	return nil
+}
+
+func locationBefore(a, b *Location) bool {
+	if a.Row != b.Row {
+		return a.Row < b.Row
+	}
+	return a.Col < b.Col
+}
+
 // findRules returns a slice of rules referred to by ref.
 //
 // For example, given package a.b.c containing rules p and q:
@@ -1025,6 +1299,12 @@ func (vis *bodySafetyVisitor) Visit(x interface{}) Visitor {
 	case *ArrayComprehension:
 		vis.checkArrayComprehensionSafety(x)
 		return nil
+	case *ObjectComprehension:
+		vis.checkObjectComprehensionSafety(x)
+		return nil
+	case *SetComprehension:
+		vis.checkSetComprehensionSafety(x)
+		return nil
 	}
 	return vis
 }
@@ -1048,6 +1328,45 @@ func (vis *bodySafetyVisitor) checkArrayComprehensionSafety(ac *ArrayComprehensi
 	}
 }
 
+func (vis *bodySafetyVisitor) checkObjectComprehensionSafety(oc *ObjectComprehension) {
+	// Check key and value for safety. This is analogous to the rule head safety check.
+	tv := oc.Key.Vars()
+	tv.Update(oc.Value.Vars())
+	bv := oc.Body.Vars(safetyCheckVarVisitorParams)
+	bv.Update(vis.globals)
+	uv := tv.Diff(bv)
+	for v := range uv {
+		vis.unsafe.Add(vis.current, v)
+	}
+
+	// Check body for safety, reordering as necessary.
+	r, u := reorderBodyForSafety(vis.globals, oc.Body)
+	if len(u) == 0 {
+		oc.Body = r
+	} else {
+		vis.unsafe.Update(u)
+	}
+}
+
+func (vis *bodySafetyVisitor) checkSetComprehensionSafety(sc *SetComprehension) {
+	// Check term for safety. This is analogous to the rule head safety check.
+	tv := sc.Term.Vars()
+	bv := sc.Body.Vars(safetyCheckVarVisitorParams)
+	bv.Update(vis.globals)
+	uv := tv.Diff(bv)
+	for v := range uv {
+		vis.unsafe.Add(vis.current, v)
+	}
+
+	// Check body for safety, reordering as necessary.
+	r, u := reorderBodyForSafety(vis.globals, sc.Body)
+	if len(u) == 0 {
+		sc.Body = r
+	} else {
+		vis.unsafe.Update(u)
+	}
+}
+
 // reorderBodyForClosures returns a copy of the body ordered such that
 // expressions (such as array comprehensions) that close over variables are ordered
 // after other expressions that contain the same variable in an output position.
@@ -1201,6 +1520,14 @@ func resolveRefsInExpr(globals map[Var]Value, expr *Expr) *Expr {
 		}
 		cpy.Terms = buf
 	}
+	if expr.With != nil {
+		cpy.With = make([]*With, len(expr.With))
+		for i := range expr.With {
+			w := *expr.With[i]
+			w.Value = resolveRefsInTerm(globals, w.Value)
+			cpy.With[i] = &w
+		}
+	}
 	return &cpy
 }
 
@@ -1253,6 +1580,21 @@ func resolveRefsInTerm(globals map[Var]Value, term *Term) *Term {
 		cpy := *term
 		cpy.Value = ac
 		return &cpy
+	case *ObjectComprehension:
+		oc := &ObjectComprehension{}
+		oc.Key = resolveRefsInTerm(globals, v.Key)
+		oc.Value = resolveRefsInTerm(globals, v.Value)
+		oc.Body = resolveRefsInBody(globals, v.Body)
+		cpy := *term
+		cpy.Value = oc
+		return &cpy
+	case *SetComprehension:
+		sc := &SetComprehension{}
+		sc.Term = resolveRefsInTerm(globals, v.Term)
+		sc.Body = resolveRefsInBody(globals, v.Body)
+		cpy := *term
+		cpy.Value = sc
+		return &cpy
 	default:
 		return term
 	}
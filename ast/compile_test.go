@@ -185,6 +185,8 @@ func TestCompilerCheckSafetyBodyReordering(t *testing.T) {
 			"a = [true, false], b = [true, false], not a[i], b[i]",
 			"a = [true, false], b = [true, false], b[i], not a[i]"},
 		{"built-in", "x != 0, count([1,2,3], x)", "count([1,2,3], x), x != 0"},
+		{"built-in composite", "x != 0, sort([2, 1], [y, x])", "sort([2, 1], [y, x]), x != 0"},
+		{"built-in composite", `x != 0, walk({"a": 1}, [y, x])`, `walk({"a": 1}, [y, x]), x != 0`},
 		{"var/var 1", "x = y, z = 1, y = z", "z = 1, y = z, x = y"},
 		{"var/var 2", "x = y, 1 = z, z = y", "1 = z, z = y, x = y"},
 		{"var/var 3", "x != 0, y = x, y = 1", "y = 1, y = x, x != 0"},
@@ -333,6 +335,12 @@ func TestCompilerCheckSafetyBodyErrors(t *testing.T) {
 	negatedImport3 = true :- not baz
 
 	rewriteUnsafe[{"foo": dead[i]}] :- true  # dead is not imported
+
+	# x would be unbound in the else body
+	unsafeElse = 1 :- false else = 2 :- x > 1
+
+	# x would be unbound because with values are evaluated before the expression
+	unsafeWith :- data.a[0] = x with request.foo as x
 	`)}
 	compileStages(c, "", "checkSafetyBody")
 
@@ -368,6 +376,8 @@ func TestCompilerCheckSafetyBodyErrors(t *testing.T) {
 		makeErrMsg("unsafeClosure2", "y"),
 		makeErrMsg("unsafeNestedHead", "dead"),
 		makeErrMsg("rewriteUnsafe", "dead"),
+		makeErrMsg("unsafeElse", "x"),
+		makeErrMsg("unsafeWith", "x"),
 	}
 
 	result := compilerErrsToStringSlice(c.Errors)
@@ -385,6 +395,36 @@ func TestCompilerCheckSafetyBodyErrors(t *testing.T) {
 
 }
 
+func TestCompilerCheckSafetyBodyErrorLocations(t *testing.T) {
+
+	tests := []struct {
+		note     string
+		module   string
+		expected string
+	}{
+		{"first expr", "package ex\np :- x > 1, true", "2:6: p: x is unsafe"},
+		{"later expr", "package ex\np :- true,\n\tnot data.a[i] = 1", "3:13: p: i is unsafe"},
+		{"first occurrence", "package ex\np :- a[_] = x, not a[x] = 1", "2:6: p: a is unsafe"},
+		{"closure", "package ex\np :- true, x = [y | y = data.a[_], z > 1]", "2:36: p: z is unsafe"},
+	}
+
+	for _, tc := range tests {
+		c := NewCompiler()
+		c.Compile(map[string]*Module{"ex": MustParseModule(tc.module)})
+
+		if len(c.Errors) != 1 {
+			t.Errorf("%v: Expected exactly one error but got: %v", tc.note, c.Errors)
+		} else if !strings.HasPrefix(c.Errors[0].Error(), tc.expected) {
+			t.Errorf("%v: Expected error to start with %q but got: %v", tc.note, tc.expected, c.Errors[0])
+		}
+	}
+
+	_, err := NewCompiler().QueryCompiler().Compile(MustParseBody("true, y > 1"))
+	if err == nil || !strings.Contains(err.Error(), "1:7: y is unsafe") {
+		t.Errorf("Expected query safety error to contain location but got: %v", err)
+	}
+}
+
 func TestCompilerCheckBuiltins(t *testing.T) {
 	c := NewCompiler()
 	c.Modules = map[string]*Module{
@@ -406,6 +446,28 @@ func TestCompilerCheckBuiltins(t *testing.T) {
 	assertCompilerErrorStrings(t, c, expected)
 }
 
+func TestCompilerCheckWithModifiers(t *testing.T) {
+	c := NewCompiler()
+	c.Modules = map[string]*Module{
+		"mod": MustParseModule(`
+			package badwith
+			p :- true with foo as 1
+			q :- true with data as 1
+			r :- true with data.a[0] as 1
+			s :- true with request.foo as 1 with data.a.b as 2
+			`),
+	}
+	compileStages(c, "", "checkWithModifiers")
+
+	expected := []string{
+		"p: with keyword target must refer to request or data (foo is not allowed)",
+		"q: with keyword target must refer to request or data (data is not allowed)",
+		"r: with keyword target must refer to request or data (data.a[0] is not allowed)",
+	}
+
+	assertCompilerErrorStrings(t, c, expected)
+}
+
 func TestCompilerCheckRuleConflicts(t *testing.T) {
 	c := NewCompiler()
 	c.Modules = map[string]*Module{
@@ -417,6 +479,11 @@ func TestCompilerCheckRuleConflicts(t *testing.T) {
 			q = {1,2,3} :- true
 			r[x] = y :- x = y, x = "a"
 			r[x] = y :- x = y, x = "a"
+			default s = [x]
+			s :- true
+			default t = 1
+			default t = 2
+			default u = [data.a]
 		`),
 		"mod2": MustParseModule(`
 			package badrules.r
@@ -430,6 +497,9 @@ func TestCompilerCheckRuleConflicts(t *testing.T) {
 		"package badrules.r: package declaration conflicts with rule defined at <input>:7:4",
 		"package badrules.r: package declaration conflicts with rule defined at <input>:8:4",
 		"q: conflicting rule types (all definitions of q must have the same type)",
+		"s: illegal default rule (value must be ground)",
+		"t: multiple default rules (only one default rule named t may be defined)",
+		"u: illegal default rule (value must be ground)",
 	}
 
 	assertCompilerErrorStrings(t, c, expected)
@@ -594,6 +664,70 @@ func TestCompilerSetRuleGraph(t *testing.T) {
 
 }
 
+func TestCompilerDependencyGraph(t *testing.T) {
+	c := NewCompiler()
+	c.Compile(map[string]*Module{
+		"example": MustParseModule(`
+		package opa.example
+
+		import data.servers
+		import data.networks
+		import data.ports
+
+		public_servers[server] :-
+			server = servers[_],
+			server.ports[_] = ports[i].id,
+			ports[i].networks[_] = networks[j].id,
+			networks[j].public = true
+
+		violations[server] :-
+			server = servers[_],
+			server.protocols[_] = "http",
+			public_servers[server]
+		`),
+		"report": MustParseModule(`
+		package opa.report
+
+		import data.opa.example.violations
+
+		count_violations = n :- count(violations, n)
+		`),
+	})
+
+	assertNotFailed(t, c)
+
+	g := c.DependencyGraph()
+
+	publicServers := MustParseRef("data.opa.example.public_servers")
+	violations := MustParseRef("data.opa.example.violations")
+	countViolations := MustParseRef("data.opa.report.count_violations")
+
+	expectedNodes := []Ref{publicServers, violations, countViolations}
+	if !refsEqual(g.Nodes(), expectedNodes) {
+		t.Errorf("Expected nodes %v but got: %v", expectedNodes, g.Nodes())
+	}
+
+	if deps := g.Dependencies(violations); !refsEqual(deps, []Ref{publicServers}) {
+		t.Errorf("Expected violations to depend on public_servers but got: %v", deps)
+	}
+
+	if deps := g.Dependencies(publicServers); len(deps) != 0 {
+		t.Errorf("Expected public_servers to have no dependencies but got: %v", deps)
+	}
+
+	if deps := g.Dependents(violations); !refsEqual(deps, []Ref{countViolations}) {
+		t.Errorf("Expected count_violations to depend on violations but got: %v", deps)
+	}
+
+	if !g.DependsOn(countViolations, publicServers) {
+		t.Errorf("Expected count_violations to depend on public_servers transitively")
+	}
+
+	if g.DependsOn(publicServers, countViolations) {
+		t.Errorf("Expected public_servers not to depend on count_violations")
+	}
+}
+
 func TestCompilerCheckRecursion(t *testing.T) {
 	c := NewCompiler()
 	c.Modules = map[string]*Module{
@@ -642,6 +776,22 @@ func TestCompilerCheckRecursion(t *testing.T) {
 						package rec8
 						dataref :- data
 						`),
+		"newMod10": MustParseModule(`
+						package rec9
+						self :- self
+						`),
+		"newMod11": MustParseModule(`
+						package rec10
+						mp :- mq
+						mq :- mr[x]
+						mr[x] :- x = 1, mp
+						`),
+		"newMod12": MustParseModule(`
+						package rec11
+						nrp :- nrq, nrr[x]
+						nrq :- nrr[1]
+						nrr[x] :- x = 1
+						`),
 	}
 
 	compileStages(c, "", "checkRecursion")
@@ -665,6 +815,10 @@ func TestCompilerCheckRecursion(t *testing.T) {
 		makeErrMsg("nq", "nq", "np", "nq"),
 		makeErrMsg("prefix", "prefix", "prefix"),
 		makeErrMsg("dataref", "dataref", "dataref"),
+		makeErrMsg("self", "self", "self"),
+		makeErrMsg("mp", "mp", "mq", "mr", "mp"),
+		makeErrMsg("mq", "mq", "mr", "mp", "mq"),
+		makeErrMsg("mr", "mr", "mp", "mq", "mr"),
 	}
 
 	result := compilerErrsToStringSlice(c.Errors)
@@ -868,6 +1022,77 @@ func TestCompilerGetRulesWithPrefix(t *testing.T) {
 	}
 }
 
+func TestCompilerGetRuleIndex(t *testing.T) {
+	c := NewCompiler()
+	c.Compile(map[string]*Module{
+		"index": MustParseModule(`
+		package index
+
+		default allow = false
+		allow :- request.method = "GET", request.path = "a"
+		allow :- request.method = "POST"
+		allow :- "GET" = request.method, request.user = "bob"
+		allow :- request.user = "admin"
+
+		p :- request.method = "GET"
+		`),
+	})
+
+	assertNotFailed(t, c)
+
+	if idx := c.GetRuleIndex(MustParseRef("data.index.p")); idx != nil {
+		t.Fatalf("Expected single rule not to be indexed but got: %v", idx.Ref())
+	}
+
+	rules := c.GetRulesExact(MustParseRef("data.index.allow"))
+	idx := c.GetRuleIndex(MustParseRef("data.index.allow"))
+
+	if idx == nil {
+		t.Fatalf("Expected rules to be indexed")
+	}
+
+	if !idx.Ref().Equal(MustParseRef("request.method")) {
+		t.Fatalf("Expected index on request.method but got: %v", idx.Ref())
+	}
+
+	tests := []struct {
+		note     string
+		request  string
+		expected []int
+	}{
+		{"match", `{"method": "GET"}`, []int{0, 1, 3, 4}},
+		{"match other", `{"method": "POST", "user": "bob"}`, []int{0, 2, 4}},
+		{"no match", `{"method": "PUT"}`, []int{0, 4}},
+		{"non-scalar", `{"method": {"GET": true}}`, []int{0, 4}},
+		{"undefined", `{"user": "admin"}`, []int{0, 4}},
+		{"no request", ``, []int{0, 4}},
+		{"ref", `{"method": data.method}`, []int{0, 1, 2, 3, 4}},
+		{"non-object", `{"method"}`, []int{0, 1, 2, 3, 4}},
+	}
+
+	for _, tc := range tests {
+		var request Value
+		if tc.request != "" {
+			request = MustParseTerm(tc.request).Value
+		}
+		var expected []*Rule
+		for _, i := range tc.expected {
+			expected = append(expected, rules[i])
+		}
+		result := idx.Lookup(request)
+		if len(result) != len(expected) {
+			t.Errorf("%v: expected %v but got: %v", tc.note, expected, result)
+			continue
+		}
+		for i := range result {
+			if result[i] != expected[i] {
+				t.Errorf("%v: expected %v but got: %v", tc.note, expected, result)
+				break
+			}
+		}
+	}
+}
+
 func TestCompilerLazyLoadingError(t *testing.T) {
 
 	testLoader := func(map[string]*Module) (map[string]*Module, error) {
@@ -1014,6 +1239,18 @@ func assertCompilerErrorStrings(t *testing.T, compiler *Compiler, expected []str
 	}
 }
 
+func refsEqual(a, b []Ref) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
 func assertNotFailed(t *testing.T, c *Compiler) {
 	if c.Failed() {
 		t.Errorf("Unexpected compilation error: %v", c.Errors)
@@ -0,0 +1,304 @@
+// Copyright 2016 The OPA Authors.  All rights reserved.
+// Use of this source code is governed by an Apache2
+// license that can be found in the LICENSE file.
+
+package ast
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Format returns the canonical source representation of module. Imports are
+// listed one per line, rules are separated by blank lines, and rule bodies
+// containing more than one expression are written one expression per line.
+// Parsing the result yields a module equal to the original. Comments that
+// directly precede a statement are retained (see Module.CommentsBefore); other
+// comments are dropped.
+func Format(module *Module) ([]byte, error) {
+	f := &formatter{}
+	if err := f.module(module); err != nil {
+		return nil, err
+	}
+	return f.buf.Bytes(), nil
+}
+
+type formatter struct {
+	buf      bytes.Buffer
+	comments commentMap
+}
+
+func (f *formatter) module(module *Module) error {
+
+	f.comments = newCommentMap(module)
+
+	f.writeComments(module.Package)
+	f.buf.WriteString(module.Package.String())
+	f.buf.WriteString("\n")
+
+	if len(module.Imports) > 0 {
+		f.buf.WriteString("\n")
+		for _, imp := range module.Imports {
+			f.writeComments(imp)
+			f.buf.WriteString(imp.String())
+			f.buf.WriteString("\n")
+		}
+	}
+
+	for _, rule := range module.Rules {
+		f.buf.WriteString("\n")
+		f.writeComments(rule)
+		if err := f.rule(rule); err != nil {
+			return err
+		}
+		f.buf.WriteString("\n")
+	}
+
+	return nil
+}
+
+func (f *formatter) writeComments(stmt Statement) {
+	for _, c := range f.comments.before(stmt) {
+		f.buf.WriteString(c.String())
+		f.buf.WriteString("\n")
+	}
+}
+
+func (f *formatter) rule(rule *Rule) error {
+
+	if rule.Default {
+		value, err := formatTerm(rule.Value)
+		if err != nil {
+			return err
+		}
+		f.buf.WriteString("default " + rule.Name.String() + " = " + value)
+		return nil
+	}
+
+	head := rule.Name.String()
+
+	if rule.Key != nil {
+		key, err := formatTerm(rule.Key)
+		if err != nil {
+			return err
+		}
+		head += "[" + key + "]"
+	}
+
+	if rule.Value != nil && (rule.Key != nil || !rule.Value.Equal(BooleanTerm(true))) {
+		value, err := formatTerm(rule.Value)
+		if err != nil {
+			return err
+		}
+		head += " = " + value
+	}
+
+	f.buf.WriteString(head)
+
+	if err := f.body(rule.Body); err != nil {
+		return err
+	}
+
+	for curr := rule.Else; curr != nil; curr = curr.Else {
+		f.buf.WriteString("\nelse")
+		if !curr.Value.Equal(BooleanTerm(true)) {
+			value, err := formatTerm(curr.Value)
+			if err != nil {
+				return err
+			}
+			f.buf.WriteString(" = " + value)
+		}
+		if err := f.body(curr.Body); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// body writes the body of a rule. Bodies containing a single expression are
+// written on the same line as the rule head.
+func (f *formatter) body(body Body) error {
+
+	f.buf.WriteString(" :-")
+
+	if len(body) == 1 {
+		expr, err := formatExpr(body[0])
+		if err != nil {
+			return err
+		}
+		f.buf.WriteString(" " + expr)
+		return nil
+	}
+
+	for i, e := range body {
+		expr, err := formatExpr(e)
+		if err != nil {
+			return err
+		}
+		f.buf.WriteString("\n\t" + expr)
+		if i < len(body)-1 {
+			f.buf.WriteString(",")
+		}
+	}
+
+	return nil
+}
+
+func formatBody(body Body) (string, error) {
+	buf := make([]string, len(body))
+	for i := range body {
+		expr, err := formatExpr(body[i])
+		if err != nil {
+			return "", err
+		}
+		buf[i] = expr
+	}
+	return strings.Join(buf, ", "), nil
+}
+
+func formatExpr(expr *Expr) (string, error) {
+
+	var buf []string
+
+	if expr.Negated {
+		buf = append(buf, "not")
+	}
+
+	switch ts := expr.Terms.(type) {
+	case *Term:
+		s, err := formatTerm(ts)
+		if err != nil {
+			return "", err
+		}
+		buf = append(buf, s)
+	case []*Term:
+		name, ok := ts[0].Value.(Var)
+		if !ok {
+			return "", fmt.Errorf("illegal operator: %v", ts[0])
+		}
+		args, err := formatTerms(ts[1:])
+		if err != nil {
+			return "", err
+		}
+		if b, ok := BuiltinMap[name]; ok && len(b.Infix) > 0 && len(args) == 2 {
+			buf = append(buf, args[0], b.Infix.String(), args[1])
+		} else {
+			buf = append(buf, name.String()+"("+strings.Join(args, ", ")+")")
+		}
+	default:
+		return "", fmt.Errorf("illegal expression: %v", expr)
+	}
+
+	for _, w := range expr.With {
+		target, err := formatTerm(w.Target)
+		if err != nil {
+			return "", err
+		}
+		value, err := formatTerm(w.Value)
+		if err != nil {
+			return "", err
+		}
+		buf = append(buf, "with", target, "as", value)
+	}
+
+	return strings.Join(buf, " "), nil
+}
+
+func formatTerms(terms []*Term) ([]string, error) {
+	buf := make([]string, len(terms))
+	for i := range terms {
+		s, err := formatTerm(terms[i])
+		if err != nil {
+			return nil, err
+		}
+		buf[i] = s
+	}
+	return buf, nil
+}
+
+func formatTerm(term *Term) (string, error) {
+
+	switch v := term.Value.(type) {
+	case Ref:
+		if len(v) == 0 {
+			return "", nil
+		}
+		buf := []string{}
+		path := v
+		if head, ok := v[0].Value.(Var); ok {
+			buf = append(buf, head.String())
+			path = path[1:]
+		}
+		for _, p := range path {
+			if s, ok := p.Value.(String); ok && varRegexp.MatchString(string(s)) && !IsKeyword(string(s)) && len(buf) > 0 {
+				buf = append(buf, "."+string(s))
+				continue
+			}
+			s, err := formatTerm(p)
+			if err != nil {
+				return "", err
+			}
+			buf = append(buf, "["+s+"]")
+		}
+		return strings.Join(buf, ""), nil
+	case Array:
+		elems, err := formatTerms(v)
+		if err != nil {
+			return "", err
+		}
+		return "[" + strings.Join(elems, ", ") + "]", nil
+	case *Set:
+		if len(*v) == 0 {
+			return "set()", nil
+		}
+		elems, err := formatTerms(*v)
+		if err != nil {
+			return "", err
+		}
+		return "{" + strings.Join(elems, ", ") + "}", nil
+	case Object:
+		elems := make([]string, len(v))
+		for i, item := range v {
+			kv, err := formatTerms(item[:])
+			if err != nil {
+				return "", err
+			}
+			elems[i] = kv[0] + ": " + kv[1]
+		}
+		return "{" + strings.Join(elems, ", ") + "}", nil
+	case *ArrayComprehension:
+		t, err := formatTerm(v.Term)
+		if err != nil {
+			return "", err
+		}
+		body, err := formatBody(v.Body)
+		if err != nil {
+			return "", err
+		}
+		return "[" + t + " | " + body + "]", nil
+	case *SetComprehension:
+		t, err := formatTerm(v.Term)
+		if err != nil {
+			return "", err
+		}
+		body, err := formatBody(v.Body)
+		if err != nil {
+			return "", err
+		}
+		return "{" + t + " | " + body + "}", nil
+	case *ObjectComprehension:
+		kv, err := formatTerms([]*Term{v.Key, v.Value})
+		if err != nil {
+			return "", err
+		}
+		body, err := formatBody(v.Body)
+		if err != nil {
+			return "", err
+		}
+		return "{" + kv[0] + ": " + kv[1] + " | " + body + "}", nil
+	default:
+		return v.String(), nil
+	}
+}
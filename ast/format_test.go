@@ -0,0 +1,114 @@
+// Copyright 2016 The OPA Authors.  All rights reserved.
+// Use of this source code is governed by an Apache2
+// license that can be found in the LICENSE file.
+
+package ast
+
+import "testing"
+
+func TestFormatRoundTrip(t *testing.T) {
+
+	tests := []struct {
+		note   string
+		module string
+	}{
+		{"example", `
+        package opa.example
+
+        import data.servers
+        import data.networks
+        import data.ports
+
+        public_servers[server] :-
+            server = servers[_],
+            server.ports[_] = ports[i].id,
+            ports[i].networks[_] = networks[j].id,
+            networks[j].public = true
+
+        violations[server] :-
+            server = servers[_],
+            server.protocols[_] = "http",
+            public_servers[server]
+		`},
+		{"constructs", `
+		package a.b["c.d"]
+		import request.x as y
+		default p = {"a": [1, null, false]}
+		p = 7 :- false else = x :- x = request.y else :- true
+		q[k] = v :- data.a[k] = v, not v = {"b": {1, 2}} with request as {"x": 1}
+		r[x] :- x = {k: v | data.a[k] = v}, y = {v | data.b[_] = v, v != 1}, z = [i | data.c[i]]
+		s :- plus(1, 2, x), x >= 3, count(set(), 0), data.a[data.b[0]][_] < "\t\"quoted\""
+		`},
+		{"keyword keys", `
+		package a.b
+		p :- data.x["true"] = data.x["false"], data.x["null"][_] = 1
+		q :- request["not"].y = 1, data.x["else"] = data.x["import"]["package"]
+		`},
+	}
+
+	for _, tc := range tests {
+		module := MustParseModule(tc.module)
+
+		bs, err := Format(module)
+		if err != nil {
+			t.Errorf("%v: Unexpected format error: %v", tc.note, err)
+			continue
+		}
+
+		result, err := ParseModule("", string(bs))
+		if err != nil {
+			t.Errorf("%v: Unexpected parse error: %v\n\n%s", tc.note, err, bs)
+			continue
+		}
+
+		if !module.Equal(result) {
+			t.Errorf("%v: Expected formatted module to equal original:\n%v\n\nGot:\n%v", tc.note, module, result)
+		}
+
+		// Formatting is idempotent.
+		again, err := Format(result)
+		if err != nil {
+			t.Errorf("%v: Unexpected format error: %v", tc.note, err)
+		} else if string(again) != string(bs) {
+			t.Errorf("%v: Expected formatting to be stable:\n%s\n\nGot:\n%s", tc.note, bs, again)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+
+	module := MustParseModule(`
+	# Package comment.
+	package ex
+	import data.foo   as   bar # dropped
+
+	# Rule comment
+	# spanning lines.
+	p[x]:-bar[x]=y,y>1,   not eq(y, 3)
+	q = x :- x = [y | p[y]]
+	`)
+
+	expected := `# Package comment.
+package ex
+
+import data.foo as bar
+
+# Rule comment
+# spanning lines.
+p[x] :-
+	bar[x] = y,
+	y > 1,
+	not y = 3
+
+q = x :- x = [y | p[y]]
+`
+
+	bs, err := Format(module)
+	if err != nil {
+		t.Fatalf("Unexpected format error: %v", err)
+	}
+
+	if string(bs) != expected {
+		t.Fatalf("Expected:\n%v\n\nGot:\n%v", expected, string(bs))
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2016 The OPA Authors.  All rights reserved.
+// Use of this source code is governed by an Apache2
+// license that can be found in the LICENSE file.
+
+package ast
+
+import "sort"
+
+// Graph represents the dependencies between documents defined by rules. The
+// nodes in the graph are rule paths, e.g., data.a.b.p. An edge (u, v) is
+// contained in the graph if a rule defining u refers to a rule defining v.
+type Graph struct {
+	nodes map[string]Ref
+	edges map[string]map[string]struct{}
+}
+
+func newGraph() *Graph {
+	return &Graph{
+		nodes: map[string]Ref{},
+		edges: map[string]map[string]struct{}{},
+	}
+}
+
+// Nodes returns the rule paths contained in the graph in sorted order.
+func (g *Graph) Nodes() []Ref {
+	keys := make([]string, 0, len(g.nodes))
+	for k := range g.nodes {
+		keys = append(keys, k)
+	}
+	return g.refs(keys)
+}
+
+// Dependencies returns the rule paths that the rule path u refers to in sorted
+// order.
+func (g *Graph) Dependencies(u Ref) []Ref {
+	keys := []string{}
+	for k := range g.edges[u.String()] {
+		keys = append(keys, k)
+	}
+	return g.refs(keys)
+}
+
+// Dependents returns the rule paths that refer to the rule path v in sorted
+// order.
+func (g *Graph) Dependents(v Ref) []Ref {
+	keys := []string{}
+	for k, edges := range g.edges {
+		if _, ok := edges[v.String()]; ok {
+			keys = append(keys, k)
+		}
+	}
+	return g.refs(keys)
+}
+
+// DependsOn returns true if the rule path u refers to the rule path v either
+// directly or through other rules.
+func (g *Graph) DependsOn(u, v Ref) bool {
+	visited := map[string]struct{}{}
+	target := v.String()
+	var visit func(string) bool
+	visit = func(k string) bool {
+		for next := range g.edges[k] {
+			if next == target {
+				return true
+			}
+			if _, ok := visited[next]; ok {
+				continue
+			}
+			visited[next] = struct{}{}
+			if visit(next) {
+				return true
+			}
+		}
+		return false
+	}
+	return visit(u.String())
+}
+
+func (g *Graph) addNode(u Ref) {
+	k := u.String()
+	if _, ok := g.nodes[k]; !ok {
+		g.nodes[k] = u
+		g.edges[k] = map[string]struct{}{}
+	}
+}
+
+func (g *Graph) addEdge(u, v Ref) {
+	g.addNode(u)
+	g.addNode(v)
+	g.edges[u.String()][v.String()] = struct{}{}
+}
+
+func (g *Graph) refs(keys []string) []Ref {
+	sort.Strings(keys)
+	r := make([]Ref, len(keys))
+	for i := range keys {
+		r[i] = g.nodes[keys[i]]
+	}
+	return r
+}
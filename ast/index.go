@@ -0,0 +1,206 @@
+// Copyright 2016 The OPA Authors.  All rights reserved.
+// Use of this source code is governed by an Apache2
+// license that can be found in the LICENSE file.
+
+package ast
+
+import "github.com/open-policy-agent/opa/util"
+
+// RuleIndex selects the rules defining a document that may produce a value for
+// a given request. The rules are indexed on a field of the request that the
+// rule bodies compare against constants. E.g., given the following rules:
+//
+//	allow :- request.method = "GET", ...    # rule1
+//	allow :- request.method = "POST", ...   # rule2
+//	allow :- request.user = "admin"         # rule3
+//
+// The rules are indexed on request.method and a lookup for a request with
+// method "GET" yields rule1 and rule3.
+type RuleIndex struct {
+	ref    Ref
+	rules  []*Rule
+	values *util.HashMap // maps constants to the positions of rules comparing against them
+	always []int         // positions of rules that do not compare against a constant
+}
+
+// buildRuleIndex returns a RuleIndex for rules. If the rules cannot be indexed,
+// nil is returned.
+func buildRuleIndex(rules []*Rule) *RuleIndex {
+
+	guards := make([]map[string]ruleIndexGuard, len(rules))
+	counts := map[string]int{}
+
+	for i, rule := range rules {
+		guards[i] = ruleIndexGuards(rule)
+		for k := range guards[i] {
+			counts[k]++
+		}
+	}
+
+	// Index on the request field that the most rules compare against.
+	var key string
+	for k, n := range counts {
+		if n > counts[key] || (n == counts[key] && k < key) {
+			key = k
+		}
+	}
+
+	if counts[key] < 2 {
+		return nil
+	}
+
+	index := &RuleIndex{
+		rules: rules,
+		values: util.NewHashMap(func(a, b util.T) bool {
+			return a.(Value).Equal(b.(Value))
+		}, func(x util.T) int {
+			return x.(Value).Hash()
+		}),
+	}
+
+	for i := range rules {
+		guard, ok := guards[i][key]
+		if !ok {
+			index.always = append(index.always, i)
+			continue
+		}
+		index.ref = guard.ref
+		var positions []int
+		if x, ok := index.values.Get(guard.value); ok {
+			positions = x.([]int)
+		}
+		index.values.Put(guard.value, append(positions, i))
+	}
+
+	return index
+}
+
+// Ref returns the reference to the request field that the rules are indexed
+// on.
+func (idx *RuleIndex) Ref() Ref {
+	return idx.ref
+}
+
+// Lookup returns the rules that may produce a value for request. The rules are
+// returned in the same order as they were indexed.
+func (idx *RuleIndex) Lookup(request Value) []*Rule {
+
+	var curr Value = request
+
+	for _, x := range idx.ref[1:] {
+		switch v := curr.(type) {
+		case nil:
+			return idx.selectRules(nil)
+		case Object:
+			term := v.Get(x)
+			if term == nil {
+				return idx.selectRules(nil)
+			}
+			curr = term.Value
+		default:
+			// The request may contain references or other values that are
+			// only known during evaluation.
+			return idx.rules
+		}
+	}
+
+	if _, ok := curr.(Ref); ok || !curr.IsGround() {
+		return idx.rules
+	}
+
+	x, _ := idx.values.Get(curr)
+	positions, _ := x.([]int)
+
+	return idx.selectRules(positions)
+}
+
+func (idx *RuleIndex) selectRules(positions []int) []*Rule {
+
+	if len(positions) == 0 && len(idx.always) == 0 {
+		return nil
+	}
+
+	result := make([]*Rule, 0, len(positions)+len(idx.always))
+	i, j := 0, 0
+
+	for i < len(positions) || j < len(idx.always) {
+		if j == len(idx.always) || (i < len(positions) && positions[i] < idx.always[j]) {
+			result = append(result, idx.rules[positions[i]])
+			i++
+		} else {
+			result = append(result, idx.rules[idx.always[j]])
+			j++
+		}
+	}
+
+	return result
+}
+
+// ruleIndexGuard represents an expression that compares a request field
+// against a constant.
+type ruleIndexGuard struct {
+	ref   Ref
+	value Value
+}
+
+// ruleIndexGuards returns the request fields that the body of rule compares
+// against constants keyed by the string representation of the reference to
+// the field. Default rules and rules chained with the else keyword have no
+// guards because they may produce values without evaluating the body.
+func ruleIndexGuards(rule *Rule) map[string]ruleIndexGuard {
+
+	guards := map[string]ruleIndexGuard{}
+
+	if rule.Default || rule.Else != nil {
+		return guards
+	}
+
+	for _, expr := range rule.Body {
+
+		if expr.Negated || len(expr.With) > 0 || !expr.IsEquality() {
+			continue
+		}
+
+		terms := expr.Terms.([]*Term)
+		ref, value := terms[1].Value, terms[2].Value
+
+		if _, ok := ref.(Ref); !ok {
+			ref, value = value, ref
+		}
+
+		r, ok := ref.(Ref)
+		if !ok || !isIndexableRequestRef(r) || !isIndexableValue(value) {
+			continue
+		}
+
+		k := r.String()
+		if _, ok := guards[k]; !ok {
+			guards[k] = ruleIndexGuard{r, value}
+		}
+	}
+
+	return guards
+}
+
+func isIndexableRequestRef(ref Ref) bool {
+	if len(ref) < 2 || !ref[0].Equal(RequestRootDocument) {
+		return false
+	}
+	for _, x := range ref[1:] {
+		if _, ok := x.Value.(String); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// isIndexableValue returns true if v can be compared against request values
+// by equality. Numbers are excluded because the same number may have different
+// representations.
+func isIndexableValue(v Value) bool {
+	switch v.(type) {
+	case String, Boolean, Null:
+		return true
+	}
+	return false
+}
@@ -35,49 +35,52 @@ var g = &grammar{
 				expr: &seqExpr{
 					pos: position{line: 16, col: 12, offset: 378},
 					exprs: []interface{}{
-						&ruleRefExpr{
-							pos:  position{line: 16, col: 12, offset: 378},
-							name: "_",
+						&zeroOrOneExpr{
+							pos: position{line: 16, col: 12, offset: 378},
+							expr: &ruleRefExpr{
+								pos:  position{line: 16, col: 12, offset: 378},
+								name: "ws",
+							},
 						},
 						&labeledExpr{
-							pos:   position{line: 16, col: 14, offset: 380},
+							pos:   position{line: 16, col: 16, offset: 382},
 							label: "vals",
 							expr: &zeroOrOneExpr{
-								pos: position{line: 16, col: 19, offset: 385},
+								pos: position{line: 16, col: 21, offset: 387},
 								expr: &seqExpr{
-									pos: position{line: 16, col: 20, offset: 386},
+									pos: position{line: 16, col: 22, offset: 388},
 									exprs: []interface{}{
 										&labeledExpr{
-											pos:   position{line: 16, col: 20, offset: 386},
+											pos:   position{line: 16, col: 22, offset: 388},
 											label: "head",
 											expr: &ruleRefExpr{
-												pos:  position{line: 16, col: 25, offset: 391},
+												pos:  position{line: 16, col: 27, offset: 393},
 												name: "Stmt",
 											},
 										},
 										&labeledExpr{
-											pos:   position{line: 16, col: 30, offset: 396},
+											pos:   position{line: 16, col: 32, offset: 398},
 											label: "tail",
 											expr: &zeroOrMoreExpr{
-												pos: position{line: 16, col: 35, offset: 401},
+												pos: position{line: 16, col: 37, offset: 403},
 												expr: &seqExpr{
-													pos: position{line: 16, col: 36, offset: 402},
+													pos: position{line: 16, col: 38, offset: 404},
 													exprs: []interface{}{
 														&choiceExpr{
-															pos: position{line: 16, col: 37, offset: 403},
+															pos: position{line: 16, col: 39, offset: 405},
 															alternatives: []interface{}{
 																&ruleRefExpr{
-																	pos:  position{line: 16, col: 37, offset: 403},
+																	pos:  position{line: 16, col: 39, offset: 405},
 																	name: "ws",
 																},
 																&ruleRefExpr{
-																	pos:  position{line: 16, col: 42, offset: 408},
+																	pos:  position{line: 16, col: 44, offset: 410},
 																	name: "ParseError",
 																},
 															},
 														},
 														&ruleRefExpr{
-															pos:  position{line: 16, col: 54, offset: 420},
+															pos:  position{line: 16, col: 56, offset: 422},
 															name: "Stmt",
 														},
 													},
@@ -89,11 +92,11 @@ var g = &grammar{
 							},
 						},
 						&ruleRefExpr{
-							pos:  position{line: 16, col: 63, offset: 429},
+							pos:  position{line: 16, col: 65, offset: 431},
 							name: "_",
 						},
 						&ruleRefExpr{
-							pos:  position{line: 16, col: 65, offset: 431},
+							pos:  position{line: 16, col: 67, offset: 433},
 							name: "EOF",
 						},
 					},
@@ -102,38 +105,42 @@ var g = &grammar{
 		},
 		{
 			name: "Stmt",
-			pos:  position{line: 34, col: 1, offset: 768},
+			pos:  position{line: 34, col: 1, offset: 770},
 			expr: &actionExpr{
-				pos: position{line: 34, col: 9, offset: 776},
+				pos: position{line: 34, col: 9, offset: 778},
 				run: (*parser).callonStmt1,
 				expr: &labeledExpr{
-					pos:   position{line: 34, col: 9, offset: 776},
+					pos:   position{line: 34, col: 9, offset: 778},
 					label: "val",
 					expr: &choiceExpr{
-						pos: position{line: 34, col: 14, offset: 781},
+						pos: position{line: 34, col: 14, offset: 783},
 						alternatives: []interface{}{
 							&ruleRefExpr{
-								pos:  position{line: 34, col: 14, offset: 781},
+								pos:  position{line: 34, col: 14, offset: 783},
 								name: "Package",
 							},
 							&ruleRefExpr{
-								pos:  position{line: 34, col: 24, offset: 791},
+								pos:  position{line: 34, col: 24, offset: 793},
 								name: "Import",
 							},
 							&ruleRefExpr{
-								pos:  position{line: 34, col: 33, offset: 800},
+								pos:  position{line: 34, col: 33, offset: 802},
+								name: "DefaultRule",
+							},
+							&ruleRefExpr{
+								pos:  position{line: 34, col: 47, offset: 816},
 								name: "Rule",
 							},
 							&ruleRefExpr{
-								pos:  position{line: 34, col: 40, offset: 807},
+								pos:  position{line: 34, col: 54, offset: 823},
 								name: "Body",
 							},
 							&ruleRefExpr{
-								pos:  position{line: 34, col: 47, offset: 814},
+								pos:  position{line: 34, col: 61, offset: 830},
 								name: "Comment",
 							},
 							&ruleRefExpr{
-								pos:  position{line: 34, col: 57, offset: 824},
+								pos:  position{line: 34, col: 71, offset: 840},
 								name: "ParseError",
 							},
 						},
@@ -143,45 +150,45 @@ var g = &grammar{
 		},
 		{
 			name: "ParseError",
-			pos:  position{line: 43, col: 1, offset: 1188},
+			pos:  position{line: 43, col: 1, offset: 1204},
 			expr: &actionExpr{
-				pos: position{line: 43, col: 15, offset: 1202},
+				pos: position{line: 43, col: 15, offset: 1218},
 				run: (*parser).callonParseError1,
 				expr: &anyMatcher{
-					line: 43, col: 15, offset: 1202,
+					line: 43, col: 15, offset: 1218,
 				},
 			},
 		},
 		{
 			name: "Package",
-			pos:  position{line: 47, col: 1, offset: 1275},
+			pos:  position{line: 47, col: 1, offset: 1291},
 			expr: &actionExpr{
-				pos: position{line: 47, col: 12, offset: 1286},
+				pos: position{line: 47, col: 12, offset: 1302},
 				run: (*parser).callonPackage1,
 				expr: &seqExpr{
-					pos: position{line: 47, col: 12, offset: 1286},
+					pos: position{line: 47, col: 12, offset: 1302},
 					exprs: []interface{}{
 						&litMatcher{
-							pos:        position{line: 47, col: 12, offset: 1286},
+							pos:        position{line: 47, col: 12, offset: 1302},
 							val:        "package",
 							ignoreCase: false,
 						},
 						&ruleRefExpr{
-							pos:  position{line: 47, col: 22, offset: 1296},
+							pos:  position{line: 47, col: 22, offset: 1312},
 							name: "ws",
 						},
 						&labeledExpr{
-							pos:   position{line: 47, col: 25, offset: 1299},
+							pos:   position{line: 47, col: 25, offset: 1315},
 							label: "val",
 							expr: &choiceExpr{
-								pos: position{line: 47, col: 30, offset: 1304},
+								pos: position{line: 47, col: 30, offset: 1320},
 								alternatives: []interface{}{
 									&ruleRefExpr{
-										pos:  position{line: 47, col: 30, offset: 1304},
+										pos:  position{line: 47, col: 30, offset: 1320},
 										name: "Ref",
 									},
 									&ruleRefExpr{
-										pos:  position{line: 47, col: 36, offset: 1310},
+										pos:  position{line: 47, col: 36, offset: 1326},
 										name: "Var",
 									},
 								},
@@ -193,62 +200,62 @@ var g = &grammar{
 		},
 		{
 			name: "Import",
-			pos:  position{line: 83, col: 1, offset: 2691},
+			pos:  position{line: 83, col: 1, offset: 2707},
 			expr: &actionExpr{
-				pos: position{line: 83, col: 11, offset: 2701},
+				pos: position{line: 83, col: 11, offset: 2717},
 				run: (*parser).callonImport1,
 				expr: &seqExpr{
-					pos: position{line: 83, col: 11, offset: 2701},
+					pos: position{line: 83, col: 11, offset: 2717},
 					exprs: []interface{}{
 						&litMatcher{
-							pos:        position{line: 83, col: 11, offset: 2701},
+							pos:        position{line: 83, col: 11, offset: 2717},
 							val:        "import",
 							ignoreCase: false,
 						},
 						&ruleRefExpr{
-							pos:  position{line: 83, col: 20, offset: 2710},
+							pos:  position{line: 83, col: 20, offset: 2726},
 							name: "ws",
 						},
 						&labeledExpr{
-							pos:   position{line: 83, col: 23, offset: 2713},
+							pos:   position{line: 83, col: 23, offset: 2729},
 							label: "path",
 							expr: &choiceExpr{
-								pos: position{line: 83, col: 29, offset: 2719},
+								pos: position{line: 83, col: 29, offset: 2735},
 								alternatives: []interface{}{
 									&ruleRefExpr{
-										pos:  position{line: 83, col: 29, offset: 2719},
+										pos:  position{line: 83, col: 29, offset: 2735},
 										name: "Ref",
 									},
 									&ruleRefExpr{
-										pos:  position{line: 83, col: 35, offset: 2725},
+										pos:  position{line: 83, col: 35, offset: 2741},
 										name: "Var",
 									},
 								},
 							},
 						},
 						&labeledExpr{
-							pos:   position{line: 83, col: 40, offset: 2730},
+							pos:   position{line: 83, col: 40, offset: 2746},
 							label: "alias",
 							expr: &zeroOrOneExpr{
-								pos: position{line: 83, col: 46, offset: 2736},
+								pos: position{line: 83, col: 46, offset: 2752},
 								expr: &seqExpr{
-									pos: position{line: 83, col: 47, offset: 2737},
+									pos: position{line: 83, col: 47, offset: 2753},
 									exprs: []interface{}{
 										&ruleRefExpr{
-											pos:  position{line: 83, col: 47, offset: 2737},
+											pos:  position{line: 83, col: 47, offset: 2753},
 											name: "ws",
 										},
 										&litMatcher{
-											pos:        position{line: 83, col: 50, offset: 2740},
+											pos:        position{line: 83, col: 50, offset: 2756},
 											val:        "as",
 											ignoreCase: false,
 										},
 										&ruleRefExpr{
-											pos:  position{line: 83, col: 55, offset: 2745},
+											pos:  position{line: 83, col: 55, offset: 2761},
 											name: "ws",
 										},
 										&ruleRefExpr{
-											pos:  position{line: 83, col: 58, offset: 2748},
+											pos:  position{line: 83, col: 58, offset: 2764},
 											name: "Var",
 										},
 									},
@@ -259,59 +266,110 @@ var g = &grammar{
 				},
 			},
 		},
+		{
+			name: "DefaultRule",
+			pos:  position{line: 99, col: 1, offset: 3214},
+			expr: &actionExpr{
+				pos: position{line: 99, col: 16, offset: 3229},
+				run: (*parser).callonDefaultRule1,
+				expr: &seqExpr{
+					pos: position{line: 99, col: 16, offset: 3229},
+					exprs: []interface{}{
+						&litMatcher{
+							pos:        position{line: 99, col: 16, offset: 3229},
+							val:        "default",
+							ignoreCase: false,
+						},
+						&ruleRefExpr{
+							pos:  position{line: 99, col: 26, offset: 3239},
+							name: "ws",
+						},
+						&labeledExpr{
+							pos:   position{line: 99, col: 29, offset: 3242},
+							label: "name",
+							expr: &ruleRefExpr{
+								pos:  position{line: 99, col: 34, offset: 3247},
+								name: "Var",
+							},
+						},
+						&ruleRefExpr{
+							pos:  position{line: 99, col: 38, offset: 3251},
+							name: "_",
+						},
+						&litMatcher{
+							pos:        position{line: 99, col: 40, offset: 3253},
+							val:        "=",
+							ignoreCase: false,
+						},
+						&ruleRefExpr{
+							pos:  position{line: 99, col: 44, offset: 3257},
+							name: "_",
+						},
+						&labeledExpr{
+							pos:   position{line: 99, col: 46, offset: 3259},
+							label: "value",
+							expr: &ruleRefExpr{
+								pos:  position{line: 99, col: 52, offset: 3265},
+								name: "Term",
+							},
+						},
+					},
+				},
+			},
+		},
 		{
 			name: "Rule",
-			pos:  position{line: 99, col: 1, offset: 3198},
+			pos:  position{line: 124, col: 1, offset: 3825},
 			expr: &actionExpr{
-				pos: position{line: 99, col: 9, offset: 3206},
+				pos: position{line: 124, col: 9, offset: 3833},
 				run: (*parser).callonRule1,
 				expr: &seqExpr{
-					pos: position{line: 99, col: 9, offset: 3206},
+					pos: position{line: 124, col: 9, offset: 3833},
 					exprs: []interface{}{
 						&labeledExpr{
-							pos:   position{line: 99, col: 9, offset: 3206},
+							pos:   position{line: 124, col: 9, offset: 3833},
 							label: "name",
 							expr: &ruleRefExpr{
-								pos:  position{line: 99, col: 14, offset: 3211},
+								pos:  position{line: 124, col: 14, offset: 3838},
 								name: "Var",
 							},
 						},
 						&labeledExpr{
-							pos:   position{line: 99, col: 18, offset: 3215},
+							pos:   position{line: 124, col: 18, offset: 3842},
 							label: "key",
 							expr: &zeroOrOneExpr{
-								pos: position{line: 99, col: 22, offset: 3219},
+								pos: position{line: 124, col: 22, offset: 3846},
 								expr: &seqExpr{
-									pos: position{line: 99, col: 24, offset: 3221},
+									pos: position{line: 124, col: 24, offset: 3848},
 									exprs: []interface{}{
 										&ruleRefExpr{
-											pos:  position{line: 99, col: 24, offset: 3221},
+											pos:  position{line: 124, col: 24, offset: 3848},
 											name: "_",
 										},
 										&litMatcher{
-											pos:        position{line: 99, col: 26, offset: 3223},
+											pos:        position{line: 124, col: 26, offset: 3850},
 											val:        "[",
 											ignoreCase: false,
 										},
 										&ruleRefExpr{
-											pos:  position{line: 99, col: 30, offset: 3227},
+											pos:  position{line: 124, col: 30, offset: 3854},
 											name: "_",
 										},
 										&ruleRefExpr{
-											pos:  position{line: 99, col: 32, offset: 3229},
+											pos:  position{line: 124, col: 32, offset: 3856},
 											name: "Term",
 										},
 										&ruleRefExpr{
-											pos:  position{line: 99, col: 37, offset: 3234},
+											pos:  position{line: 124, col: 37, offset: 3861},
 											name: "_",
 										},
 										&litMatcher{
-											pos:        position{line: 99, col: 39, offset: 3236},
+											pos:        position{line: 124, col: 39, offset: 3863},
 											val:        "]",
 											ignoreCase: false,
 										},
 										&ruleRefExpr{
-											pos:  position{line: 99, col: 43, offset: 3240},
+											pos:  position{line: 124, col: 43, offset: 3867},
 											name: "_",
 										},
 									},
@@ -319,28 +377,28 @@ var g = &grammar{
 							},
 						},
 						&labeledExpr{
-							pos:   position{line: 99, col: 48, offset: 3245},
+							pos:   position{line: 124, col: 48, offset: 3872},
 							label: "value",
 							expr: &zeroOrOneExpr{
-								pos: position{line: 99, col: 54, offset: 3251},
+								pos: position{line: 124, col: 54, offset: 3878},
 								expr: &seqExpr{
-									pos: position{line: 99, col: 56, offset: 3253},
+									pos: position{line: 124, col: 56, offset: 3880},
 									exprs: []interface{}{
 										&ruleRefExpr{
-											pos:  position{line: 99, col: 56, offset: 3253},
+											pos:  position{line: 124, col: 56, offset: 3880},
 											name: "_",
 										},
 										&litMatcher{
-											pos:        position{line: 99, col: 58, offset: 3255},
+											pos:        position{line: 124, col: 58, offset: 3882},
 											val:        "=",
 											ignoreCase: false,
 										},
 										&ruleRefExpr{
-											pos:  position{line: 99, col: 62, offset: 3259},
+											pos:  position{line: 124, col: 62, offset: 3886},
 											name: "_",
 										},
 										&ruleRefExpr{
-											pos:  position{line: 99, col: 64, offset: 3261},
+											pos:  position{line: 124, col: 64, offset: 3888},
 											name: "Term",
 										},
 									},
@@ -348,82 +406,194 @@ var g = &grammar{
 							},
 						},
 						&labeledExpr{
-							pos:   position{line: 99, col: 72, offset: 3269},
+							pos:   position{line: 124, col: 72, offset: 3896},
 							label: "body",
 							expr: &seqExpr{
-								pos: position{line: 99, col: 79, offset: 3276},
+								pos: position{line: 124, col: 79, offset: 3903},
 								exprs: []interface{}{
 									&ruleRefExpr{
-										pos:  position{line: 99, col: 79, offset: 3276},
+										pos:  position{line: 124, col: 79, offset: 3903},
 										name: "_",
 									},
 									&litMatcher{
-										pos:        position{line: 99, col: 81, offset: 3278},
+										pos:        position{line: 124, col: 81, offset: 3905},
 										val:        ":-",
 										ignoreCase: false,
 									},
 									&ruleRefExpr{
-										pos:  position{line: 99, col: 86, offset: 3283},
+										pos:  position{line: 124, col: 86, offset: 3910},
 										name: "_",
 									},
 									&ruleRefExpr{
-										pos:  position{line: 99, col: 88, offset: 3285},
+										pos:  position{line: 124, col: 88, offset: 3912},
 										name: "Body",
 									},
 								},
 							},
 						},
+						&labeledExpr{
+							pos:   position{line: 124, col: 94, offset: 3918},
+							label: "elses",
+							expr: &zeroOrMoreExpr{
+								pos: position{line: 124, col: 100, offset: 3924},
+								expr: &seqExpr{
+									pos: position{line: 124, col: 102, offset: 3926},
+									exprs: []interface{}{
+										&ruleRefExpr{
+											pos:  position{line: 124, col: 102, offset: 3926},
+											name: "_",
+										},
+										&ruleRefExpr{
+											pos:  position{line: 124, col: 104, offset: 3928},
+											name: "Else",
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "Else",
+			pos:  position{line: 203, col: 1, offset: 6281},
+			expr: &actionExpr{
+				pos: position{line: 203, col: 9, offset: 6289},
+				run: (*parser).callonElse1,
+				expr: &seqExpr{
+					pos: position{line: 203, col: 9, offset: 6289},
+					exprs: []interface{}{
+						&litMatcher{
+							pos:        position{line: 203, col: 9, offset: 6289},
+							val:        "else",
+							ignoreCase: false,
+						},
+						&notExpr{
+							pos: position{line: 203, col: 16, offset: 6296},
+							expr: &choiceExpr{
+								pos: position{line: 203, col: 19, offset: 6299},
+								alternatives: []interface{}{
+									&ruleRefExpr{
+										pos:  position{line: 203, col: 19, offset: 6299},
+										name: "AsciiLetter",
+									},
+									&ruleRefExpr{
+										pos:  position{line: 203, col: 33, offset: 6313},
+										name: "DecimalDigit",
+									},
+								},
+							},
+						},
+						&labeledExpr{
+							pos:   position{line: 203, col: 48, offset: 6328},
+							label: "value",
+							expr: &zeroOrOneExpr{
+								pos: position{line: 203, col: 54, offset: 6334},
+								expr: &seqExpr{
+									pos: position{line: 203, col: 56, offset: 6336},
+									exprs: []interface{}{
+										&ruleRefExpr{
+											pos:  position{line: 203, col: 56, offset: 6336},
+											name: "_",
+										},
+										&litMatcher{
+											pos:        position{line: 203, col: 58, offset: 6338},
+											val:        "=",
+											ignoreCase: false,
+										},
+										&ruleRefExpr{
+											pos:  position{line: 203, col: 62, offset: 6342},
+											name: "_",
+										},
+										&ruleRefExpr{
+											pos:  position{line: 203, col: 64, offset: 6344},
+											name: "Term",
+										},
+									},
+								},
+							},
+						},
+						&labeledExpr{
+							pos:   position{line: 203, col: 72, offset: 6352},
+							label: "body",
+							expr: &zeroOrOneExpr{
+								pos: position{line: 203, col: 77, offset: 6357},
+								expr: &seqExpr{
+									pos: position{line: 203, col: 79, offset: 6359},
+									exprs: []interface{}{
+										&ruleRefExpr{
+											pos:  position{line: 203, col: 79, offset: 6359},
+											name: "_",
+										},
+										&litMatcher{
+											pos:        position{line: 203, col: 81, offset: 6361},
+											val:        ":-",
+											ignoreCase: false,
+										},
+										&ruleRefExpr{
+											pos:  position{line: 203, col: 86, offset: 6366},
+											name: "_",
+										},
+										&ruleRefExpr{
+											pos:  position{line: 203, col: 88, offset: 6368},
+											name: "Body",
+										},
+									},
+								},
+							},
+						},
 					},
 				},
 			},
 		},
 		{
 			name: "Body",
-			pos:  position{line: 155, col: 1, offset: 4916},
+			pos:  position{line: 227, col: 1, offset: 7037},
 			expr: &actionExpr{
-				pos: position{line: 155, col: 9, offset: 4924},
+				pos: position{line: 227, col: 9, offset: 7045},
 				run: (*parser).callonBody1,
 				expr: &seqExpr{
-					pos: position{line: 155, col: 9, offset: 4924},
+					pos: position{line: 227, col: 9, offset: 7045},
 					exprs: []interface{}{
 						&labeledExpr{
-							pos:   position{line: 155, col: 9, offset: 4924},
+							pos:   position{line: 227, col: 9, offset: 7045},
 							label: "head",
 							expr: &ruleRefExpr{
-								pos:  position{line: 155, col: 14, offset: 4929},
+								pos:  position{line: 227, col: 14, offset: 7050},
 								name: "Expr",
 							},
 						},
 						&labeledExpr{
-							pos:   position{line: 155, col: 19, offset: 4934},
+							pos:   position{line: 227, col: 19, offset: 7055},
 							label: "tail",
 							expr: &zeroOrMoreExpr{
-								pos: position{line: 155, col: 24, offset: 4939},
+								pos: position{line: 227, col: 24, offset: 7060},
 								expr: &seqExpr{
-									pos: position{line: 155, col: 26, offset: 4941},
+									pos: position{line: 227, col: 26, offset: 7062},
 									exprs: []interface{}{
 										&ruleRefExpr{
-											pos:  position{line: 155, col: 26, offset: 4941},
+											pos:  position{line: 227, col: 26, offset: 7062},
 											name: "_",
 										},
 										&litMatcher{
-											pos:        position{line: 155, col: 28, offset: 4943},
+											pos:        position{line: 227, col: 28, offset: 7064},
 											val:        ",",
 											ignoreCase: false,
 										},
 										&ruleRefExpr{
-											pos:  position{line: 155, col: 32, offset: 4947},
+											pos:  position{line: 227, col: 32, offset: 7068},
 											name: "_",
 										},
 										&choiceExpr{
-											pos: position{line: 155, col: 35, offset: 4950},
+											pos: position{line: 227, col: 35, offset: 7071},
 											alternatives: []interface{}{
 												&ruleRefExpr{
-													pos:  position{line: 155, col: 35, offset: 4950},
+													pos:  position{line: 227, col: 35, offset: 7071},
 													name: "Expr",
 												},
 												&ruleRefExpr{
-													pos:  position{line: 155, col: 42, offset: 4957},
+													pos:  position{line: 227, col: 42, offset: 7078},
 													name: "ParseError",
 												},
 											},
@@ -438,28 +608,28 @@ var g = &grammar{
 		},
 		{
 			name: "Expr",
-			pos:  position{line: 165, col: 1, offset: 5177},
+			pos:  position{line: 237, col: 1, offset: 7298},
 			expr: &actionExpr{
-				pos: position{line: 165, col: 9, offset: 5185},
+				pos: position{line: 237, col: 9, offset: 7306},
 				run: (*parser).callonExpr1,
 				expr: &seqExpr{
-					pos: position{line: 165, col: 9, offset: 5185},
+					pos: position{line: 237, col: 9, offset: 7306},
 					exprs: []interface{}{
 						&labeledExpr{
-							pos:   position{line: 165, col: 9, offset: 5185},
+							pos:   position{line: 237, col: 9, offset: 7306},
 							label: "neg",
 							expr: &zeroOrOneExpr{
-								pos: position{line: 165, col: 13, offset: 5189},
+								pos: position{line: 237, col: 13, offset: 7310},
 								expr: &seqExpr{
-									pos: position{line: 165, col: 15, offset: 5191},
+									pos: position{line: 237, col: 15, offset: 7312},
 									exprs: []interface{}{
 										&litMatcher{
-											pos:        position{line: 165, col: 15, offset: 5191},
+											pos:        position{line: 237, col: 15, offset: 7312},
 											val:        "not",
 											ignoreCase: false,
 										},
 										&ruleRefExpr{
-											pos:  position{line: 165, col: 21, offset: 5197},
+											pos:  position{line: 237, col: 21, offset: 7318},
 											name: "ws",
 										},
 									},
@@ -467,68 +637,139 @@ var g = &grammar{
 							},
 						},
 						&labeledExpr{
-							pos:   position{line: 165, col: 27, offset: 5203},
+							pos:   position{line: 237, col: 27, offset: 7324},
 							label: "val",
 							expr: &choiceExpr{
-								pos: position{line: 165, col: 32, offset: 5208},
+								pos: position{line: 237, col: 32, offset: 7329},
 								alternatives: []interface{}{
 									&ruleRefExpr{
-										pos:  position{line: 165, col: 32, offset: 5208},
+										pos:  position{line: 237, col: 32, offset: 7329},
 										name: "InfixExpr",
 									},
 									&ruleRefExpr{
-										pos:  position{line: 165, col: 44, offset: 5220},
+										pos:  position{line: 237, col: 44, offset: 7341},
 										name: "PrefixExpr",
 									},
 									&ruleRefExpr{
-										pos:  position{line: 165, col: 57, offset: 5233},
+										pos:  position{line: 237, col: 57, offset: 7354},
 										name: "Term",
 									},
 								},
 							},
 						},
+						&labeledExpr{
+							pos:   position{line: 237, col: 63, offset: 7360},
+							label: "with",
+							expr: &zeroOrMoreExpr{
+								pos: position{line: 237, col: 68, offset: 7365},
+								expr: &seqExpr{
+									pos: position{line: 237, col: 70, offset: 7367},
+									exprs: []interface{}{
+										&ruleRefExpr{
+											pos:  position{line: 237, col: 70, offset: 7367},
+											name: "ws",
+										},
+										&ruleRefExpr{
+											pos:  position{line: 237, col: 73, offset: 7370},
+											name: "With",
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "With",
+			pos:  position{line: 251, col: 1, offset: 7732},
+			expr: &actionExpr{
+				pos: position{line: 251, col: 9, offset: 7740},
+				run: (*parser).callonWith1,
+				expr: &seqExpr{
+					pos: position{line: 251, col: 9, offset: 7740},
+					exprs: []interface{}{
+						&litMatcher{
+							pos:        position{line: 251, col: 9, offset: 7740},
+							val:        "with",
+							ignoreCase: false,
+						},
+						&ruleRefExpr{
+							pos:  position{line: 251, col: 16, offset: 7747},
+							name: "ws",
+						},
+						&labeledExpr{
+							pos:   position{line: 251, col: 19, offset: 7750},
+							label: "target",
+							expr: &ruleRefExpr{
+								pos:  position{line: 251, col: 26, offset: 7757},
+								name: "Term",
+							},
+						},
+						&ruleRefExpr{
+							pos:  position{line: 251, col: 31, offset: 7762},
+							name: "ws",
+						},
+						&litMatcher{
+							pos:        position{line: 251, col: 34, offset: 7765},
+							val:        "as",
+							ignoreCase: false,
+						},
+						&ruleRefExpr{
+							pos:  position{line: 251, col: 39, offset: 7770},
+							name: "ws",
+						},
+						&labeledExpr{
+							pos:   position{line: 251, col: 42, offset: 7773},
+							label: "value",
+							expr: &ruleRefExpr{
+								pos:  position{line: 251, col: 48, offset: 7779},
+								name: "Term",
+							},
+						},
 					},
 				},
 			},
 		},
 		{
 			name: "InfixExpr",
-			pos:  position{line: 173, col: 1, offset: 5375},
+			pos:  position{line: 259, col: 1, offset: 7933},
 			expr: &actionExpr{
-				pos: position{line: 173, col: 14, offset: 5388},
+				pos: position{line: 259, col: 14, offset: 7946},
 				run: (*parser).callonInfixExpr1,
 				expr: &seqExpr{
-					pos: position{line: 173, col: 14, offset: 5388},
+					pos: position{line: 259, col: 14, offset: 7946},
 					exprs: []interface{}{
 						&labeledExpr{
-							pos:   position{line: 173, col: 14, offset: 5388},
+							pos:   position{line: 259, col: 14, offset: 7946},
 							label: "left",
 							expr: &ruleRefExpr{
-								pos:  position{line: 173, col: 19, offset: 5393},
+								pos:  position{line: 259, col: 19, offset: 7951},
 								name: "Term",
 							},
 						},
 						&ruleRefExpr{
-							pos:  position{line: 173, col: 24, offset: 5398},
+							pos:  position{line: 259, col: 24, offset: 7956},
 							name: "_",
 						},
 						&labeledExpr{
-							pos:   position{line: 173, col: 26, offset: 5400},
+							pos:   position{line: 259, col: 26, offset: 7958},
 							label: "op",
 							expr: &ruleRefExpr{
-								pos:  position{line: 173, col: 29, offset: 5403},
+								pos:  position{line: 259, col: 29, offset: 7961},
 								name: "InfixOp",
 							},
 						},
 						&ruleRefExpr{
-							pos:  position{line: 173, col: 37, offset: 5411},
+							pos:  position{line: 259, col: 37, offset: 7969},
 							name: "_",
 						},
 						&labeledExpr{
-							pos:   position{line: 173, col: 39, offset: 5413},
+							pos:   position{line: 259, col: 39, offset: 7971},
 							label: "right",
 							expr: &ruleRefExpr{
-								pos:  position{line: 173, col: 45, offset: 5419},
+								pos:  position{line: 259, col: 45, offset: 7977},
 								name: "Term",
 							},
 						},
@@ -538,43 +779,43 @@ var g = &grammar{
 		},
 		{
 			name: "InfixOp",
-			pos:  position{line: 177, col: 1, offset: 5494},
+			pos:  position{line: 263, col: 1, offset: 8052},
 			expr: &actionExpr{
-				pos: position{line: 177, col: 12, offset: 5505},
+				pos: position{line: 263, col: 12, offset: 8063},
 				run: (*parser).callonInfixOp1,
 				expr: &labeledExpr{
-					pos:   position{line: 177, col: 12, offset: 5505},
+					pos:   position{line: 263, col: 12, offset: 8063},
 					label: "val",
 					expr: &choiceExpr{
-						pos: position{line: 177, col: 17, offset: 5510},
+						pos: position{line: 263, col: 17, offset: 8068},
 						alternatives: []interface{}{
 							&litMatcher{
-								pos:        position{line: 177, col: 17, offset: 5510},
+								pos:        position{line: 263, col: 17, offset: 8068},
 								val:        "=",
 								ignoreCase: false,
 							},
 							&litMatcher{
-								pos:        position{line: 177, col: 23, offset: 5516},
+								pos:        position{line: 263, col: 23, offset: 8074},
 								val:        "!=",
 								ignoreCase: false,
 							},
 							&litMatcher{
-								pos:        position{line: 177, col: 30, offset: 5523},
+								pos:        position{line: 263, col: 30, offset: 8081},
 								val:        "<=",
 								ignoreCase: false,
 							},
 							&litMatcher{
-								pos:        position{line: 177, col: 37, offset: 5530},
+								pos:        position{line: 263, col: 37, offset: 8088},
 								val:        ">=",
 								ignoreCase: false,
 							},
 							&litMatcher{
-								pos:        position{line: 177, col: 44, offset: 5537},
+								pos:        position{line: 263, col: 44, offset: 8095},
 								val:        "<",
 								ignoreCase: false,
 							},
 							&litMatcher{
-								pos:        position{line: 177, col: 50, offset: 5543},
+								pos:        position{line: 263, col: 50, offset: 8101},
 								val:        ">",
 								ignoreCase: false,
 							},
@@ -585,16 +826,16 @@ var g = &grammar{
 		},
 		{
 			name: "PrefixExpr",
-			pos:  position{line: 189, col: 1, offset: 5787},
+			pos:  position{line: 275, col: 1, offset: 8345},
 			expr: &choiceExpr{
-				pos: position{line: 189, col: 15, offset: 5801},
+				pos: position{line: 275, col: 15, offset: 8359},
 				alternatives: []interface{}{
 					&ruleRefExpr{
-						pos:  position{line: 189, col: 15, offset: 5801},
+						pos:  position{line: 275, col: 15, offset: 8359},
 						name: "SetEmpty",
 					},
 					&ruleRefExpr{
-						pos:  position{line: 189, col: 26, offset: 5812},
+						pos:  position{line: 275, col: 26, offset: 8370},
 						name: "Builtin",
 					},
 				},
@@ -602,64 +843,64 @@ var g = &grammar{
 		},
 		{
 			name: "Builtin",
-			pos:  position{line: 191, col: 1, offset: 5821},
+			pos:  position{line: 277, col: 1, offset: 8379},
 			expr: &actionExpr{
-				pos: position{line: 191, col: 12, offset: 5832},
+				pos: position{line: 277, col: 12, offset: 8390},
 				run: (*parser).callonBuiltin1,
 				expr: &seqExpr{
-					pos: position{line: 191, col: 12, offset: 5832},
+					pos: position{line: 277, col: 12, offset: 8390},
 					exprs: []interface{}{
 						&labeledExpr{
-							pos:   position{line: 191, col: 12, offset: 5832},
+							pos:   position{line: 277, col: 12, offset: 8390},
 							label: "op",
 							expr: &ruleRefExpr{
-								pos:  position{line: 191, col: 15, offset: 5835},
+								pos:  position{line: 277, col: 15, offset: 8393},
 								name: "Var",
 							},
 						},
 						&litMatcher{
-							pos:        position{line: 191, col: 19, offset: 5839},
+							pos:        position{line: 277, col: 19, offset: 8397},
 							val:        "(",
 							ignoreCase: false,
 						},
 						&ruleRefExpr{
-							pos:  position{line: 191, col: 23, offset: 5843},
+							pos:  position{line: 277, col: 23, offset: 8401},
 							name: "_",
 						},
 						&labeledExpr{
-							pos:   position{line: 191, col: 25, offset: 5845},
+							pos:   position{line: 277, col: 25, offset: 8403},
 							label: "head",
 							expr: &zeroOrOneExpr{
-								pos: position{line: 191, col: 30, offset: 5850},
+								pos: position{line: 277, col: 30, offset: 8408},
 								expr: &ruleRefExpr{
-									pos:  position{line: 191, col: 30, offset: 5850},
+									pos:  position{line: 277, col: 30, offset: 8408},
 									name: "Term",
 								},
 							},
 						},
 						&labeledExpr{
-							pos:   position{line: 191, col: 36, offset: 5856},
+							pos:   position{line: 277, col: 36, offset: 8414},
 							label: "tail",
 							expr: &zeroOrMoreExpr{
-								pos: position{line: 191, col: 41, offset: 5861},
+								pos: position{line: 277, col: 41, offset: 8419},
 								expr: &seqExpr{
-									pos: position{line: 191, col: 43, offset: 5863},
+									pos: position{line: 277, col: 43, offset: 8421},
 									exprs: []interface{}{
 										&ruleRefExpr{
-											pos:  position{line: 191, col: 43, offset: 5863},
+											pos:  position{line: 277, col: 43, offset: 8421},
 											name: "_",
 										},
 										&litMatcher{
-											pos:        position{line: 191, col: 45, offset: 5865},
+											pos:        position{line: 277, col: 45, offset: 8423},
 											val:        ",",
 											ignoreCase: false,
 										},
 										&ruleRefExpr{
-											pos:  position{line: 191, col: 49, offset: 5869},
+											pos:  position{line: 277, col: 49, offset: 8427},
 											name: "_",
 										},
 										&ruleRefExpr{
-											pos:  position{line: 191, col: 51, offset: 5871},
+											pos:  position{line: 277, col: 51, offset: 8429},
 											name: "Term",
 										},
 									},
@@ -667,11 +908,11 @@ var g = &grammar{
 							},
 						},
 						&ruleRefExpr{
-							pos:  position{line: 191, col: 59, offset: 5879},
+							pos:  position{line: 277, col: 59, offset: 8437},
 							name: "_",
 						},
 						&litMatcher{
-							pos:        position{line: 191, col: 62, offset: 5882},
+							pos:        position{line: 277, col: 62, offset: 8440},
 							val:        ")",
 							ignoreCase: false,
 						},
@@ -681,34 +922,34 @@ var g = &grammar{
 		},
 		{
 			name: "Term",
-			pos:  position{line: 207, col: 1, offset: 6284},
+			pos:  position{line: 293, col: 1, offset: 8842},
 			expr: &actionExpr{
-				pos: position{line: 207, col: 9, offset: 6292},
+				pos: position{line: 293, col: 9, offset: 8850},
 				run: (*parser).callonTerm1,
 				expr: &labeledExpr{
-					pos:   position{line: 207, col: 9, offset: 6292},
+					pos:   position{line: 293, col: 9, offset: 8850},
 					label: "val",
 					expr: &choiceExpr{
-						pos: position{line: 207, col: 15, offset: 6298},
+						pos: position{line: 293, col: 15, offset: 8856},
 						alternatives: []interface{}{
 							&ruleRefExpr{
-								pos:  position{line: 207, col: 15, offset: 6298},
+								pos:  position{line: 293, col: 15, offset: 8856},
 								name: "Comprehension",
 							},
 							&ruleRefExpr{
-								pos:  position{line: 207, col: 31, offset: 6314},
+								pos:  position{line: 293, col: 31, offset: 8872},
 								name: "Composite",
 							},
 							&ruleRefExpr{
-								pos:  position{line: 207, col: 43, offset: 6326},
+								pos:  position{line: 293, col: 43, offset: 8884},
 								name: "Scalar",
 							},
 							&ruleRefExpr{
-								pos:  position{line: 207, col: 52, offset: 6335},
+								pos:  position{line: 293, col: 52, offset: 8893},
 								name: "Ref",
 							},
 							&ruleRefExpr{
-								pos:  position{line: 207, col: 58, offset: 6341},
+								pos:  position{line: 293, col: 58, offset: 8899},
 								name: "Var",
 							},
 						},
@@ -718,65 +959,78 @@ var g = &grammar{
 		},
 		{
 			name: "Comprehension",
-			pos:  position{line: 211, col: 1, offset: 6372},
-			expr: &ruleRefExpr{
-				pos:  position{line: 211, col: 18, offset: 6389},
-				name: "ArrayComprehension",
+			pos:  position{line: 297, col: 1, offset: 8930},
+			expr: &choiceExpr{
+				pos: position{line: 297, col: 18, offset: 8947},
+				alternatives: []interface{}{
+					&ruleRefExpr{
+						pos:  position{line: 297, col: 18, offset: 8947},
+						name: "ArrayComprehension",
+					},
+					&ruleRefExpr{
+						pos:  position{line: 297, col: 39, offset: 8968},
+						name: "ObjectComprehension",
+					},
+					&ruleRefExpr{
+						pos:  position{line: 297, col: 61, offset: 8990},
+						name: "SetComprehension",
+					},
+				},
 			},
 		},
 		{
 			name: "ArrayComprehension",
-			pos:  position{line: 213, col: 1, offset: 6409},
+			pos:  position{line: 299, col: 1, offset: 9008},
 			expr: &actionExpr{
-				pos: position{line: 213, col: 23, offset: 6431},
+				pos: position{line: 299, col: 23, offset: 9030},
 				run: (*parser).callonArrayComprehension1,
 				expr: &seqExpr{
-					pos: position{line: 213, col: 23, offset: 6431},
+					pos: position{line: 299, col: 23, offset: 9030},
 					exprs: []interface{}{
 						&litMatcher{
-							pos:        position{line: 213, col: 23, offset: 6431},
+							pos:        position{line: 299, col: 23, offset: 9030},
 							val:        "[",
 							ignoreCase: false,
 						},
 						&ruleRefExpr{
-							pos:  position{line: 213, col: 27, offset: 6435},
+							pos:  position{line: 299, col: 27, offset: 9034},
 							name: "_",
 						},
 						&labeledExpr{
-							pos:   position{line: 213, col: 29, offset: 6437},
+							pos:   position{line: 299, col: 29, offset: 9036},
 							label: "term",
 							expr: &ruleRefExpr{
-								pos:  position{line: 213, col: 34, offset: 6442},
+								pos:  position{line: 299, col: 34, offset: 9041},
 								name: "Term",
 							},
 						},
 						&ruleRefExpr{
-							pos:  position{line: 213, col: 39, offset: 6447},
+							pos:  position{line: 299, col: 39, offset: 9046},
 							name: "_",
 						},
 						&litMatcher{
-							pos:        position{line: 213, col: 41, offset: 6449},
+							pos:        position{line: 299, col: 41, offset: 9048},
 							val:        "|",
 							ignoreCase: false,
 						},
 						&ruleRefExpr{
-							pos:  position{line: 213, col: 45, offset: 6453},
+							pos:  position{line: 299, col: 45, offset: 9052},
 							name: "_",
 						},
 						&labeledExpr{
-							pos:   position{line: 213, col: 47, offset: 6455},
+							pos:   position{line: 299, col: 47, offset: 9054},
 							label: "body",
 							expr: &ruleRefExpr{
-								pos:  position{line: 213, col: 52, offset: 6460},
+								pos:  position{line: 299, col: 52, offset: 9059},
 								name: "Body",
 							},
 						},
 						&ruleRefExpr{
-							pos:  position{line: 213, col: 57, offset: 6465},
+							pos:  position{line: 299, col: 57, offset: 9064},
 							name: "_",
 						},
 						&litMatcher{
-							pos:        position{line: 213, col: 59, offset: 6467},
+							pos:        position{line: 299, col: 59, offset: 9066},
 							val:        "]",
 							ignoreCase: false,
 						},
@@ -784,22 +1038,163 @@ var g = &grammar{
 				},
 			},
 		},
+		{
+			name: "ObjectComprehension",
+			pos:  position{line: 305, col: 1, offset: 9191},
+			expr: &actionExpr{
+				pos: position{line: 305, col: 24, offset: 9214},
+				run: (*parser).callonObjectComprehension1,
+				expr: &seqExpr{
+					pos: position{line: 305, col: 24, offset: 9214},
+					exprs: []interface{}{
+						&litMatcher{
+							pos:        position{line: 305, col: 24, offset: 9214},
+							val:        "{",
+							ignoreCase: false,
+						},
+						&ruleRefExpr{
+							pos:  position{line: 305, col: 28, offset: 9218},
+							name: "_",
+						},
+						&labeledExpr{
+							pos:   position{line: 305, col: 30, offset: 9220},
+							label: "key",
+							expr: &ruleRefExpr{
+								pos:  position{line: 305, col: 34, offset: 9224},
+								name: "Key",
+							},
+						},
+						&ruleRefExpr{
+							pos:  position{line: 305, col: 38, offset: 9228},
+							name: "_",
+						},
+						&litMatcher{
+							pos:        position{line: 305, col: 40, offset: 9230},
+							val:        ":",
+							ignoreCase: false,
+						},
+						&ruleRefExpr{
+							pos:  position{line: 305, col: 44, offset: 9234},
+							name: "_",
+						},
+						&labeledExpr{
+							pos:   position{line: 305, col: 46, offset: 9236},
+							label: "value",
+							expr: &ruleRefExpr{
+								pos:  position{line: 305, col: 52, offset: 9242},
+								name: "Term",
+							},
+						},
+						&ruleRefExpr{
+							pos:  position{line: 305, col: 57, offset: 9247},
+							name: "_",
+						},
+						&litMatcher{
+							pos:        position{line: 305, col: 59, offset: 9249},
+							val:        "|",
+							ignoreCase: false,
+						},
+						&ruleRefExpr{
+							pos:  position{line: 305, col: 63, offset: 9253},
+							name: "_",
+						},
+						&labeledExpr{
+							pos:   position{line: 305, col: 65, offset: 9255},
+							label: "body",
+							expr: &ruleRefExpr{
+								pos:  position{line: 305, col: 70, offset: 9260},
+								name: "Body",
+							},
+						},
+						&ruleRefExpr{
+							pos:  position{line: 305, col: 75, offset: 9265},
+							name: "_",
+						},
+						&litMatcher{
+							pos:        position{line: 305, col: 77, offset: 9267},
+							val:        "}",
+							ignoreCase: false,
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "SetComprehension",
+			pos:  position{line: 311, col: 1, offset: 9407},
+			expr: &actionExpr{
+				pos: position{line: 311, col: 21, offset: 9427},
+				run: (*parser).callonSetComprehension1,
+				expr: &seqExpr{
+					pos: position{line: 311, col: 21, offset: 9427},
+					exprs: []interface{}{
+						&litMatcher{
+							pos:        position{line: 311, col: 21, offset: 9427},
+							val:        "{",
+							ignoreCase: false,
+						},
+						&ruleRefExpr{
+							pos:  position{line: 311, col: 25, offset: 9431},
+							name: "_",
+						},
+						&labeledExpr{
+							pos:   position{line: 311, col: 27, offset: 9433},
+							label: "term",
+							expr: &ruleRefExpr{
+								pos:  position{line: 311, col: 32, offset: 9438},
+								name: "Term",
+							},
+						},
+						&ruleRefExpr{
+							pos:  position{line: 311, col: 37, offset: 9443},
+							name: "_",
+						},
+						&litMatcher{
+							pos:        position{line: 311, col: 39, offset: 9445},
+							val:        "|",
+							ignoreCase: false,
+						},
+						&ruleRefExpr{
+							pos:  position{line: 311, col: 43, offset: 9449},
+							name: "_",
+						},
+						&labeledExpr{
+							pos:   position{line: 311, col: 45, offset: 9451},
+							label: "body",
+							expr: &ruleRefExpr{
+								pos:  position{line: 311, col: 50, offset: 9456},
+								name: "Body",
+							},
+						},
+						&ruleRefExpr{
+							pos:  position{line: 311, col: 55, offset: 9461},
+							name: "_",
+						},
+						&litMatcher{
+							pos:        position{line: 311, col: 57, offset: 9463},
+							val:        "}",
+							ignoreCase: false,
+						},
+					},
+				},
+			},
+		},
 		{
 			name: "Composite",
-			pos:  position{line: 219, col: 1, offset: 6592},
+			pos:  position{line: 317, col: 1, offset: 9586},
 			expr: &choiceExpr{
-				pos: position{line: 219, col: 14, offset: 6605},
+				pos: position{line: 317, col: 14, offset: 9599},
 				alternatives: []interface{}{
 					&ruleRefExpr{
-						pos:  position{line: 219, col: 14, offset: 6605},
+						pos:  position{line: 317, col: 14, offset: 9599},
 						name: "Object",
 					},
 					&ruleRefExpr{
-						pos:  position{line: 219, col: 23, offset: 6614},
+						pos:  position{line: 317, col: 23, offset: 9608},
 						name: "Array",
 					},
 					&ruleRefExpr{
-						pos:  position{line: 219, col: 31, offset: 6622},
+						pos:  position{line: 317, col: 31, offset: 9616},
 						name: "Set",
 					},
 				},
@@ -807,24 +1202,24 @@ var g = &grammar{
 		},
 		{
 			name: "Scalar",
-			pos:  position{line: 221, col: 1, offset: 6627},
+			pos:  position{line: 319, col: 1, offset: 9621},
 			expr: &choiceExpr{
-				pos: position{line: 221, col: 11, offset: 6637},
+				pos: position{line: 319, col: 11, offset: 9631},
 				alternatives: []interface{}{
 					&ruleRefExpr{
-						pos:  position{line: 221, col: 11, offset: 6637},
+						pos:  position{line: 319, col: 11, offset: 9631},
 						name: "Number",
 					},
 					&ruleRefExpr{
-						pos:  position{line: 221, col: 20, offset: 6646},
+						pos:  position{line: 319, col: 20, offset: 9640},
 						name: "String",
 					},
 					&ruleRefExpr{
-						pos:  position{line: 221, col: 29, offset: 6655},
+						pos:  position{line: 319, col: 29, offset: 9649},
 						name: "Bool",
 					},
 					&ruleRefExpr{
-						pos:  position{line: 221, col: 36, offset: 6662},
+						pos:  position{line: 319, col: 36, offset: 9656},
 						name: "Null",
 					},
 				},
@@ -832,20 +1227,20 @@ var g = &grammar{
 		},
 		{
 			name: "Key",
-			pos:  position{line: 223, col: 1, offset: 6668},
+			pos:  position{line: 321, col: 1, offset: 9662},
 			expr: &choiceExpr{
-				pos: position{line: 223, col: 8, offset: 6675},
+				pos: position{line: 321, col: 8, offset: 9669},
 				alternatives: []interface{}{
 					&ruleRefExpr{
-						pos:  position{line: 223, col: 8, offset: 6675},
+						pos:  position{line: 321, col: 8, offset: 9669},
 						name: "Scalar",
 					},
 					&ruleRefExpr{
-						pos:  position{line: 223, col: 17, offset: 6684},
+						pos:  position{line: 321, col: 17, offset: 9678},
 						name: "Ref",
 					},
 					&ruleRefExpr{
-						pos:  position{line: 223, col: 23, offset: 6690},
+						pos:  position{line: 321, col: 23, offset: 9684},
 						name: "Var",
 					},
 				},
@@ -853,49 +1248,49 @@ var g = &grammar{
 		},
 		{
 			name: "Object",
-			pos:  position{line: 225, col: 1, offset: 6695},
+			pos:  position{line: 323, col: 1, offset: 9689},
 			expr: &actionExpr{
-				pos: position{line: 225, col: 11, offset: 6705},
+				pos: position{line: 323, col: 11, offset: 9699},
 				run: (*parser).callonObject1,
 				expr: &seqExpr{
-					pos: position{line: 225, col: 11, offset: 6705},
+					pos: position{line: 323, col: 11, offset: 9699},
 					exprs: []interface{}{
 						&litMatcher{
-							pos:        position{line: 225, col: 11, offset: 6705},
+							pos:        position{line: 323, col: 11, offset: 9699},
 							val:        "{",
 							ignoreCase: false,
 						},
 						&ruleRefExpr{
-							pos:  position{line: 225, col: 15, offset: 6709},
+							pos:  position{line: 323, col: 15, offset: 9703},
 							name: "_",
 						},
 						&labeledExpr{
-							pos:   position{line: 225, col: 17, offset: 6711},
+							pos:   position{line: 323, col: 17, offset: 9705},
 							label: "head",
 							expr: &zeroOrOneExpr{
-								pos: position{line: 225, col: 22, offset: 6716},
+								pos: position{line: 323, col: 22, offset: 9710},
 								expr: &seqExpr{
-									pos: position{line: 225, col: 23, offset: 6717},
+									pos: position{line: 323, col: 23, offset: 9711},
 									exprs: []interface{}{
 										&ruleRefExpr{
-											pos:  position{line: 225, col: 23, offset: 6717},
+											pos:  position{line: 323, col: 23, offset: 9711},
 											name: "Key",
 										},
 										&ruleRefExpr{
-											pos:  position{line: 225, col: 27, offset: 6721},
+											pos:  position{line: 323, col: 27, offset: 9715},
 											name: "_",
 										},
 										&litMatcher{
-											pos:        position{line: 225, col: 29, offset: 6723},
+											pos:        position{line: 323, col: 29, offset: 9717},
 											val:        ":",
 											ignoreCase: false,
 										},
 										&ruleRefExpr{
-											pos:  position{line: 225, col: 33, offset: 6727},
+											pos:  position{line: 323, col: 33, offset: 9721},
 											name: "_",
 										},
 										&ruleRefExpr{
-											pos:  position{line: 225, col: 35, offset: 6729},
+											pos:  position{line: 323, col: 35, offset: 9723},
 											name: "Term",
 										},
 									},
@@ -903,45 +1298,45 @@ var g = &grammar{
 							},
 						},
 						&labeledExpr{
-							pos:   position{line: 225, col: 42, offset: 6736},
+							pos:   position{line: 323, col: 42, offset: 9730},
 							label: "tail",
 							expr: &zeroOrMoreExpr{
-								pos: position{line: 225, col: 47, offset: 6741},
+								pos: position{line: 323, col: 47, offset: 9735},
 								expr: &seqExpr{
-									pos: position{line: 225, col: 49, offset: 6743},
+									pos: position{line: 323, col: 49, offset: 9737},
 									exprs: []interface{}{
 										&ruleRefExpr{
-											pos:  position{line: 225, col: 49, offset: 6743},
+											pos:  position{line: 323, col: 49, offset: 9737},
 											name: "_",
 										},
 										&litMatcher{
-											pos:        position{line: 225, col: 51, offset: 6745},
+											pos:        position{line: 323, col: 51, offset: 9739},
 											val:        ",",
 											ignoreCase: false,
 										},
 										&ruleRefExpr{
-											pos:  position{line: 225, col: 55, offset: 6749},
+											pos:  position{line: 323, col: 55, offset: 9743},
 											name: "_",
 										},
 										&ruleRefExpr{
-											pos:  position{line: 225, col: 57, offset: 6751},
+											pos:  position{line: 323, col: 57, offset: 9745},
 											name: "Key",
 										},
 										&ruleRefExpr{
-											pos:  position{line: 225, col: 61, offset: 6755},
+											pos:  position{line: 323, col: 61, offset: 9749},
 											name: "_",
 										},
 										&litMatcher{
-											pos:        position{line: 225, col: 63, offset: 6757},
+											pos:        position{line: 323, col: 63, offset: 9751},
 											val:        ":",
 											ignoreCase: false,
 										},
 										&ruleRefExpr{
-											pos:  position{line: 225, col: 67, offset: 6761},
+											pos:  position{line: 323, col: 67, offset: 9755},
 											name: "_",
 										},
 										&ruleRefExpr{
-											pos:  position{line: 225, col: 69, offset: 6763},
+											pos:  position{line: 323, col: 69, offset: 9757},
 											name: "Term",
 										},
 									},
@@ -949,11 +1344,11 @@ var g = &grammar{
 							},
 						},
 						&ruleRefExpr{
-							pos:  position{line: 225, col: 77, offset: 6771},
+							pos:  position{line: 323, col: 77, offset: 9765},
 							name: "_",
 						},
 						&litMatcher{
-							pos:        position{line: 225, col: 79, offset: 6773},
+							pos:        position{line: 323, col: 79, offset: 9767},
 							val:        "}",
 							ignoreCase: false,
 						},
@@ -963,56 +1358,56 @@ var g = &grammar{
 		},
 		{
 			name: "Array",
-			pos:  position{line: 249, col: 1, offset: 7552},
+			pos:  position{line: 347, col: 1, offset: 10546},
 			expr: &actionExpr{
-				pos: position{line: 249, col: 10, offset: 7561},
+				pos: position{line: 347, col: 10, offset: 10555},
 				run: (*parser).callonArray1,
 				expr: &seqExpr{
-					pos: position{line: 249, col: 10, offset: 7561},
+					pos: position{line: 347, col: 10, offset: 10555},
 					exprs: []interface{}{
 						&litMatcher{
-							pos:        position{line: 249, col: 10, offset: 7561},
+							pos:        position{line: 347, col: 10, offset: 10555},
 							val:        "[",
 							ignoreCase: false,
 						},
 						&ruleRefExpr{
-							pos:  position{line: 249, col: 14, offset: 7565},
+							pos:  position{line: 347, col: 14, offset: 10559},
 							name: "_",
 						},
 						&labeledExpr{
-							pos:   position{line: 249, col: 17, offset: 7568},
+							pos:   position{line: 347, col: 17, offset: 10562},
 							label: "head",
 							expr: &zeroOrOneExpr{
-								pos: position{line: 249, col: 22, offset: 7573},
+								pos: position{line: 347, col: 22, offset: 10567},
 								expr: &ruleRefExpr{
-									pos:  position{line: 249, col: 22, offset: 7573},
+									pos:  position{line: 347, col: 22, offset: 10567},
 									name: "Term",
 								},
 							},
 						},
 						&labeledExpr{
-							pos:   position{line: 249, col: 28, offset: 7579},
+							pos:   position{line: 347, col: 28, offset: 10573},
 							label: "tail",
 							expr: &zeroOrMoreExpr{
-								pos: position{line: 249, col: 33, offset: 7584},
+								pos: position{line: 347, col: 33, offset: 10578},
 								expr: &seqExpr{
-									pos: position{line: 249, col: 34, offset: 7585},
+									pos: position{line: 347, col: 34, offset: 10579},
 									exprs: []interface{}{
 										&ruleRefExpr{
-											pos:  position{line: 249, col: 34, offset: 7585},
+											pos:  position{line: 347, col: 34, offset: 10579},
 											name: "_",
 										},
 										&litMatcher{
-											pos:        position{line: 249, col: 36, offset: 7587},
+											pos:        position{line: 347, col: 36, offset: 10581},
 											val:        ",",
 											ignoreCase: false,
 										},
 										&ruleRefExpr{
-											pos:  position{line: 249, col: 40, offset: 7591},
+											pos:  position{line: 347, col: 40, offset: 10585},
 											name: "_",
 										},
 										&ruleRefExpr{
-											pos:  position{line: 249, col: 42, offset: 7593},
+											pos:  position{line: 347, col: 42, offset: 10587},
 											name: "Term",
 										},
 									},
@@ -1020,11 +1415,11 @@ var g = &grammar{
 							},
 						},
 						&ruleRefExpr{
-							pos:  position{line: 249, col: 49, offset: 7600},
+							pos:  position{line: 347, col: 49, offset: 10594},
 							name: "_",
 						},
 						&litMatcher{
-							pos:        position{line: 249, col: 51, offset: 7602},
+							pos:        position{line: 347, col: 51, offset: 10596},
 							val:        "]",
 							ignoreCase: false,
 						},
@@ -1034,16 +1429,16 @@ var g = &grammar{
 		},
 		{
 			name: "Set",
-			pos:  position{line: 273, col: 1, offset: 8175},
+			pos:  position{line: 371, col: 1, offset: 11169},
 			expr: &choiceExpr{
-				pos: position{line: 273, col: 8, offset: 8182},
+				pos: position{line: 371, col: 8, offset: 11176},
 				alternatives: []interface{}{
 					&ruleRefExpr{
-						pos:  position{line: 273, col: 8, offset: 8182},
+						pos:  position{line: 371, col: 8, offset: 11176},
 						name: "SetEmpty",
 					},
 					&ruleRefExpr{
-						pos:  position{line: 273, col: 19, offset: 8193},
+						pos:  position{line: 371, col: 19, offset: 11187},
 						name: "SetNonEmpty",
 					},
 				},
@@ -1051,24 +1446,24 @@ var g = &grammar{
 		},
 		{
 			name: "SetEmpty",
-			pos:  position{line: 275, col: 1, offset: 8206},
+			pos:  position{line: 373, col: 1, offset: 11200},
 			expr: &actionExpr{
-				pos: position{line: 275, col: 13, offset: 8218},
+				pos: position{line: 373, col: 13, offset: 11212},
 				run: (*parser).callonSetEmpty1,
 				expr: &seqExpr{
-					pos: position{line: 275, col: 13, offset: 8218},
+					pos: position{line: 373, col: 13, offset: 11212},
 					exprs: []interface{}{
 						&litMatcher{
-							pos:        position{line: 275, col: 13, offset: 8218},
+							pos:        position{line: 373, col: 13, offset: 11212},
 							val:        "set(",
 							ignoreCase: false,
 						},
 						&ruleRefExpr{
-							pos:  position{line: 275, col: 20, offset: 8225},
+							pos:  position{line: 373, col: 20, offset: 11219},
 							name: "_",
 						},
 						&litMatcher{
-							pos:        position{line: 275, col: 22, offset: 8227},
+							pos:        position{line: 373, col: 22, offset: 11221},
 							val:        ")",
 							ignoreCase: false,
 						},
@@ -1078,53 +1473,53 @@ var g = &grammar{
 		},
 		{
 			name: "SetNonEmpty",
-			pos:  position{line: 281, col: 1, offset: 8315},
+			pos:  position{line: 379, col: 1, offset: 11309},
 			expr: &actionExpr{
-				pos: position{line: 281, col: 16, offset: 8330},
+				pos: position{line: 379, col: 16, offset: 11324},
 				run: (*parser).callonSetNonEmpty1,
 				expr: &seqExpr{
-					pos: position{line: 281, col: 16, offset: 8330},
+					pos: position{line: 379, col: 16, offset: 11324},
 					exprs: []interface{}{
 						&litMatcher{
-							pos:        position{line: 281, col: 16, offset: 8330},
+							pos:        position{line: 379, col: 16, offset: 11324},
 							val:        "{",
 							ignoreCase: false,
 						},
 						&ruleRefExpr{
-							pos:  position{line: 281, col: 20, offset: 8334},
+							pos:  position{line: 379, col: 20, offset: 11328},
 							name: "_",
 						},
 						&labeledExpr{
-							pos:   position{line: 281, col: 22, offset: 8336},
+							pos:   position{line: 379, col: 22, offset: 11330},
 							label: "head",
 							expr: &ruleRefExpr{
-								pos:  position{line: 281, col: 27, offset: 8341},
+								pos:  position{line: 379, col: 27, offset: 11335},
 								name: "Term",
 							},
 						},
 						&labeledExpr{
-							pos:   position{line: 281, col: 32, offset: 8346},
+							pos:   position{line: 379, col: 32, offset: 11340},
 							label: "tail",
 							expr: &zeroOrMoreExpr{
-								pos: position{line: 281, col: 37, offset: 8351},
+								pos: position{line: 379, col: 37, offset: 11345},
 								expr: &seqExpr{
-									pos: position{line: 281, col: 38, offset: 8352},
+									pos: position{line: 379, col: 38, offset: 11346},
 									exprs: []interface{}{
 										&ruleRefExpr{
-											pos:  position{line: 281, col: 38, offset: 8352},
+											pos:  position{line: 379, col: 38, offset: 11346},
 											name: "_",
 										},
 										&litMatcher{
-											pos:        position{line: 281, col: 40, offset: 8354},
+											pos:        position{line: 379, col: 40, offset: 11348},
 											val:        ",",
 											ignoreCase: false,
 										},
 										&ruleRefExpr{
-											pos:  position{line: 281, col: 44, offset: 8358},
+											pos:  position{line: 379, col: 44, offset: 11352},
 											name: "_",
 										},
 										&ruleRefExpr{
-											pos:  position{line: 281, col: 46, offset: 8360},
+											pos:  position{line: 379, col: 46, offset: 11354},
 											name: "Term",
 										},
 									},
@@ -1132,11 +1527,11 @@ var g = &grammar{
 							},
 						},
 						&ruleRefExpr{
-							pos:  position{line: 281, col: 53, offset: 8367},
+							pos:  position{line: 379, col: 53, offset: 11361},
 							name: "_",
 						},
 						&litMatcher{
-							pos:        position{line: 281, col: 55, offset: 8369},
+							pos:        position{line: 379, col: 55, offset: 11363},
 							val:        "}",
 							ignoreCase: false,
 						},
@@ -1146,35 +1541,35 @@ var g = &grammar{
 		},
 		{
 			name: "Ref",
-			pos:  position{line: 298, col: 1, offset: 8774},
+			pos:  position{line: 396, col: 1, offset: 11768},
 			expr: &actionExpr{
-				pos: position{line: 298, col: 8, offset: 8781},
+				pos: position{line: 396, col: 8, offset: 11775},
 				run: (*parser).callonRef1,
 				expr: &seqExpr{
-					pos: position{line: 298, col: 8, offset: 8781},
+					pos: position{line: 396, col: 8, offset: 11775},
 					exprs: []interface{}{
 						&labeledExpr{
-							pos:   position{line: 298, col: 8, offset: 8781},
+							pos:   position{line: 396, col: 8, offset: 11775},
 							label: "head",
 							expr: &ruleRefExpr{
-								pos:  position{line: 298, col: 13, offset: 8786},
+								pos:  position{line: 396, col: 13, offset: 11780},
 								name: "Var",
 							},
 						},
 						&labeledExpr{
-							pos:   position{line: 298, col: 17, offset: 8790},
+							pos:   position{line: 396, col: 17, offset: 11784},
 							label: "tail",
 							expr: &oneOrMoreExpr{
-								pos: position{line: 298, col: 22, offset: 8795},
+								pos: position{line: 396, col: 22, offset: 11789},
 								expr: &choiceExpr{
-									pos: position{line: 298, col: 24, offset: 8797},
+									pos: position{line: 396, col: 24, offset: 11791},
 									alternatives: []interface{}{
 										&ruleRefExpr{
-											pos:  position{line: 298, col: 24, offset: 8797},
+											pos:  position{line: 396, col: 24, offset: 11791},
 											name: "RefDot",
 										},
 										&ruleRefExpr{
-											pos:  position{line: 298, col: 33, offset: 8806},
+											pos:  position{line: 396, col: 33, offset: 11800},
 											name: "RefBracket",
 										},
 									},
@@ -1187,23 +1582,23 @@ var g = &grammar{
 		},
 		{
 			name: "RefDot",
-			pos:  position{line: 311, col: 1, offset: 9045},
+			pos:  position{line: 409, col: 1, offset: 12039},
 			expr: &actionExpr{
-				pos: position{line: 311, col: 11, offset: 9055},
+				pos: position{line: 409, col: 11, offset: 12049},
 				run: (*parser).callonRefDot1,
 				expr: &seqExpr{
-					pos: position{line: 311, col: 11, offset: 9055},
+					pos: position{line: 409, col: 11, offset: 12049},
 					exprs: []interface{}{
 						&litMatcher{
-							pos:        position{line: 311, col: 11, offset: 9055},
+							pos:        position{line: 409, col: 11, offset: 12049},
 							val:        ".",
 							ignoreCase: false,
 						},
 						&labeledExpr{
-							pos:   position{line: 311, col: 15, offset: 9059},
+							pos:   position{line: 409, col: 15, offset: 12053},
 							label: "val",
 							expr: &ruleRefExpr{
-								pos:  position{line: 311, col: 19, offset: 9063},
+								pos:  position{line: 409, col: 19, offset: 12057},
 								name: "Var",
 							},
 						},
@@ -1213,41 +1608,41 @@ var g = &grammar{
 		},
 		{
 			name: "RefBracket",
-			pos:  position{line: 318, col: 1, offset: 9282},
+			pos:  position{line: 416, col: 1, offset: 12276},
 			expr: &actionExpr{
-				pos: position{line: 318, col: 15, offset: 9296},
+				pos: position{line: 416, col: 15, offset: 12290},
 				run: (*parser).callonRefBracket1,
 				expr: &seqExpr{
-					pos: position{line: 318, col: 15, offset: 9296},
+					pos: position{line: 416, col: 15, offset: 12290},
 					exprs: []interface{}{
 						&litMatcher{
-							pos:        position{line: 318, col: 15, offset: 9296},
+							pos:        position{line: 416, col: 15, offset: 12290},
 							val:        "[",
 							ignoreCase: false,
 						},
 						&labeledExpr{
-							pos:   position{line: 318, col: 19, offset: 9300},
+							pos:   position{line: 416, col: 19, offset: 12294},
 							label: "val",
 							expr: &choiceExpr{
-								pos: position{line: 318, col: 24, offset: 9305},
+								pos: position{line: 416, col: 24, offset: 12299},
 								alternatives: []interface{}{
 									&ruleRefExpr{
-										pos:  position{line: 318, col: 24, offset: 9305},
+										pos:  position{line: 416, col: 24, offset: 12299},
 										name: "Ref",
 									},
 									&ruleRefExpr{
-										pos:  position{line: 318, col: 30, offset: 9311},
+										pos:  position{line: 416, col: 30, offset: 12305},
 										name: "Scalar",
 									},
 									&ruleRefExpr{
-										pos:  position{line: 318, col: 39, offset: 9320},
+										pos:  position{line: 416, col: 39, offset: 12314},
 										name: "Var",
 									},
 								},
 							},
 						},
 						&litMatcher{
-							pos:        position{line: 318, col: 44, offset: 9325},
+							pos:        position{line: 416, col: 44, offset: 12319},
 							val:        "]",
 							ignoreCase: false,
 						},
@@ -1257,15 +1652,15 @@ var g = &grammar{
 		},
 		{
 			name: "Var",
-			pos:  position{line: 322, col: 1, offset: 9354},
+			pos:  position{line: 420, col: 1, offset: 12348},
 			expr: &actionExpr{
-				pos: position{line: 322, col: 8, offset: 9361},
+				pos: position{line: 420, col: 8, offset: 12355},
 				run: (*parser).callonVar1,
 				expr: &labeledExpr{
-					pos:   position{line: 322, col: 8, offset: 9361},
+					pos:   position{line: 420, col: 8, offset: 12355},
 					label: "val",
 					expr: &ruleRefExpr{
-						pos:  position{line: 322, col: 12, offset: 9365},
+						pos:  position{line: 420, col: 12, offset: 12359},
 						name: "VarChecked",
 					},
 				},
@@ -1273,20 +1668,20 @@ var g = &grammar{
 		},
 		{
 			name: "VarChecked",
-			pos:  position{line: 327, col: 1, offset: 9487},
+			pos:  position{line: 425, col: 1, offset: 12481},
 			expr: &seqExpr{
-				pos: position{line: 327, col: 15, offset: 9501},
+				pos: position{line: 425, col: 15, offset: 12495},
 				exprs: []interface{}{
 					&labeledExpr{
-						pos:   position{line: 327, col: 15, offset: 9501},
+						pos:   position{line: 425, col: 15, offset: 12495},
 						label: "val",
 						expr: &ruleRefExpr{
-							pos:  position{line: 327, col: 19, offset: 9505},
+							pos:  position{line: 425, col: 19, offset: 12499},
 							name: "VarUnchecked",
 						},
 					},
 					&notCodeExpr{
-						pos: position{line: 327, col: 32, offset: 9518},
+						pos: position{line: 425, col: 32, offset: 12512},
 						run: (*parser).callonVarChecked4,
 					},
 				},
@@ -1294,28 +1689,28 @@ var g = &grammar{
 		},
 		{
 			name: "VarUnchecked",
-			pos:  position{line: 331, col: 1, offset: 9583},
+			pos:  position{line: 429, col: 1, offset: 12577},
 			expr: &actionExpr{
-				pos: position{line: 331, col: 17, offset: 9599},
+				pos: position{line: 429, col: 17, offset: 12593},
 				run: (*parser).callonVarUnchecked1,
 				expr: &seqExpr{
-					pos: position{line: 331, col: 17, offset: 9599},
+					pos: position{line: 429, col: 17, offset: 12593},
 					exprs: []interface{}{
 						&ruleRefExpr{
-							pos:  position{line: 331, col: 17, offset: 9599},
+							pos:  position{line: 429, col: 17, offset: 12593},
 							name: "AsciiLetter",
 						},
 						&zeroOrMoreExpr{
-							pos: position{line: 331, col: 29, offset: 9611},
+							pos: position{line: 429, col: 29, offset: 12605},
 							expr: &choiceExpr{
-								pos: position{line: 331, col: 30, offset: 9612},
+								pos: position{line: 429, col: 30, offset: 12606},
 								alternatives: []interface{}{
 									&ruleRefExpr{
-										pos:  position{line: 331, col: 30, offset: 9612},
+										pos:  position{line: 429, col: 30, offset: 12606},
 										name: "AsciiLetter",
 									},
 									&ruleRefExpr{
-										pos:  position{line: 331, col: 44, offset: 9626},
+										pos:  position{line: 429, col: 44, offset: 12620},
 										name: "DecimalDigit",
 									},
 								},
@@ -1327,39 +1722,39 @@ var g = &grammar{
 		},
 		{
 			name: "Number",
-			pos:  position{line: 338, col: 1, offset: 9769},
+			pos:  position{line: 436, col: 1, offset: 12763},
 			expr: &actionExpr{
-				pos: position{line: 338, col: 11, offset: 9779},
+				pos: position{line: 436, col: 11, offset: 12773},
 				run: (*parser).callonNumber1,
 				expr: &seqExpr{
-					pos: position{line: 338, col: 11, offset: 9779},
+					pos: position{line: 436, col: 11, offset: 12773},
 					exprs: []interface{}{
 						&zeroOrOneExpr{
-							pos: position{line: 338, col: 11, offset: 9779},
+							pos: position{line: 436, col: 11, offset: 12773},
 							expr: &litMatcher{
-								pos:        position{line: 338, col: 11, offset: 9779},
+								pos:        position{line: 436, col: 11, offset: 12773},
 								val:        "-",
 								ignoreCase: false,
 							},
 						},
 						&ruleRefExpr{
-							pos:  position{line: 338, col: 16, offset: 9784},
+							pos:  position{line: 436, col: 16, offset: 12778},
 							name: "Integer",
 						},
 						&zeroOrOneExpr{
-							pos: position{line: 338, col: 24, offset: 9792},
+							pos: position{line: 436, col: 24, offset: 12786},
 							expr: &seqExpr{
-								pos: position{line: 338, col: 26, offset: 9794},
+								pos: position{line: 436, col: 26, offset: 12788},
 								exprs: []interface{}{
 									&litMatcher{
-										pos:        position{line: 338, col: 26, offset: 9794},
+										pos:        position{line: 436, col: 26, offset: 12788},
 										val:        ".",
 										ignoreCase: false,
 									},
 									&oneOrMoreExpr{
-										pos: position{line: 338, col: 30, offset: 9798},
+										pos: position{line: 436, col: 30, offset: 12792},
 										expr: &ruleRefExpr{
-											pos:  position{line: 338, col: 30, offset: 9798},
+											pos:  position{line: 436, col: 30, offset: 12792},
 											name: "DecimalDigit",
 										},
 									},
@@ -1367,9 +1762,9 @@ var g = &grammar{
 							},
 						},
 						&zeroOrOneExpr{
-							pos: position{line: 338, col: 47, offset: 9815},
+							pos: position{line: 436, col: 47, offset: 12809},
 							expr: &ruleRefExpr{
-								pos:  position{line: 338, col: 47, offset: 9815},
+								pos:  position{line: 436, col: 47, offset: 12809},
 								name: "Exponent",
 							},
 						},
@@ -1379,48 +1774,48 @@ var g = &grammar{
 		},
 		{
 			name: "String",
-			pos:  position{line: 347, col: 1, offset: 10074},
+			pos:  position{line: 445, col: 1, offset: 13068},
 			expr: &actionExpr{
-				pos: position{line: 347, col: 11, offset: 10084},
+				pos: position{line: 445, col: 11, offset: 13078},
 				run: (*parser).callonString1,
 				expr: &seqExpr{
-					pos: position{line: 347, col: 11, offset: 10084},
+					pos: position{line: 445, col: 11, offset: 13078},
 					exprs: []interface{}{
 						&litMatcher{
-							pos:        position{line: 347, col: 11, offset: 10084},
+							pos:        position{line: 445, col: 11, offset: 13078},
 							val:        "\"",
 							ignoreCase: false,
 						},
 						&zeroOrMoreExpr{
-							pos: position{line: 347, col: 15, offset: 10088},
+							pos: position{line: 445, col: 15, offset: 13082},
 							expr: &choiceExpr{
-								pos: position{line: 347, col: 17, offset: 10090},
+								pos: position{line: 445, col: 17, offset: 13084},
 								alternatives: []interface{}{
 									&seqExpr{
-										pos: position{line: 347, col: 17, offset: 10090},
+										pos: position{line: 445, col: 17, offset: 13084},
 										exprs: []interface{}{
 											&notExpr{
-												pos: position{line: 347, col: 17, offset: 10090},
+												pos: position{line: 445, col: 17, offset: 13084},
 												expr: &ruleRefExpr{
-													pos:  position{line: 347, col: 18, offset: 10091},
+													pos:  position{line: 445, col: 18, offset: 13085},
 													name: "EscapedChar",
 												},
 											},
 											&anyMatcher{
-												line: 347, col: 30, offset: 10103,
+												line: 445, col: 30, offset: 13097,
 											},
 										},
 									},
 									&seqExpr{
-										pos: position{line: 347, col: 34, offset: 10107},
+										pos: position{line: 445, col: 34, offset: 13101},
 										exprs: []interface{}{
 											&litMatcher{
-												pos:        position{line: 347, col: 34, offset: 10107},
+												pos:        position{line: 445, col: 34, offset: 13101},
 												val:        "\\",
 												ignoreCase: false,
 											},
 											&ruleRefExpr{
-												pos:  position{line: 347, col: 39, offset: 10112},
+												pos:  position{line: 445, col: 39, offset: 13106},
 												name: "EscapeSequence",
 											},
 										},
@@ -1429,7 +1824,7 @@ var g = &grammar{
 							},
 						},
 						&litMatcher{
-							pos:        position{line: 347, col: 57, offset: 10130},
+							pos:        position{line: 445, col: 57, offset: 13124},
 							val:        "\"",
 							ignoreCase: false,
 						},
@@ -1439,24 +1834,24 @@ var g = &grammar{
 		},
 		{
 			name: "Bool",
-			pos:  position{line: 356, col: 1, offset: 10388},
+			pos:  position{line: 454, col: 1, offset: 13382},
 			expr: &choiceExpr{
-				pos: position{line: 356, col: 9, offset: 10396},
+				pos: position{line: 454, col: 9, offset: 13390},
 				alternatives: []interface{}{
 					&actionExpr{
-						pos: position{line: 356, col: 9, offset: 10396},
+						pos: position{line: 454, col: 9, offset: 13390},
 						run: (*parser).callonBool2,
 						expr: &litMatcher{
-							pos:        position{line: 356, col: 9, offset: 10396},
+							pos:        position{line: 454, col: 9, offset: 13390},
 							val:        "true",
 							ignoreCase: false,
 						},
 					},
 					&actionExpr{
-						pos: position{line: 360, col: 5, offset: 10496},
+						pos: position{line: 458, col: 5, offset: 13490},
 						run: (*parser).callonBool4,
 						expr: &litMatcher{
-							pos:        position{line: 360, col: 5, offset: 10496},
+							pos:        position{line: 458, col: 5, offset: 13490},
 							val:        "false",
 							ignoreCase: false,
 						},
@@ -1466,12 +1861,12 @@ var g = &grammar{
 		},
 		{
 			name: "Null",
-			pos:  position{line: 366, col: 1, offset: 10597},
+			pos:  position{line: 464, col: 1, offset: 13591},
 			expr: &actionExpr{
-				pos: position{line: 366, col: 9, offset: 10605},
+				pos: position{line: 464, col: 9, offset: 13599},
 				run: (*parser).callonNull1,
 				expr: &litMatcher{
-					pos:        position{line: 366, col: 9, offset: 10605},
+					pos:        position{line: 464, col: 9, offset: 13599},
 					val:        "null",
 					ignoreCase: false,
 				},
@@ -1479,26 +1874,26 @@ var g = &grammar{
 		},
 		{
 			name: "Integer",
-			pos:  position{line: 372, col: 1, offset: 10700},
+			pos:  position{line: 470, col: 1, offset: 13694},
 			expr: &choiceExpr{
-				pos: position{line: 372, col: 12, offset: 10711},
+				pos: position{line: 470, col: 12, offset: 13705},
 				alternatives: []interface{}{
 					&litMatcher{
-						pos:        position{line: 372, col: 12, offset: 10711},
+						pos:        position{line: 470, col: 12, offset: 13705},
 						val:        "0",
 						ignoreCase: false,
 					},
 					&seqExpr{
-						pos: position{line: 372, col: 18, offset: 10717},
+						pos: position{line: 470, col: 18, offset: 13711},
 						exprs: []interface{}{
 							&ruleRefExpr{
-								pos:  position{line: 372, col: 18, offset: 10717},
+								pos:  position{line: 470, col: 18, offset: 13711},
 								name: "NonZeroDecimalDigit",
 							},
 							&zeroOrMoreExpr{
-								pos: position{line: 372, col: 38, offset: 10737},
+								pos: position{line: 470, col: 38, offset: 13731},
 								expr: &ruleRefExpr{
-									pos:  position{line: 372, col: 38, offset: 10737},
+									pos:  position{line: 470, col: 38, offset: 13731},
 									name: "DecimalDigit",
 								},
 							},
@@ -1509,19 +1904,19 @@ var g = &grammar{
 		},
 		{
 			name: "Exponent",
-			pos:  position{line: 374, col: 1, offset: 10752},
+			pos:  position{line: 472, col: 1, offset: 13746},
 			expr: &seqExpr{
-				pos: position{line: 374, col: 13, offset: 10764},
+				pos: position{line: 472, col: 13, offset: 13758},
 				exprs: []interface{}{
 					&litMatcher{
-						pos:        position{line: 374, col: 13, offset: 10764},
+						pos:        position{line: 472, col: 13, offset: 13758},
 						val:        "e",
 						ignoreCase: true,
 					},
 					&zeroOrOneExpr{
-						pos: position{line: 374, col: 18, offset: 10769},
+						pos: position{line: 472, col: 18, offset: 13763},
 						expr: &charClassMatcher{
-							pos:        position{line: 374, col: 18, offset: 10769},
+							pos:        position{line: 472, col: 18, offset: 13763},
 							val:        "[+-]",
 							chars:      []rune{'+', '-'},
 							ignoreCase: false,
@@ -1529,9 +1924,9 @@ var g = &grammar{
 						},
 					},
 					&oneOrMoreExpr{
-						pos: position{line: 374, col: 24, offset: 10775},
+						pos: position{line: 472, col: 24, offset: 13769},
 						expr: &ruleRefExpr{
-							pos:  position{line: 374, col: 24, offset: 10775},
+							pos:  position{line: 472, col: 24, offset: 13769},
 							name: "DecimalDigit",
 						},
 					},
@@ -1540,9 +1935,9 @@ var g = &grammar{
 		},
 		{
 			name: "AsciiLetter",
-			pos:  position{line: 376, col: 1, offset: 10790},
+			pos:  position{line: 474, col: 1, offset: 13784},
 			expr: &charClassMatcher{
-				pos:        position{line: 376, col: 16, offset: 10805},
+				pos:        position{line: 474, col: 16, offset: 13799},
 				val:        "[A-Za-z_]",
 				chars:      []rune{'_'},
 				ranges:     []rune{'A', 'Z', 'a', 'z'},
@@ -1552,9 +1947,9 @@ var g = &grammar{
 		},
 		{
 			name: "EscapedChar",
-			pos:  position{line: 378, col: 1, offset: 10816},
+			pos:  position{line: 476, col: 1, offset: 13810},
 			expr: &charClassMatcher{
-				pos:        position{line: 378, col: 16, offset: 10831},
+				pos:        position{line: 476, col: 16, offset: 13825},
 				val:        "[\\x00-\\x1f\"\\\\]",
 				chars:      []rune{'"', '\\'},
 				ranges:     []rune{'\x00', '\x1f'},
@@ -1564,16 +1959,16 @@ var g = &grammar{
 		},
 		{
 			name: "EscapeSequence",
-			pos:  position{line: 380, col: 1, offset: 10847},
+			pos:  position{line: 478, col: 1, offset: 13841},
 			expr: &choiceExpr{
-				pos: position{line: 380, col: 19, offset: 10865},
+				pos: position{line: 478, col: 19, offset: 13859},
 				alternatives: []interface{}{
 					&ruleRefExpr{
-						pos:  position{line: 380, col: 19, offset: 10865},
+						pos:  position{line: 478, col: 19, offset: 13859},
 						name: "SingleCharEscape",
 					},
 					&ruleRefExpr{
-						pos:  position{line: 380, col: 38, offset: 10884},
+						pos:  position{line: 478, col: 38, offset: 13878},
 						name: "UnicodeEscape",
 					},
 				},
@@ -1581,9 +1976,9 @@ var g = &grammar{
 		},
 		{
 			name: "SingleCharEscape",
-			pos:  position{line: 382, col: 1, offset: 10899},
+			pos:  position{line: 480, col: 1, offset: 13893},
 			expr: &charClassMatcher{
-				pos:        position{line: 382, col: 21, offset: 10919},
+				pos:        position{line: 480, col: 21, offset: 13913},
 				val:        "[\"\\\\/bfnrt]",
 				chars:      []rune{'"', '\\', '/', 'b', 'f', 'n', 'r', 't'},
 				ignoreCase: false,
@@ -1592,29 +1987,29 @@ var g = &grammar{
 		},
 		{
 			name: "UnicodeEscape",
-			pos:  position{line: 384, col: 1, offset: 10932},
+			pos:  position{line: 482, col: 1, offset: 13926},
 			expr: &seqExpr{
-				pos: position{line: 384, col: 18, offset: 10949},
+				pos: position{line: 482, col: 18, offset: 13943},
 				exprs: []interface{}{
 					&litMatcher{
-						pos:        position{line: 384, col: 18, offset: 10949},
+						pos:        position{line: 482, col: 18, offset: 13943},
 						val:        "u",
 						ignoreCase: false,
 					},
 					&ruleRefExpr{
-						pos:  position{line: 384, col: 22, offset: 10953},
+						pos:  position{line: 482, col: 22, offset: 13947},
 						name: "HexDigit",
 					},
 					&ruleRefExpr{
-						pos:  position{line: 384, col: 31, offset: 10962},
+						pos:  position{line: 482, col: 31, offset: 13956},
 						name: "HexDigit",
 					},
 					&ruleRefExpr{
-						pos:  position{line: 384, col: 40, offset: 10971},
+						pos:  position{line: 482, col: 40, offset: 13965},
 						name: "HexDigit",
 					},
 					&ruleRefExpr{
-						pos:  position{line: 384, col: 49, offset: 10980},
+						pos:  position{line: 482, col: 49, offset: 13974},
 						name: "HexDigit",
 					},
 				},
@@ -1622,9 +2017,9 @@ var g = &grammar{
 		},
 		{
 			name: "DecimalDigit",
-			pos:  position{line: 386, col: 1, offset: 10990},
+			pos:  position{line: 484, col: 1, offset: 13984},
 			expr: &charClassMatcher{
-				pos:        position{line: 386, col: 17, offset: 11006},
+				pos:        position{line: 484, col: 17, offset: 14000},
 				val:        "[0-9]",
 				ranges:     []rune{'0', '9'},
 				ignoreCase: false,
@@ -1633,9 +2028,9 @@ var g = &grammar{
 		},
 		{
 			name: "NonZeroDecimalDigit",
-			pos:  position{line: 388, col: 1, offset: 11013},
+			pos:  position{line: 486, col: 1, offset: 14007},
 			expr: &charClassMatcher{
-				pos:        position{line: 388, col: 24, offset: 11036},
+				pos:        position{line: 486, col: 24, offset: 14030},
 				val:        "[1-9]",
 				ranges:     []rune{'1', '9'},
 				ignoreCase: false,
@@ -1644,9 +2039,9 @@ var g = &grammar{
 		},
 		{
 			name: "HexDigit",
-			pos:  position{line: 390, col: 1, offset: 11043},
+			pos:  position{line: 488, col: 1, offset: 14037},
 			expr: &charClassMatcher{
-				pos:        position{line: 390, col: 13, offset: 11055},
+				pos:        position{line: 488, col: 13, offset: 14049},
 				val:        "[0-9a-f]",
 				ranges:     []rune{'0', '9', 'a', 'f'},
 				ignoreCase: false,
@@ -1656,11 +2051,11 @@ var g = &grammar{
 		{
 			name:        "ws",
 			displayName: "\"whitespace\"",
-			pos:         position{line: 392, col: 1, offset: 11065},
+			pos:         position{line: 490, col: 1, offset: 14059},
 			expr: &oneOrMoreExpr{
-				pos: position{line: 392, col: 20, offset: 11084},
+				pos: position{line: 490, col: 20, offset: 14078},
 				expr: &charClassMatcher{
-					pos:        position{line: 392, col: 20, offset: 11084},
+					pos:        position{line: 490, col: 20, offset: 14078},
 					val:        "[ \\t\\r\\n]",
 					chars:      []rune{' ', '\t', '\r', '\n'},
 					ignoreCase: false,
@@ -1671,21 +2066,21 @@ var g = &grammar{
 		{
 			name:        "_",
 			displayName: "\"whitespace\"",
-			pos:         position{line: 394, col: 1, offset: 11096},
+			pos:         position{line: 492, col: 1, offset: 14090},
 			expr: &zeroOrMoreExpr{
-				pos: position{line: 394, col: 19, offset: 11114},
+				pos: position{line: 492, col: 19, offset: 14108},
 				expr: &choiceExpr{
-					pos: position{line: 394, col: 21, offset: 11116},
+					pos: position{line: 492, col: 21, offset: 14110},
 					alternatives: []interface{}{
 						&charClassMatcher{
-							pos:        position{line: 394, col: 21, offset: 11116},
+							pos:        position{line: 492, col: 21, offset: 14110},
 							val:        "[ \\t\\r\\n]",
 							chars:      []rune{' ', '\t', '\r', '\n'},
 							ignoreCase: false,
 							inverted:   false,
 						},
 						&ruleRefExpr{
-							pos:  position{line: 394, col: 33, offset: 11128},
+							pos:  position{line: 492, col: 33, offset: 14122},
 							name: "Comment",
 						},
 					},
@@ -1694,33 +2089,27 @@ var g = &grammar{
 		},
 		{
 			name: "Comment",
-			pos:  position{line: 396, col: 1, offset: 11140},
-			expr: &seqExpr{
-				pos: position{line: 396, col: 12, offset: 11151},
-				exprs: []interface{}{
-					&zeroOrMoreExpr{
-						pos: position{line: 396, col: 12, offset: 11151},
-						expr: &charClassMatcher{
-							pos:        position{line: 396, col: 12, offset: 11151},
-							val:        "[ \\t]",
-							chars:      []rune{' ', '\t'},
+			pos:  position{line: 494, col: 1, offset: 14134},
+			expr: &actionExpr{
+				pos: position{line: 494, col: 12, offset: 14145},
+				run: (*parser).callonComment1,
+				expr: &seqExpr{
+					pos: position{line: 494, col: 12, offset: 14145},
+					exprs: []interface{}{
+						&litMatcher{
+							pos:        position{line: 494, col: 12, offset: 14145},
+							val:        "#",
 							ignoreCase: false,
-							inverted:   false,
 						},
-					},
-					&litMatcher{
-						pos:        position{line: 396, col: 19, offset: 11158},
-						val:        "#",
-						ignoreCase: false,
-					},
-					&zeroOrMoreExpr{
-						pos: position{line: 396, col: 23, offset: 11162},
-						expr: &charClassMatcher{
-							pos:        position{line: 396, col: 23, offset: 11162},
-							val:        "[^\\r\\n]",
-							chars:      []rune{'\r', '\n'},
-							ignoreCase: false,
-							inverted:   true,
+						&zeroOrMoreExpr{
+							pos: position{line: 494, col: 16, offset: 14149},
+							expr: &charClassMatcher{
+								pos:        position{line: 494, col: 16, offset: 14149},
+								val:        "[^\\r\\n]",
+								chars:      []rune{'\r', '\n'},
+								ignoreCase: false,
+								inverted:   true,
+							},
 						},
 					},
 				},
@@ -1728,11 +2117,11 @@ var g = &grammar{
 		},
 		{
 			name: "EOF",
-			pos:  position{line: 398, col: 1, offset: 11172},
+			pos:  position{line: 501, col: 1, offset: 14263},
 			expr: &notExpr{
-				pos: position{line: 398, col: 8, offset: 11179},
+				pos: position{line: 501, col: 8, offset: 14270},
 				expr: &anyMatcher{
-					line: 398, col: 9, offset: 11180,
+					line: 501, col: 9, offset: 14271,
 				},
 			},
 		},
@@ -1847,7 +2236,38 @@ func (p *parser) callonImport1() (interface{}, error) {
 	return p.cur.onImport1(stack["path"], stack["alias"])
 }
 
-func (c *current) onRule1(name, key, value, body interface{}) (interface{}, error) {
+func (c *current) onDefaultRule1(name, value interface{}) (interface{}, error) {
+
+	term := value.(*Term)
+	var closure interface{}
+	WalkClosures(term, func(x interface{}) bool {
+		closure = x
+		return true
+	})
+
+	if closure != nil {
+		return nil, fmt.Errorf("default rules cannot contain closures (%v appears in value)", closure)
+	}
+
+	rule := &Rule{
+		Default: true,
+		Name:    name.(*Term).Value.(Var),
+		Value:   term,
+		Body:    NewBody(NewExpr(BooleanTerm(true))),
+	}
+	rule.Location = currentLocation(c)
+	rule.Body[0].Location = rule.Location
+
+	return rule, nil
+}
+
+func (p *parser) callonDefaultRule1() (interface{}, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onDefaultRule1(stack["name"], stack["value"])
+}
+
+func (c *current) onRule1(name, key, value, body, elses interface{}) (interface{}, error) {
 
 	rule := &Rule{}
 	rule.Location = currentLocation(c)
@@ -1900,13 +2320,66 @@ func (c *current) onRule1(name, key, value, body interface{}) (interface{}, erro
 	// Rule definition above describes the "body" slice. We only care about the "Body" element.
 	rule.Body = body.([]interface{})[3].(Body)
 
+	// Rule definition above describes the "elses" slice. We only care about the "Else" elements.
+	curr := rule
+	for _, s := range elses.([]interface{}) {
+		if rule.Key != nil {
+			return nil, fmt.Errorf("else keyword cannot be used on partial rules")
+		}
+		elseRule := s.([]interface{})[1].(*Rule)
+		elseRule.Name = rule.Name
+
+		var closure interface{}
+		WalkClosures(elseRule.Value, func(x interface{}) bool {
+			closure = x
+			return true
+		})
+
+		if closure != nil {
+			return nil, fmt.Errorf("else cannot contain closures (%v appears in value)", closure)
+		}
+
+		curr.Else = elseRule
+		curr = elseRule
+	}
+
 	return rule, nil
 }
 
 func (p *parser) callonRule1() (interface{}, error) {
 	stack := p.vstack[len(p.vstack)-1]
 	_ = stack
-	return p.cur.onRule1(stack["name"], stack["key"], stack["value"], stack["body"])
+	return p.cur.onRule1(stack["name"], stack["key"], stack["value"], stack["body"], stack["elses"])
+}
+
+func (c *current) onElse1(value, body interface{}) (interface{}, error) {
+
+	rule := &Rule{}
+	rule.Location = currentLocation(c)
+
+	if value != nil {
+		valueSlice := value.([]interface{})
+		// Else definition above describes the "value" slice. We care about the "Term" element.
+		rule.Value = valueSlice[len(valueSlice)-1].(*Term)
+	} else {
+		rule.Value = BooleanTerm(true)
+	}
+
+	if body != nil {
+		// Else definition above describes the "body" slice. We only care about the "Body" element.
+		rule.Body = body.([]interface{})[3].(Body)
+	} else {
+		rule.Body = NewBody(NewExpr(BooleanTerm(true)))
+		rule.Body[0].Location = rule.Location
+	}
+
+	return rule, nil
+}
+
+func (p *parser) callonElse1() (interface{}, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onElse1(stack["value"], stack["body"])
 }
 
 func (c *current) onBody1(head, tail interface{}) (interface{}, error) {
@@ -1925,18 +2398,38 @@ func (p *parser) callonBody1() (interface{}, error) {
 	return p.cur.onBody1(stack["head"], stack["tail"])
 }
 
-func (c *current) onExpr1(neg, val interface{}) (interface{}, error) {
+func (c *current) onExpr1(neg, val, with interface{}) (interface{}, error) {
 	expr := &Expr{}
 	expr.Location = currentLocation(c)
 	expr.Negated = neg != nil
 	expr.Terms = val
+
+	// Expr definition above describes the "with" slice. We only care about the "With" elements.
+	for _, s := range with.([]interface{}) {
+		expr.With = append(expr.With, s.([]interface{})[1].(*With))
+	}
+
 	return expr, nil
 }
 
 func (p *parser) callonExpr1() (interface{}, error) {
 	stack := p.vstack[len(p.vstack)-1]
 	_ = stack
-	return p.cur.onExpr1(stack["neg"], stack["val"])
+	return p.cur.onExpr1(stack["neg"], stack["val"], stack["with"])
+}
+
+func (c *current) onWith1(target, value interface{}) (interface{}, error) {
+	with := &With{}
+	with.Location = currentLocation(c)
+	with.Target = target.(*Term)
+	with.Value = value.(*Term)
+	return with, nil
+}
+
+func (p *parser) callonWith1() (interface{}, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onWith1(stack["target"], stack["value"])
 }
 
 func (c *current) onInfixExpr1(left, op, right interface{}) (interface{}, error) {
@@ -2011,6 +2504,30 @@ func (p *parser) callonArrayComprehension1() (interface{}, error) {
 	return p.cur.onArrayComprehension1(stack["term"], stack["body"])
 }
 
+func (c *current) onObjectComprehension1(key, value, body interface{}) (interface{}, error) {
+	oc := ObjectComprehensionTerm(key.(*Term), value.(*Term), body.(Body))
+	oc.Location = currentLocation(c)
+	return oc, nil
+}
+
+func (p *parser) callonObjectComprehension1() (interface{}, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onObjectComprehension1(stack["key"], stack["value"], stack["body"])
+}
+
+func (c *current) onSetComprehension1(term, body interface{}) (interface{}, error) {
+	sc := SetComprehensionTerm(term.(*Term), body.(Body))
+	sc.Location = currentLocation(c)
+	return sc, nil
+}
+
+func (p *parser) callonSetComprehension1() (interface{}, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onSetComprehension1(stack["term"], stack["body"])
+}
+
 func (c *current) onObject1(head, tail interface{}) (interface{}, error) {
 	obj := ObjectTerm()
 	obj.Location = currentLocation(c)
@@ -2247,6 +2764,19 @@ func (p *parser) callonNull1() (interface{}, error) {
 	return p.cur.onNull1()
 }
 
+func (c *current) onComment1() (interface{}, error) {
+	return &Comment{
+		Text:     c.text[1:],
+		Location: currentLocation(c),
+	}, nil
+}
+
+func (p *parser) callonComment1() (interface{}, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onComment1()
+}
+
 var (
 	// errNoRule is returned when the grammar to parse has no rule.
 	errNoRule = errors.New("grammar has no rule")
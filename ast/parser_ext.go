@@ -328,6 +328,18 @@ func formatParserError(filename string, e *parserError) *Error {
 
 func parseModule(stmts []interface{}) (*Module, error) {
 
+	var comments []*Comment
+
+	// Comments may precede the package directive.
+	for len(stmts) > 0 {
+		comment, ok := stmts[0].(*Comment)
+		if !ok {
+			break
+		}
+		comments = append(comments, comment)
+		stmts = stmts[1:]
+	}
+
 	if len(stmts) == 0 {
 		return nil, nil
 	}
@@ -339,11 +351,14 @@ func parseModule(stmts []interface{}) (*Module, error) {
 	}
 
 	mod := &Module{
-		Package: _package,
+		Package:  _package,
+		Comments: comments,
 	}
 
 	for _, stmt := range stmts[1:] {
 		switch stmt := stmt.(type) {
+		case *Comment:
+			mod.Comments = append(mod.Comments, stmt)
 		case *Import:
 			mod.Imports = append(mod.Imports, stmt)
 		case *Rule:
@@ -469,6 +484,8 @@ func setFilename(filename string, stmts []interface{}) {
 	for _, stmt := range stmts {
 		vis := &GenericVisitor{func(x interface{}) bool {
 			switch x := x.(type) {
+			case *Comment:
+				x.Location.File = filename
 			case *Package:
 				x.Location.File = filename
 			case *Import:
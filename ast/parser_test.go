@@ -214,6 +214,57 @@ func TestArrayComprehensions(t *testing.T) {
 
 }
 
+func TestObjectComprehensions(t *testing.T) {
+
+	input := `[
+		{"x": {k: [v | v = ys[j]] | xs[k] = ys}}
+	]`
+
+	expected := ArrayTerm(
+		ObjectTerm(Item(
+			StringTerm("x"),
+			ObjectComprehensionTerm(
+				VarTerm("k"),
+				ArrayComprehensionTerm(
+					VarTerm("v"),
+					NewBody(
+						Equality.Expr(VarTerm("v"), RefTerm(VarTerm("ys"), VarTerm("j"))),
+					),
+				),
+				NewBody(
+					Equality.Expr(RefTerm(VarTerm("xs"), VarTerm("k")), VarTerm("ys")),
+				),
+			),
+		)),
+	)
+
+	assertParseOneTerm(t, "nested", input, expected)
+	assertParseOneTerm(t, "ref key", `{a.b: x | x = 1}`, ObjectComprehensionTerm(RefTerm(VarTerm("a"), StringTerm("b")), VarTerm("x"), NewBody(Equality.Expr(VarTerm("x"), IntNumberTerm(1)))))
+	assertParseOneTerm(t, "object fallback", `{"a": 1}`, ObjectTerm(Item(StringTerm("a"), IntNumberTerm(1))))
+}
+
+func TestSetComprehensions(t *testing.T) {
+
+	input := `[{x | a[x]}, {[y | y = b[i]] | c[j]}]`
+
+	expected := ArrayTerm(
+		SetComprehensionTerm(
+			VarTerm("x"),
+			NewBody(&Expr{Terms: RefTerm(VarTerm("a"), VarTerm("x"))}),
+		),
+		SetComprehensionTerm(
+			ArrayComprehensionTerm(
+				VarTerm("y"),
+				NewBody(Equality.Expr(VarTerm("y"), RefTerm(VarTerm("b"), VarTerm("i")))),
+			),
+			NewBody(&Expr{Terms: RefTerm(VarTerm("c"), VarTerm("j"))}),
+		),
+	)
+
+	assertParseOneTerm(t, "nested", input, expected)
+	assertParseOneTerm(t, "set fallback", `{[x | a[x]]}`, SetTerm(ArrayComprehensionTerm(VarTerm("x"), NewBody(&Expr{Terms: RefTerm(VarTerm("a"), VarTerm("x"))}))))
+}
+
 func TestInfixExpr(t *testing.T) {
 	assertParseOneExpr(t, "scalars 1", "true = false", Equality.Expr(BooleanTerm(true), BooleanTerm(false)))
 	assertParseOneExpr(t, "scalars 2", "3.14 = null", Equality.Expr(FloatNumberTerm(3.14), NullTerm()))
@@ -262,6 +313,42 @@ func TestNegatedExpr(t *testing.T) {
 	assertParseOneExprNegated(t, "misc. builtin", "not sorted(x[y].z[a])", NewBuiltinExpr(VarTerm("sorted"), ref1))
 }
 
+func TestExprWith(t *testing.T) {
+	assertParseOneExpr(t, "request", "data.foo with request as bar", &Expr{
+		Terms: MustParseTerm("data.foo"),
+		With: []*With{
+			{
+				Target: MustParseTerm("request"),
+				Value:  VarTerm("bar"),
+			},
+		},
+	})
+
+	assertParseOneExpr(t, "builtin/ref target", `count(data.foo, x) with request.foo as {"bar": baz}`, &Expr{
+		Terms: MustParseExpr("count(data.foo, x)").Terms,
+		With: []*With{
+			{
+				Target: MustParseTerm("request.foo"),
+				Value:  MustParseTerm(`{"bar": baz}`),
+			},
+		},
+	})
+
+	assertParseOneExpr(t, "multiple", `data.foo with request.foo as 1 with data.bar as [1, 2]`, &Expr{
+		Terms: MustParseTerm("data.foo"),
+		With: []*With{
+			{
+				Target: MustParseTerm("request.foo"),
+				Value:  IntNumberTerm(1),
+			},
+			{
+				Target: MustParseTerm("data.bar"),
+				Value:  MustParseTerm("[1, 2]"),
+			},
+		},
+	})
+}
+
 func TestPackage(t *testing.T) {
 	ref1 := RefTerm(DefaultRootDocument, StringTerm("foo"))
 	assertParsePackage(t, "single", "package foo", &Package{Path: ref1.Value.(Ref)})
@@ -406,9 +493,46 @@ func TestRule(t *testing.T) {
 		Body:  MustParseBody("true"),
 	})
 
+	assertParseRule(t, "default", `default p = {"a": [1, 2]}`, &Rule{
+		Default: true,
+		Name:    Var("p"),
+		Value:   MustParseTerm(`{"a": [1, 2]}`),
+		Body:    MustParseBody("true"),
+	})
+
+	assertParseRule(t, "else", `p = 1 :- false else = 2 :- x = 1 else :- true`, &Rule{
+		Name:  Var("p"),
+		Value: IntNumberTerm(1),
+		Body:  MustParseBody("false"),
+		Else: &Rule{
+			Name:  Var("p"),
+			Value: IntNumberTerm(2),
+			Body:  MustParseBody("x = 1"),
+			Else: &Rule{
+				Name:  Var("p"),
+				Value: BooleanTerm(true),
+				Body:  MustParseBody("true"),
+			},
+		},
+	})
+
+	assertParseRule(t, "else without body", `p :- false else = false`, &Rule{
+		Name:  Var("p"),
+		Value: BooleanTerm(true),
+		Body:  MustParseBody("false"),
+		Else: &Rule{
+			Name:  Var("p"),
+			Value: BooleanTerm(false),
+			Body:  MustParseBody("true"),
+		},
+	})
+
 	assertParseErrorEquals(t, "object composite key", "p[[x,y]] = z :- true", "head of object rule must have string, var, or ref key ([x, y] is not allowed)")
 	assertParseErrorEquals(t, "closure in key", "p[[1 | true]] :- true", "head cannot contain closures ([1 | true] appears in key)")
 	assertParseErrorEquals(t, "closure in value", "p = [[1 | true]] :- true", "head cannot contain closures ([1 | true] appears in value)")
+	assertParseErrorEquals(t, "closure in default", "default p = [[1 | true]]", "default rules cannot contain closures ([1 | true] appears in value)")
+	assertParseErrorEquals(t, "closure in else", "p :- false else = [[1 | true]]", "else cannot contain closures ([1 | true] appears in value)")
+	assertParseErrorEquals(t, "else in partial rule", "p[x] :- x = 1 else :- true", "else keyword cannot be used on partial rules")
 
 	// TODO(tsandall): improve error checking here. This is a common mistake
 	// and the current error message is not very good. Need to investigate if the
@@ -425,6 +549,10 @@ func TestEmptyModule(t *testing.T) {
 	if r != nil {
 		t.Errorf("Expected nil for empty module: %v", r)
 	}
+	r, err = ParseModule("", "  # only a comment\n")
+	if err != nil || r != nil {
+		t.Errorf("Expected nil for module containing only comments but got: %v (err: %v)", r, err)
+	}
 }
 
 func TestComments(t *testing.T) {
@@ -471,6 +599,56 @@ func TestComments(t *testing.T) {
 	})
 }
 
+func TestCommentsRetained(t *testing.T) {
+
+	mod, err := ParseModule("test.rego", `package ex
+
+# Comments directly above a rule
+# are associated with it.
+p :- true # trailing
+
+# Separated by a blank line.
+
+q :- x = "# not a comment", x != "\\\"#"  # after string
+`)
+
+	if err != nil {
+		t.Fatalf("Unexpected parse error: %v", err)
+	}
+
+	expected := []struct {
+		text string
+		row  int
+		col  int
+	}{
+		{" Comments directly above a rule", 3, 1},
+		{" are associated with it.", 4, 1},
+		{" trailing", 5, 11},
+		{" Separated by a blank line.", 7, 1},
+		{" after string", 9, 43},
+	}
+
+	if len(mod.Comments) != len(expected) {
+		t.Fatalf("Expected %d comments but got: %v", len(expected), mod.Comments)
+	}
+
+	for i, e := range expected {
+		c := mod.Comments[i]
+		if string(c.Text) != e.text || c.Location.Row != e.row || c.Location.Col != e.col || c.Location.File != "test.rego" {
+			t.Errorf("Expected comment %q at %d:%d but got %q at %v:%v", e.text, e.row, e.col, c.Text, c.Location.Row, c.Location.Col)
+		}
+	}
+
+	before := mod.CommentsBefore(mod.Rules[0])
+	if len(before) != 2 || before[0] != mod.Comments[0] || before[1] != mod.Comments[1] {
+		t.Errorf("Expected first two comments to be associated with p but got: %v", before)
+	}
+
+	if before := mod.CommentsBefore(mod.Rules[1]); len(before) != 0 {
+		t.Errorf("Expected no comments to be associated with q but got: %v", before)
+	}
+}
+
 func TestExample(t *testing.T) {
 	assertParseModule(t, "example module", testModule, &Module{
 		Package: MustParseStatement("package opa.examples").(*Package),
@@ -42,6 +42,7 @@ var Wildcard = &Term{Value: Var("_")}
 var Keywords = [...]string{
 	"not",
 	"package",
+	"else",
 	"import",
 	"null",
 	"true",
@@ -68,9 +69,10 @@ type (
 	// within a namespace (defined by the package) and optional
 	// dependencies on external documents (defined by imports).
 	Module struct {
-		Package *Package
-		Imports []*Import
-		Rules   []*Rule
+		Package  *Package
+		Imports  []*Import
+		Rules    []*Rule
+		Comments []*Comment `json:"-"`
 	}
 
 	// Statement represents a single statement within a module.
@@ -78,6 +80,14 @@ type (
 		Loc() *Location
 	}
 
+	// Comment contains the text of a comment in the module source, excluding
+	// the leading "#". Only comments between statements are retained by the
+	// parser. Comments do not affect module equality.
+	Comment struct {
+		Text     []byte
+		Location *Location
+	}
+
 	// Package represents the namespace of the documents produced
 	// by rules inside the module.
 	Package struct {
@@ -97,10 +107,12 @@ type (
 	// content of documents that represent policy decisions.
 	Rule struct {
 		Location *Location `json:"-"`
+		Default  bool      `json:",omitempty"`
 		Name     Var
 		Key      *Term `json:",omitempty"`
 		Value    *Term `json:",omitempty"`
 		Body     Body
+		Else     *Rule `json:",omitempty"`
 	}
 
 	// Head represents the head of a rule.
@@ -120,6 +132,15 @@ type (
 		Index    int
 		Negated  bool `json:",omitempty"`
 		Terms    interface{}
+		With     []*With `json:",omitempty"`
+	}
+
+	// With represents a modifier on an expression. The target document is
+	// replaced by the value while the expression is evaluated.
+	With struct {
+		Location *Location `json:"-"`
+		Target   *Term
+		Value    *Term
 	}
 )
 
@@ -147,9 +168,77 @@ func (mod *Module) Copy() *Module {
 		cpy.Imports[i] = mod.Imports[i].Copy()
 	}
 	cpy.Package = mod.Package.Copy()
+	cpy.Comments = make([]*Comment, len(mod.Comments))
+	copy(cpy.Comments, mod.Comments)
 	return &cpy
 }
 
+// CommentsBefore returns the comments associated with stmt. A comment is
+// associated with stmt if it is on a line by itself and it is followed by
+// stmt or by other comments associated with stmt, i.e., the comments form a
+// block directly above stmt.
+func (mod *Module) CommentsBefore(stmt Statement) []*Comment {
+	return newCommentMap(mod).before(stmt)
+}
+
+// commentMap indexes the comments in a module that are on lines by themselves
+// so that the comments associated with many statements can be looked up
+// without rescanning the module.
+type commentMap map[int]*Comment
+
+func newCommentMap(mod *Module) commentMap {
+	occupied := mod.occupiedRows()
+	m := commentMap{}
+	for _, c := range mod.Comments {
+		if !occupied[c.Location.Row] {
+			m[c.Location.Row] = c
+		}
+	}
+	return m
+}
+
+// before returns the comments associated with stmt. See Module.CommentsBefore.
+func (m commentMap) before(stmt Statement) []*Comment {
+
+	loc := stmt.Loc()
+	if loc == nil {
+		return nil
+	}
+
+	var result []*Comment
+	for row := loc.Row - 1; m[row] != nil; row-- {
+		result = append([]*Comment{m[row]}, result...)
+	}
+
+	return result
+}
+
+// occupiedRows returns the set of rows that contain the start of a statement
+// or expression.
+func (mod *Module) occupiedRows() map[int]bool {
+	rows := map[int]bool{}
+	add := func(loc *Location) {
+		if loc != nil {
+			rows[loc.Row] = true
+		}
+	}
+	add(mod.Package.Location)
+	for _, imp := range mod.Imports {
+		add(imp.Location)
+	}
+	WalkRules(mod, func(r *Rule) bool {
+		add(r.Location)
+		return false
+	})
+	WalkBodies(mod, func(b Body) bool {
+		for _, e := range b {
+			add(e.Location)
+		}
+		return false
+	})
+	return rows
+}
+
 // Equal returns true if mod equals other.
 func (mod *Module) Equal(other *Module) bool {
 	return mod.Compare(other) == 0
@@ -173,6 +262,15 @@ func (mod *Module) String() string {
 	return strings.Join(buf, "\n")
 }
 
+// Loc returns the location of the comment in the definition.
+func (c *Comment) Loc() *Location {
+	return c.Location
+}
+
+func (c *Comment) String() string {
+	return "#" + string(c.Text)
+}
+
 // Compare returns an integer indicating whether pkg is less than, equal to,
 // or greater than other.
 func (pkg *Package) Compare(other *Package) int {
@@ -283,6 +381,12 @@ func (imp *Import) String() string {
 // Compare returns an integer indicating whether rule is less than, equal to,
 // or greater than other.
 func (rule *Rule) Compare(other *Rule) int {
+	if rule.Default != other.Default {
+		if !rule.Default {
+			return -1
+		}
+		return 1
+	}
 	if cmp := Compare(rule.Name, other.Name); cmp != 0 {
 		return cmp
 	}
@@ -292,7 +396,18 @@ func (rule *Rule) Compare(other *Rule) int {
 	if cmp := Compare(rule.Value, other.Value); cmp != 0 {
 		return cmp
 	}
-	return rule.Body.Compare(other.Body)
+	if cmp := rule.Body.Compare(other.Body); cmp != 0 {
+		return cmp
+	}
+	switch {
+	case rule.Else == nil && other.Else == nil:
+		return 0
+	case rule.Else == nil:
+		return -1
+	case other.Else == nil:
+		return 1
+	}
+	return rule.Else.Compare(other.Else)
 }
 
 // Copy returns a deep copy of rule.
@@ -301,6 +416,9 @@ func (rule *Rule) Copy() *Rule {
 	cpy.Key = rule.Key.Copy()
 	cpy.Value = rule.Value.Copy()
 	cpy.Body = rule.Body.Copy()
+	if rule.Else != nil {
+		cpy.Else = rule.Else.Copy()
+	}
 	return &cpy
 }
 
@@ -367,11 +485,31 @@ func (rule *Rule) Path(ns Ref) Ref {
 }
 
 func (rule *Rule) String() string {
+	if rule.Default {
+		return "default " + rule.Head().String()
+	}
 	buf := []string{rule.Head().String()}
 	if len(rule.Body) >= 0 {
 		buf = append(buf, ":-")
 		buf = append(buf, rule.Body.String())
 	}
+	if rule.Else != nil {
+		buf = append(buf, rule.Else.elseString())
+	}
+	return strings.Join(buf, " ")
+}
+
+func (rule *Rule) elseString() string {
+	buf := []string{"else"}
+	if rule.Value != nil {
+		buf = append(buf, "=")
+		buf = append(buf, rule.Value.String())
+	}
+	buf = append(buf, ":-")
+	buf = append(buf, rule.Body.String())
+	if rule.Else != nil {
+		buf = append(buf, rule.Else.elseString())
+	}
 	return strings.Join(buf, " ")
 }
 
@@ -545,15 +683,21 @@ func (expr *Expr) Compare(other *Expr) int {
 		if !ok {
 			return -1
 		}
-		return Compare(t.Value, u.Value)
+		if cmp := Compare(t.Value, u.Value); cmp != 0 {
+			return cmp
+		}
 	case []*Term:
 		u, ok := other.Terms.([]*Term)
 		if !ok {
 			return 1
 		}
-		return termSliceCompare(t, u)
+		if cmp := termSliceCompare(t, u); cmp != 0 {
+			return cmp
+		}
+	default:
+		panic(fmt.Sprintf("illegal value: %T", expr.Terms))
 	}
-	panic(fmt.Sprintf("illegal value: %T", expr.Terms))
+	return withSliceCompare(expr.With, other.With)
 }
 
 // Copy returns a deep copy of expr.
@@ -569,6 +713,12 @@ func (expr *Expr) Copy() *Expr {
 	case *Term:
 		cpy.Terms = ts.Copy()
 	}
+	if expr.With != nil {
+		cpy.With = make([]*With, len(expr.With))
+		for i := range expr.With {
+			cpy.With[i] = expr.With[i].Copy()
+		}
+	}
 	return &cpy
 }
 
@@ -583,6 +733,9 @@ func (expr *Expr) Hash() int {
 	case *Term:
 		s += ts.Value.Hash()
 	}
+	for _, w := range expr.With {
+		s += w.Hash()
+	}
 	if expr.Negated {
 		s++
 	}
@@ -617,8 +770,20 @@ func (expr *Expr) IsGround() bool {
 }
 
 // OutputVars returns a VarSet containing variables that would be bound by evaluating
-// this expression.
+// this expression. If the expression has with modifiers, the variables in the
+// with values must be safe before the expression can bind any variables.
 func (expr *Expr) OutputVars(safe VarSet) VarSet {
+	for _, w := range expr.With {
+		vis := NewVarVisitor().WithParams(VarVisitorParams{
+			SkipClosures:         true,
+			SkipRefHead:          true,
+			SkipBuiltinOperators: true,
+		})
+		Walk(vis, w.Value)
+		if len(vis.Vars().Diff(safe)) > 0 {
+			return VarSet{}
+		}
+	}
 	if !expr.Negated {
 		switch terms := expr.Terms.(type) {
 		case *Term:
@@ -653,6 +818,9 @@ func (expr *Expr) String() string {
 	case *Term:
 		buf = append(buf, t.String())
 	}
+	for _, w := range expr.With {
+		buf = append(buf, w.String())
+	}
 	return strings.Join(buf, " ")
 }
 
@@ -701,16 +869,32 @@ func (expr *Expr) outputVarsBuiltins(b *Builtin, safe VarSet) VarSet {
 
 	// Add vars in target positions to result.
 	for i, t := range terms[1:] {
-		if v, ok := t.Value.(Var); ok {
-			if b.IsTargetPos(i) {
-				o.Add(v)
-			}
+		if b.IsTargetPos(i) {
+			addTargetVars(t.Value, o)
 		}
 	}
 
 	return o
 }
 
+// addTargetVars adds the variables that are bound by unifying v with the output
+// of a built-in function to vs. Variables nested inside arrays and object
+// values are bound by unification.
+func addTargetVars(v Value, vs VarSet) {
+	switch v := v.(type) {
+	case Var:
+		vs.Add(v)
+	case Array:
+		for _, x := range v {
+			addTargetVars(x.Value, vs)
+		}
+	case Object:
+		for _, item := range v {
+			addTargetVars(item[1].Value, vs)
+		}
+	}
+}
+
 func (expr *Expr) outputVarsEquality(safe VarSet) VarSet {
 	ts := expr.Terms.([]*Term)
 	o := expr.outputVarsRefs()
@@ -721,13 +905,77 @@ func (expr *Expr) outputVarsEquality(safe VarSet) VarSet {
 
 func (expr *Expr) outputVarsRefs() VarSet {
 	o := VarSet{}
-	WalkRefs(expr, func(r Ref) bool {
+	f := func(r Ref) bool {
 		o.Update(r.OutputVars())
 		return false
-	})
+	}
+	// Refs in with modifiers are evaluated before the expression and
+	// therefore do not produce output vars.
+	switch ts := expr.Terms.(type) {
+	case *Term:
+		WalkRefs(ts, f)
+	case []*Term:
+		for _, t := range ts {
+			WalkRefs(t, f)
+		}
+	}
 	return o
 }
 
+// Compare returns an integer indicating whether w is less than, equal to, or
+// greater than other.
+func (w *With) Compare(other *With) int {
+	if cmp := Compare(w.Target, other.Target); cmp != 0 {
+		return cmp
+	}
+	return Compare(w.Value, other.Value)
+}
+
+// Copy returns a deep copy of w.
+func (w *With) Copy() *With {
+	cpy := *w
+	cpy.Target = w.Target.Copy()
+	cpy.Value = w.Value.Copy()
+	return &cpy
+}
+
+// Equal returns true if this With is equals the other With.
+func (w *With) Equal(other *With) bool {
+	return w.Compare(other) == 0
+}
+
+// Hash returns the hash code of the With.
+func (w *With) Hash() int {
+	return w.Target.Hash() + w.Value.Hash()
+}
+
+// Loc returns the location of the With in the definition.
+func (w *With) Loc() *Location {
+	return w.Location
+}
+
+func (w *With) String() string {
+	return "with " + w.Target.String() + " as " + w.Value.String()
+}
+
+func withSliceCompare(a, b []*With) int {
+	minLen := len(a)
+	if len(b) < minLen {
+		minLen = len(b)
+	}
+	for i := 0; i < minLen; i++ {
+		if cmp := a[i].Compare(b[i]); cmp != 0 {
+			return cmp
+		}
+	}
+	if len(a) < len(b) {
+		return -1
+	} else if len(b) < len(a) {
+		return 1
+	}
+	return 0
+}
+
 // NewBuiltinExpr creates a new Expr object with the supplied terms.
 // The builtin operator must be the first term.
 func NewBuiltinExpr(terms ...*Term) *Expr {
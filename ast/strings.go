@@ -16,14 +16,16 @@ func TypeName(x interface{}) string {
 
 // The type names provide consistent strings for types in error messages.
 const (
-	NullTypeName               = "null"
-	BooleanTypeName            = "boolean"
-	StringTypeName             = "string"
-	NumberTypeName             = "number"
-	VarTypeName                = "var"
-	RefTypeName                = "ref"
-	ArrayTypeName              = "array"
-	ObjectTypeName             = "object"
-	SetTypeName                = "set"
-	ArrayComprehensionTypeName = "arraycomprehension"
+	NullTypeName                = "null"
+	BooleanTypeName             = "boolean"
+	StringTypeName              = "string"
+	NumberTypeName              = "number"
+	VarTypeName                 = "var"
+	RefTypeName                 = "ref"
+	ArrayTypeName               = "array"
+	ObjectTypeName              = "object"
+	SetTypeName                 = "set"
+	ArrayComprehensionTypeName  = "arraycomprehension"
+	ObjectComprehensionTypeName = "objectcomprehension"
+	SetComprehensionTypeName    = "setcomprehension"
 )
@@ -159,6 +159,10 @@ func (term *Term) Copy() *Term {
 		cpy.Value = v.Copy()
 	case *ArrayComprehension:
 		cpy.Value = v.Copy()
+	case *ObjectComprehension:
+		cpy.Value = v.Copy()
+	case *SetComprehension:
+		cpy.Value = v.Copy()
 	}
 
 	return &cpy
@@ -215,6 +219,10 @@ func (term *Term) MarshalJSON() ([]byte, error) {
 		typ = "set"
 	case *ArrayComprehension:
 		typ = "array-comprehension"
+	case *ObjectComprehension:
+		typ = "object-comprehension"
+	case *SetComprehension:
+		typ = "set-comprehension"
 	}
 	d := map[string]interface{}{
 		"Type":  typ,
@@ -947,6 +955,95 @@ func (ac *ArrayComprehension) String() string {
 	return "[" + ac.Term.String() + " | " + ac.Body.String() + "]"
 }
 
+// ObjectComprehension represents an object comprehension as defined in the language.
+type ObjectComprehension struct {
+	Key   *Term
+	Value *Term
+	Body  Body
+}
+
+// ObjectComprehensionTerm creates a new Term with an ObjectComprehension value.
+func ObjectComprehensionTerm(key, value *Term, body Body) *Term {
+	return &Term{
+		Value: &ObjectComprehension{
+			Key:   key,
+			Value: value,
+			Body:  body,
+		},
+	}
+}
+
+// Copy returns a deep copy of oc.
+func (oc *ObjectComprehension) Copy() *ObjectComprehension {
+	cpy := *oc
+	cpy.Body = oc.Body.Copy()
+	cpy.Key = oc.Key.Copy()
+	cpy.Value = oc.Value.Copy()
+	return &cpy
+}
+
+// Equal returns true if oc is equal to other.
+func (oc *ObjectComprehension) Equal(other Value) bool {
+	return Compare(oc, other) == 0
+}
+
+// Hash returns the hash code of the Value.
+func (oc *ObjectComprehension) Hash() int {
+	return oc.Key.Hash() + oc.Value.Hash() + oc.Body.Hash()
+}
+
+// IsGround returns true if the Key, Value and Body are ground.
+func (oc *ObjectComprehension) IsGround() bool {
+	return oc.Key.IsGround() && oc.Value.IsGround() && oc.Body.IsGround()
+}
+
+func (oc *ObjectComprehension) String() string {
+	return "{" + oc.Key.String() + ": " + oc.Value.String() + " | " + oc.Body.String() + "}"
+}
+
+// SetComprehension represents a set comprehension as defined in the language.
+type SetComprehension struct {
+	Term *Term
+	Body Body
+}
+
+// SetComprehensionTerm creates a new Term with an SetComprehension value.
+func SetComprehensionTerm(term *Term, body Body) *Term {
+	return &Term{
+		Value: &SetComprehension{
+			Term: term,
+			Body: body,
+		},
+	}
+}
+
+// Copy returns a deep copy of sc.
+func (sc *SetComprehension) Copy() *SetComprehension {
+	cpy := *sc
+	cpy.Body = sc.Body.Copy()
+	cpy.Term = sc.Term.Copy()
+	return &cpy
+}
+
+// Equal returns true if sc is equal to other.
+func (sc *SetComprehension) Equal(other Value) bool {
+	return Compare(sc, other) == 0
+}
+
+// Hash returns the hash code of the Value.
+func (sc *SetComprehension) Hash() int {
+	return sc.Term.Hash() + sc.Body.Hash()
+}
+
+// IsGround returns true if the Term and Body are ground.
+func (sc *SetComprehension) IsGround() bool {
+	return sc.Term.IsGround() && sc.Body.IsGround()
+}
+
+func (sc *SetComprehension) String() string {
+	return "{" + sc.Term.String() + " | " + sc.Body.String() + "}"
+}
+
 func termSliceCopy(a []*Term) []*Term {
 	cpy := make([]*Term, len(a))
 	for i := range a {
@@ -1036,9 +1133,37 @@ func unmarshalExpr(expr *Expr, v map[string]interface{}) error {
 	default:
 		return fmt.Errorf(`ast: unable to unmarshal Terms field with type: %T (expected {"Value": ..., "Type": ...} or [{"Value": ..., "Type": ...}, ...])`, v["Terms"])
 	}
+	if x, ok := v["With"]; ok {
+		if sl, ok := x.([]interface{}); ok {
+			ws := make([]*With, len(sl))
+			for i := range sl {
+				var err error
+				ws[i], err = unmarshalWith(sl[i])
+				if err != nil {
+					return err
+				}
+			}
+			expr.With = ws
+		}
+	}
 	return nil
 }
 
+func unmarshalWith(i interface{}) (*With, error) {
+	if m, ok := i.(map[string]interface{}); ok {
+		if t, ok := m["Target"].(map[string]interface{}); ok {
+			if target, err := unmarshalTerm(t); err == nil {
+				if v, ok := m["Value"].(map[string]interface{}); ok {
+					if value, err := unmarshalTerm(v); err == nil {
+						return &With{Target: target, Value: value}, nil
+					}
+				}
+			}
+		}
+	}
+	return nil, fmt.Errorf("ast: unable to unmarshal with modifier")
+}
+
 func unmarshalExprIndex(expr *Expr, v map[string]interface{}) error {
 	if x, ok := v["Index"]; ok {
 		if n, ok := x.(json.Number); ok {
@@ -1149,6 +1274,43 @@ func unmarshalValue(d map[string]interface{}) (Value, error) {
 				}
 			}
 		}
+	case "object-comprehension":
+		if m, ok := v.(map[string]interface{}); ok {
+			if k, ok := m["Key"].(map[string]interface{}); ok {
+				if key, err := unmarshalTerm(k); err == nil {
+					if t, ok := m["Value"].(map[string]interface{}); ok {
+						if value, err := unmarshalTerm(t); err == nil {
+							if b, ok := m["Body"].([]interface{}); ok {
+								if body, err := unmarshalBody(b); err == nil {
+									buf := &ObjectComprehension{
+										Key:   key,
+										Value: value,
+										Body:  body,
+									}
+									return buf, nil
+								}
+							}
+						}
+					}
+				}
+			}
+		}
+	case "set-comprehension":
+		if m, ok := v.(map[string]interface{}); ok {
+			if t, ok := m["Term"].(map[string]interface{}); ok {
+				if term, err := unmarshalTerm(t); err == nil {
+					if b, ok := m["Body"].([]interface{}); ok {
+						if body, err := unmarshalBody(b); err == nil {
+							buf := &SetComprehension{
+								Term: term,
+								Body: body,
+							}
+							return buf, nil
+						}
+					}
+				}
+			}
+		}
 	}
 unmarshal_error:
 	return nil, fmt.Errorf("ast: unable to unmarshal term")
@@ -95,6 +95,15 @@ func Transform(t Transformer, x interface{}) (interface{}, error) {
 		if y.Body, err = transformBody(t, y.Body); err != nil {
 			return nil, err
 		}
+		if y.Else != nil {
+			rule, err := Transform(t, y.Else)
+			if err != nil {
+				return nil, err
+			}
+			if y.Else, ok = rule.(*Rule); !ok {
+				return nil, fmt.Errorf("illegal transform: %T != %T", y.Else, rule)
+			}
+		}
 		return y, nil
 	case Body:
 		for i, e := range y {
@@ -120,6 +129,23 @@ func Transform(t Transformer, x interface{}) (interface{}, error) {
 				return nil, err
 			}
 		}
+		for i, w := range y.With {
+			w, err := Transform(t, w)
+			if err != nil {
+				return nil, err
+			}
+			if y.With[i], ok = w.(*With); !ok {
+				return nil, fmt.Errorf("illegal transform: %T != %T", y.With[i], w)
+			}
+		}
+		return y, nil
+	case *With:
+		if y.Target, err = transformTerm(t, y.Target); err != nil {
+			return nil, err
+		}
+		if y.Value, err = transformTerm(t, y.Value); err != nil {
+			return nil, err
+		}
 		return y, nil
 	case Ref:
 		for i, term := range y {
@@ -164,6 +190,25 @@ func Transform(t Transformer, x interface{}) (interface{}, error) {
 			return nil, err
 		}
 		return y, nil
+	case *SetComprehension:
+		if y.Term, err = transformTerm(t, y.Term); err != nil {
+			return nil, err
+		}
+		if y.Body, err = transformBody(t, y.Body); err != nil {
+			return nil, err
+		}
+		return y, nil
+	case *ObjectComprehension:
+		if y.Key, err = transformTerm(t, y.Key); err != nil {
+			return nil, err
+		}
+		if y.Value, err = transformTerm(t, y.Value); err != nil {
+			return nil, err
+		}
+		if y.Body, err = transformBody(t, y.Body); err != nil {
+			return nil, err
+		}
+		return y, nil
 	default:
 		return y, nil
 	}
@@ -180,6 +225,131 @@ func TransformRefs(x interface{}, f func(Ref) (Value, error)) (interface{}, erro
 	return Transform(t, x)
 }
 
+// TransformBodies calls the function f on all bodies under x. This includes rule
+// bodies and comprehension bodies. Unlike Transform with a GenericTransformer,
+// only bodies are passed to f so package paths and imports are not modified.
+func TransformBodies(x interface{}, f func(Body) (Body, error)) (interface{}, error) {
+	t := &GenericTransformer{func(x interface{}) (interface{}, error) {
+		if b, ok := x.(Body); ok {
+			return f(b)
+		}
+		return x, nil
+	}}
+	return Transform(t, x)
+}
+
+// RenameVars returns a copy of x with variables replaced according to the
+// mapping. Variables that are not contained in the mapping are left unchanged.
+// Rule names, import aliases, built-in operators, and the heads of references
+// to the root documents are never renamed. The input x must be a *Module,
+// *Rule, Body, *Expr, or *Term.
+func RenameVars(x interface{}, mapping map[Var]Var) (interface{}, error) {
+	r := &varRenamer{mapping}
+	switch x := x.(type) {
+	case *Module:
+		cpy := x.Copy()
+		for _, rule := range cpy.Rules {
+			r.renameRule(rule)
+		}
+		return cpy, nil
+	case *Rule:
+		cpy := x.Copy()
+		r.renameRule(cpy)
+		return cpy, nil
+	case Body:
+		cpy := x.Copy()
+		r.renameBody(cpy)
+		return cpy, nil
+	case *Expr:
+		cpy := x.Copy()
+		r.renameExpr(cpy)
+		return cpy, nil
+	case *Term:
+		cpy := x.Copy()
+		r.renameTerm(cpy)
+		return cpy, nil
+	default:
+		return nil, fmt.Errorf("illegal rename: %T", x)
+	}
+}
+
+type varRenamer struct {
+	mapping map[Var]Var
+}
+
+func (r *varRenamer) renameRule(rule *Rule) {
+	if rule.Key != nil {
+		r.renameTerm(rule.Key)
+	}
+	if rule.Value != nil {
+		r.renameTerm(rule.Value)
+	}
+	r.renameBody(rule.Body)
+	if rule.Else != nil {
+		r.renameRule(rule.Else)
+	}
+}
+
+func (r *varRenamer) renameBody(body Body) {
+	for _, expr := range body {
+		r.renameExpr(expr)
+	}
+}
+
+func (r *varRenamer) renameExpr(expr *Expr) {
+	switch ts := expr.Terms.(type) {
+	case []*Term:
+		for _, term := range ts[1:] {
+			r.renameTerm(term)
+		}
+	case *Term:
+		r.renameTerm(ts)
+	}
+	for _, w := range expr.With {
+		r.renameTerm(w.Target)
+		r.renameTerm(w.Value)
+	}
+}
+
+func (r *varRenamer) renameTerm(term *Term) {
+	switch v := term.Value.(type) {
+	case Var:
+		if x, ok := r.mapping[v]; ok {
+			term.Value = x
+		}
+	case Ref:
+		for i := range v {
+			if i == 0 && (v[0].Equal(DefaultRootDocument) || v[0].Equal(RequestRootDocument)) {
+				continue
+			}
+			r.renameTerm(v[i])
+		}
+	case Array:
+		for i := range v {
+			r.renameTerm(v[i])
+		}
+	case Object:
+		for i := range v {
+			r.renameTerm(v[i][0])
+			r.renameTerm(v[i][1])
+		}
+	case *Set:
+		for _, elem := range *v {
+			r.renameTerm(elem)
+		}
+	case *ArrayComprehension:
+		r.renameTerm(v.Term)
+		r.renameBody(v.Body)
+	case *ObjectComprehension:
+		r.renameTerm(v.Key)
+		r.renameTerm(v.Value)
+		r.renameBody(v.Body)
+	case *SetComprehension:
+		r.renameTerm(v.Term)
+		r.renameBody(v.Body)
+	}
+}
+
 // GenericTransformer implements the Transformer interface to provide a utility
 // to transform AST nodes using a closure.
 type GenericTransformer struct {
@@ -54,3 +54,97 @@ func TestTransform(t *testing.T) {
 	}
 
 }
+
+func TestTransformBodies(t *testing.T) {
+	module := MustParseModule(`
+	package ex["this"]
+	import data.bar["this"] as qux
+	p :- "this" = "that"
+	p :- x = ["this" | "this"]
+	`)
+
+	result, err := TransformBodies(module, func(body Body) (Body, error) {
+		for i, expr := range body {
+			switch {
+			case expr.Equal(MustParseExpr(`"this" = "that"`)):
+				body[i] = MustParseExpr(`"that" = "that"`)
+			case expr.Equal(MustParseExpr(`"this"`)):
+				body[i] = MustParseExpr(`"that"`)
+			}
+		}
+		return body, nil
+	})
+
+	if err != nil {
+		t.Fatalf("Unexpected error during transform: %v", err)
+	}
+
+	resultMod, ok := result.(*Module)
+	if !ok {
+		t.Fatalf("Expected module from transform but got: %v", result)
+	}
+
+	expected := MustParseModule(`
+	package ex["this"]
+	import data.bar["this"] as qux
+	p :- "that" = "that"
+	p :- x = ["this" | "that"]
+	`)
+
+	if !expected.Equal(resultMod) {
+		t.Fatalf("Expected module:\n%v\n\nGot:\n%v\n", expected, resultMod)
+	}
+}
+
+func TestRenameVars(t *testing.T) {
+	rule := MustParseRule(`p[x] = y :- a[x] = y, z = [x | b[x]], not q[y], count({x | c[_] = x}, n)`)
+
+	result, err := RenameVars(rule, map[Var]Var{
+		Var("x"): Var("x1"),
+		Var("a"): Var("a1"),
+	})
+
+	if err != nil {
+		t.Fatalf("Unexpected error during rename: %v", err)
+	}
+
+	expected := MustParseRule(`p[x1] = y :- a1[x1] = y, z = [x1 | b[x1]], not q[y], count({x1 | c[_] = x1}, n)`)
+
+	if !expected.Equal(result.(*Rule)) {
+		t.Fatalf("Expected rule:\n%v\n\nGot:\n%v\n", expected, result)
+	}
+
+	mod := MustParseModule(`
+	package test
+	import request.x as x
+	p = y :- count([1], y), x[_] = 1, data.test.q[y], request.z = y
+	`)
+
+	result, err = RenameVars(mod, map[Var]Var{
+		Var("count"):   Var("c1"),
+		Var("eq"):      Var("eq2"),
+		Var("p"):       Var("p1"),
+		Var("x"):       Var("x1"),
+		Var("y"):       Var("y1"),
+		Var("data"):    Var("d1"),
+		Var("request"): Var("r1"),
+	})
+
+	if err != nil {
+		t.Fatalf("Unexpected error during rename: %v", err)
+	}
+
+	expectedMod := MustParseModule(`
+	package test
+	import request.x as x
+	p = y1 :- count([1], y1), x1[_] = 1, data.test.q[y1], request.z = y1
+	`)
+
+	if !expectedMod.Equal(result.(*Module)) {
+		t.Fatalf("Expected module:\n%v\n\nGot:\n%v\n", expectedMod, result)
+	}
+
+	if !mod.Rules[0].Value.Equal(VarTerm("y")) {
+		t.Fatalf("Expected input module to be unchanged but got:\n%v", mod)
+	}
+}
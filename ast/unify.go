@@ -12,6 +12,7 @@ func Unify(safe VarSet, a *Term, b *Term) VarSet {
 		safe:    safe,
 		unified: VarSet{},
 		unknown: map[Var]VarSet{},
+		waiting: map[Var]VarSet{},
 	}
 	u.unify(a, b)
 	return u.unified
@@ -21,6 +22,12 @@ type unifier struct {
 	safe    VarSet
 	unified VarSet
 	unknown map[Var]VarSet
+
+	// waiting contains variables that become safe once all of the variables
+	// they are waiting on are safe. Unlike unknown, the relationship is one
+	// way: the variables being waited on are not marked safe when the waiting
+	// variable is.
+	waiting map[Var]VarSet
 }
 
 func (u *unifier) isSafe(x Var) bool {
@@ -44,6 +51,8 @@ func (u *unifier) unify(a *Term, b *Term) {
 			}
 		case Array, Object:
 			u.unifyAll(a, b)
+		case *Set:
+			u.unifyFrom(a, b)
 		default:
 			u.markSafe(a)
 		}
@@ -64,6 +73,20 @@ func (u *unifier) unify(a *Term, b *Term) {
 			u.markAllSafe(b, a)
 		}
 
+	case *ObjectComprehension:
+		switch b := b.Value.(type) {
+		case Var:
+			u.markSafe(b)
+		case Object:
+			u.markAllSafe(b, a)
+		}
+
+	case *SetComprehension:
+		switch b := b.Value.(type) {
+		case Var:
+			u.markSafe(b)
+		}
+
 	case Array:
 		switch b := b.Value.(type) {
 		case Var:
@@ -78,11 +101,17 @@ func (u *unifier) unify(a *Term, b *Term) {
 			}
 		}
 
+	case *Set:
+		switch b := b.Value.(type) {
+		case Var:
+			u.unifyFrom(b, a)
+		}
+
 	case Object:
 		switch b := b.Value.(type) {
 		case Var:
 			u.unifyAll(b, a)
-		case Ref:
+		case Ref, *ObjectComprehension:
 			u.markAllSafe(a, b)
 		case Object:
 			if len(a) == len(b) {
@@ -114,6 +143,7 @@ func (u *unifier) markSafe(x Var) {
 	// Add dependencies of 'x' to safe set
 	vs := u.unknown[x]
 	delete(u.unknown, x)
+	delete(u.waiting, x)
 	for v := range vs {
 		u.markSafe(v)
 	}
@@ -128,6 +158,16 @@ func (u *unifier) markSafe(x Var) {
 			}
 		}
 	}
+
+	for v, deps := range u.waiting {
+		if deps.Contains(x) {
+			delete(deps, x)
+			if len(deps) == 0 {
+				delete(u.waiting, v)
+				u.markSafe(v)
+			}
+		}
+	}
 }
 
 func (u *unifier) markUnknown(a, b Var) {
@@ -154,6 +194,25 @@ func (u *unifier) unifyAll(a Var, b Value) {
 	}
 }
 
+// unifyFrom marks a as safe once all of the variables in b are safe. Unlike
+// unifyAll, variables in b are not marked safe if a is safe because the
+// evaluator does not bind variables contained in sets.
+func (u *unifier) unifyFrom(a Var, b Value) {
+	vis := u.varVisitor()
+	Walk(vis, b)
+	unsafe := vis.Vars().Diff(u.safe).Diff(u.unified)
+	if len(unsafe) == 0 {
+		u.markSafe(a)
+	} else if !u.isSafe(a) {
+		if _, ok := u.waiting[a]; !ok {
+			u.waiting[a] = NewVarSet()
+		}
+		for v := range unsafe {
+			u.waiting[a].Add(v)
+		}
+	}
+}
+
 func (u *unifier) varVisitor() *VarVisitor {
 	return NewVarVisitor().WithParams(VarVisitorParams{
 		SkipRefHead:          true,
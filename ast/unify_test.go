@@ -31,6 +31,25 @@ func TestUnify(t *testing.T) {
 		{"object/var-3", `{"x": 1, "y": x} = y`, "[]", "[]"},
 		{"object/uneven", `{"x": x, "y": 1} = {"x": y}`, "[]", "[]"},
 		{"object/uneven", `{"x": x, "y": 1} = {"x": y}`, "[x]", "[]"},
+		{"set/var", `{1, 2} = y`, "[]", "[y]"},
+		{"set/var (reversed)", `y = {1, 2}`, "[]", "[y]"},
+		{"set/var-2", `{1, x} = y`, "[x]", "[y]"},
+		{"set/var-2 (reversed)", `y = {1, x}`, "[x]", "[y]"},
+		{"set/var-3", `{1, x} = y`, "[y]", "[]"},
+		{"set/var-4", `{1, x} = y`, "[]", "[]"},
+		{"set/ref", `{x} = a[_]`, "[a]", "[]"},
+		{"set/set", `{x} = {1}`, "[]", "[]"},
+
+		// comprehension cases
+		{"array comprehension/var", "x = [y | a[_] = y]", "[a]", "[x]"},
+		{"array comprehension/var (reversed)", "[y | a[_] = y] = x", "[a]", "[x]"},
+		{"array comprehension/array", "[z, 1] = [y | a[_] = y]", "[a]", "[z]"},
+		{"object comprehension/var", "x = {k: v | a[k] = v}", "[a]", "[x]"},
+		{"object comprehension/var (reversed)", "{k: v | a[k] = v} = x", "[a]", "[x]"},
+		{"object comprehension/object", `{"a": z} = {k: v | a[k] = v}`, "[a]", "[z]"},
+		{"set comprehension/var", "x = {y | a[_] = y}", "[a]", "[x]"},
+		{"set comprehension/var (reversed)", "{y | a[_] = y} = x", "[a]", "[x]"},
+		{"set comprehension/set", "{z} = {y | a[_] = y}", "[a]", "[]"},
 
 		// transitive cases
 		{"trans/redundant", "[x, x] = [x, 0]", "[]", "[x]"},
@@ -41,6 +60,8 @@ func TestUnify(t *testing.T) {
 		{"trans/lazy", "[x, z, 2] = [1, [y, x], y]", "[]", "[x, y, z]"},
 		{"trans/redundant-nested", "[x, z, z] = [1, [y, x], [2, 1]]", "[]", "[x, y, z]"},
 		{"trans/bidirectional", "[x, z, y] = [[z,y], [1,y], 2]", "[]", "[x, y, z]"},
+		{"trans/set", "[y, x] = [{x}, 1]", "[]", "[x, y]"},
+		{"trans/set-2", "[y, 1] = [{x}, y]", "[]", "[y]"},
 		{"trans/occurs", "[x, z, y] = [[y,z], [y, 1], [2, x]]", "[]", "[]"},
 	}
 
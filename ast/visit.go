@@ -46,6 +46,9 @@ func Walk(v Visitor, x interface{}) {
 			Walk(w, x.Value.Value)
 		}
 		Walk(w, x.Body)
+		if x.Else != nil {
+			Walk(w, x.Else)
+		}
 	case Body:
 		for _, e := range x {
 			Walk(w, e)
@@ -59,6 +62,12 @@ func Walk(v Visitor, x interface{}) {
 		case *Term:
 			Walk(w, ts.Value)
 		}
+		for _, with := range x.With {
+			Walk(w, with)
+		}
+	case *With:
+		Walk(w, x.Target.Value)
+		Walk(w, x.Value.Value)
 	case Ref:
 		for _, t := range x {
 			Walk(w, t.Value)
@@ -79,6 +88,13 @@ func Walk(v Visitor, x interface{}) {
 	case *ArrayComprehension:
 		Walk(w, x.Term)
 		Walk(w, x.Body)
+	case *ObjectComprehension:
+		Walk(w, x.Key)
+		Walk(w, x.Value)
+		Walk(w, x.Body)
+	case *SetComprehension:
+		Walk(w, x.Term)
+		Walk(w, x.Body)
 	}
 }
 
@@ -87,7 +103,7 @@ func Walk(v Visitor, x interface{}) {
 func WalkClosures(x interface{}, f func(interface{}) bool) {
 	vis := &GenericVisitor{func(x interface{}) bool {
 		switch x.(type) {
-		case *ArrayComprehension:
+		case *ArrayComprehension, *ObjectComprehension, *SetComprehension:
 			return f(x)
 		}
 		return false
@@ -119,6 +135,19 @@ func WalkVars(x interface{}, f func(Var) bool) {
 	Walk(vis, x)
 }
 
+// WalkRules calls the function f on all rules under x. Rules chained with the
+// else keyword are visited after the rule that they follow. If the function f
+// returns true, AST nodes under the last node will not be visited.
+func WalkRules(x interface{}, f func(*Rule) bool) {
+	vis := &GenericVisitor{func(x interface{}) bool {
+		if r, ok := x.(*Rule); ok {
+			return f(r)
+		}
+		return false
+	}}
+	Walk(vis, x)
+}
+
 // WalkBodies calls the function f on all bodies under x. If the function f
 // returns true, AST nodes under the last node will not be visited.
 func WalkBodies(x interface{}, f func(Body) bool) {
@@ -200,7 +229,7 @@ func (vis *VarVisitor) Visit(v interface{}) Visitor {
 	}
 	if vis.params.SkipClosures {
 		switch v.(type) {
-		case *ArrayComprehension:
+		case *ArrayComprehension, *ObjectComprehension, *SetComprehension:
 			return nil
 		}
 	}
@@ -210,6 +239,9 @@ func (vis *VarVisitor) Visit(v interface{}) Visitor {
 				for _, t := range ts[1:] {
 					Walk(vis, t)
 				}
+				for _, w := range v.With {
+					Walk(vis, w)
+				}
 				return nil
 			}
 		}
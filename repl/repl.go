@@ -14,15 +14,18 @@ import (
 	"fmt"
 	"html/template"
 	"io"
+	"io/ioutil"
 	"os"
 	"sort"
 	"strings"
 
+	"github.com/ghodss/yaml"
 	"github.com/olekukonko/tablewriter"
 	"github.com/open-policy-agent/opa/ast"
 	"github.com/open-policy-agent/opa/storage"
 	"github.com/open-policy-agent/opa/topdown"
 	"github.com/open-policy-agent/opa/topdown/explain"
+	"github.com/open-policy-agent/opa/util"
 	"github.com/open-policy-agent/opa/version"
 	"github.com/peterh/liner"
 )
@@ -41,6 +44,7 @@ type REPL struct {
 	// inside the default module.
 	outputFormat string
 	explain      explainMode
+	metrics      bool
 	historyPath  string
 	initPrompt   string
 	bufferPrompt string
@@ -141,7 +145,7 @@ func (r *REPL) Loop(ctx context.Context) {
 		fmt.Fprintln(r.output, r.banner)
 	}
 
-	line.SetCompleter(r.complete)
+	line.SetCompleter(r.Complete)
 
 loop:
 	for true {
@@ -229,16 +233,22 @@ func (r *REPL) OneShot(ctx context.Context, line string) error {
 				return r.cmdDump(ctx, cmd.args)
 			case "json":
 				return r.cmdFormat("json")
+			case "load":
+				return r.cmdLoad(ctx, cmd.args)
 			case "show":
 				return r.cmdShow()
 			case "unset":
 				return r.cmdUnset(cmd.args)
 			case "pretty":
 				return r.cmdFormat("pretty")
+			case "yaml":
+				return r.cmdFormat("yaml")
 			case "trace":
 				return r.cmdTrace()
 			case "truth":
 				return r.cmdTruth()
+			case "metrics":
+				return r.cmdMetrics()
 			case "help":
 				return r.cmdHelp(cmd.args)
 			case "exit":
@@ -271,10 +281,14 @@ func (r *REPL) DisableUndefinedOutput(yes bool) *REPL {
 	return r
 }
 
-func (r *REPL) complete(line string) (c []string) {
+// Complete returns a sorted list of completion candidates for line. The
+// candidates include imports and rules defined in the REPL, rules defined by
+// policies in storage, and the top-level base documents in storage. Complete
+// does not evaluate or modify anything.
+func (r *REPL) Complete(line string) (c []string) {
 
 	ctx := context.Background()
-	txn, err := r.store.NewTransaction(ctx)
+	txn, err := r.store.NewTransactionWithParams(ctx, storage.NewTransactionParams().WithReadOnly(true))
 
 	if err != nil {
 		fmt.Fprintln(r.output, "error:", err)
@@ -283,23 +297,19 @@ func (r *REPL) complete(line string) (c []string) {
 
 	defer r.store.Close(ctx, txn)
 
+	set := map[string]struct{}{}
+
 	// add imports
 	for _, mod := range r.modules {
 		for _, imp := range mod.Imports {
-			path := imp.Name().String()
-			if strings.HasPrefix(path, line) {
-				c = append(c, path)
-			}
+			set[imp.Name().String()] = struct{}{}
 		}
 	}
 
 	// add virtual docs defined in repl
 	for _, mod := range r.modules {
 		for _, rule := range mod.Rules {
-			path := rule.Path(mod.Package.Path).String()
-			if strings.HasPrefix(path, line) {
-				c = append(c, path)
-			}
+			set[rule.Path(mod.Package.Path).String()] = struct{}{}
 		}
 	}
 
@@ -308,13 +318,27 @@ func (r *REPL) complete(line string) (c []string) {
 	// add virtual docs defined by policies
 	for _, mod := range mods {
 		for _, rule := range mod.Rules {
-			path := rule.Path(mod.Package.Path).String()
-			if strings.HasPrefix(path, line) {
-				c = append(c, path)
+			set[rule.Path(mod.Package.Path).String()] = struct{}{}
+		}
+	}
+
+	// add top-level base docs
+	if node, err := r.store.Read(ctx, txn, storage.Path{}); err == nil {
+		if obj, ok := node.(map[string]interface{}); ok {
+			for key := range obj {
+				set[ast.DefaultRootRef.Append(ast.StringTerm(key)).String()] = struct{}{}
 			}
 		}
 	}
 
+	for path := range set {
+		if strings.HasPrefix(path, line) {
+			c = append(c, path)
+		}
+	}
+
+	sort.Strings(c)
+
 	return c
 }
 
@@ -359,6 +383,60 @@ func (r *REPL) cmdHelp(args []string) error {
 	return nil
 }
 
+func (r *REPL) cmdLoad(ctx context.Context, args []string) error {
+
+	if len(args) == 0 || len(args) > 2 {
+		return newBadArgsErr("load <file> [path]: expects one or two arguments")
+	}
+
+	path := storage.Path{}
+
+	if len(args) == 2 {
+		ref, err := ast.ParseRef(args[1])
+		if err != nil || !ref.HasPrefix(ast.DefaultRootRef) || !ref.IsGround() {
+			return newBadArgsErr("load <file> [path]: path must be a ground reference to data (e.g., data.servers)")
+		}
+		path, err = storage.NewPathForRef(ref)
+		if err != nil {
+			return err
+		}
+	}
+
+	bs, err := ioutil.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+
+	var value interface{}
+	if err := util.UnmarshalJSON(bs, &value); err != nil {
+		return fmt.Errorf("%v: %v", args[0], err)
+	}
+
+	if len(path) == 0 {
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%v: root document must be an object", args[0])
+		}
+		for k, v := range obj {
+			if err := r.store.Write(ctx, r.txn, storage.AddOp, storage.Path{k}, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := r.makeDir(ctx, path[:len(path)-1]); err != nil {
+		return err
+	}
+
+	return r.store.Write(ctx, r.txn, storage.AddOp, path, value)
+}
+
+func (r *REPL) cmdMetrics() error {
+	r.metrics = !r.metrics
+	return nil
+}
+
 func (r *REPL) cmdShow() error {
 	module := r.modules[r.currentModuleID]
 	fmt.Fprintln(r.output, module)
@@ -607,8 +685,11 @@ func (r *REPL) evalBody(ctx context.Context, compiler *ast.Compiler, request ast
 	if len(body) == 1 {
 		expr := body[0]
 		if !expr.Negated {
-			if _, ok := expr.Terms.(*ast.Term); ok {
+			if term, ok := expr.Terms.(*ast.Term); ok {
 				if singleValue(body) {
+					if table, ok := r.partialDocTable(compiler, term); ok {
+						return r.evalQuery(ctx, compiler, request, table, true)
+					}
 					return r.evalTermSingleValue(ctx, compiler, request, body)
 				}
 				return r.evalTermMultiValue(ctx, compiler, request, body)
@@ -616,8 +697,19 @@ func (r *REPL) evalBody(ctx context.Context, compiler *ast.Compiler, request ast
 		}
 	}
 
+	return r.evalQuery(ctx, compiler, request, body, false)
+}
+
+// evalQuery evaluates body and prints the bindings of the variables in body for
+// each result. If there are no results, "false" is printed unless table is
+// true, in which case an empty table is printed. Bodies returned by
+// partialDocTable are evaluated with table set because no results means the
+// document is empty rather than undefined.
+func (r *REPL) evalQuery(ctx context.Context, compiler *ast.Compiler, request ast.Value, body ast.Body, table bool) error {
+
 	t := topdown.New(ctx, body, compiler, r.store, r.txn)
 	t.Request = request
+	t.Metrics = r.newMetrics()
 
 	var buf *topdown.BufferTracer
 
@@ -637,6 +729,7 @@ func (r *REPL) evalBody(ctx context.Context, compiler *ast.Compiler, request ast
 	var results []map[string]interface{}
 
 	// Execute query and accumulate results.
+	stopTimer := t.Metrics.StartTimer("eval_time")
 	err := topdown.Eval(t, func(t *topdown.Topdown) error {
 		var err error
 		row := map[string]interface{}{}
@@ -670,6 +763,8 @@ func (r *REPL) evalBody(ctx context.Context, compiler *ast.Compiler, request ast
 		return nil
 	})
 
+	stopTimer()
+
 	if buf != nil {
 		r.printTrace(ctx, compiler, *buf)
 	}
@@ -684,10 +779,14 @@ func (r *REPL) evalBody(ctx context.Context, compiler *ast.Compiler, request ast
 		} else {
 			fmt.Fprintln(r.output, "true")
 		}
+	} else if table {
+		r.printResults(getHeaderForBody(body), nil)
 	} else {
 		fmt.Fprintln(r.output, "false")
 	}
 
+	r.printMetrics(t.Metrics)
+
 	return nil
 }
 
@@ -737,6 +836,7 @@ func (r *REPL) evalTermSingleValue(ctx context.Context, compiler *ast.Compiler,
 
 	t := topdown.New(ctx, body, compiler, r.store, r.txn)
 	t.Request = request
+	t.Metrics = r.newMetrics()
 
 	var buf *topdown.BufferTracer
 
@@ -748,6 +848,7 @@ func (r *REPL) evalTermSingleValue(ctx context.Context, compiler *ast.Compiler,
 	var result interface{}
 	isTrue := false
 
+	stopTimer := t.Metrics.StartTimer("eval_time")
 	err := topdown.Eval(t, func(t *topdown.Topdown) error {
 		p := t.Locals.Get(outputVar.Value)
 		v, err := topdown.ValueToInterface(p, t)
@@ -759,6 +860,8 @@ func (r *REPL) evalTermSingleValue(ctx context.Context, compiler *ast.Compiler,
 		return nil
 	})
 
+	stopTimer()
+
 	if buf != nil {
 		r.printTrace(ctx, compiler, *buf)
 	}
@@ -768,11 +871,13 @@ func (r *REPL) evalTermSingleValue(ctx context.Context, compiler *ast.Compiler,
 	}
 
 	if isTrue {
-		r.printJSON(result)
+		r.printValue(result)
 	} else if !r.undefinedDisabled {
 		r.printUndefined()
 	}
 
+	r.printMetrics(t.Metrics)
+
 	return nil
 }
 
@@ -788,6 +893,7 @@ func (r *REPL) evalTermMultiValue(ctx context.Context, compiler *ast.Compiler, r
 
 	t := topdown.New(ctx, body, compiler, r.store, r.txn)
 	t.Request = request
+	t.Metrics = r.newMetrics()
 
 	var buf *topdown.BufferTracer
 
@@ -805,6 +911,7 @@ func (r *REPL) evalTermMultiValue(ctx context.Context, compiler *ast.Compiler, r
 	// as true.
 	includeValue := !r.isSetReference(compiler, term)
 
+	stopTimer := t.Metrics.StartTimer("eval_time")
 	err := topdown.Eval(t, func(t *topdown.Topdown) error {
 
 		result := map[string]interface{}{}
@@ -846,6 +953,8 @@ func (r *REPL) evalTermMultiValue(ctx context.Context, compiler *ast.Compiler, r
 		return nil
 	})
 
+	stopTimer()
+
 	if buf != nil {
 		r.printTrace(ctx, compiler, *buf)
 	}
@@ -868,9 +977,38 @@ func (r *REPL) evalTermMultiValue(ctx context.Context, compiler *ast.Compiler, r
 		r.printUndefined()
 	}
 
+	r.printMetrics(t.Metrics)
+
 	return nil
 }
 
+// makeDir creates objects in storage for each element of path that does not
+// exist.
+func (r *REPL) makeDir(ctx context.Context, path storage.Path) error {
+
+	if len(path) == 0 {
+		return nil
+	}
+
+	node, err := r.store.Read(ctx, r.txn, path)
+	if err == nil {
+		if _, ok := node.(map[string]interface{}); ok {
+			return nil
+		}
+		return fmt.Errorf("%v is not an object", path)
+	}
+
+	if !storage.IsNotFound(err) {
+		return err
+	}
+
+	if err := r.makeDir(ctx, path[:len(path)-1]); err != nil {
+		return err
+	}
+
+	return r.store.Write(ctx, r.txn, storage.AddOp, path, map[string]interface{}{})
+}
+
 func (r *REPL) getPrompt() string {
 	if len(r.buffer) > 0 {
 		return r.bufferPrompt
@@ -878,6 +1016,64 @@ func (r *REPL) getPrompt() string {
 	return r.initPrompt
 }
 
+// partialDocTable returns a body that binds the variables in the heads of the
+// rules that term refers to, e.g., given p[[x, y]] :- ..., the body binds x
+// and y for each element of p. The body is only returned if the output format
+// is pretty and term refers to a partial set or object document defined by
+// rules with the same head.
+func (r *REPL) partialDocTable(compiler *ast.Compiler, term *ast.Term) (ast.Body, bool) {
+
+	if r.outputFormat == "json" || r.outputFormat == "yaml" {
+		return nil, false
+	}
+
+	ref, ok := term.Value.(ast.Ref)
+	if !ok {
+		return nil, false
+	}
+
+	rs := compiler.GetRulesExact(ref)
+	if len(rs) == 0 {
+		return nil, false
+	}
+
+	kind := rs[0].DocKind()
+	if kind != ast.PartialSetDoc && kind != ast.PartialObjectDoc {
+		return nil, false
+	}
+
+	for _, rule := range rs[1:] {
+		if !rule.Key.Equal(rs[0].Key) || !rule.Value.Equal(rs[0].Value) {
+			return nil, false
+		}
+	}
+
+	key := ast.VarTerm(ast.WildcardPrefix + "key")
+	value := ast.VarTerm(ast.WildcardPrefix + "value")
+	elem := ast.RefTerm(append(ref.Copy(), key)...)
+
+	var body ast.Body
+
+	if kind == ast.PartialSetDoc {
+		body = ast.NewBody(
+			ast.NewExpr(elem),
+			ast.Equality.Expr(key, rs[0].Key),
+		)
+	} else {
+		body = ast.NewBody(
+			ast.Equality.Expr(elem, value),
+			ast.Equality.Expr(key, rs[0].Key),
+			ast.Equality.Expr(value, rs[0].Value),
+		)
+	}
+
+	if len(getHeaderForBody(body)) == 0 {
+		return nil, false
+	}
+
+	return body, true
+}
+
 // isSetReference returns true if term is a reference that refers to a set document.
 func (r *REPL) isSetReference(compiler *ast.Compiler, term *ast.Term) bool {
 	ref, ok := term.Value.(ast.Ref)
@@ -905,15 +1101,63 @@ func (r *REPL) loadHistory(prompt *liner.State) {
 	}
 }
 
+// newMetrics returns a new Metrics object if metrics are enabled.
+func (r *REPL) newMetrics() *topdown.Metrics {
+	if !r.metrics {
+		return nil
+	}
+	return topdown.NewMetrics()
+}
+
+func (r *REPL) printMetrics(metrics *topdown.Metrics) {
+	if metrics == nil {
+		return
+	}
+	all := metrics.All()
+	switch r.outputFormat {
+	case "json":
+		r.printJSON(all)
+	case "yaml":
+		r.printYAML(all)
+	default:
+		keys := []string{}
+		for k := range all {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		table := tablewriter.NewWriter(r.output)
+		table.SetAlignment(tablewriter.ALIGN_LEFT)
+		table.SetAutoFormatHeaders(false)
+		table.SetHeader([]string{"metric", "value"})
+		for _, k := range keys {
+			table.Append([]string{k, fmt.Sprint(all[k])})
+		}
+		table.Render()
+	}
+}
+
 func (r *REPL) printResults(keys []string, results []map[string]interface{}) {
 	switch r.outputFormat {
 	case "json":
 		r.printJSON(results)
+	case "yaml":
+		r.printYAML(results)
 	default:
 		r.printPretty(keys, results)
 	}
 }
 
+// printValue prints a single value. YAML is used if the output format is set
+// to YAML, otherwise the value is printed as JSON.
+func (r *REPL) printValue(x interface{}) {
+	switch r.outputFormat {
+	case "yaml":
+		r.printYAML(x)
+	default:
+		r.printJSON(x)
+	}
+}
+
 func (r *REPL) printJSON(x interface{}) {
 	buf, err := json.MarshalIndent(x, "", "  ")
 	if err != nil {
@@ -923,6 +1167,15 @@ func (r *REPL) printJSON(x interface{}) {
 	fmt.Fprintln(r.output, string(buf))
 }
 
+func (r *REPL) printYAML(x interface{}) {
+	buf, err := yaml.Marshal(x)
+	if err != nil {
+		fmt.Fprintln(r.output, err)
+		return
+	}
+	fmt.Fprint(r.output, string(buf))
+}
+
 func (r *REPL) printPretty(keys []string, results []map[string]interface{}) {
 	table := tablewriter.NewWriter(r.output)
 	table.SetAlignment(tablewriter.ALIGN_LEFT)
@@ -1008,8 +1261,11 @@ var builtin = [...]commandDesc{
 	{"unset", []string{"<var>"}, "undefine rules in currently active module"},
 	{"json", []string{}, "set output format to JSON"},
 	{"pretty", []string{}, "set output format to pretty"},
+	{"yaml", []string{}, "set output format to YAML"},
 	{"trace", []string{}, "toggle full trace"},
 	{"truth", []string{}, "toggle truth explanation"},
+	{"metrics", []string{}, "toggle evaluation metrics"},
+	{"load", []string{"<file>", "[path]"}, "load JSON file into storage"},
 	{"dump", []string{"[path]"}, "dump raw data in storage"},
 	{"help", []string{"[topic]"}, "print this message"},
 	{"exit", []string{}, "exit out of shell (or ctrl+d)"},
@@ -1107,7 +1363,7 @@ func singleValue(body ast.Body) bool {
 		return false
 	}
 	switch term.Value.(type) {
-	case *ast.ArrayComprehension:
+	case *ast.ArrayComprehension, *ast.ObjectComprehension, *ast.SetComprehension:
 		return true
 	default:
 		return term.IsGround()
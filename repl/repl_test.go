@@ -47,8 +47,9 @@ func TestComplete(t *testing.T) {
 	repl.OneShot(ctx, "s = 4")
 	buf.Reset()
 
-	result := repl.complete("")
+	result := repl.Complete("")
 	expected := []string{
+		"data.a",
 		"data.a.b.c.p",
 		"data.a.b.c.q",
 		"data.a.b.d.r",
@@ -63,7 +64,7 @@ func TestComplete(t *testing.T) {
 		t.Fatalf("Expected %v but got: %v", expected, result)
 	}
 
-	result = repl.complete("data.a.b")
+	result = repl.Complete("data.a.b")
 	expected = []string{
 		"data.a.b.c.p",
 		"data.a.b.c.q",
@@ -77,7 +78,7 @@ func TestComplete(t *testing.T) {
 		t.Fatalf("Expected %v but got: %v", expected, result)
 	}
 
-	result = repl.complete("data.a.b.c.p[x]")
+	result = repl.Complete("data.a.b.c.p[x]")
 	expected = nil
 
 	if !reflect.DeepEqual(result, expected) {
@@ -87,7 +88,7 @@ func TestComplete(t *testing.T) {
 	repl.OneShot(ctx, "import data.a.b.c.p as xyz")
 	repl.OneShot(ctx, "import data.a.b.d")
 
-	result = repl.complete("x")
+	result = repl.Complete("x")
 	expected = []string{
 		"xyz",
 	}
@@ -97,6 +98,49 @@ func TestComplete(t *testing.T) {
 	}
 }
 
+func TestCompleteRulesAndBaseDocs(t *testing.T) {
+	ctx := context.Background()
+	input := `{"servers": [], "networks": []}`
+	var data map[string]interface{}
+	if err := util.UnmarshalJSON([]byte(input), &data); err != nil {
+		panic(err)
+	}
+	store := storage.New(storage.InMemoryWithJSONConfig(data))
+	var buf bytes.Buffer
+	repl := newRepl(store, &buf)
+	repl.OneShot(ctx, "package opa.ex")
+	repl.OneShot(ctx, "p[x] :- data.servers[x]")
+	repl.OneShot(ctx, "p[x] :- x = 1")
+	repl.OneShot(ctx, "q = true")
+
+	result := repl.Complete("data.")
+	expected := []string{
+		"data.networks",
+		"data.opa.ex.p",
+		"data.opa.ex.q",
+		"data.repl.version",
+		"data.servers",
+	}
+
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("Expected %v but got: %v", expected, result)
+	}
+
+	result = repl.Complete("data.opa.ex")
+	expected = []string{
+		"data.opa.ex.p",
+		"data.opa.ex.q",
+	}
+
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("Expected %v but got: %v", expected, result)
+	}
+
+	if buf.String() != "" {
+		t.Fatalf("Expected no output but got: %v", buf.String())
+	}
+}
+
 func TestDump(t *testing.T) {
 	ctx := context.Background()
 	input := `{"a": [1,2,3,4]}`
@@ -152,6 +196,64 @@ func TestDumpPath(t *testing.T) {
 	}
 }
 
+func TestLoad(t *testing.T) {
+	ctx := context.Background()
+	store := storage.New(storage.InMemoryConfig())
+	var buffer bytes.Buffer
+	repl := newRepl(store, &buffer)
+
+	dir, err := ioutil.TempDir("", "load-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"data.json":    `{"a": [1,2,3,4], "b": {"c": true}}`,
+		"servers.json": `[{"id": "s1"}, {"id": "s2"}]`,
+		"bad.json":     `{"a": [1,2,3,4]`,
+	}
+
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := repl.OneShot(ctx, fmt.Sprintf("load %s", filepath.Join(dir, "data.json"))); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if err := repl.OneShot(ctx, fmt.Sprintf("load %s data.x.servers", filepath.Join(dir, "servers.json"))); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	repl.OneShot(ctx, "data.a[i] = 3")
+	expectOutput(t, buffer.String(), "+---+\n| i |\n+---+\n| 2 |\n+---+\n")
+
+	buffer.Reset()
+	repl.OneShot(ctx, "data.x.servers[i].id = \"s2\"")
+	expectOutput(t, buffer.String(), "+---+\n| i |\n+---+\n| 1 |\n+---+\n")
+
+	// Errors are reported and the store is left unmodified.
+	buffer.Reset()
+	if err := repl.OneShot(ctx, fmt.Sprintf("load %s data.a", filepath.Join(dir, "bad.json"))); err == nil {
+		t.Fatalf("Expected error for bad file")
+	}
+
+	if err := repl.OneShot(ctx, fmt.Sprintf("load %s", filepath.Join(dir, "servers.json"))); err == nil {
+		t.Fatalf("Expected error for non-object root document")
+	}
+
+	if err := repl.OneShot(ctx, fmt.Sprintf("load %s request.a", filepath.Join(dir, "servers.json"))); err == nil {
+		t.Fatalf("Expected error for bad path")
+	}
+
+	repl.OneShot(ctx, "data.b.c")
+	expectOutput(t, buffer.String(), "true\n")
+}
+
 func TestHelp(t *testing.T) {
 	topics["deadbeef"] = topicDesc{
 		fn: func(w io.Writer) error {
@@ -389,6 +491,24 @@ func TestOneShotJSON(t *testing.T) {
 	}
 }
 
+func TestOneShotYAML(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore()
+	var buffer bytes.Buffer
+	repl := New(store, "", &buffer, "yaml", "")
+	repl.OneShot(ctx, `p = {"a": [1, 2], "b": {"c": "d"}, "e": {true}} :- true`)
+	repl.OneShot(ctx, "p")
+	expectOutput(t, buffer.String(), "a:\n- 1\n- 2\nb:\n  c: d\ne:\n- true\n")
+
+	buffer.Reset()
+	repl.OneShot(ctx, "data.a[i].b.c[j] = 1")
+	expectOutput(t, buffer.String(), "- i: 1\n  j: 2\n")
+
+	buffer.Reset()
+	repl.OneShot(ctx, "p.foo")
+	expectOutput(t, buffer.String(), "undefined\n")
+}
+
 func TestEvalData(t *testing.T) {
 	ctx := context.Background()
 	store := newTestStore()
@@ -626,6 +746,80 @@ func TestEvalSingleTermMultiValueSetRef(t *testing.T) {
 	}
 }
 
+func TestEvalPrettyPartialDocs(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore()
+	var buffer bytes.Buffer
+	repl := New(store, "", &buffer, "pretty", "")
+	repl.OneShot(ctx, "p[[i, j]] :- data.a[i].b.c[j] = true")
+	repl.OneShot(ctx, `q[k] = v :- x = {"foo": 1, "bar": [2]}, x[k] = v`)
+	repl.OneShot(ctx, "r = 1 :- true")
+
+	// The order of rows depends on the order of elements in the document so
+	// only the lines are compared.
+	expectLines := func(expected string) {
+		result := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+		lines := strings.Split(strings.TrimSpace(expected), "\n")
+		sort.Strings(result)
+		sort.Strings(lines)
+		if !reflect.DeepEqual(lines, result) {
+			t.Fatalf("Expected output to contain lines:\n%v\n\nGot:\n\n%v\n", expected, buffer.String())
+		}
+	}
+
+	repl.OneShot(ctx, "p")
+	expectLines(`
++---+---+
+| i | j |
++---+---+
+| 0 | 0 |
+| 1 | 1 |
++---+---+`)
+
+	buffer.Reset()
+	repl.OneShot(ctx, "q")
+	expectLines(`
++-------+-----+
+|   k   |  v  |
++-------+-----+
+| "foo" | 1   |
+| "bar" | [2] |
++-------+-----+`)
+
+	// Complete documents are printed as single values.
+	buffer.Reset()
+	repl.OneShot(ctx, "r")
+	expectOutput(t, buffer.String(), "1\n")
+
+	// Partial documents are printed as single values in other formats.
+	buffer.Reset()
+	repl.outputFormat = "json"
+	repl.OneShot(ctx, "p")
+	if !strings.HasPrefix(buffer.String(), "[\n  [") {
+		t.Fatalf("Expected JSON array but got: %v", buffer.String())
+	}
+}
+
+func TestEvalPrettyEmptyPartialDoc(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore()
+	var buffer bytes.Buffer
+	repl := New(store, "", &buffer, "pretty", "")
+	repl.OneShot(ctx, `p[[i, j]] :- data.a[i].b.c[j] = "missing"`)
+
+	repl.OneShot(ctx, "p")
+	expectOutput(t, buffer.String(), `+---+---+
+| i | j |
++---+---+
++---+---+
+`)
+
+	buffer.Reset()
+	repl.outputFormat = "json"
+	repl.OneShot(ctx, "p")
+	expectOutput(t, buffer.String(), "[]\n")
+}
+
 func TestEvalRuleCompileError(t *testing.T) {
 	ctx := context.Background()
 	store := newTestStore()
@@ -788,8 +982,8 @@ Enter eq(data.a[i].b.c[j], x), eq(data.a[k].b.c[x], 1)
 | Eval eq(data.a[k].b.c[2], 1)
 | Fail eq(data.a[0].b.c[2], 1)
 | Redo eq(data.a[0].b.c[2], 1)
-| Exit eq(data.a[i].b.c[j], x), eq(data.a[k].b.c[x], 1)
-Redo eq(data.a[i].b.c[j], x), eq(data.a[k].b.c[x], 1)
+| Exit eq(data.a[i].b.c[j], x), eq(data.a[k].b.c[x], 1) {i: 0, j: 1, k: 1, x: 2}
+Redo eq(data.a[i].b.c[j], x), eq(data.a[k].b.c[x], 1) {i: 0, j: 1, k: 1, x: 2}
 | Redo eq(data.a[0].b.c[1], x)
 | Eval eq(data.a[k].b.c[false], 1)
 | Fail eq(data.a[k].b.c[false], 1)
@@ -827,7 +1021,7 @@ func TestEvalTruth(t *testing.T) {
 Enter eq(data.a[i].b.c[j], x), eq(data.a[k].b.c[x], 1)
 | Redo eq(data.a[0].b.c[0], x)
 | Redo eq(data.a[0].b.c[2], 1)
-| Exit eq(data.a[i].b.c[j], x), eq(data.a[k].b.c[x], 1)
+| Exit eq(data.a[i].b.c[j], x), eq(data.a[k].b.c[x], 1) {i: 0, j: 1, k: 1, x: 2}
 +---+---+---+---+
 | i | j | k | x |
 +---+---+---+---+
@@ -840,6 +1034,61 @@ Enter eq(data.a[i].b.c[j], x), eq(data.a[k].b.c[x], 1)
 	}
 }
 
+func TestEvalMetrics(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore()
+	var buffer bytes.Buffer
+	repl := newRepl(store, &buffer)
+	repl.outputFormat = "json"
+	repl.OneShot(ctx, "p[x] :- data.a[i].b.c[x] = true")
+	repl.OneShot(ctx, "metrics")
+	repl.OneShot(ctx, "p[x]")
+
+	decoder := json.NewDecoder(&buffer)
+
+	var result interface{}
+	if err := decoder.Decode(&result); err != nil {
+		t.Fatalf("Unexpected output format: %v", err)
+	}
+
+	var metrics map[string]interface{}
+	if err := decoder.Decode(&metrics); err != nil {
+		t.Fatalf("Expected metrics after result but got error: %v", err)
+	}
+
+	if _, ok := metrics["eval_time"]; !ok {
+		t.Fatalf("Expected eval_time in metrics but got: %v", metrics)
+	}
+
+	delete(metrics, "eval_time")
+
+	expected := map[string]interface{}{
+		"eval_exprs":  float64(2),
+		"eval_rules":  float64(1),
+		"store_reads": float64(2),
+	}
+
+	if !reflect.DeepEqual(expected, metrics) {
+		t.Fatalf("Expected %v but got: %v", expected, metrics)
+	}
+
+	// Metrics are printed in the current output format.
+	buffer.Reset()
+	repl.outputFormat = ""
+	repl.OneShot(ctx, "true")
+	output := buffer.String()
+
+	if !strings.HasPrefix(output, "true\n") || !strings.Contains(output, "metric") || !strings.Contains(output, "| eval_exprs | 1 ") {
+		t.Fatalf("Expected metrics table after result but got:\n%v", buffer.String())
+	}
+
+	// Metrics are not printed once toggled off.
+	buffer.Reset()
+	repl.OneShot(ctx, "metrics")
+	repl.OneShot(ctx, "true")
+	expectOutput(t, buffer.String(), "true\n")
+}
+
 func TestBuildHeader(t *testing.T) {
 	expr := ast.MustParseStatement(`[{"a": x, "b": data.a.b[y]}] = [{"a": 1, "b": 2}]`).(ast.Body)[0]
 	terms := expr.Terms.([]*ast.Term)
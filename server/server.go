@@ -434,7 +434,7 @@ func (s *Server) indexGet(w http.ResponseWriter, r *http.Request) {
 		t0 := time.Now()
 
 		var results interface{}
-		txn, err := s.store.NewTransaction(ctx)
+		txn, err := s.store.NewTransactionWithParams(ctx, storage.NewTransactionParams().WithReadOnly(true))
 
 		if err == nil {
 			var query ast.Body
@@ -481,7 +481,7 @@ func (s *Server) v1DataGet(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Prepare for query.
-	txn, err := s.store.NewTransaction(ctx)
+	txn, err := s.store.NewTransactionWithParams(ctx, storage.NewTransactionParams().WithReadOnly(true))
 	if err != nil {
 		handleErrorAuto(w, err)
 		return
@@ -569,6 +569,11 @@ func (s *Server) v1DataPatch(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if err := s.store.Commit(ctx, txn); err != nil {
+		handleErrorAuto(w, err)
+		return
+	}
+
 	handleResponse(w, 204, nil)
 }
 
@@ -617,6 +622,11 @@ func (s *Server) v1DataPut(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := s.store.Commit(ctx, txn); err != nil {
+		handleErrorAuto(w, err)
+		return
+	}
+
 	handleResponse(w, 204, nil)
 }
 
@@ -664,7 +674,7 @@ func (s *Server) v1PoliciesGet(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	txn, err := s.store.NewTransaction(ctx)
+	txn, err := s.store.NewTransactionWithParams(ctx, storage.NewTransactionParams().WithReadOnly(true))
 	if err != nil {
 		handleErrorAuto(w, err)
 		return
@@ -693,7 +703,7 @@ func (s *Server) v1PoliciesRawGet(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	txn, err := s.store.NewTransaction(ctx)
+	txn, err := s.store.NewTransactionWithParams(ctx, storage.NewTransactionParams().WithReadOnly(true))
 	if err != nil {
 		handleErrorAuto(w, err)
 		return
@@ -803,7 +813,7 @@ func (s *Server) v1QueryGet(w http.ResponseWriter, r *http.Request) {
 
 	qStr := qStrs[len(qStrs)-1]
 
-	txn, err := s.store.NewTransaction(ctx)
+	txn, err := s.store.NewTransactionWithParams(ctx, storage.NewTransactionParams().WithReadOnly(true))
 	if err != nil {
 		handleErrorAuto(w, err)
 		return
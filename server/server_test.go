@@ -245,6 +245,42 @@ func TestDataPutV1IfNoneMatch(t *testing.T) {
 	}
 }
 
+func TestDataPutV1CommitError(t *testing.T) {
+
+	ctx := context.Background()
+
+	dir, err := ioutil.TempDir("", "server-test")
+	if err != nil {
+		panic(err)
+	}
+
+	ds, err := storage.NewDiskStore(dir + "/data.json")
+	if err != nil {
+		panic(err)
+	}
+
+	// Remove the directory so that the documents cannot be persisted.
+	if err := os.RemoveAll(dir); err != nil {
+		panic(err)
+	}
+
+	store := storage.New(storage.Config{Builtin: ds})
+	server, err := New(ctx, store, ":8182", false)
+	if err != nil {
+		panic(err)
+	}
+
+	f := &fixture{
+		server:   server,
+		recorder: httptest.NewRecorder(),
+		t:        t,
+	}
+
+	if err := f.v1("PUT", "/data/a", "1", 500, ""); err != nil {
+		t.Fatalf("Expected commit error from PUT /data/a: %v", err)
+	}
+}
+
 func TestDataGetExplainFull(t *testing.T) {
 	f := newFixture(t)
 
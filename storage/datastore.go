@@ -6,8 +6,10 @@ package storage
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"sync"
 
 	"github.com/open-policy-agent/opa/util"
 
@@ -15,9 +17,31 @@ import (
 )
 
 // DataStore is a simple in-memory data store that implements the storage.Store interface.
+//
+// Reads performed in a transaction see a consistent snapshot of the data taken
+// when the transaction began. Writes copy the objects and arrays along the
+// written path and share the remaining documents with the snapshot. The copies
+// are private to the transaction and become visible to other transactions when
+// the transaction is closed. If the transaction is aborted, the writes are
+// discarded. Only one transaction may have pending writes at a time and a
+// transaction cannot write after another transaction has modified the store
+// since its snapshot was taken.
 type DataStore struct {
+	mtx      sync.Mutex
 	data     map[string]interface{}
+	version  uint64
 	triggers map[string]TriggerConfig
+	txns     map[uint64]*dataStoreTxn
+	writer   *dataStoreTxn
+}
+
+// dataStoreTxn contains the documents read by a transaction. If the
+// transaction has performed writes, the documents along the written paths are
+// private copies.
+type dataStoreTxn struct {
+	data    map[string]interface{}
+	version uint64
+	dirty   bool
 }
 
 // NewDataStore returns an empty DataStore.
@@ -25,6 +49,7 @@ func NewDataStore() *DataStore {
 	return &DataStore{
 		data:     map[string]interface{}{},
 		triggers: map[string]TriggerConfig{},
+		txns:     map[uint64]*dataStoreTxn{},
 	}
 }
 
@@ -58,34 +83,119 @@ func (ds *DataStore) ID() string {
 
 // Begin is called when a new transaction is started.
 func (ds *DataStore) Begin(ctx context.Context, txn Transaction, params TransactionParams) error {
-	// TODO(tsandall):
+	ds.mtx.Lock()
+	defer ds.mtx.Unlock()
+	ds.begin(txn)
 	return nil
 }
 
-// Close is called when a transaction is finished.
+func (ds *DataStore) begin(txn Transaction) *dataStoreTxn {
+	t := &dataStoreTxn{data: ds.data, version: ds.version}
+	ds.txns[txn.ID()] = t
+	return t
+}
+
+// Close is called when a transaction is finished. Writes performed by the
+// transaction become visible to other transactions.
 func (ds *DataStore) Close(ctx context.Context, txn Transaction) {
-	// TODO(tsandall):
+	ds.mtx.Lock()
+	defer ds.mtx.Unlock()
+	if t := ds.end(txn); t != nil && t.dirty {
+		ds.data = t.data
+		ds.version++
+	}
+}
+
+// Abort is called when a transaction is finished without committing. Writes
+// performed by the transaction are discarded.
+func (ds *DataStore) Abort(ctx context.Context, txn Transaction) {
+	ds.mtx.Lock()
+	defer ds.mtx.Unlock()
+	ds.end(txn)
+}
+
+func (ds *DataStore) end(txn Transaction) *dataStoreTxn {
+	t, ok := ds.txns[txn.ID()]
+	if !ok {
+		return nil
+	}
+	delete(ds.txns, txn.ID())
+	if ds.writer == t {
+		ds.writer = nil
+	}
+	return t
 }
 
 // Register adds a trigger.
 func (ds *DataStore) Register(id string, config TriggerConfig) error {
+	ds.mtx.Lock()
+	defer ds.mtx.Unlock()
 	ds.triggers[id] = config
 	return nil
 }
 
 // Unregister removes a trigger.
 func (ds *DataStore) Unregister(id string) {
+	ds.mtx.Lock()
+	defer ds.mtx.Unlock()
 	delete(ds.triggers, id)
 }
 
 // Read fetches a value from the in-memory store.
 func (ds *DataStore) Read(ctx context.Context, txn Transaction, path Path) (interface{}, error) {
+	ds.mtx.Lock()
+	defer ds.mtx.Unlock()
+	if txn != nil {
+		if t, ok := ds.txns[txn.ID()]; ok {
+			return get(t.data, path)
+		}
+	}
 	return get(ds.data, path)
 }
 
-// Write modifies a document referred to by path.
+// Write modifies a document referred to by path. If txn is nil, the write is
+// visible to other transactions immediately.
 func (ds *DataStore) Write(ctx context.Context, txn Transaction, op PatchOp, path Path, value interface{}) error {
-	return ds.patch(ctx, op, path, value)
+	ds.mtx.Lock()
+	defer ds.mtx.Unlock()
+
+	if txn == nil {
+		data := copyPath(ds.data, path)
+		if err := ds.patchRoot(ctx, &data, op, path, value); err != nil {
+			return err
+		}
+		ds.data = data
+		ds.version++
+		return nil
+	}
+
+	t, ok := ds.txns[txn.ID()]
+	if !ok {
+		t = ds.begin(txn)
+	}
+
+	if !t.dirty {
+		if t.version != ds.version || ds.writer != nil {
+			return writeConflictError(path)
+		}
+		t.dirty = true
+		ds.writer = t
+	}
+
+	data := copyPath(t.data, path)
+	if err := ds.patchRoot(ctx, &data, op, path, value); err != nil {
+		return err
+	}
+
+	t.data = data
+	return nil
+}
+
+// marshalJSON returns the JSON serialization of the current documents.
+func (ds *DataStore) marshalJSON() ([]byte, error) {
+	ds.mtx.Lock()
+	defer ds.mtx.Unlock()
+	return json.Marshal(ds.data)
 }
 
 func (ds *DataStore) String() string {
@@ -93,11 +203,19 @@ func (ds *DataStore) String() string {
 }
 
 func (ds *DataStore) patch(ctx context.Context, op PatchOp, path Path, value interface{}) error {
+	return ds.patchRoot(ctx, &ds.data, op, path, value)
+}
+
+// patchRoot applies a write to the documents referred to by root. If the write
+// replaces the root document, root is updated.
+func (ds *DataStore) patchRoot(ctx context.Context, root *map[string]interface{}, op PatchOp, path Path, value interface{}) error {
+
+	data := *root
 
 	if len(path) == 0 {
 		if op == AddOp || op == ReplaceOp {
 			if obj, ok := value.(map[string]interface{}); ok {
-				ds.data = obj
+				*root = obj
 				return nil
 			}
 			return invalidPatchErr(rootMustBeObjectMsg)
@@ -119,11 +237,11 @@ func (ds *DataStore) patch(ctx context.Context, op PatchOp, path Path, value int
 	var err error
 	switch op {
 	case AddOp:
-		err = add(ds.data, path, value)
+		err = add(data, path, value)
 	case RemoveOp:
-		err = remove(ds.data, path)
+		err = remove(data, path)
 	case ReplaceOp:
-		err = replace(ds.data, path, value)
+		err = replace(data, path, value)
 	}
 
 	if err != nil {
@@ -441,3 +559,76 @@ func checkArrayIndex(path Path, node []interface{}, v string) (int, error) {
 	}
 	return i, nil
 }
+
+// copyPath returns a shallow copy of data in which the objects and arrays that
+// would be modified by a write to path are also copied. The nodes along the
+// path up to, but not including, the last element are copied. Documents that
+// are not on the path are shared with data.
+func copyPath(data map[string]interface{}, path Path) map[string]interface{} {
+
+	root := copyObject(data)
+
+	if len(path) == 0 {
+		return root
+	}
+
+	var node interface{} = root
+
+	for _, key := range path[:len(path)-1] {
+		switch n := node.(type) {
+		case map[string]interface{}:
+			child, ok := n[key]
+			if !ok {
+				return root
+			}
+			node = copyNode(child)
+			n[key] = node
+		case []interface{}:
+			i, err := checkArrayIndex(path, n, key)
+			if err != nil {
+				return root
+			}
+			node = copyNode(n[i])
+			n[i] = node
+		default:
+			return root
+		}
+	}
+
+	return root
+}
+
+// copyNode returns a shallow copy of objects and arrays. Other values are
+// returned unchanged.
+func copyNode(node interface{}) interface{} {
+	switch node := node.(type) {
+	case map[string]interface{}:
+		return copyObject(node)
+	case []interface{}:
+		cpy := make([]interface{}, len(node))
+		copy(cpy, node)
+		return cpy
+	default:
+		return node
+	}
+}
+
+// copyDocument returns a deep copy of the document.
+func copyDocument(doc interface{}) interface{} {
+	switch doc := doc.(type) {
+	case map[string]interface{}:
+		cpy := make(map[string]interface{}, len(doc))
+		for k, v := range doc {
+			cpy[k] = copyDocument(v)
+		}
+		return cpy
+	case []interface{}:
+		cpy := make([]interface{}, len(doc))
+		for i, v := range doc {
+			cpy[i] = copyDocument(v)
+		}
+		return cpy
+	default:
+		return doc
+	}
+}
@@ -187,6 +187,129 @@ func TestDataStorePatch(t *testing.T) {
 
 }
 
+func TestDataStoreSnapshot(t *testing.T) {
+
+	ctx := context.Background()
+	ds := NewDataStoreFromJSONObject(loadSmallTestData())
+
+	txn1, txn2, txn3 := transaction(1), transaction(2), transaction(3)
+
+	if err := ds.Begin(ctx, txn1, TransactionParams{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	held, err := ds.Read(ctx, txn1, MustParsePath("/a"))
+	if err != nil {
+		t.Fatalf("Unexpected read error: %v", err)
+	}
+
+	if err := ds.Begin(ctx, txn2, TransactionParams{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if err := ds.Write(ctx, txn2, ReplaceOp, MustParsePath("/a/0"), json.Number("100")); err != nil {
+		t.Fatalf("Unexpected write error: %v", err)
+	}
+
+	if err := ds.Write(ctx, txn2, AddOp, MustParsePath("/b/v3"), "x"); err != nil {
+		t.Fatalf("Unexpected write error: %v", err)
+	}
+
+	if err := ds.Write(ctx, txn2, AddOp, MustParsePath("/g/c/-"), json.Number("5")); err != nil {
+		t.Fatalf("Unexpected write error: %v", err)
+	}
+
+	// Writes are visible in the transaction that performed them.
+	assertDataStoreRead(t, ds, txn2, "/a/0", `100`)
+
+	ds.Close(ctx, txn2)
+
+	// Reads in the first transaction see the documents as they were when the
+	// transaction began, including documents read before the write.
+	assertDataStoreRead(t, ds, txn1, "/a/0", `1`)
+	assertDataStoreRead(t, ds, txn1, "/b", `{"v1": "hello", "v2": "goodbye"}`)
+	assertDataStoreRead(t, ds, txn1, "/g/c", `[0, 0, 0, 4]`)
+
+	if !reflect.DeepEqual(held, loadExpectedResult(`[1,2,3,4]`)) {
+		t.Fatalf("Expected held document to be unmodified but got: %v", held)
+	}
+
+	// Transactions reading from a snapshot cannot write.
+	err = ds.Write(ctx, txn1, AddOp, MustParsePath("/c"), "x")
+	if storageErr, ok := err.(*Error); !ok || storageErr.Code != WriteConflictErr {
+		t.Fatalf("Expected write conflict error but got: %v", err)
+	}
+
+	// New transactions see the latest documents.
+	if err := ds.Begin(ctx, txn3, TransactionParams{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	assertDataStoreRead(t, ds, txn3, "/a/0", `100`)
+	assertDataStoreRead(t, ds, txn3, "/b/v3", `"x"`)
+	assertDataStoreRead(t, ds, txn3, "/g/c", `[0, 0, 0, 4, 5]`)
+
+	// Documents that were not written are shared with the previous snapshot.
+	before, _ := ds.Read(ctx, txn1, MustParsePath("/g/a"))
+	after, _ := ds.Read(ctx, txn3, MustParsePath("/g/a"))
+
+	if reflect.ValueOf(before).Pointer() != reflect.ValueOf(after).Pointer() {
+		t.Fatalf("Expected unmodified document to be shared")
+	}
+
+	ds.Close(ctx, txn1)
+	ds.Close(ctx, txn3)
+}
+
+func TestDataStoreAbort(t *testing.T) {
+
+	ctx := context.Background()
+	ds := NewDataStoreFromJSONObject(loadSmallTestData())
+
+	txn1, txn2 := transaction(1), transaction(2)
+
+	for _, txn := range []Transaction{txn1, txn2} {
+		if err := ds.Begin(ctx, txn, TransactionParams{}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	if err := ds.Write(ctx, txn1, ReplaceOp, MustParsePath("/a/0"), json.Number("100")); err != nil {
+		t.Fatalf("Unexpected write error: %v", err)
+	}
+
+	// Only one transaction may have pending writes.
+	err := ds.Write(ctx, txn2, AddOp, MustParsePath("/c"), "x")
+	if storageErr, ok := err.(*Error); !ok || storageErr.Code != WriteConflictErr {
+		t.Fatalf("Expected write conflict error but got: %v", err)
+	}
+
+	ds.Abort(ctx, txn1)
+
+	// Writes are discarded when the transaction is aborted so other
+	// transactions may write.
+	assertDataStoreRead(t, ds, txn2, "/a/0", `1`)
+
+	if err := ds.Write(ctx, txn2, AddOp, MustParsePath("/c"), "x"); err != nil {
+		t.Fatalf("Unexpected write error: %v", err)
+	}
+
+	ds.Close(ctx, txn2)
+
+	assertDataStoreRead(t, ds, nil, "/a/0", `1`)
+	assertDataStoreRead(t, ds, nil, "/c", `"x"`)
+}
+
+func assertDataStoreRead(t *testing.T, ds *DataStore, txn Transaction, path string, expected string) {
+	result, err := ds.Read(context.Background(), txn, MustParsePath(path))
+	if err != nil {
+		t.Fatalf("Unexpected read error: %v", err)
+	}
+	if e := loadExpectedResult(expected); !reflect.DeepEqual(result, e) {
+		t.Fatalf("Expected %v (txn: %v) to be %v but got: %v", path, txn, e, result)
+	}
+}
+
 func loadExpectedResult(input string) interface{} {
 	if len(input) == 0 {
 		return nil
@@ -0,0 +1,198 @@
+// Copyright 2016 The OPA Authors.  All rights reserved.
+// Use of this source code is governed by an Apache2
+// license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/open-policy-agent/opa/util"
+)
+
+// DiskStore is a data store that keeps documents in memory and persists them
+// to a JSON file. Documents are loaded from the file when the store is created.
+// Writes are flushed to the file when the transaction that performed them is
+// closed. If the process exits before the transaction is closed, the writes are
+// lost.
+//
+// If the documents cannot be flushed when a transaction is closed, subsequent
+// writes fail with the flush error until a call to Flush succeeds. The
+// documents remain modified in memory.
+type DiskStore struct {
+	mem  *DataStore
+	path string
+
+	mtx     sync.Mutex
+	writers map[uint64]struct{} // open transactions that have performed writes
+	err     error               // error returned by the last flush
+}
+
+// NewDiskStore returns a new DiskStore that persists documents to the file at
+// path. If the file exists, it must contain a JSON object. If the file does
+// not exist, the store is empty and the file is created on the first flush.
+func NewDiskStore(path string) (*DiskStore, error) {
+
+	ds := &DiskStore{
+		mem:     NewDataStore(),
+		path:    path,
+		writers: map[uint64]struct{}{},
+	}
+
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ds, nil
+		}
+		return nil, err
+	}
+
+	var data map[string]interface{}
+	if err := util.UnmarshalJSON(bs, &data); err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+
+	if data == nil {
+		return nil, fmt.Errorf("%v: %v", path, rootMustBeObjectMsg)
+	}
+
+	ds.mem.data = data
+
+	return ds, nil
+}
+
+// ID returns a unique identifier for the disk store.
+func (ds *DiskStore) ID() string {
+	return "org.openpolicyagent/disk"
+}
+
+// Begin is called when a new transaction is started.
+func (ds *DiskStore) Begin(ctx context.Context, txn Transaction, params TransactionParams) error {
+	return ds.mem.Begin(ctx, txn, params)
+}
+
+// Close is called when a transaction is finished. See Commit for details. The
+// flush error, if any, is discarded.
+func (ds *DiskStore) Close(ctx context.Context, txn Transaction) {
+	ds.Commit(ctx, txn)
+}
+
+// Commit is called when a transaction is finished. If the transaction modified
+// any documents, they are flushed to disk and the flush error is returned.
+// Committing a transaction that did not modify any documents does not flush
+// writes performed by other transactions that are still open.
+func (ds *DiskStore) Commit(ctx context.Context, txn Transaction) error {
+
+	ds.mem.Close(ctx, txn)
+
+	ds.mtx.Lock()
+	_, wrote := ds.writers[txn.ID()]
+	delete(ds.writers, txn.ID())
+	ds.mtx.Unlock()
+
+	if wrote {
+		return ds.Flush()
+	}
+
+	return nil
+}
+
+// Abort is called when a transaction is finished without committing. Writes
+// performed by the transaction are discarded and not flushed.
+func (ds *DiskStore) Abort(ctx context.Context, txn Transaction) {
+
+	ds.mem.Abort(ctx, txn)
+
+	ds.mtx.Lock()
+	delete(ds.writers, txn.ID())
+	ds.mtx.Unlock()
+}
+
+// Flush writes the documents to disk.
+func (ds *DiskStore) Flush() error {
+
+	ds.mtx.Lock()
+	defer ds.mtx.Unlock()
+
+	ds.err = ds.flush()
+	return ds.err
+}
+
+func (ds *DiskStore) flush() error {
+
+	bs, err := ds.mem.marshalJSON()
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file and rename it so that the file on disk always
+	// contains a complete snapshot, even if the process exits during the flush.
+	tmp, err := ioutil.TempFile(filepath.Dir(ds.path), filepath.Base(ds.path))
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(bs); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), ds.path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return nil
+}
+
+// Register adds a trigger.
+func (ds *DiskStore) Register(id string, config TriggerConfig) error {
+	return ds.mem.Register(id, config)
+}
+
+// Unregister removes a trigger.
+func (ds *DiskStore) Unregister(id string) {
+	ds.mem.Unregister(id)
+}
+
+// Read fetches a value from the disk store.
+func (ds *DiskStore) Read(ctx context.Context, txn Transaction, path Path) (interface{}, error) {
+	return ds.mem.Read(ctx, txn, path)
+}
+
+// Write modifies a document referred to by path. The modification is persisted
+// when the transaction is closed. If txn is nil, the modification is persisted
+// immediately.
+func (ds *DiskStore) Write(ctx context.Context, txn Transaction, op PatchOp, path Path, value interface{}) error {
+
+	ds.mtx.Lock()
+	if ds.err != nil {
+		defer ds.mtx.Unlock()
+		return internalError("%v: documents could not be persisted: %v", ds.path, ds.err)
+	}
+	if txn != nil {
+		ds.writers[txn.ID()] = struct{}{}
+	}
+	ds.mtx.Unlock()
+
+	if err := ds.mem.Write(ctx, txn, op, path, value); err != nil {
+		return err
+	}
+
+	if txn == nil {
+		return ds.Flush()
+	}
+
+	return nil
+}
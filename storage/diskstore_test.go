@@ -0,0 +1,303 @@
+// Copyright 2016 The OPA Authors.  All rights reserved.
+// Use of this source code is governed by an Apache2
+// license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDiskStorePersistence(t *testing.T) {
+
+	withTempDir(t, func(dir string) {
+
+		ctx := context.Background()
+		file := filepath.Join(dir, "data.json")
+
+		ds, err := NewDiskStore(file)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		store := New(Config{Builtin: ds})
+		txn := NewTransactionOrDie(ctx, store)
+
+		if err := store.Write(ctx, txn, AddOp, MustParsePath("/a"), loadExpectedResult(`{"b": [1,2,3]}`)); err != nil {
+			t.Fatalf("Unexpected write error: %v", err)
+		}
+
+		if err := store.Write(ctx, txn, AddOp, MustParsePath("/a/b/-"), loadExpectedResult(`4`)); err != nil {
+			t.Fatalf("Unexpected write error: %v", err)
+		}
+
+		store.Close(ctx, txn)
+
+		ds, err = NewDiskStore(file)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		store = New(Config{Builtin: ds})
+		txn = NewTransactionOrDie(ctx, store)
+		defer store.Close(ctx, txn)
+
+		result, err := store.Read(ctx, txn, MustParsePath("/a/b"))
+		if err != nil {
+			t.Fatalf("Unexpected read error: %v", err)
+		}
+
+		expected := loadExpectedResult(`[1,2,3,4]`)
+
+		if !reflect.DeepEqual(result, expected) {
+			t.Fatalf("Expected %v but got: %v", expected, result)
+		}
+
+		// Writes outside of a transaction are persisted immediately.
+		if err := ds.Write(ctx, nil, AddOp, MustParsePath("/c"), loadExpectedResult(`5`)); err != nil {
+			t.Fatalf("Unexpected write error: %v", err)
+		}
+
+		assertDiskStoreContains(t, file, `{"a": {"b": [1,2,3,4]}, "c": 5}`)
+	})
+}
+
+func TestDiskStoreUncommittedWrites(t *testing.T) {
+
+	withTempDir(t, func(dir string) {
+
+		ctx := context.Background()
+		file := filepath.Join(dir, "data.json")
+
+		if err := ioutil.WriteFile(file, []byte(`{"a": 1}`), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		ds, err := NewDiskStore(file)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		store := New(Config{Builtin: ds})
+		txn := NewTransactionOrDie(ctx, store)
+
+		if err := store.Write(ctx, txn, ReplaceOp, MustParsePath("/a"), loadExpectedResult(`2`)); err != nil {
+			t.Fatalf("Unexpected write error: %v", err)
+		}
+
+		// Writes are not visible to other instances until the transaction is
+		// closed.
+		assertDiskStoreContains(t, file, `{"a": 1}`)
+
+		store.Close(ctx, txn)
+
+		assertDiskStoreContains(t, file, `{"a": 2}`)
+
+		// Failed writes do not corrupt the file.
+		txn = NewTransactionOrDie(ctx, store)
+
+		if err := store.Write(ctx, txn, RemoveOp, MustParsePath("/x/y"), nil); !IsNotFound(err) {
+			t.Fatalf("Expected not found error but got: %v", err)
+		}
+
+		store.Close(ctx, txn)
+
+		assertDiskStoreContains(t, file, `{"a": 2}`)
+
+		// Closing a transaction that did not write does not flush writes
+		// performed by transactions that are still open.
+		writer, reader := transaction(100), transaction(101)
+
+		for _, txn := range []Transaction{writer, reader} {
+			if err := ds.Begin(ctx, txn, TransactionParams{}); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		}
+
+		if err := ds.Write(ctx, writer, ReplaceOp, MustParsePath("/a"), loadExpectedResult(`3`)); err != nil {
+			t.Fatalf("Unexpected write error: %v", err)
+		}
+
+		ds.Close(ctx, reader)
+
+		assertDiskStoreContains(t, file, `{"a": 2}`)
+
+		ds.Close(ctx, writer)
+
+		assertDiskStoreContains(t, file, `{"a": 3}`)
+
+		// Aborted writes are not flushed.
+		if err := ds.Begin(ctx, writer, TransactionParams{}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if err := ds.Write(ctx, writer, ReplaceOp, MustParsePath("/a"), loadExpectedResult(`4`)); err != nil {
+			t.Fatalf("Unexpected write error: %v", err)
+		}
+
+		ds.Abort(ctx, writer)
+
+		assertDiskStoreContains(t, file, `{"a": 3}`)
+
+		if err := ds.Flush(); err != nil {
+			t.Fatalf("Unexpected flush error: %v", err)
+		}
+
+		assertDiskStoreContains(t, file, `{"a": 3}`)
+	})
+}
+
+func TestDiskStoreFlushError(t *testing.T) {
+
+	ctx := context.Background()
+
+	dir, err := ioutil.TempDir("", "diskstore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := filepath.Join(dir, "data.json")
+
+	ds, err := NewDiskStore(file)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Remove the directory so that the documents cannot be persisted.
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	store := New(Config{Builtin: ds})
+	txn := NewTransactionOrDie(ctx, store)
+
+	if err := store.Write(ctx, txn, AddOp, MustParsePath("/a"), loadExpectedResult(`1`)); err != nil {
+		t.Fatalf("Unexpected write error: %v", err)
+	}
+
+	if err := store.Commit(ctx, txn); err == nil {
+		t.Fatalf("Expected commit error")
+	}
+
+	txn = NewTransactionOrDie(ctx, store)
+
+	err = store.Write(ctx, txn, AddOp, MustParsePath("/b"), loadExpectedResult(`2`))
+	if storageErr, ok := err.(*Error); !ok || storageErr.Code != InternalErr {
+		t.Fatalf("Expected internal error but got: %v", err)
+	}
+
+	store.Close(ctx, txn)
+
+	if err := ds.Flush(); err == nil {
+		t.Fatalf("Expected flush error")
+	}
+
+	// Writes succeed again once the documents are persisted.
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	if err := ds.Flush(); err != nil {
+		t.Fatalf("Unexpected flush error: %v", err)
+	}
+
+	assertDiskStoreContains(t, file, `{"a": 1}`)
+
+	txn = NewTransactionOrDie(ctx, store)
+
+	if err := store.Write(ctx, txn, AddOp, MustParsePath("/b"), loadExpectedResult(`2`)); err != nil {
+		t.Fatalf("Unexpected write error: %v", err)
+	}
+
+	store.Close(ctx, txn)
+
+	assertDiskStoreContains(t, file, `{"a": 1, "b": 2}`)
+}
+
+func TestDiskStoreMount(t *testing.T) {
+
+	withTempDir(t, func(dir string) {
+
+		ctx := context.Background()
+		file := filepath.Join(dir, "data.json")
+
+		if err := ioutil.WriteFile(file, []byte(`{"corge": [5,6,7,8]}`), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		ds, err := NewDiskStore(file)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		store := New(InMemoryWithJSONConfig(loadExpectedResult(`{"foo": {"bar": {}}}`).(map[string]interface{})))
+
+		if err := store.Mount(ds, MustParsePath("/foo/bar/qux")); err != nil {
+			t.Fatalf("Unexpected mount error: %v", err)
+		}
+
+		txn := NewTransactionOrDie(ctx, store)
+		defer store.Close(ctx, txn)
+
+		result, err := store.Read(ctx, txn, MustParsePath("/foo/bar/qux/corge/1"))
+		if err != nil {
+			t.Fatalf("Unexpected read error: %v", err)
+		}
+
+		if !reflect.DeepEqual(result, loadExpectedResult(`6`)) {
+			t.Fatalf("Expected 6 but got: %v", result)
+		}
+	})
+}
+
+func TestDiskStoreBadFile(t *testing.T) {
+
+	withTempDir(t, func(dir string) {
+
+		for _, content := range []string{`[1,2,3]`, `{"a": 1`} {
+
+			file := filepath.Join(dir, "data.json")
+
+			if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := NewDiskStore(file); err == nil {
+				t.Fatalf("Expected error for %v", content)
+			}
+		}
+	})
+}
+
+func assertDiskStoreContains(t *testing.T, file string, expected string) {
+
+	ds, err := NewDiskStore(file)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	result, err := ds.Read(context.Background(), nil, Path{})
+	if err != nil {
+		t.Fatalf("Unexpected read error: %v", err)
+	}
+
+	if !reflect.DeepEqual(result, loadExpectedResult(expected)) {
+		t.Fatalf("Expected %v but got: %v", expected, result)
+	}
+}
+
+func withTempDir(t *testing.T, f func(string)) {
+	dir, err := ioutil.TempDir("", "diskstore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	f(dir)
+}
@@ -45,6 +45,14 @@ const (
 	// WritesNotSupportedErr indicate the caller attempted to perform a write
 	// against a store that does not support them.
 	WritesNotSupportedErr = iota
+
+	// WriteConflictErr indicates the caller attempted to perform a write in a
+	// transaction after another transaction modified the store.
+	WriteConflictErr = iota
+
+	// ReadOnlyErr indicates the caller attempted to perform a write against a
+	// document under a read-only mount or in a read-only transaction.
+	ReadOnlyErr = iota
 )
 
 // Error is the error type returned by the storage layer.
@@ -144,6 +152,20 @@ func notFoundErrorf(f string, a ...interface{}) *Error {
 	}
 }
 
+func readOnlyError(path Path, mountPath Path) *Error {
+	return &Error{
+		Code:    ReadOnlyErr,
+		Message: fmt.Sprintf("bad path: %v, %v is mounted read-only", path, mountPath),
+	}
+}
+
+func readOnlyTransactionError() *Error {
+	return &Error{
+		Code:    ReadOnlyErr,
+		Message: "transaction is read-only",
+	}
+}
+
 func triggersNotSupportedError() *Error {
 	return &Error{
 		Code:    TriggersNotSupportedErr,
@@ -151,6 +173,13 @@ func triggersNotSupportedError() *Error {
 	}
 }
 
+func writeConflictError(path Path) *Error {
+	return &Error{
+		Code:    WriteConflictErr,
+		Message: fmt.Sprintf("bad path: %v, store modified by another transaction", path),
+	}
+}
+
 func writesNotSupportedError() *Error {
 	return &Error{
 		Code:    WritesNotSupportedErr,
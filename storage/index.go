@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"hash/fnv"
 	"strings"
+	"sync"
 
 	"github.com/open-policy-agent/opa/ast"
 	"github.com/open-policy-agent/opa/util"
@@ -33,7 +34,9 @@ import (
 // reference obtained by plugging bindings into the non-ground reference that is the
 // index key.
 //
+// The indices are safe for concurrent use.
 type indices struct {
+	mtx   sync.Mutex
 	table map[int]*indicesNode
 }
 
@@ -53,14 +56,31 @@ func newIndices() *indices {
 // Build initializes the references' index by walking the store for the reference and
 // creating the index that maps values to bindings.
 func (ind *indices) Build(ctx context.Context, store Store, txn Transaction, ref ast.Ref) error {
-	index := newBindingIndex()
 	ind.registerTriggers(store)
+	index, err := buildBindingIndex(ctx, store, txn, ref)
+	if err != nil {
+		return err
+	}
+	ind.insert(ref, index)
+	return nil
+}
+
+// buildBindingIndex returns a new index for the reference over the snapshot
+// identified by the transaction.
+func buildBindingIndex(ctx context.Context, store Store, txn Transaction, ref ast.Ref) (*bindingIndex, error) {
+	index := newBindingIndex()
 	err := iterStorage(ctx, store, txn, ref, ast.EmptyRef(), ast.NewValueMap(), func(bindings *ast.ValueMap, val interface{}) {
 		index.Add(val, bindings)
 	})
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return index, nil
+}
+
+func (ind *indices) insert(ref ast.Ref, index *bindingIndex) {
+	ind.mtx.Lock()
+	defer ind.mtx.Unlock()
 	hashCode := ref.Hash()
 	head := ind.table[hashCode]
 	entry := &indicesNode{
@@ -69,11 +89,12 @@ func (ind *indices) Build(ctx context.Context, store Store, txn Transaction, ref
 		next: head,
 	}
 	ind.table[hashCode] = entry
-	return nil
 }
 
 // Drop removes the index for the reference.
 func (ind *indices) Drop(ref ast.Ref) {
+	ind.mtx.Lock()
+	defer ind.mtx.Unlock()
 	hashCode := ref.Hash()
 	var prev *indicesNode
 	for entry := ind.table[hashCode]; entry != nil; entry = entry.next {
@@ -90,6 +111,8 @@ func (ind *indices) Drop(ref ast.Ref) {
 
 // Get returns the reference's index.
 func (ind *indices) Get(ref ast.Ref) *bindingIndex {
+	ind.mtx.Lock()
+	defer ind.mtx.Unlock()
 	node := ind.getNode(ref)
 	if node != nil {
 		return node.val
@@ -99,6 +122,8 @@ func (ind *indices) Get(ref ast.Ref) *bindingIndex {
 
 // Iter calls the iter function for each of the indices.
 func (ind *indices) Iter(iter func(ast.Ref, *bindingIndex) error) error {
+	ind.mtx.Lock()
+	defer ind.mtx.Unlock()
 	for _, head := range ind.table {
 		for entry := head; entry != nil; entry = entry.next {
 			if err := iter(entry.key, entry.val); err != nil {
@@ -110,6 +135,8 @@ func (ind *indices) Iter(iter func(ast.Ref, *bindingIndex) error) error {
 }
 
 func (ind *indices) String() string {
+	ind.mtx.Lock()
+	defer ind.mtx.Unlock()
 	buf := []string{}
 	for _, head := range ind.table {
 		for entry := head; entry != nil; entry = entry.next {
@@ -121,6 +148,8 @@ func (ind *indices) String() string {
 }
 
 func (ind *indices) dropAll(context.Context, Transaction, PatchOp, Path, interface{}) error {
+	ind.mtx.Lock()
+	defer ind.mtx.Unlock()
 	ind.table = map[int]*indicesNode{}
 	return nil
 }
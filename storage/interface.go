@@ -22,7 +22,10 @@ type Store interface {
 	// transaction.
 	Begin(ctx context.Context, txn Transaction, params TransactionParams) error
 
-	// Read is called to fetch a document referred to by path.
+	// Read is called to fetch a document referred to by path. The path may
+	// refer to an element nested inside of objects and arrays, e.g.,
+	// /a/b/3. Stores should resolve the entire path and return only the
+	// element so that callers do not have to read the enclosing documents.
 	Read(ctx context.Context, txn Transaction, path Path) (interface{}, error)
 
 	// Write is called to modify a document referred to by path.
@@ -33,6 +36,27 @@ type Store interface {
 	Close(ctx context.Context, txn Transaction)
 }
 
+// Aborter is implemented by stores that can discard the writes performed in a
+// transaction. When a transaction is aborted, stores that do not implement
+// Aborter are closed instead.
+type Aborter interface {
+
+	// Abort indicates a transaction has finished without being committed. The
+	// store should discard the writes performed in the transaction.
+	Abort(ctx context.Context, txn Transaction)
+}
+
+// Committer is implemented by stores that can fail to commit the writes
+// performed in a transaction. When a transaction is committed, stores that do
+// not implement Committer are closed instead.
+type Committer interface {
+
+	// Commit indicates a transaction has finished. The store should make the
+	// writes performed in the transaction visible and return an error if they
+	// could not be committed.
+	Commit(ctx context.Context, txn Transaction) error
+}
+
 // TransactionParams describes a new transaction.
 type TransactionParams struct {
 
@@ -40,6 +64,10 @@ type TransactionParams struct {
 	// transaction. The paths may be provided by the caller to hint to the
 	// storage layer that certain documents could be pre-loaded.
 	Paths []Path
+
+	// ReadOnly indicates that the transaction will not perform any writes.
+	// Read-only transactions do not wait for other transactions to finish.
+	ReadOnly bool
 }
 
 // NewTransactionParams returns a new TransactionParams object.
@@ -53,6 +81,13 @@ func (params TransactionParams) WithPaths(paths []Path) TransactionParams {
 	return params
 }
 
+// WithReadOnly returns a new TransactionParams object with the read-only flag
+// set.
+func (params TransactionParams) WithReadOnly(readOnly bool) TransactionParams {
+	params.ReadOnly = readOnly
+	return params
+}
+
 // PatchOp is the enumeration of supposed modifications.
 type PatchOp int
 
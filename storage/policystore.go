@@ -10,6 +10,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/open-policy-agent/opa/ast"
 	"github.com/pkg/errors"
@@ -18,7 +19,9 @@ import (
 // TODO(tsandall): update policy store to use correct transaction ids
 
 // policyStore provides a storage abstraction for policy definitions and modules.
+// The policy store is safe for concurrent use.
 type policyStore struct {
+	mtx       sync.Mutex
 	policyDir string
 	raw       map[string][]byte
 	modules   map[string]*ast.Module
@@ -57,6 +60,8 @@ func newPolicyStore(policyDir string) *policyStore {
 
 // List returns all of the modules.
 func (p *policyStore) List() map[string]*ast.Module {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
 	cpy := map[string]*ast.Module{}
 	for k, v := range p.modules {
 		cpy[k] = v
@@ -118,6 +123,9 @@ func (p *policyStore) Add(id string, mod *ast.Module, raw []byte, persist bool)
 		return fmt.Errorf("cannot persist without --policy-dir set")
 	}
 
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
 	p.raw[id] = raw
 	p.modules[id] = mod
 
@@ -144,6 +152,9 @@ func (p *policyStore) Remove(id string) error {
 		}
 	}
 
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
 	delete(p.raw, id)
 	delete(p.modules, id)
 
@@ -152,6 +163,8 @@ func (p *policyStore) Remove(id string) error {
 
 // Get returns the policy module for id.
 func (p *policyStore) Get(id string) (*ast.Module, error) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
 	mod, ok := p.modules[id]
 	if !ok {
 		return nil, notFoundErrorf("module not found: %v", id)
@@ -161,6 +174,8 @@ func (p *policyStore) Get(id string) (*ast.Module, error) {
 
 // GetRaw returns the raw content of the module for id.
 func (p *policyStore) GetRaw(id string) ([]byte, error) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
 	bs, ok := p.raw[id]
 	if !ok {
 		return nil, notFoundErrorf("definition not found: %v", id)
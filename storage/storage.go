@@ -41,24 +41,82 @@ func (c Config) WithPolicyDir(dir string) Config {
 }
 
 // Storage represents the policy engine's storage layer.
+//
+// Transactions are serialized: creating a transaction blocks until the
+// previous transaction has been closed or aborted. Read-only transactions are
+// the exception; they may be open concurrently with each other and with a
+// transaction that writes. Each transaction reads from the snapshot of the
+// stores taken when the stores were first accessed in the transaction.
 type Storage struct {
 	builtin     Store
-	indices     *indices
-	mounts      []*mount
 	policyStore *policyStore
 
-	// TODO(tsandall): currently we serialize all transactions; this means we
-	// only have to keep track of a single set of stores active in the
-	// transaction. In the future, we will allow concurrent transactions, in
-	// which case most of this will have to be refactored.
+	// writeMtx is held by the transaction that may write from the time it is
+	// created until it is closed or aborted.
+	writeMtx sync.Mutex
+
+	// mtx guards the mounts and the set of open transactions. The lock is only
+	// held while these fields are accessed.
 	mtx    sync.Mutex
-	active map[string]struct{}
+	mounts []*mount
+	txns   map[uint64]*txnState
 	txn    transaction
+
+	// indices contains the indices built over the latest committed version of
+	// the built-in store. The generation identifies that version and is
+	// incremented each time a transaction that wrote to the built-in store is
+	// closed. Transactions that read from an older version, or that have
+	// written to the built-in store, build indices of their own.
+	indexMtx   sync.Mutex
+	indices    *indices
+	generation uint64
+
+	// triggers contains the change triggers registered with the storage
+	// layer. The triggers are guarded by their own mutex so that they can be
+	// registered while a transaction is open.
+	triggerMtx sync.Mutex
+	triggers   map[string]ChangeTrigger
+}
+
+// txnState contains the state of an open transaction.
+type txnState struct {
+
+	// active contains the stores that have been notified that the transaction
+	// has started. When the transaction is closed, the set is consulted to
+	// determine which stores to notify.
+	active map[string]struct{}
+
+	// changes contains the before values of documents watched by triggers.
+	// Values are recorded when the documents are first modified in the
+	// transaction.
+	changes map[string]interface{}
+
+	// generation identifies the version of the built-in store read by the
+	// transaction. If wrote is true, the transaction has written to the
+	// built-in store.
+	generation uint64
+	wrote      bool
+
+	// indices contains the indices built for the transaction when the shared
+	// indices cannot be used.
+	indices *indices
+
+	// readOnly is true if the transaction does not hold the write lock.
+	readOnly bool
 }
 
 type mount struct {
-	path    Path
-	backend Store
+	path     Path
+	backend  Store
+	readOnly bool
+}
+
+// MountOptions contains options for mounting a store into the storage layer.
+type MountOptions struct {
+
+	// ReadOnly prevents writes to documents under the mount path. Writes that
+	// would modify documents under the mount path return an error.
+	ReadOnly bool
 }
 
 // New returns a new instance of the policy engine's storage layer.
@@ -67,7 +125,8 @@ func New(config Config) *Storage {
 		builtin:     config.Builtin,
 		indices:     newIndices(),
 		policyStore: newPolicyStore(config.PolicyDir),
-		active:      map[string]struct{}{},
+		txns:        map[uint64]*txnState{},
+		triggers:    map[string]ChangeTrigger{},
 	}
 }
 
@@ -112,17 +171,30 @@ func (s *Storage) GetPolicy(txn Transaction, id string) (*ast.Module, []byte, er
 // module already exists, it is replaced. If the persist flag is true, the
 // storage layer will attempt to write the raw policy module content to disk.
 func (s *Storage) InsertPolicy(txn Transaction, id string, module *ast.Module, raw []byte, persist bool) error {
+	if err := s.checkWritable(txn); err != nil {
+		return err
+	}
 	return s.policyStore.Add(id, module, raw, persist)
 }
 
 // DeletePolicy removes a policy from the storage layer.
 func (s *Storage) DeletePolicy(txn Transaction, id string) error {
+	if err := s.checkWritable(txn); err != nil {
+		return err
+	}
 	return s.policyStore.Remove(id)
 }
 
 // Mount adds a store into the storage layer at the given path. If the path
 // conflicts with an existing mount, an error is returned.
 func (s *Storage) Mount(backend Store, path Path) error {
+	return s.MountWithOptions(backend, path, MountOptions{})
+}
+
+// MountWithOptions adds a store into the storage layer at the given path with
+// the given options. If the path conflicts with an existing mount, an error is
+// returned.
+func (s *Storage) MountWithOptions(backend Store, path Path, opts MountOptions) error {
 
 	s.mtx.Lock()
 	defer s.mtx.Unlock()
@@ -134,14 +206,37 @@ func (s *Storage) Mount(backend Store, path Path) error {
 	}
 
 	m := &mount{
-		path:    path,
-		backend: backend,
+		path:     path,
+		backend:  backend,
+		readOnly: opts.ReadOnly,
 	}
 
 	s.mounts = append(s.mounts, m)
 	return nil
 }
 
+// Register adds a trigger that is fired when a transaction modifies the
+// document referred to by the trigger's path. Triggers are fired after the
+// transaction is closed. Writes that fail are not considered modifications. If
+// a trigger is already registered with the id, it is replaced.
+func (s *Storage) Register(id string, trigger ChangeTrigger) error {
+
+	s.triggerMtx.Lock()
+	defer s.triggerMtx.Unlock()
+
+	s.triggers[id] = trigger
+	return nil
+}
+
+// Unregister removes a trigger from the storage layer.
+func (s *Storage) Unregister(id string) {
+
+	s.triggerMtx.Lock()
+	defer s.triggerMtx.Unlock()
+
+	delete(s.triggers, id)
+}
+
 // Unmount removes a store from the storage layer. If the path does not locate
 // an existing mount, an error is returned.
 func (s *Storage) Unmount(path Path) error {
@@ -151,13 +246,24 @@ func (s *Storage) Unmount(path Path) error {
 
 	for i := range s.mounts {
 		if s.mounts[i].path.Equal(path) {
-			s.mounts = append(s.mounts[:i], s.mounts[i+1:]...)
+			// The mounts are copied because open transactions may be
+			// iterating over them.
+			mounts := make([]*mount, 0, len(s.mounts)-1)
+			mounts = append(mounts, s.mounts[:i]...)
+			s.mounts = append(mounts, s.mounts[i+1:]...)
 			return nil
 		}
 	}
 	return notFoundError(path, "unmount")
 }
 
+// getMounts returns the stores mounted into the storage layer.
+func (s *Storage) getMounts() []*mount {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.mounts
+}
+
 // Read fetches the value in storage referred to by path. The path may refer to
 // multiple stores in which case the storage layer will fetch the values from
 // each store and then stitch together the result.
@@ -170,7 +276,7 @@ func (s *Storage) Read(ctx context.Context, txn Transaction, path Path) (interfa
 
 	holes := []hole{}
 
-	for _, mount := range s.mounts {
+	for _, mount := range s.getMounts() {
 
 		// Check if read is against this mount (alone)
 		if path.HasPrefix(mount.path) {
@@ -203,39 +309,185 @@ func (s *Storage) Read(ctx context.Context, txn Transaction, path Path) (interfa
 		return nil, err
 	}
 
+	if len(holes) == 0 {
+		return doc, nil
+	}
+
 	// Fill holes in built-in document with any documents obtained from mounted
 	// stores. The mounts imply a hierarchy of objects, so traverse each mount
-	// path and create that hierarchy as necessary.
+	// path and create that hierarchy as necessary. The built-in document may
+	// be shared with other transactions, so the objects along the mount paths
+	// are copied instead of being modified.
+	root := copyObject(doc.(map[string]interface{}))
+
 	for _, hole := range holes {
 
 		p := hole.path
-		curr := doc.(map[string]interface{})
+		curr := root
 
 		for _, s := range p[:len(p)-1] {
 			next, ok := curr[s]
 			if !ok {
 				next = map[string]interface{}{}
-				curr[s] = next
 			}
-			curr = next.(map[string]interface{})
+			cpy := copyObject(next.(map[string]interface{}))
+			curr[s] = cpy
+			curr = cpy
 		}
 
 		curr[p[len(p)-1]] = hole.doc
 	}
 
-	return doc, nil
+	return root, nil
 }
 
-// Write updates a value in storage.
+// copyObject returns a shallow copy of obj.
+func copyObject(obj map[string]interface{}) map[string]interface{} {
+	cpy := make(map[string]interface{}, len(obj))
+	for k, v := range obj {
+		cpy[k] = v
+	}
+	return cpy
+}
+
+// Write updates a value in storage. The op is interpreted as in JSON Patch
+// (RFC 6902) except that add operations create intermediate objects that do
+// not exist. Remove and replace operations return a not found error if the
+// path does not refer to an existing document.
 func (s *Storage) Write(ctx context.Context, txn Transaction, op PatchOp, path Path, value interface{}) error {
 
+	for _, mount := range s.getMounts() {
+		if mount.readOnly && (path.HasPrefix(mount.path) || mount.path.HasPrefix(path)) {
+			return readOnlyError(path, mount.path)
+		}
+	}
+
+	if err := s.checkWritable(txn); err != nil {
+		return err
+	}
+
 	if err := s.lazyActivate(ctx, s.builtin, txn, nil); err != nil {
 		return err
 	}
 
+	state := s.getTxn(txn)
+
+	recorded, err := s.recordChanges(ctx, state, txn, path)
+	if err != nil {
+		return err
+	}
+
+	if state != nil {
+		// Indices built before the write may no longer be valid.
+		state.wrote = true
+		state.indices = nil
+	}
+
+	if err := s.write(ctx, txn, op, path, value); err != nil {
+		for _, id := range recorded {
+			delete(state.changes, id)
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (s *Storage) write(ctx context.Context, txn Transaction, op PatchOp, path Path, value interface{}) error {
+
+	if op == AddOp {
+		if err := s.makeDirs(ctx, txn, path); err != nil {
+			return err
+		}
+	}
+
 	return s.builtin.Write(ctx, txn, op, path, value)
 }
 
+// recordChanges saves the values of documents watched by triggers that may be
+// modified by a write to path. Values are only saved the first time the
+// document is modified in the transaction. The ids of the triggers that values
+// were saved for are returned.
+func (s *Storage) recordChanges(ctx context.Context, state *txnState, txn Transaction, path Path) (recorded []string, err error) {
+
+	if state == nil {
+		return nil, nil
+	}
+
+	for id, trigger := range s.getTriggers() {
+
+		if _, ok := state.changes[id]; ok {
+			continue
+		}
+
+		if !path.HasPrefix(trigger.Path) && !trigger.Path.HasPrefix(path) {
+			continue
+		}
+
+		before, err := s.readDocument(ctx, txn, trigger.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		state.changes[id] = before
+		recorded = append(recorded, id)
+	}
+
+	return recorded, nil
+}
+
+// getTriggers returns a copy of the registered triggers.
+func (s *Storage) getTriggers() map[string]ChangeTrigger {
+	s.triggerMtx.Lock()
+	defer s.triggerMtx.Unlock()
+	triggers := make(map[string]ChangeTrigger, len(s.triggers))
+	for id, trigger := range s.triggers {
+		triggers[id] = trigger
+	}
+	return triggers
+}
+
+// readDocument returns a copy of the document referred to by path or nil if
+// the document does not exist.
+func (s *Storage) readDocument(ctx context.Context, txn Transaction, path Path) (interface{}, error) {
+	doc, err := s.Read(ctx, txn, path)
+	if err != nil {
+		if IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return copyDocument(doc), nil
+}
+
+// makeDirs creates empty objects for each intermediate document along path
+// that does not exist. Objects are only created inside of other objects, e.g.,
+// if path refers into an array, the array is not modified.
+func (s *Storage) makeDirs(ctx context.Context, txn Transaction, path Path) error {
+
+	if len(path) == 0 || path[len(path)-1] == "-" {
+		return nil
+	}
+
+	for i := 1; i < len(path); i++ {
+		node, err := s.builtin.Read(ctx, txn, path[:i])
+		if err == nil {
+			if _, ok := node.(map[string]interface{}); !ok {
+				return nil
+			}
+			continue
+		}
+		if !IsNotFound(err) {
+			return err
+		}
+		if err := s.builtin.Write(ctx, txn, AddOp, path[:i], map[string]interface{}{}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // NewTransaction returns a new Transaction with default parameters.
 func (s *Storage) NewTransaction(ctx context.Context) (Transaction, error) {
 	return s.NewTransactionWithParams(ctx, TransactionParams{})
@@ -244,21 +496,84 @@ func (s *Storage) NewTransaction(ctx context.Context) (Transaction, error) {
 // NewTransactionWithParams returns a new Transaction.
 func (s *Storage) NewTransactionWithParams(ctx context.Context, params TransactionParams) (Transaction, error) {
 
+	state := &txnState{
+		active:   map[string]struct{}{},
+		changes:  map[string]interface{}{},
+		readOnly: params.ReadOnly,
+	}
+
+	if !state.readOnly {
+		s.writeMtx.Lock()
+	}
+
 	s.mtx.Lock()
 	s.txn++
 	txn := s.txn
+	s.txns[txn.ID()] = state
+	s.mtx.Unlock()
 
-	if err := s.notifyStoresBegin(ctx, txn, params.Paths); err != nil {
+	if err := s.notifyStoresBegin(ctx, state, txn, params.Paths); err != nil {
+		s.notifyStoresAbort(ctx, state, txn)
+		s.removeTxn(txn, state)
 		return nil, err
 	}
 
 	return txn, nil
 }
 
-// Close completes a transaction.
+// Close completes a transaction and commits the writes performed in it. See
+// Commit for details. Errors returned by the stores are discarded.
 func (s *Storage) Close(ctx context.Context, txn Transaction) {
-	s.notifyStoresClose(ctx, txn)
-	s.mtx.Unlock()
+	s.Commit(ctx, txn)
+}
+
+// Commit completes a transaction and commits the writes performed in it.
+// Triggers for documents modified by the transaction are fired after the
+// transaction is committed. If a store fails to commit the writes, the first
+// error is returned after all stores have been notified. Closing or committing
+// a transaction that has already completed has no effect.
+func (s *Storage) Commit(ctx context.Context, txn Transaction) error {
+
+	state := s.getTxn(txn)
+	if state == nil {
+		return nil
+	}
+
+	var events []changeEvent
+	triggers := s.getTriggers()
+
+	for id, before := range state.changes {
+		trigger, ok := triggers[id]
+		if !ok {
+			continue
+		}
+		after, err := s.readDocument(ctx, txn, trigger.Path)
+		if err != nil {
+			continue
+		}
+		events = append(events, changeEvent{trigger, before, after})
+	}
+
+	err := s.notifyStoresClose(ctx, state, txn)
+	s.removeTxn(txn, state)
+
+	for _, event := range events {
+		event.trigger.Callback(ctx, event.trigger.Path, event.before, event.after)
+	}
+
+	return err
+}
+
+// Abort completes a transaction without committing it. Writes performed in the
+// transaction are discarded and triggers are not fired. Stores that do not
+// implement Aborter are closed as if the transaction was committed.
+func (s *Storage) Abort(ctx context.Context, txn Transaction) {
+	state := s.getTxn(txn)
+	if state == nil {
+		return
+	}
+	s.notifyStoresAbort(ctx, state, txn)
+	s.removeTxn(txn, state)
 }
 
 // BuildIndex causes the storage layer to create an index for the given
@@ -274,13 +589,18 @@ func (s *Storage) BuildIndex(ctx context.Context, txn Transaction, ref ast.Ref)
 	// built-in. This will be revisited in the future. To determine the
 	// reference touches an external store, we collect the ground portion of
 	// the reference and see if it matches any mounts.
-	for _, mount := range s.mounts {
+	for _, mount := range s.getMounts() {
 		if path.HasPrefix(mount.path) || mount.path.HasPrefix(path) {
 			return indexingNotSupportedError()
 		}
 	}
 
-	return s.indices.Build(ctx, s.builtin, txn, ref)
+	if err := s.lazyActivate(ctx, s.builtin, txn, nil); err != nil {
+		return err
+	}
+
+	_, err = s.buildIndex(ctx, txn, ref)
+	return err
 }
 
 // IndexExists returns true if an index has been built for reference.
@@ -290,22 +610,124 @@ func (s *Storage) IndexExists(ref ast.Ref) bool {
 
 // Index invokes the iterator with bindings for each variable in the reference
 // that if plugged into the reference, would locate a document with a matching
-// value.
+// value. If the index does not exist for the snapshot identified by the
+// transaction, it is built.
 func (s *Storage) Index(txn Transaction, ref ast.Ref, value interface{}, iter func(*ast.ValueMap) error) error {
 
-	idx := s.indices.Get(ref)
+	ctx := context.Background()
+
+	if err := s.lazyActivate(ctx, s.builtin, txn, nil); err != nil {
+		return err
+	}
+
+	idx := s.getIndex(txn, ref)
 	if idx == nil {
-		return indexNotFoundError()
+		var err error
+		if idx, err = s.buildIndex(ctx, txn, ref); err != nil {
+			return err
+		}
 	}
 
 	return idx.Iter(value, iter)
 }
 
+// getIndex returns the index for the reference that is valid for the snapshot
+// identified by the transaction or nil if the index has not been built.
+func (s *Storage) getIndex(txn Transaction, ref ast.Ref) *bindingIndex {
+
+	state := s.getTxn(txn)
+
+	s.indexMtx.Lock()
+	defer s.indexMtx.Unlock()
+
+	if s.isCurrent(state) {
+		return s.indices.Get(ref)
+	}
+
+	if state.indices == nil {
+		return nil
+	}
+
+	return state.indices.Get(ref)
+}
+
+// buildIndex builds the index for the reference over the snapshot identified
+// by the transaction. If the snapshot is the latest committed version of the
+// built-in store, the index is shared with other transactions.
+func (s *Storage) buildIndex(ctx context.Context, txn Transaction, ref ast.Ref) (*bindingIndex, error) {
+
+	state := s.getTxn(txn)
+
+	s.indexMtx.Lock()
+	current := s.isCurrent(state)
+	generation := s.generation
+	s.indexMtx.Unlock()
+
+	if current {
+		if err := s.indices.registerTriggers(s.builtin); err != nil {
+			return nil, err
+		}
+	}
+
+	index, err := buildBindingIndex(ctx, s.builtin, txn, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	s.indexMtx.Lock()
+	defer s.indexMtx.Unlock()
+
+	// The built-in store may have been modified while the index was being
+	// built, in which case the index is only valid for this transaction.
+	if current && generation == s.generation {
+		s.indices.insert(ref, index)
+	} else if state != nil {
+		if state.indices == nil {
+			state.indices = newIndices()
+		}
+		state.indices.insert(ref, index)
+	}
+
+	return index, nil
+}
+
+// isCurrent returns true if the transaction reads the latest committed version
+// of the built-in store. Operations outside of a transaction always read the
+// latest version. The caller must hold the index lock.
+func (s *Storage) isCurrent(state *txnState) bool {
+	return state == nil || (!state.wrote && state.generation == s.generation)
+}
+
+func (s *Storage) getTxn(txn Transaction) *txnState {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.txns[txn.ID()]
+}
+
+// removeTxn forgets the transaction and releases the write lock if the
+// transaction held it.
+func (s *Storage) removeTxn(txn Transaction, state *txnState) {
+	s.mtx.Lock()
+	delete(s.txns, txn.ID())
+	s.mtx.Unlock()
+	if !state.readOnly {
+		s.writeMtx.Unlock()
+	}
+}
+
+// checkWritable returns an error if the transaction is read-only.
+func (s *Storage) checkWritable(txn Transaction) error {
+	if state := s.getTxn(txn); state != nil && state.readOnly {
+		return readOnlyTransactionError()
+	}
+	return nil
+}
+
 func (s *Storage) getStoreByID(id string) Store {
 	if id == s.builtin.ID() {
 		return s.builtin
 	}
-	for _, mount := range s.mounts {
+	for _, mount := range s.getMounts() {
 		if mount.backend.ID() == id {
 			return mount.backend
 		}
@@ -315,31 +737,45 @@ func (s *Storage) getStoreByID(id string) Store {
 
 func (s *Storage) lazyActivate(ctx context.Context, store Store, txn Transaction, paths []Path) error {
 
+	state := s.getTxn(txn)
+	if state == nil {
+		return nil
+	}
+
 	id := store.ID()
-	if _, ok := s.active[id]; ok {
+	if _, ok := state.active[id]; ok {
 		return nil
 	}
 
 	params := TransactionParams{}
+	return s.begin(ctx, state, store, txn, params)
+}
+
+// begin notifies the store that the transaction has started and adds the store
+// to the transaction's active set. The version of the built-in store read by
+// the transaction is recorded at the same time as the snapshot is taken.
+func (s *Storage) begin(ctx context.Context, state *txnState, store Store, txn Transaction, params TransactionParams) error {
+
+	if store.ID() == s.builtin.ID() {
+		s.indexMtx.Lock()
+		defer s.indexMtx.Unlock()
+		state.generation = s.generation
+	}
+
 	if err := store.Begin(ctx, txn, params); err != nil {
 		return err
 	}
 
-	s.active[id] = struct{}{}
+	state.active[store.ID()] = struct{}{}
 	return nil
 }
 
-func (s *Storage) notifyStoresBegin(ctx context.Context, txn Transaction, paths []Path) error {
+func (s *Storage) notifyStoresBegin(ctx context.Context, state *txnState, txn Transaction, paths []Path) error {
 
 	builtinID := s.builtin.ID()
 
-	// Initialize the active set. After a store has been notified that a
-	// transaction has started, it is added to this set. When a transaction is
-	// closed, the set is consulted to determine which stores to notify.
-	s.active = map[string]struct{}{}
-
 	mounts := map[string]Path{}
-	for _, mount := range s.mounts {
+	for _, mount := range s.getMounts() {
 		mounts[mount.backend.ID()] = mount.path
 	}
 
@@ -349,20 +785,51 @@ func (s *Storage) notifyStoresBegin(ctx context.Context, txn Transaction, paths
 		params := TransactionParams{
 			Paths: groupedPaths,
 		}
-		if err := s.getStoreByID(id).Begin(ctx, txn, params); err != nil {
+		if err := s.begin(ctx, state, s.getStoreByID(id), txn, params); err != nil {
 			return err
 		}
-		s.active[id] = struct{}{}
 	}
 
 	return nil
 }
 
-func (s *Storage) notifyStoresClose(ctx context.Context, txn Transaction) {
-	for id := range s.active {
-		s.getStoreByID(id).Close(ctx, txn)
+func (s *Storage) notifyStoresClose(ctx context.Context, state *txnState, txn Transaction) error {
+
+	// Writes to the built-in store are committed while holding the index lock
+	// so that no transaction can take a snapshot of the new version before the
+	// shared indices have been invalidated.
+	if state.wrote {
+		s.indexMtx.Lock()
+		defer s.indexMtx.Unlock()
+		s.generation++
+		s.indices.dropAll(ctx, txn, AddOp, nil, nil)
+	}
+
+	var result error
+
+	for id := range state.active {
+		store := s.getStoreByID(id)
+		if committer, ok := store.(Committer); ok {
+			if err := committer.Commit(ctx, txn); err != nil && result == nil {
+				result = err
+			}
+		} else {
+			store.Close(ctx, txn)
+		}
+	}
+
+	return result
+}
+
+func (s *Storage) notifyStoresAbort(ctx context.Context, state *txnState, txn Transaction) {
+	for id := range state.active {
+		store := s.getStoreByID(id)
+		if aborter, ok := store.(Aborter); ok {
+			aborter.Abort(ctx, txn)
+		} else {
+			store.Close(ctx, txn)
+		}
 	}
-	s.active = nil
 }
 
 // InsertPolicy upserts a policy module into storage inside a new transaction.
@@ -387,7 +854,7 @@ func DeletePolicy(ctx context.Context, store *Storage, id string) error {
 
 // GetPolicy returns a policy module from storage inside a new transaction.
 func GetPolicy(ctx context.Context, store *Storage, id string) (*ast.Module, []byte, error) {
-	txn, err := store.NewTransaction(ctx)
+	txn, err := store.NewTransactionWithParams(ctx, NewTransactionParams().WithReadOnly(true))
 	if err != nil {
 		return nil, nil, err
 	}
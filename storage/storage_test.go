@@ -5,9 +5,11 @@
 package storage
 
 import (
+	"encoding/json"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"context"
 
@@ -70,6 +72,456 @@ func TestStorageReadPlugin(t *testing.T) {
 
 }
 
+func TestStorageReadOnlyMount(t *testing.T) {
+
+	ctx := context.Background()
+
+	store := New(Config{
+		Builtin: NewDataStoreFromReader(strings.NewReader(`{"foo": {"bar": {}, "baz": 1}}`)),
+	})
+
+	mem := NewDataStoreFromReader(strings.NewReader(`{"corge": [5,6,7,8]}`))
+
+	if err := store.MountWithOptions(mem, MustParsePath("/foo/bar/qux"), MountOptions{ReadOnly: true}); err != nil {
+		t.Fatalf("Unexpected mount error: %v", err)
+	}
+
+	txn := NewTransactionOrDie(ctx, store)
+	defer store.Close(ctx, txn)
+
+	result, err := store.Read(ctx, txn, MustParsePath("/foo/bar/qux/corge/1"))
+	if err != nil {
+		t.Fatalf("Unexpected read error: %v", err)
+	} else if !reflect.DeepEqual(result, loadExpectedResult(`6`)) {
+		t.Fatalf("Expected 6 but got: %v", result)
+	}
+
+	tests := []struct {
+		note string
+		op   PatchOp
+		path string
+	}{
+		{"add under mount", AddOp, "/foo/bar/qux/grault"},
+		{"replace under mount", ReplaceOp, "/foo/bar/qux/corge/1"},
+		{"remove mount", RemoveOp, "/foo/bar/qux"},
+		{"replace parent of mount", ReplaceOp, "/foo/bar"},
+	}
+
+	for _, tc := range tests {
+		err := store.Write(ctx, txn, tc.op, MustParsePath(tc.path), loadExpectedResult(`1`))
+		if err, ok := err.(*Error); !ok || err.Code != ReadOnlyErr {
+			t.Errorf("%v: Expected read-only error but got: %v", tc.note, err)
+		}
+	}
+
+	// Writes outside of the mount are not affected.
+	if err := store.Write(ctx, txn, ReplaceOp, MustParsePath("/foo/baz"), loadExpectedResult(`2`)); err != nil {
+		t.Fatalf("Unexpected write error: %v", err)
+	}
+
+	result, err = store.Read(ctx, txn, MustParsePath("/foo/bar/qux"))
+	if err != nil {
+		t.Fatalf("Unexpected read error: %v", err)
+	} else if expected := loadExpectedResult(`{"corge": [5,6,7,8]}`); !reflect.DeepEqual(result, expected) {
+		t.Fatalf("Expected %v but got: %v", expected, result)
+	}
+}
+
+func TestStorageWrite(t *testing.T) {
+
+	tests := []struct {
+		note     string
+		op       PatchOp
+		path     string
+		value    string
+		expected string
+	}{
+		{"add nested", AddOp, "/x/y/z", `1`, `{"a": [1,2,3], "b": {"c": "d"}, "x": {"y": {"z": 1}}}`},
+		{"add nested existing", AddOp, "/b/e/f", `1`, `{"a": [1,2,3], "b": {"c": "d", "e": {"f": 1}}}`},
+		{"add array", AddOp, "/a/1", `9`, `{"a": [1,9,2,3], "b": {"c": "d"}}`},
+		{"append array", AddOp, "/a/-", `9`, `{"a": [1,2,3,9], "b": {"c": "d"}}`},
+		{"replace array element", ReplaceOp, "/a/1", `9`, `{"a": [1,9,3], "b": {"c": "d"}}`},
+		{"replace nested", ReplaceOp, "/b/c", `"e"`, `{"a": [1,2,3], "b": {"c": "e"}}`},
+		{"remove nested", RemoveOp, "/b/c", ``, `{"a": [1,2,3], "b": {}}`},
+		{"remove array element", RemoveOp, "/a/0", ``, `{"a": [2,3], "b": {"c": "d"}}`},
+	}
+
+	for _, tc := range tests {
+		ctx := context.Background()
+		store := New(Config{
+			Builtin: NewDataStoreFromReader(strings.NewReader(`{"a": [1,2,3], "b": {"c": "d"}}`)),
+		})
+		txn := NewTransactionOrDie(ctx, store)
+
+		if err := store.Write(ctx, txn, tc.op, MustParsePath(tc.path), loadExpectedResult(tc.value)); err != nil {
+			t.Errorf("%v: Unexpected write error: %v", tc.note, err)
+		} else {
+			result, err := store.Read(ctx, txn, Path{})
+			if err != nil {
+				t.Errorf("%v: Unexpected read error: %v", tc.note, err)
+			} else if expected := loadExpectedResult(tc.expected); !reflect.DeepEqual(result, expected) {
+				t.Errorf("%v: Expected %v but got: %v", tc.note, expected, result)
+			}
+		}
+
+		store.Close(ctx, txn)
+	}
+}
+
+func TestStorageWriteErrors(t *testing.T) {
+
+	tests := []struct {
+		note string
+		op   PatchOp
+		path string
+	}{
+		{"remove missing", RemoveOp, "/x/y"},
+		{"remove missing array element", RemoveOp, "/a/3"},
+		{"replace missing", ReplaceOp, "/b/x"},
+		{"replace missing nested", ReplaceOp, "/x/y/z"},
+		{"add inside missing array element", AddOp, "/a/5/x"},
+		{"add inside non-collection", AddOp, "/b/c/x"},
+		{"append missing", AddOp, "/x/-"},
+	}
+
+	for _, tc := range tests {
+		ctx := context.Background()
+		store := New(Config{
+			Builtin: NewDataStoreFromReader(strings.NewReader(`{"a": [1,2,3], "b": {"c": "d"}}`)),
+		})
+		txn := NewTransactionOrDie(ctx, store)
+
+		if err := store.Write(ctx, txn, tc.op, MustParsePath(tc.path), loadExpectedResult(`1`)); !IsNotFound(err) {
+			t.Errorf("%v: Expected not found error but got: %v", tc.note, err)
+		}
+
+		// Failed writes must not create intermediate objects.
+		result, err := store.Read(ctx, txn, Path{})
+		if err != nil {
+			t.Errorf("%v: Unexpected read error: %v", tc.note, err)
+		} else if expected := loadExpectedResult(`{"a": [1,2,3], "b": {"c": "d"}}`); !reflect.DeepEqual(result, expected) {
+			t.Errorf("%v: Expected %v but got: %v", tc.note, expected, result)
+		}
+
+		store.Close(ctx, txn)
+	}
+}
+
+func TestStorageTriggers(t *testing.T) {
+
+	ctx := context.Background()
+	store := New(Config{
+		Builtin: NewDataStoreFromReader(strings.NewReader(`{"a": {"b": {"c": 1}}, "x": 1}`)),
+	})
+
+	type event struct {
+		path   Path
+		before interface{}
+		after  interface{}
+	}
+
+	var events []event
+
+	store.Register("test", ChangeTrigger{
+		Path: MustParsePath("/a/b"),
+		Callback: func(ctx context.Context, path Path, before, after interface{}) {
+			// Callbacks are invoked after the transaction is closed so new
+			// transactions may be started.
+			txn := NewTransactionOrDie(ctx, store)
+			defer store.Close(ctx, txn)
+			events = append(events, event{path, before, after})
+		},
+	})
+
+	write := func(op PatchOp, path string, value string) {
+		txn := NewTransactionOrDie(ctx, store)
+		defer store.Close(ctx, txn)
+		store.Write(ctx, txn, op, MustParsePath(path), loadExpectedResult(value))
+	}
+
+	tests := []struct {
+		note     string
+		op       PatchOp
+		path     string
+		value    string
+		expected []event
+	}{
+		{"unrelated", ReplaceOp, "/x", `2`, nil},
+		{"sibling", AddOp, "/a/d", `2`, nil},
+		{"failed write", RemoveOp, "/a/b/d", ``, nil},
+		{"contained", AddOp, "/a/b/d", `2`, []event{
+			{MustParsePath("/a/b"), loadExpectedResult(`{"c": 1}`), loadExpectedResult(`{"c": 1, "d": 2}`)},
+		}},
+		{"exact", ReplaceOp, "/a/b", `{"e": 3}`, []event{
+			{MustParsePath("/a/b"), loadExpectedResult(`{"c": 1, "d": 2}`), loadExpectedResult(`{"e": 3}`)},
+		}},
+		{"container", RemoveOp, "/a", ``, []event{
+			{MustParsePath("/a/b"), loadExpectedResult(`{"e": 3}`), nil},
+		}},
+	}
+
+	for _, tc := range tests {
+		events = nil
+		write(tc.op, tc.path, tc.value)
+		if !reflect.DeepEqual(events, tc.expected) {
+			t.Errorf("%v: Expected %v but got: %v", tc.note, tc.expected, events)
+		}
+	}
+
+	// Multiple writes in the same transaction fire the trigger once.
+	events = nil
+	txn := NewTransactionOrDie(ctx, store)
+	store.Write(ctx, txn, AddOp, MustParsePath("/a/b/c"), loadExpectedResult(`1`))
+	store.Write(ctx, txn, AddOp, MustParsePath("/a/b/d"), loadExpectedResult(`2`))
+
+	if len(events) != 0 {
+		t.Fatalf("Expected triggers to fire after close but got: %v", events)
+	}
+
+	store.Close(ctx, txn)
+
+	expected := []event{
+		{MustParsePath("/a/b"), nil, loadExpectedResult(`{"c": 1, "d": 2}`)},
+	}
+
+	if !reflect.DeepEqual(events, expected) {
+		t.Fatalf("Expected %v but got: %v", expected, events)
+	}
+
+	// Aborted transactions do not fire triggers or modify documents.
+	events = nil
+	txn = NewTransactionOrDie(ctx, store)
+
+	if err := store.Write(ctx, txn, AddOp, MustParsePath("/a/b/e"), loadExpectedResult(`3`)); err != nil {
+		t.Fatalf("Unexpected write error: %v", err)
+	}
+
+	store.Abort(ctx, txn)
+
+	if len(events) != 0 {
+		t.Fatalf("Expected no events after abort but got: %v", events)
+	}
+
+	txn = NewTransactionOrDie(ctx, store)
+
+	if result, err := store.Read(ctx, txn, MustParsePath("/a/b")); err != nil {
+		t.Fatalf("Unexpected read error: %v", err)
+	} else if e := loadExpectedResult(`{"c": 1, "d": 2}`); !reflect.DeepEqual(result, e) {
+		t.Fatalf("Expected %v after abort but got: %v", e, result)
+	}
+
+	store.Close(ctx, txn)
+
+	// Unregistered triggers do not fire.
+	events = nil
+	store.Unregister("test")
+	write(AddOp, "/a/b/e", `3`)
+
+	if len(events) != 0 {
+		t.Fatalf("Expected no events after unregister but got: %v", events)
+	}
+}
+
+func TestStorageTriggersRegisterInTransaction(t *testing.T) {
+
+	ctx := context.Background()
+	store := New(Config{
+		Builtin: NewDataStoreFromReader(strings.NewReader(`{"x": 1}`)),
+	})
+
+	fired := 0
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		txn := NewTransactionOrDie(ctx, store)
+		store.Register("test", ChangeTrigger{
+			Path: MustParsePath("/x"),
+			Callback: func(context.Context, Path, interface{}, interface{}) {
+				fired++
+			},
+		})
+		store.Write(ctx, txn, ReplaceOp, MustParsePath("/x"), loadExpectedResult(`2`))
+		store.Close(ctx, txn)
+
+		txn = NewTransactionOrDie(ctx, store)
+		store.Unregister("test")
+		store.Write(ctx, txn, ReplaceOp, MustParsePath("/x"), loadExpectedResult(`3`))
+		store.Close(ctx, txn)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out registering triggers while a transaction is open")
+	}
+
+	if fired != 1 {
+		t.Fatalf("Expected trigger to fire once but got: %v", fired)
+	}
+}
+
+func TestStorageSnapshotReads(t *testing.T) {
+
+	ctx := context.Background()
+	store := New(Config{
+		Builtin: NewDataStoreFromReader(strings.NewReader(`{"a": 1}`)),
+	})
+
+	assertRead := func(txn Transaction, expected string) {
+		result, err := store.Read(ctx, txn, MustParsePath("/a"))
+		if err != nil {
+			t.Fatalf("Unexpected read error: %v", err)
+		}
+		if e := loadExpectedResult(expected); !reflect.DeepEqual(result, e) {
+			t.Fatalf("Expected %v but got: %v", e, result)
+		}
+	}
+
+	reader := newReadOnlyTransactionOrDie(ctx, store)
+	assertRead(reader, `1`)
+
+	// The writer is opened and closed while the reader is still open.
+	writer := NewTransactionOrDie(ctx, store)
+	if err := store.Write(ctx, writer, ReplaceOp, MustParsePath("/a"), loadExpectedResult(`2`)); err != nil {
+		t.Fatalf("Unexpected write error: %v", err)
+	}
+	assertRead(writer, `2`)
+	assertRead(reader, `1`)
+	store.Close(ctx, writer)
+
+	assertRead(reader, `1`)
+	store.Close(ctx, reader)
+
+	txn := NewTransactionOrDie(ctx, store)
+	defer store.Close(ctx, txn)
+
+	assertRead(txn, `2`)
+}
+
+func TestStorageWritersSerialized(t *testing.T) {
+
+	ctx := context.Background()
+	store := New(Config{
+		Builtin: NewDataStoreFromReader(strings.NewReader(`{"a": 1}`)),
+	})
+
+	path := MustParsePath("/a")
+
+	writer := NewTransactionOrDie(ctx, store)
+	if err := store.Write(ctx, writer, ReplaceOp, path, loadExpectedResult(`2`)); err != nil {
+		t.Fatalf("Unexpected write error: %v", err)
+	}
+
+	// The second writer waits until the first writer is closed and then
+	// observes its writes.
+	started := make(chan struct{})
+	done := make(chan interface{})
+
+	go func() {
+		close(started)
+		txn := NewTransactionOrDie(ctx, store)
+		defer store.Close(ctx, txn)
+		result, err := store.Read(ctx, txn, path)
+		if err != nil {
+			done <- err
+			return
+		}
+		if err := store.Write(ctx, txn, ReplaceOp, path, loadExpectedResult(`3`)); err != nil {
+			done <- err
+			return
+		}
+		done <- result
+	}()
+
+	<-started
+
+	select {
+	case result := <-done:
+		t.Fatalf("Expected second writer to wait but got: %v", result)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	store.Close(ctx, writer)
+
+	select {
+	case result := <-done:
+		if e := loadExpectedResult(`2`); !reflect.DeepEqual(result, e) {
+			t.Fatalf("Expected %v but got: %v", e, result)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for second writer")
+	}
+
+	// Read-only transactions cannot write.
+	reader := newReadOnlyTransactionOrDie(ctx, store)
+	defer store.Close(ctx, reader)
+
+	err := store.Write(ctx, reader, ReplaceOp, path, loadExpectedResult(`4`))
+	if storageErr, ok := err.(*Error); !ok || storageErr.Code != ReadOnlyErr {
+		t.Fatalf("Expected read-only error but got: %v", err)
+	}
+
+	err = store.InsertPolicy(reader, "test", ast.MustParseModule("package test"), nil, false)
+	if storageErr, ok := err.(*Error); !ok || storageErr.Code != ReadOnlyErr {
+		t.Fatalf("Expected read-only error but got: %v", err)
+	}
+
+	result, err := store.Read(ctx, reader, path)
+	if err != nil {
+		t.Fatalf("Unexpected read error: %v", err)
+	}
+	if e := loadExpectedResult(`3`); !reflect.DeepEqual(result, e) {
+		t.Fatalf("Expected %v but got: %v", e, result)
+	}
+}
+
+func TestStorageIndexingSnapshots(t *testing.T) {
+
+	ctx := context.Background()
+	store := New(Config{
+		Builtin: NewDataStoreFromReader(strings.NewReader(`{"a": [1, 2]}`)),
+	})
+
+	ref := ast.MustParseRef("data.a[i]")
+
+	assertIndex := func(txn Transaction, value interface{}, expected int) {
+		found := 0
+		err := store.Index(txn, ref, value, func(*ast.ValueMap) error {
+			found++
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Unexpected index error: %v", err)
+		}
+		if found != expected {
+			t.Fatalf("Expected %v bindings for %v but got: %v", expected, value, found)
+		}
+	}
+
+	reader := newReadOnlyTransactionOrDie(ctx, store)
+	if err := store.BuildIndex(ctx, reader, ref); err != nil {
+		t.Fatalf("Unexpected build error: %v", err)
+	}
+
+	writer := NewTransactionOrDie(ctx, store)
+	if err := store.Write(ctx, writer, AddOp, MustParsePath("/a/-"), json.Number("3")); err != nil {
+		t.Fatalf("Unexpected write error: %v", err)
+	}
+	assertIndex(writer, json.Number("3"), 1)
+	store.Close(ctx, writer)
+
+	// The reader must not observe the index built over the new version.
+	txn := NewTransactionOrDie(ctx, store)
+	assertIndex(txn, json.Number("3"), 1)
+	assertIndex(reader, json.Number("3"), 0)
+	assertIndex(reader, json.Number("1"), 1)
+
+	store.Close(ctx, txn)
+	store.Close(ctx, reader)
+}
+
 func TestStorageIndexingBasicUpdate(t *testing.T) {
 
 	refA := ast.MustParseRef("data.a[i]")
@@ -110,18 +562,28 @@ func TestStorageTransactionManagement(t *testing.T) {
 		t.Fatalf("Unexpected error: %v", err)
 	}
 
-	if !reflect.DeepEqual(store.active, map[string]struct{}{mock.ID(): struct{}{}}) {
-		t.Fatalf("Expected active to contain exactly one element but got: %v", store.active)
+	active := store.txns[txn.ID()].active
+
+	if !reflect.DeepEqual(active, map[string]struct{}{mock.ID(): struct{}{}}) {
+		t.Fatalf("Expected active to contain exactly one element but got: %v", active)
 	}
 
 	store.Close(ctx, txn)
 
-	if len(store.active) != 0 {
-		t.Fatalf("Expected active to be reset but got: %v", store.active)
+	if len(store.txns) != 0 {
+		t.Fatalf("Expected transaction state to be reset but got: %v", store.txns)
 	}
 
 }
 
+func newReadOnlyTransactionOrDie(ctx context.Context, store *Storage) Transaction {
+	txn, err := store.NewTransactionWithParams(ctx, NewTransactionParams().WithReadOnly(true))
+	if err != nil {
+		panic(err)
+	}
+	return txn
+}
+
 type mockStore struct {
 	WritesNotSupported
 	TriggersNotSupported
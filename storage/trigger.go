@@ -44,3 +44,29 @@ func (TriggersNotSupported) Register(string, TriggerConfig) error {
 func (TriggersNotSupported) Unregister(string) {
 
 }
+
+// ChangeCallback defines the interface that callers can implement to be
+// notified when a document is modified. The before and after values are the
+// values of the document at the start and end of the transaction. If the
+// document did not exist, the value is nil.
+type ChangeCallback func(ctx context.Context, path Path, before interface{}, after interface{})
+
+// ChangeTrigger contains the configuration for a trigger registered with the
+// storage layer.
+type ChangeTrigger struct {
+
+	// Path refers to the document to watch. The trigger fires if a write
+	// modifies the document, a document contained in it, or a document that
+	// contains it.
+	Path Path
+
+	// Callback is invoked after the transaction is closed.
+	Callback ChangeCallback
+}
+
+// changeEvent represents a modification of a watched document.
+type changeEvent struct {
+	trigger ChangeTrigger
+	before  interface{}
+	after   interface{}
+}
@@ -8,6 +8,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"math/big"
+	"sort"
 
 	"github.com/open-policy-agent/opa/ast"
 	"github.com/open-policy-agent/opa/util"
@@ -61,6 +62,21 @@ func reduceSum(x interface{}) (ast.Value, error) {
 	return nil, fmt.Errorf("sum: source must be array")
 }
 
+func reduceProduct(x interface{}) (ast.Value, error) {
+	if s, ok := x.([]interface{}); ok {
+		product := big.NewFloat(1)
+		for _, x := range s {
+			n, ok := x.(json.Number)
+			if !ok {
+				return nil, fmt.Errorf("product: input value must be a number: illegal argument: %v", x)
+			}
+			product = new(big.Float).Mul(product, jsonNumberToFloat(n))
+		}
+		return floatToASTNumber(product), nil
+	}
+	return nil, fmt.Errorf("product: source must be array")
+}
+
 func reduceCount(x interface{}) (ast.Value, error) {
 	switch x := x.(type) {
 	case []interface{}:
@@ -70,7 +86,11 @@ func reduceCount(x interface{}) (ast.Value, error) {
 	case string:
 		return ast.IntNumberTerm(len(x)).Value, nil
 	default:
-		return nil, fmt.Errorf("count: source must be array, object, or string")
+		v, err := ast.InterfaceToValue(x)
+		if err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("count: input must be array, object, set, or string: illegal argument: %v", v)
 	}
 }
 
@@ -90,3 +110,291 @@ func reduceMax(x interface{}) (ast.Value, error) {
 	}
 	return nil, fmt.Errorf("max: source must be array")
 }
+
+func reduceMin(x interface{}) (ast.Value, error) {
+	switch x := x.(type) {
+	case []interface{}:
+		if len(x) == 0 {
+			return nil, empty{}
+		}
+		min := x[0]
+		for i := range x[1:] {
+			if util.Compare(x[i+1], min) < 0 {
+				min = x[i+1]
+			}
+		}
+		return ast.InterfaceToValue(min)
+	}
+	return nil, fmt.Errorf("min: source must be array")
+}
+
+func reduceMedian(x interface{}) (ast.Value, error) {
+	s, ok := x.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("median: source must be array")
+	}
+	if len(s) == 0 {
+		return nil, empty{}
+	}
+	nums := make([]*big.Float, len(s))
+	for i := range s {
+		n, ok := s[i].(json.Number)
+		if !ok {
+			return nil, fmt.Errorf("median: input elements must be numbers")
+		}
+		nums[i] = jsonNumberToFloat(n)
+	}
+	sort.Sort(floatSlice(nums))
+	mid := len(nums) / 2
+	if len(nums)%2 == 1 {
+		return floatToASTNumber(nums[mid]), nil
+	}
+	sum := new(big.Float).Add(nums[mid-1], nums[mid])
+	return floatToASTNumber(sum.Quo(sum, big.NewFloat(2))), nil
+}
+
+type floatSlice []*big.Float
+
+func (s floatSlice) Less(i, j int) bool { return s[i].Cmp(s[j]) < 0 }
+func (s floatSlice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s floatSlice) Len() int           { return len(s) }
+
+// reduceAll and reduceAny treat elements the same way as expressions: an
+// element is true unless it is false.
+func reduceAll(x interface{}) (ast.Value, error) {
+	s, ok := x.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("all: source must be array or set")
+	}
+	for i := range s {
+		if s[i] == false {
+			return ast.Boolean(false), nil
+		}
+	}
+	return ast.Boolean(true), nil
+}
+
+func reduceAny(x interface{}) (ast.Value, error) {
+	s, ok := x.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("any: source must be array or set")
+	}
+	for i := range s {
+		if s[i] != false {
+			return ast.Boolean(true), nil
+		}
+	}
+	return ast.Boolean(false), nil
+}
+
+func reduceSort(x interface{}) (ast.Value, error) {
+	s, ok := x.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("sort: source must be array")
+	}
+	sorted := make([]interface{}, len(s))
+	copy(sorted, s)
+	sort.Sort(interfaceSlice(sorted))
+	return ast.InterfaceToValue(sorted)
+}
+
+type interfaceSlice []interface{}
+
+func (s interfaceSlice) Less(i, j int) bool { return util.Compare(s[i], s[j]) < 0 }
+func (s interfaceSlice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s interfaceSlice) Len() int           { return len(s) }
+
+// keyedElem is an element of a collection along with the key obtained by
+// applying a keypath to it. If the keypath does not exist in the element, found
+// is false.
+type keyedElem struct {
+	value interface{}
+	key   interface{}
+	found bool
+}
+
+type reduceByFunc func(elems []keyedElem) (ast.Value, error)
+
+// evalReduceBy returns a BuiltinFunc that derives a key for each element in a
+// collection by applying a keypath (an array of object keys and array indices)
+// to it before reducing the collection.
+func evalReduceBy(builtin *ast.Builtin, f reduceByFunc) BuiltinFunc {
+	return func(t *Topdown, expr *ast.Expr, iter Iterator) error {
+		ops := expr.Terms.([]*ast.Term)
+
+		x, err := ValueToInterface(ops[1].Value, t)
+		if err != nil {
+			return errors.Wrapf(err, "%v", builtin.Name)
+		}
+
+		s, ok := x.([]interface{})
+		if !ok {
+			return fmt.Errorf("%v: input must be an array or set", builtin.Name)
+		}
+
+		y, err := ValueToInterface(ops[2].Value, t)
+		if err != nil {
+			return errors.Wrapf(err, "%v", builtin.Name)
+		}
+
+		keypath, ok := y.([]interface{})
+		if !ok {
+			return fmt.Errorf("%v: keypath must be an array", builtin.Name)
+		}
+
+		elems := make([]keyedElem, len(s))
+		for i := range s {
+			key, found := lookupKeypath(s[i], keypath)
+			elems[i] = keyedElem{s[i], key, found}
+		}
+
+		result, err := f(elems)
+		if err != nil {
+			switch err.(type) {
+			case empty:
+				return nil
+			}
+			return err
+		}
+
+		undo, err := evalEqUnify(t, result, ops[3].Value, nil, iter)
+		t.Unbind(undo)
+		return err
+	}
+}
+
+func lookupKeypath(x interface{}, keypath []interface{}) (interface{}, bool) {
+	for _, k := range keypath {
+		switch v := x.(type) {
+		case map[string]interface{}:
+			s, ok := k.(string)
+			if !ok {
+				return nil, false
+			}
+			if x, ok = v[s]; !ok {
+				return nil, false
+			}
+		case []interface{}:
+			n, ok := k.(json.Number)
+			if !ok {
+				return nil, false
+			}
+			i, err := n.Int64()
+			if err != nil || i < 0 || i >= int64(len(v)) {
+				return nil, false
+			}
+			x = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return x, true
+}
+
+// reduceMaxBy and reduceMinBy skip elements that do not contain the keypath. If
+// multiple elements have the same key, the first one is selected.
+func reduceMaxBy(elems []keyedElem) (ast.Value, error) {
+	return reduceExtremeBy(elems, 1)
+}
+
+func reduceMinBy(elems []keyedElem) (ast.Value, error) {
+	return reduceExtremeBy(elems, -1)
+}
+
+func reduceExtremeBy(elems []keyedElem, sign int) (ast.Value, error) {
+	var best *keyedElem
+	for i := range elems {
+		if !elems[i].found {
+			continue
+		}
+		if best == nil || sign*util.Compare(elems[i].key, best.key) > 0 {
+			best = &elems[i]
+		}
+	}
+	if best == nil {
+		return nil, empty{}
+	}
+	return ast.InterfaceToValue(best.value)
+}
+
+// reduceSortBy sorts elements that do not contain the keypath before all other
+// elements. Elements with equal keys retain their original order.
+func reduceSortBy(elems []keyedElem) (ast.Value, error) {
+	sort.Stable(keyedElemSlice(elems))
+	sorted := make([]interface{}, len(elems))
+	for i := range elems {
+		sorted[i] = elems[i].value
+	}
+	return ast.InterfaceToValue(sorted)
+}
+
+type keyedElemSlice []keyedElem
+
+func (s keyedElemSlice) Less(i, j int) bool {
+	if !s[i].found || !s[j].found {
+		return !s[i].found && s[j].found
+	}
+	return util.Compare(s[i].key, s[j].key) < 0
+}
+
+func (s keyedElemSlice) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s keyedElemSlice) Len() int      { return len(s) }
+
+func evalHistogram(builtin *ast.Builtin, clamp bool) BuiltinFunc {
+	return func(t *Topdown, expr *ast.Expr, iter Iterator) error {
+		ops := expr.Terms.([]*ast.Term)
+
+		values, err := valueToFloat64s(ops[1].Value, t)
+		if err != nil {
+			return errors.Wrapf(err, "%v: input must be array of numbers", builtin.Name)
+		}
+
+		edges, err := valueToFloat64s(ops[2].Value, t)
+		if err != nil {
+			return errors.Wrapf(err, "%v: bin edges must be array of numbers", builtin.Name)
+		}
+
+		if len(edges) < 2 {
+			return fmt.Errorf("%v: bin edges must contain at least two values", builtin.Name)
+		}
+
+		for i := 1; i < len(edges); i++ {
+			if edges[i] <= edges[i-1] {
+				return fmt.Errorf("%v: bin edges must be sorted in increasing order", builtin.Name)
+			}
+		}
+
+		counts := make([]int, len(edges)-1)
+
+		for _, x := range values {
+			if i, ok := histogramBin(edges, x, clamp); ok {
+				counts[i]++
+			}
+		}
+
+		result := make(ast.Array, len(counts))
+		for i := range counts {
+			result[i] = ast.IntNumberTerm(counts[i])
+		}
+
+		undo, err := evalEqUnify(t, result, ops[3].Value, nil, iter)
+		t.Unbind(undo)
+		return err
+	}
+}
+
+// histogramBin returns the index of the bin that x falls into. Bins include
+// their lower edge and exclude their upper edge except for the last bin which
+// includes both. If x is outside of the edges and clamp is false, the second
+// return value is false.
+func histogramBin(edges []float64, x float64, clamp bool) (int, bool) {
+	last := len(edges) - 2
+	if x < edges[0] {
+		return 0, clamp
+	}
+	if x >= edges[last+1] {
+		return last, clamp || x == edges[last+1]
+	}
+	i := sort.Search(len(edges), func(i int) bool { return edges[i] > x })
+	return i - 1, true
+}
@@ -7,9 +7,11 @@ package topdown
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"math/big"
 
 	"github.com/open-policy-agent/opa/ast"
+	"github.com/pkg/errors"
 )
 
 func jsonNumberToFloat(n json.Number) *big.Float {
@@ -46,6 +48,30 @@ func arithRound(a *big.Float) (*big.Float, error) {
 	return new(big.Float).SetInt(i), nil
 }
 
+type arithToInt func(a *big.Float) *big.Int
+
+var bigOne = big.NewInt(1)
+
+func arithFloor(a *big.Float) *big.Int {
+	// Int truncates towards zero so negative numbers with a fractional part
+	// must be adjusted down.
+	i, acc := a.Int(nil)
+	if acc == big.Above {
+		i.Sub(i, bigOne)
+	}
+	return i
+}
+
+func arithCeil(a *big.Float) *big.Int {
+	// Int truncates towards zero so positive numbers with a fractional part
+	// must be adjusted up.
+	i, acc := a.Int(nil)
+	if acc == big.Below {
+		i.Add(i, bigOne)
+	}
+	return i
+}
+
 type arithArity2 func(a, b *big.Float) (*big.Float, error)
 
 func arithPlus(a, b *big.Float) (*big.Float, error) {
@@ -89,6 +115,26 @@ func evalArithArity1(f arithArity1) BuiltinFunc {
 	}
 }
 
+// evalArithToInt returns a BuiltinFunc that converts a number to an integer
+// using f. The result is computed on the underlying big.Float so that it is
+// exact, i.e., the output does not contain floating point error.
+func evalArithToInt(builtin *ast.Builtin, f arithToInt) BuiltinFunc {
+	return func(t *Topdown, expr *ast.Expr, iter Iterator) error {
+		ops := expr.Terms.([]*ast.Term)
+
+		a, err := ValueToJSONNumber(ops[1].Value, t)
+		if err != nil {
+			return errors.Wrapf(err, "%v: input must be a number", builtin.Name)
+		}
+
+		i := f(jsonNumberToFloat(a))
+
+		undo, err := evalEqUnify(t, ast.Number(i.String()), ops[2].Value, nil, iter)
+		t.Unbind(undo)
+		return err
+	}
+}
+
 func evalArithArity2(f arithArity2) BuiltinFunc {
 	return func(t *Topdown, expr *ast.Expr, iter Iterator) error {
 		ops := expr.Terms.([]*ast.Term)
@@ -115,3 +161,215 @@ func evalArithArity2(f arithArity2) BuiltinFunc {
 		return err
 	}
 }
+
+func evalRem(t *Topdown, expr *ast.Expr, iter Iterator) error {
+	ops := expr.Terms.([]*ast.Term)
+
+	a, err := valueToBigInt(ops[1].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v: operands must be integers", ast.Rem.Name)
+	}
+
+	b, err := valueToBigInt(ops[2].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v: operands must be integers", ast.Rem.Name)
+	}
+
+	if b.Sign() == 0 {
+		return fmt.Errorf("%v: modulo by zero", ast.Rem.Name)
+	}
+
+	c := new(big.Int).Rem(a, b)
+
+	undo, err := evalEqUnify(t, ast.Number(c.String()), ops[3].Value, nil, iter)
+	t.Unbind(undo)
+	return err
+}
+
+// numbersRangeMaxSize is the maximum number of integers that numbers_range
+// produces. Larger ranges are rejected so that policies cannot exhaust memory.
+const numbersRangeMaxSize = 1000000
+
+func evalNumbersRange(t *Topdown, expr *ast.Expr, iter Iterator) error {
+	ops := expr.Terms.([]*ast.Term)
+
+	low, err := valueToBigInt(ops[1].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v: operands must be integers", ast.NumbersRange.Name)
+	}
+
+	high, err := valueToBigInt(ops[2].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v: operands must be integers", ast.NumbersRange.Name)
+	}
+
+	step := bigOne
+	if low.Cmp(high) > 0 {
+		step = big.NewInt(-1)
+	}
+
+	size := new(big.Int).Sub(high, low)
+	if size.Abs(size).Cmp(big.NewInt(numbersRangeMaxSize)) >= 0 {
+		return fmt.Errorf("%v: range must not contain more than %d integers", ast.NumbersRange.Name, numbersRangeMaxSize)
+	}
+
+	result := ast.Array{ast.NumberTerm(json.Number(low.String()))}
+	for i := low; i.Cmp(high) != 0; {
+		i = new(big.Int).Add(i, step)
+		result = append(result, ast.NumberTerm(json.Number(i.String())))
+	}
+
+	undo, err := evalEqUnify(t, result, ops[3].Value, nil, iter)
+	t.Unbind(undo)
+	return err
+}
+
+// powMaxExactBits is the maximum size of an integer power that pow computes
+// exactly. The size of a power is estimated as the bit length of the base
+// multiplied by the exponent. Larger powers are computed with floating point so
+// that policies cannot exhaust CPU and memory.
+const powMaxExactBits = 1 << 16
+
+func evalPow(t *Topdown, expr *ast.Expr, iter Iterator) error {
+	ops := expr.Terms.([]*ast.Term)
+
+	a, err := ValueToJSONNumber(ops[1].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v: operands must be numbers", ast.Pow.Name)
+	}
+
+	b, err := ValueToJSONNumber(ops[2].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v: operands must be numbers", ast.Pow.Name)
+	}
+
+	base, exp := jsonNumberToFloat(a), jsonNumberToFloat(b)
+
+	var result ast.Value
+
+	// Integer powers of integers are computed exactly unless they are too
+	// large. All other cases fall back to floating point.
+	x, _ := base.Int(nil)
+	y, _ := exp.Int(nil)
+
+	if base.IsInt() && exp.IsInt() && exp.Sign() >= 0 && y.BitLen() < 32 && int64(x.BitLen())*y.Int64() <= powMaxExactBits {
+		result = ast.Number(new(big.Int).Exp(x, y, nil).String())
+	} else {
+		x, _ := base.Float64()
+		y, _ := exp.Float64()
+		f := math.Pow(x, y)
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return fmt.Errorf("%v: result must be a finite number", ast.Pow.Name)
+		}
+		result = ast.FloatNumberTerm(f).Value
+	}
+
+	undo, err := evalEqUnify(t, result, ops[3].Value, nil, iter)
+	t.Unbind(undo)
+	return err
+}
+
+func evalSqrt(t *Topdown, expr *ast.Expr, iter Iterator) error {
+	ops := expr.Terms.([]*ast.Term)
+
+	a, err := ValueToJSONNumber(ops[1].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v: operand must be a number", ast.Sqrt.Name)
+	}
+
+	x := jsonNumberToFloat(a)
+	if x.Sign() < 0 {
+		return fmt.Errorf("%v: operand must be non-negative", ast.Sqrt.Name)
+	}
+
+	f, _ := x.Float64()
+	r := math.Sqrt(f)
+	if math.IsInf(r, 0) || math.IsNaN(r) {
+		return fmt.Errorf("%v: result must be a finite number", ast.Sqrt.Name)
+	}
+
+	var result ast.Value
+
+	// The square roots of perfect squares are computed exactly. All other
+	// cases fall back to floating point.
+	if i, acc := x.Int(nil); acc == big.Exact && r == math.Trunc(r) {
+		ri, _ := big.NewFloat(r).Int(nil)
+		if new(big.Int).Mul(ri, ri).Cmp(i) == 0 {
+			result = ast.Number(ri.String())
+		}
+	}
+
+	if result == nil {
+		result = ast.FloatNumberTerm(r).Value
+	}
+
+	undo, err := evalEqUnify(t, result, ops[2].Value, nil, iter)
+	t.Unbind(undo)
+	return err
+}
+
+func evalEuclideanDistance(t *Topdown, expr *ast.Expr, iter Iterator) error {
+	ops := expr.Terms.([]*ast.Term)
+
+	a, err := valueToFloat64s(ops[1].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v: first input must be an array of numbers", ast.EuclideanDistance.Name)
+	}
+
+	b, err := valueToFloat64s(ops[2].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v: second input must be an array of numbers", ast.EuclideanDistance.Name)
+	}
+
+	if len(a) != len(b) {
+		return fmt.Errorf("%v: inputs must have the same length", ast.EuclideanDistance.Name)
+	}
+
+	var sum float64
+	for i := range a {
+		d := a[i] - b[i]
+		sum += d * d
+	}
+
+	dist := ast.FloatNumberTerm(math.Sqrt(sum))
+
+	undo, err := evalEqUnify(t, dist.Value, ops[3].Value, nil, iter)
+	t.Unbind(undo)
+	return err
+}
+
+// valueToFloat64s returns a slice of float64 values associated with an AST
+// array value.
+func valueToFloat64s(v ast.Value, resolver Resolver) ([]float64, error) {
+	sl, err := ValueToSlice(v, resolver)
+	if err != nil {
+		return nil, err
+	}
+	r := make([]float64, len(sl))
+	for i, x := range sl {
+		n, ok := x.(json.Number)
+		if !ok {
+			return nil, fmt.Errorf("illegal argument: %v", x)
+		}
+		r[i], err = n.Float64()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// valueToBigInt returns the integer associated with an AST number value. Numbers
+// with a fractional part are rejected.
+func valueToBigInt(v ast.Value, resolver Resolver) (*big.Int, error) {
+	n, err := ValueToJSONNumber(v, resolver)
+	if err != nil {
+		return nil, err
+	}
+	f := jsonNumberToFloat(n)
+	if !f.IsInt() {
+		return nil, fmt.Errorf("illegal argument: %v", v)
+	}
+	i, _ := f.Int(nil)
+	return i, nil
+}
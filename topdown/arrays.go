@@ -0,0 +1,298 @@
+// Copyright 2016 The OPA Authors.  All rights reserved.
+// Use of this source code is governed by an Apache2
+// license that can be found in the LICENSE file.
+
+package topdown
+
+import (
+	"fmt"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/util"
+	"github.com/pkg/errors"
+)
+
+func evalDuplicates(t *Topdown, expr *ast.Expr, iter Iterator) error {
+	ops := expr.Terms.([]*ast.Term)
+
+	arr, err := resolveArray(ast.Duplicates, ops[1].Value, t)
+	if err != nil {
+		return err
+	}
+
+	seen := &ast.Set{}
+	result := &ast.Set{}
+
+	for _, x := range arr {
+		if seen.Contains(x) {
+			result.Add(x)
+		} else {
+			seen.Add(x)
+		}
+	}
+
+	undo, err := evalEqUnify(t, result, ops[2].Value, nil, iter)
+	t.Unbind(undo)
+	return err
+}
+
+func evalCountEq(t *Topdown, expr *ast.Expr, iter Iterator) error {
+	ops := expr.Terms.([]*ast.Term)
+
+	arr, err := resolveArray(ast.CountEq, ops[1].Value, t)
+	if err != nil {
+		return err
+	}
+
+	value, err := ResolveRefs(ops[2].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v", ast.CountEq.Name)
+	}
+
+	var n int
+	for _, x := range arr {
+		if ast.Compare(x.Value, value) == 0 {
+			n++
+		}
+	}
+
+	undo, err := evalEqUnify(t, ast.IntNumberTerm(n).Value, ops[3].Value, nil, iter)
+	t.Unbind(undo)
+	return err
+}
+
+func evalRotate(t *Topdown, expr *ast.Expr, iter Iterator) error {
+	ops := expr.Terms.([]*ast.Term)
+
+	arr, err := resolveArray(ast.Rotate, ops[1].Value, t)
+	if err != nil {
+		return err
+	}
+
+	n, err := ValueToInt(ops[2].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v: n must be an integer", ast.Rotate.Name)
+	}
+
+	result := make(ast.Array, len(arr))
+
+	if len(arr) > 0 {
+		offset := int(n % int64(len(arr)))
+		if offset < 0 {
+			offset += len(arr)
+		}
+		copy(result, arr[offset:])
+		copy(result[len(arr)-offset:], arr[:offset])
+	}
+
+	undo, err := evalEqUnify(t, result, ops[3].Value, nil, iter)
+	t.Unbind(undo)
+	return err
+}
+
+func evalAtMostOne(t *Topdown, expr *ast.Expr, iter Iterator) error {
+	ops := expr.Terms.([]*ast.Term)
+
+	arr, err := resolveArray(ast.AtMostOne, ops[1].Value, t)
+	if err != nil {
+		return err
+	}
+
+	var n int
+	for _, x := range arr {
+		b, ok := x.Value.(ast.Boolean)
+		if !ok {
+			return fmt.Errorf("%v: input elements must be booleans: illegal argument: %v", ast.AtMostOne.Name, x)
+		}
+		if b {
+			n++
+		}
+	}
+
+	undo, err := evalEqUnify(t, ast.Boolean(n <= 1), ops[2].Value, nil, iter)
+	t.Unbind(undo)
+	return err
+}
+
+func evalFindIndex(t *Topdown, expr *ast.Expr, iter Iterator) error {
+	ops := expr.Terms.([]*ast.Term)
+
+	arr, err := resolveArray(ast.FindIndex, ops[1].Value, t)
+	if err != nil {
+		return err
+	}
+
+	value, err := ResolveRefs(ops[2].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v", ast.FindIndex.Name)
+	}
+
+	for i, x := range arr {
+		if ast.Compare(x.Value, value) == 0 {
+			undo, err := evalEqUnify(t, ast.IntNumberTerm(i).Value, ops[3].Value, nil, iter)
+			t.Unbind(undo)
+			return err
+		}
+	}
+
+	return nil
+}
+
+func evalCommonElements(t *Topdown, expr *ast.Expr, iter Iterator) error {
+	ops := expr.Terms.([]*ast.Term)
+
+	arr, err := resolveArray(ast.CommonElements, ops[1].Value, t)
+	if err != nil {
+		return err
+	}
+
+	arrs := make([]ast.Array, len(arr))
+	for i := range arr {
+		a, ok := arr[i].Value.(ast.Array)
+		if !ok {
+			return &Error{
+				Code:    TypeErr,
+				Message: fmt.Sprintf("%v: input elements must be arrays not %T", ast.CommonElements.Name, arr[i].Value),
+			}
+		}
+		arrs[i] = a
+	}
+
+	result := &ast.Set{}
+
+	if len(arrs) > 0 {
+		for _, x := range arrs[0] {
+			common := !arrayContains(ast.Array(*result), x.Value)
+			for i := 1; common && i < len(arrs); i++ {
+				common = arrayContains(arrs[i], x.Value)
+			}
+			if common {
+				result.Add(x)
+			}
+		}
+	}
+
+	undo, err := evalEqUnify(t, result, ops[2].Value, nil, iter)
+	t.Unbind(undo)
+	return err
+}
+
+func evalCumMax(t *Topdown, expr *ast.Expr, iter Iterator) error {
+	ops := expr.Terms.([]*ast.Term)
+
+	arr, err := resolveArray(ast.CumMax, ops[1].Value, t)
+	if err != nil {
+		return err
+	}
+
+	sl, err := ValueToSlice(arr, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v", ast.CumMax.Name)
+	}
+
+	result := make(ast.Array, len(arr))
+	max := 0
+
+	for i := range sl {
+		if util.Compare(sl[max], sl[i]) < 0 {
+			max = i
+		}
+		result[i] = arr[max]
+	}
+
+	undo, err := evalEqUnify(t, result, ops[2].Value, nil, iter)
+	t.Unbind(undo)
+	return err
+}
+
+func evalDistinct(t *Topdown, expr *ast.Expr, iter Iterator) error {
+	ops := expr.Terms.([]*ast.Term)
+
+	v, err := ResolveRefs(ops[1].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v", ast.Distinct.Name)
+	}
+
+	arr, ok := v.(ast.Array)
+	if !ok {
+		return &Error{
+			Code:    TypeErr,
+			Message: fmt.Sprintf("%v: input must be an array not %T", ast.Distinct.Name, ops[1].Value),
+		}
+	}
+
+	result := ast.Array{}
+
+	for _, x := range arr {
+		if !arrayContains(result, x.Value) {
+			result = append(result, x)
+		}
+	}
+
+	undo, err := evalEqUnify(t, result, ops[2].Value, nil, iter)
+	t.Unbind(undo)
+	return err
+}
+
+func evalFlatten(t *Topdown, expr *ast.Expr, iter Iterator) error {
+	ops := expr.Terms.([]*ast.Term)
+
+	v, err := ResolveRefs(ops[1].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v", ast.Flatten.Name)
+	}
+
+	arr, ok := v.(ast.Array)
+	if !ok {
+		return &Error{
+			Code:    TypeErr,
+			Message: fmt.Sprintf("%v: input must be an array not %T", ast.Flatten.Name, ops[1].Value),
+		}
+	}
+
+	result := flatten(ast.Array{}, arr)
+
+	undo, err := evalEqUnify(t, result, ops[2].Value, nil, iter)
+	t.Unbind(undo)
+	return err
+}
+
+// flatten appends the non-array elements of arr to result, descending into
+// nested arrays.
+func flatten(result ast.Array, arr ast.Array) ast.Array {
+	for _, x := range arr {
+		if nested, ok := x.Value.(ast.Array); ok {
+			result = flatten(result, nested)
+		} else {
+			result = append(result, x)
+		}
+	}
+	return result
+}
+
+// arrayContains returns true if arr contains an element equal to v.
+func arrayContains(arr ast.Array, v ast.Value) bool {
+	for _, x := range arr {
+		if ast.Compare(x.Value, v) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveArray returns the array referred to by v. If v does not refer to an
+// array, a type error is returned.
+func resolveArray(builtin *ast.Builtin, v ast.Value, t *Topdown) (ast.Array, error) {
+	r, err := ResolveRefs(v, t)
+	if err != nil {
+		return nil, errors.Wrapf(err, "%v", builtin.Name)
+	}
+	arr, ok := r.(ast.Array)
+	if !ok {
+		return nil, &Error{
+			Code:    TypeErr,
+			Message: fmt.Sprintf("%v: input must be array not %T", builtin.Name, v),
+		}
+	}
+	return arr, nil
+}
@@ -15,8 +15,29 @@ import "github.com/open-policy-agent/opa/ast"
 // with bindings from the current context. If the built-in function determines
 // that the expression has evaluated successfully it should bind any output variables
 // and invoke the iterator with the context produced by binding the output variables.
+//
+// Built-in functions that produce multiple results invoke the iterator once for
+// each result, e.g.:
+//
+//	for _, x := range results {
+//		if err := Unify(t, x, output, iter); err != nil {
+//			return err
+//		}
+//	}
+//
+// If the iterator returns an error, the built-in function must stop and return
+// the error.
 type BuiltinFunc func(t *Topdown, expr *ast.Expr, iter Iterator) (err error)
 
+// Unify unifies a and b and invokes the iterator if they can be unified. The
+// bindings made during unification are undone before Unify returns so that
+// built-in functions can call Unify repeatedly to produce multiple results.
+func Unify(t *Topdown, a, b ast.Value, iter Iterator) error {
+	undo, err := evalEqUnify(t, a, b, nil, iter)
+	t.Unbind(undo)
+	return err
+}
+
 // RegisterBuiltinFunc adds a new built-in function to the evaluation engine.
 func RegisterBuiltinFunc(name ast.Var, fun BuiltinFunc) {
 	builtinFunctions[name] = fun
@@ -25,33 +46,111 @@ func RegisterBuiltinFunc(name ast.Var, fun BuiltinFunc) {
 var builtinFunctions map[ast.Var]BuiltinFunc
 
 var defaultBuiltinFuncs = map[ast.Var]BuiltinFunc{
-	ast.Equality.Name:      evalEq,
-	ast.GreaterThan.Name:   evalIneq(compareGreaterThan),
-	ast.GreaterThanEq.Name: evalIneq(compareGreaterThanEq),
-	ast.LessThan.Name:      evalIneq(compareLessThan),
-	ast.LessThanEq.Name:    evalIneq(compareLessThanEq),
-	ast.NotEqual.Name:      evalIneq(compareNotEq),
-	ast.Plus.Name:          evalArithArity2(arithPlus),
-	ast.Minus.Name:         evalArithArity2(arithMinus),
-	ast.Multiply.Name:      evalArithArity2(arithMultiply),
-	ast.Divide.Name:        evalArithArity2(arithDivide),
-	ast.Round.Name:         evalArithArity1(arithRound),
-	ast.Abs.Name:           evalArithArity1(arithAbs),
-	ast.Count.Name:         evalReduce(reduceCount),
-	ast.Sum.Name:           evalReduce(reduceSum),
-	ast.Max.Name:           evalReduce(reduceMax),
-	ast.ToNumber.Name:      evalToNumber,
-	ast.RegexMatch.Name:    evalRegexMatch,
-	ast.SetDiff.Name:       evalSetDiff,
-	ast.FormatInt.Name:     evalFormatInt,
-	ast.Concat.Name:        evalConcat,
-	ast.IndexOf.Name:       evalIndexOf,
-	ast.Substring.Name:     evalSubstring,
-	ast.Contains.Name:      evalContains,
-	ast.StartsWith.Name:    evalStartsWith,
-	ast.EndsWith.Name:      evalEndsWith,
-	ast.Upper.Name:         evalUpper,
-	ast.Lower.Name:         evalLower,
+	ast.Equality.Name:             evalEq,
+	ast.GreaterThan.Name:          evalIneq(compareGreaterThan),
+	ast.GreaterThanEq.Name:        evalIneq(compareGreaterThanEq),
+	ast.LessThan.Name:             evalIneq(compareLessThan),
+	ast.LessThanEq.Name:           evalIneq(compareLessThanEq),
+	ast.NotEqual.Name:             evalIneq(compareNotEq),
+	ast.Plus.Name:                 evalArithArity2(arithPlus),
+	ast.Minus.Name:                evalArithArity2(arithMinus),
+	ast.Multiply.Name:             evalArithArity2(arithMultiply),
+	ast.Divide.Name:               evalArithArity2(arithDivide),
+	ast.Round.Name:                evalArithArity1(arithRound),
+	ast.Abs.Name:                  evalArithArity1(arithAbs),
+	ast.EuclideanDistance.Name:    evalEuclideanDistance,
+	ast.Floor.Name:                evalArithToInt(ast.Floor, arithFloor),
+	ast.Ceil.Name:                 evalArithToInt(ast.Ceil, arithCeil),
+	ast.Rem.Name:                  evalRem,
+	ast.NumbersRange.Name:         evalNumbersRange,
+	ast.Pow.Name:                  evalPow,
+	ast.Sqrt.Name:                 evalSqrt,
+	ast.Count.Name:                evalReduce(reduceCount),
+	ast.Sum.Name:                  evalReduce(reduceSum),
+	ast.Product.Name:              evalReduce(reduceProduct),
+	ast.Max.Name:                  evalReduce(reduceMax),
+	ast.Sort.Name:                 evalReduce(reduceSort),
+	ast.Duplicates.Name:           evalDuplicates,
+	ast.CountEq.Name:              evalCountEq,
+	ast.Rotate.Name:               evalRotate,
+	ast.AtMostOne.Name:            evalAtMostOne,
+	ast.FindIndex.Name:            evalFindIndex,
+	ast.CommonElements.Name:       evalCommonElements,
+	ast.CumMax.Name:               evalCumMax,
+	ast.Distinct.Name:             evalDistinct,
+	ast.Flatten.Name:              evalFlatten,
+	ast.ObjectValuesSum.Name:      evalObjectValuesSum,
+	ast.ObjectGet.Name:            evalObjectGet,
+	ast.ObjectKeySymDiff.Name:     evalObjectKeySymDiff,
+	ast.CollectPath.Name:          evalCollectPath,
+	ast.ObjectMatches.Name:        evalObjectMatches,
+	ast.KeysAbove.Name:            evalKeysAbove,
+	ast.PathAllowed.Name:          evalPathAllowed,
+	ast.EntriesSortedByValue.Name: evalEntriesSortedByValue,
+	ast.ChangedValues.Name:        evalChangedValues,
+	ast.ObjectUnion.Name:          evalObjectUnion,
+	ast.ObjectRemove.Name:         evalObjectRemove,
+	ast.Min.Name:                  evalReduce(reduceMin),
+	ast.Median.Name:               evalReduce(reduceMedian),
+	ast.MaxBy.Name:                evalReduceBy(ast.MaxBy, reduceMaxBy),
+	ast.MinBy.Name:                evalReduceBy(ast.MinBy, reduceMinBy),
+	ast.SortBy.Name:               evalReduceBy(ast.SortBy, reduceSortBy),
+	ast.Histogram.Name:            evalHistogram(ast.Histogram, false),
+	ast.HistogramClamped.Name:     evalHistogram(ast.HistogramClamped, true),
+	ast.All.Name:                  evalReduce(reduceAll),
+	ast.Any.Name:                  evalReduce(reduceAny),
+	ast.IsOneOf.Name:              evalIsOneOf,
+	ast.Member.Name:               evalMember,
+	ast.MD5.Name:                  evalMD5,
+	ast.SHA1.Name:                 evalSHA1,
+	ast.SHA256.Name:               evalSHA256,
+	ast.JSONMarshal.Name:          evalJSONMarshal,
+	ast.JSONUnmarshal.Name:        evalJSONUnmarshal,
+	ast.ValueSizeBytes.Name:       evalValueSizeBytes,
+	ast.ToNumber.Name:             evalToNumber,
+	ast.ToBoolean.Name:            evalToBoolean,
+	ast.ParseIntAuto.Name:         evalParseIntAuto,
+	ast.ToString.Name:             evalToString,
+	ast.IsString.Name:             evalTypeCheck(ast.IsString, isString),
+	ast.IsNumber.Name:             evalTypeCheck(ast.IsNumber, isNumber),
+	ast.IsBoolean.Name:            evalTypeCheck(ast.IsBoolean, isBoolean),
+	ast.IsArray.Name:              evalTypeCheck(ast.IsArray, isArray),
+	ast.IsSet.Name:                evalTypeCheck(ast.IsSet, isSet),
+	ast.IsObject.Name:             evalTypeCheck(ast.IsObject, isObject),
+	ast.IsNull.Name:               evalTypeCheck(ast.IsNull, isNull),
+	ast.TypeNameBuiltin.Name:      evalTypeName,
+	ast.ValidateAll.Name:          evalValidateAll,
+	ast.MaxDepth.Name:             evalMaxDepth,
+	ast.LeafCount.Name:            evalLeafCount,
+	ast.WalkBuiltin.Name:          evalWalk,
+	ast.Trace.Name:                evalTrace,
+	ast.RegexMatch.Name:           evalRegexMatch,
+	ast.RegexFind.Name:            evalRegexFind,
+	ast.RegexReplace.Name:         evalRegexReplace,
+	ast.GlobMatchAny.Name:         evalGlobMatchAny,
+	ast.EffectivePermissions.Name: evalEffectivePermissions,
+	ast.WeightedSample.Name:       evalWeightedSample,
+	ast.LabelBucket.Name:          evalLabelBucket,
+	ast.SetDiff.Name:              evalSetDiff,
+	ast.SetComplement.Name:        evalSetComplement,
+	ast.Jaccard.Name:              evalJaccard,
+	ast.FormatInt.Name:            evalFormatInt,
+	ast.Concat.Name:               evalConcat,
+	ast.IndexOf.Name:              evalIndexOf,
+	ast.Substring.Name:            evalSubstring,
+	ast.Contains.Name:             evalContains,
+	ast.StartsWith.Name:           evalStartsWith,
+	ast.EndsWith.Name:             evalEndsWith,
+	ast.Upper.Name:                evalUpper,
+	ast.Lower.Name:                evalLower,
+	ast.Replace.Name:              evalReplace,
+	ast.Trim.Name:                 evalTrim,
+	ast.TrimPrefixAny.Name:        evalTrimPrefixAny,
+	ast.Sprintf.Name:              evalSprintf,
+	ast.CanonicalHost.Name:        evalCanonicalHost,
+	ast.InBusinessHours.Name:      evalInBusinessHours,
+	ast.ParseKV.Name:              evalParseKV(ast.ParseKV, false),
+	ast.ParseKVLenient.Name:       evalParseKV(ast.ParseKVLenient, true),
 }
 
 func init() {
@@ -7,7 +7,9 @@ package topdown
 import (
 	"encoding/json"
 	"fmt"
+	"math/big"
 	"strconv"
+	"strings"
 
 	"github.com/open-policy-agent/opa/ast"
 	"github.com/pkg/errors"
@@ -81,3 +83,132 @@ func evalToNumber(t *Topdown, expr *ast.Expr, iter Iterator) error {
 	// Step 6. finished, return error (which may be nil).
 	return err
 }
+
+func evalToBoolean(t *Topdown, expr *ast.Expr, iter Iterator) error {
+	ops := expr.Terms.([]*ast.Term)
+	a, b := ops[1].Value, ops[2].Value
+
+	x, err := ValueToInterface(a, t)
+	if err != nil {
+		return errors.Wrapf(err, "to_boolean")
+	}
+
+	var result ast.Boolean
+
+	switch x := x.(type) {
+	case bool:
+		result = ast.Boolean(x)
+	case string:
+		switch x {
+		case "true":
+			result = true
+		case "false":
+			result = false
+		default:
+			return fmt.Errorf("to_boolean: string must be \"true\" or \"false\": %q", x)
+		}
+	case json.Number:
+		switch f := jsonNumberToFloat(x); {
+		case f.Cmp(big.NewFloat(1)) == 0:
+			result = true
+		case f.Sign() == 0:
+			result = false
+		default:
+			return fmt.Errorf("to_boolean: number must be 1 or 0: %v", x)
+		}
+	default:
+		return fmt.Errorf("to_boolean: source must be a boolean, string, or number: %T", a)
+	}
+
+	undo, err := evalEqUnify(t, result, b, nil, iter)
+	t.Unbind(undo)
+	return err
+}
+
+func evalToString(t *Topdown, expr *ast.Expr, iter Iterator) error {
+	ops := expr.Terms.([]*ast.Term)
+	a, b := ops[1].Value, ops[2].Value
+
+	x, err := ValueToInterface(a, t)
+	if err != nil {
+		return errors.Wrapf(err, "to_string")
+	}
+
+	var result ast.String
+
+	switch x := x.(type) {
+	case string:
+		result = ast.String(x)
+	case json.Number:
+		result = ast.String(x)
+	case bool:
+		result = ast.String(strconv.FormatBool(x))
+	case nil:
+		result = ast.String("null")
+	default:
+		return fmt.Errorf("to_string: unsupported value type")
+	}
+
+	undo, err := evalEqUnify(t, result, b, nil, iter)
+	t.Unbind(undo)
+	return err
+}
+
+func evalParseIntAuto(t *Topdown, expr *ast.Expr, iter Iterator) error {
+	ops := expr.Terms.([]*ast.Term)
+
+	s, err := ValueToString(ops[1].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "parse_int_auto: input must be a string")
+	}
+
+	i, err := parseIntAuto(s)
+	if err != nil {
+		return errors.Wrapf(err, "parse_int_auto")
+	}
+
+	undo, err := evalEqUnify(t, ast.Number(i.String()), ops[2].Value, nil, iter)
+	t.Unbind(undo)
+	return err
+}
+
+func parseIntAuto(s string) (*big.Int, error) {
+
+	digits := s
+	neg := false
+
+	if len(digits) > 0 && (digits[0] == '-' || digits[0] == '+') {
+		neg = digits[0] == '-'
+		digits = digits[1:]
+	}
+
+	base := 10
+	prefix := strings.ToLower(digits)
+
+	switch {
+	case strings.HasPrefix(prefix, "0x"):
+		base, digits = 16, digits[2:]
+	case strings.HasPrefix(prefix, "0o"):
+		base, digits = 8, digits[2:]
+	case strings.HasPrefix(prefix, "0b"):
+		base, digits = 2, digits[2:]
+	case len(digits) > 1 && digits[0] == '0':
+		base, digits = 8, digits[1:]
+	}
+
+	// big.Int accepts a sign so make sure the digits do not contain one.
+	if len(digits) == 0 || digits[0] == '-' || digits[0] == '+' {
+		return nil, fmt.Errorf("invalid integer: %q", s)
+	}
+
+	i, ok := new(big.Int).SetString(digits, base)
+	if !ok {
+		return nil, fmt.Errorf("invalid integer: %q", s)
+	}
+
+	if neg {
+		i.Neg(i)
+	}
+
+	return i, nil
+}
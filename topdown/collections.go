@@ -0,0 +1,97 @@
+// Copyright 2016 The OPA Authors.  All rights reserved.
+// Use of this source code is governed by an Apache2
+// license that can be found in the LICENSE file.
+
+package topdown
+
+import (
+	"fmt"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/pkg/errors"
+)
+
+func evalIsOneOf(t *Topdown, expr *ast.Expr, iter Iterator) error {
+	ops := expr.Terms.([]*ast.Term)
+
+	value, err := ResolveRefs(ops[1].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v", ast.IsOneOf.Name)
+	}
+
+	allowed, err := ResolveRefs(ops[2].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v", ast.IsOneOf.Name)
+	}
+
+	elems, err := collectionElements(allowed)
+	if err != nil {
+		return &Error{
+			Code:    TypeErr,
+			Message: fmt.Sprintf("%v: allowed values must be array or set not %T", ast.IsOneOf.Name, ops[2].Value),
+		}
+	}
+
+	result := ast.Boolean(false)
+	for _, x := range elems {
+		if ast.Compare(value, x.Value) == 0 {
+			result = true
+			break
+		}
+	}
+
+	undo, err := evalEqUnify(t, result, ops[3].Value, nil, iter)
+	t.Unbind(undo)
+	return err
+}
+
+func evalMember(t *Topdown, expr *ast.Expr, iter Iterator) error {
+	ops := expr.Terms.([]*ast.Term)
+
+	value, err := ResolveRefs(ops[1].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v", ast.Member.Name)
+	}
+
+	collection, err := ResolveRefs(ops[2].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v", ast.Member.Name)
+	}
+
+	var result ast.Boolean
+
+	switch c := collection.(type) {
+	case *ast.Set:
+		result = ast.Boolean(c.Contains(ast.NewTerm(value)))
+	case ast.Array:
+		result = ast.Boolean(arrayContains(c, value))
+	case ast.Object:
+		for _, item := range c {
+			if ast.Compare(item[1].Value, value) == 0 {
+				result = true
+				break
+			}
+		}
+	default:
+		return &Error{
+			Code:    TypeErr,
+			Message: fmt.Sprintf("%v: collection must be array, set, or object not %T", ast.Member.Name, ops[2].Value),
+		}
+	}
+
+	undo, err := evalEqUnify(t, result, ops[3].Value, nil, iter)
+	t.Unbind(undo)
+	return err
+}
+
+// collectionElements returns the elements of an array or set value.
+func collectionElements(v ast.Value) ([]*ast.Term, error) {
+	switch v := v.(type) {
+	case ast.Array:
+		return v, nil
+	case *ast.Set:
+		return *v, nil
+	default:
+		return nil, fmt.Errorf("illegal argument: %v", v)
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright 2016 The OPA Authors.  All rights reserved.
+// Use of this source code is governed by an Apache2
+// license that can be found in the LICENSE file.
+
+package topdown
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/pkg/errors"
+)
+
+func evalHash(builtin *ast.Builtin, h func() hash.Hash) BuiltinFunc {
+	return func(t *Topdown, expr *ast.Expr, iter Iterator) error {
+		ops := expr.Terms.([]*ast.Term)
+
+		s, err := ValueToString(ops[1].Value, t)
+		if err != nil {
+			return errors.Wrapf(err, "%v: input must be a string", builtin.Name)
+		}
+
+		digest := h()
+		digest.Write([]byte(s))
+		result := ast.String(hex.EncodeToString(digest.Sum(nil)))
+
+		undo, err := evalEqUnify(t, result, ops[2].Value, nil, iter)
+		t.Unbind(undo)
+		return err
+	}
+}
+
+var (
+	evalMD5    = evalHash(ast.MD5, md5.New)
+	evalSHA1   = evalHash(ast.SHA1, sha1.New)
+	evalSHA256 = evalHash(ast.SHA256, sha256.New)
+)
@@ -0,0 +1,72 @@
+// Copyright 2016 The OPA Authors.  All rights reserved.
+// Use of this source code is governed by an Apache2
+// license that can be found in the LICENSE file.
+
+package topdown
+
+import (
+	"encoding/json"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/util"
+	"github.com/pkg/errors"
+)
+
+func evalJSONMarshal(t *Topdown, expr *ast.Expr, iter Iterator) error {
+	ops := expr.Terms.([]*ast.Term)
+
+	bs, err := marshalJSON(ops[1].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v", ast.JSONMarshal.Name)
+	}
+
+	undo, err := evalEqUnify(t, ast.String(bs), ops[2].Value, nil, iter)
+	t.Unbind(undo)
+	return err
+}
+
+func evalJSONUnmarshal(t *Topdown, expr *ast.Expr, iter Iterator) error {
+	ops := expr.Terms.([]*ast.Term)
+
+	s, err := ValueToString(ops[1].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v: input must be a string", ast.JSONUnmarshal.Name)
+	}
+
+	var x interface{}
+	if err := util.UnmarshalJSON([]byte(s), &x); err != nil {
+		return errors.Wrapf(err, "%v: invalid JSON", ast.JSONUnmarshal.Name)
+	}
+
+	v, err := ast.InterfaceToValue(x)
+	if err != nil {
+		return errors.Wrapf(err, "%v", ast.JSONUnmarshal.Name)
+	}
+
+	undo, err := evalEqUnify(t, v, ops[2].Value, nil, iter)
+	t.Unbind(undo)
+	return err
+}
+
+func evalValueSizeBytes(t *Topdown, expr *ast.Expr, iter Iterator) error {
+	ops := expr.Terms.([]*ast.Term)
+
+	bs, err := marshalJSON(ops[1].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v", ast.ValueSizeBytes.Name)
+	}
+
+	undo, err := evalEqUnify(t, ast.IntNumberTerm(len(bs)).Value, ops[2].Value, nil, iter)
+	t.Unbind(undo)
+	return err
+}
+
+// marshalJSON returns the JSON serialization of v. Object keys are sorted so
+// the serialization of a value is always the same.
+func marshalJSON(v ast.Value, resolver Resolver) ([]byte, error) {
+	x, err := ValueToInterface(v, resolver)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(x)
+}
@@ -0,0 +1,368 @@
+// Copyright 2016 The OPA Authors.  All rights reserved.
+// Use of this source code is governed by an Apache2
+// license that can be found in the LICENSE file.
+
+package topdown
+
+import (
+	"fmt"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/util"
+)
+
+// ExplainMode defines the supported ways of reducing a trace.
+type ExplainMode int
+
+const (
+	// ExplainFull returns all of the events in the trace.
+	ExplainFull ExplainMode = iota
+
+	// ExplainTruth returns the events that explain why the top-level query was
+	// true. Events from search paths that were abandoned are removed.
+	ExplainTruth
+)
+
+// Explain reduces the trace according to the mode. The compiler is used to
+// look up the rules referred to by expressions in the trace. If the top-level
+// query in the trace is undefined, ExplainTruth returns nil. If the trace
+// cannot be reduced (e.g., because it does not contain all of the events
+// emitted for the query), an error is returned.
+func Explain(compiler *ast.Compiler, events []*Event, mode ExplainMode) ([]*Event, error) {
+
+	if mode != ExplainTruth || len(events) == 0 {
+		return events, nil
+	}
+
+	truth := &truth{
+		compiler: compiler,
+		source:   nil,
+		byTime:   nil,
+		byQuery:  map[uint64][]*truthNode{},
+		allPaths: map[uint64]struct{}{},
+	}
+
+	// Process each event in the trace, updating the state stored on the truth
+	// struct. Once all events have been processed, return the answer.
+	for _, event := range events {
+		if err := truth.Update(event); err != nil {
+			return nil, err
+		}
+	}
+
+	return truth.Answer(), nil
+}
+
+// truth contains state used to reduce traces to the events that explain why
+// the top-level query was true.
+type truth struct {
+	compiler *ast.Compiler
+	source   *truthNode
+	byTime   *truthNode
+	all      []*truthNode
+	byQuery  map[uint64][]*truthNode
+	allPaths map[uint64]struct{}
+}
+
+func (t *truth) Update(event *Event) error {
+
+	n := &truthNode{event: event}
+	qid := event.QueryID
+
+	// First event initializes time and source of graph.
+	if t.source == nil {
+		t.source = n
+		t.byTime = n
+		t.all = append(t.all, n)
+		t.byQuery[qid] = append(t.byQuery[qid], n)
+		return nil
+	}
+
+	defer func() {
+		t.all = append(t.all, n)
+	}()
+
+	// Check if all paths are required. If all paths are required or this event
+	// does not represent a branch in the search, just link the node to the
+	// previous event in time.
+	//
+	// TODO(tsandall): it's possible that we could perform more filtering on
+	// child queries and avoid showing all paths. Need to consider what users
+	// need to see for negation and full evaluation cases...
+	allPaths := t.checkAndSetAllPaths(event)
+	if event.Op != RedoOp || allPaths {
+		t.predecessor(n).AddEdge(n)
+		t.byTime = n
+		t.byQuery[qid] = append(t.byQuery[qid], n)
+		return nil
+	}
+
+	// Handle branch in search.
+	switch event.Node.(type) {
+	case *ast.Rule:
+		return t.updateRedoRule(n)
+	case *ast.Expr:
+		return t.updateRedoExpr(n)
+	}
+
+	return nil
+}
+
+// Answer returns the filtered trace by performing a depth-first traversal on
+// the graph. The traversal goes from source to sink, where a sink is one of the
+// exits events of the top-level query.
+func (t *truth) Answer() (result []*Event) {
+
+	byQuery := t.byQuery[t.source.event.QueryID]
+	var sink *truthNode
+	for _, node := range byQuery {
+		if node.event.Op == ExitOp {
+			sink = node
+			break
+		}
+	}
+
+	if sink == nil {
+		return nil
+	}
+
+	traversal := newTruthTraversal(t)
+	nodes := util.DFS(traversal, t.source, sink)
+
+	for _, n := range nodes {
+		node := n.(*truthNode)
+		result = append(result, node.event)
+	}
+
+	return result
+}
+
+// checkAndSetAllPaths returns true if all search paths should be included for
+// this query. All search paths are included for negated expressions, full
+// references to partial definitions of objects and sets, and comprehensions.
+func (t *truth) checkAndSetAllPaths(event *Event) bool {
+
+	_, ok := t.allPaths[event.QueryID]
+	if ok {
+		return ok
+	}
+
+	_, ok = t.allPaths[event.ParentID]
+	if ok {
+		t.allPaths[event.QueryID] = struct{}{}
+		return ok
+	}
+
+	// Rules that are evaluated after the first rule in a set of definitions are
+	// entered with a redo event.
+	_, isRule := event.Node.(*ast.Rule)
+	entered := len(t.byQuery[event.QueryID]) == 0
+
+	if event.Op != EnterOp && !(event.Op == RedoOp && isRule && entered) {
+		return false
+	}
+
+	prevQuery := t.byQuery[event.ParentID]
+	if len(prevQuery) == 0 {
+		return false
+	}
+
+	prev := prevQuery[len(prevQuery)-1]
+	prevExpr, ok := prev.event.Node.(*ast.Expr)
+	if !ok {
+		return false
+	}
+
+	switch node := event.Node.(type) {
+	case *ast.Rule:
+		if node.DocKind() == ast.PartialObjectDoc || node.DocKind() == ast.PartialSetDoc {
+			plugged := PlugExpr(prevExpr, prev.event.Locals.Get)
+			found := false
+			ast.WalkRefs(plugged, func(r ast.Ref) bool {
+				rules := t.compiler.GetRulesWithPrefix(r)
+				for _, rule := range rules {
+					if rule.Equal(node) {
+						found = true
+						return true
+					}
+				}
+				return false
+			})
+			if found {
+				t.allPaths[event.QueryID] = struct{}{}
+				return true
+			}
+		}
+	case ast.Body:
+		if prevExpr.Negated {
+			t.allPaths[event.QueryID] = struct{}{}
+		} else {
+			found := false
+			ast.WalkClosures(prevExpr, func(x interface{}) bool {
+				switch x := x.(type) {
+				case *ast.ArrayComprehension:
+					if x.Body.Equal(node) {
+						found = true
+						return true
+					}
+				case *ast.ObjectComprehension:
+					if x.Body.Equal(node) {
+						found = true
+						return true
+					}
+				case *ast.SetComprehension:
+					if x.Body.Equal(node) {
+						found = true
+						return true
+					}
+				}
+				return false
+			})
+			if found {
+				t.allPaths[event.QueryID] = struct{}{}
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// predecessor returns the node that a non-branching event should be linked to.
+// This is normally the previous event in time. If the previous event is a
+// failure in a child query, the child query did not contribute to the answer,
+// so the event is linked to the last exit from a child query (or the last
+// event in the same query) instead.
+func (t *truth) predecessor(n *truthNode) *truthNode {
+	prev := t.byTime
+	qid := n.event.QueryID
+
+	if prev.event.Op != FailOp || prev.event.QueryID == qid {
+		return prev
+	}
+
+	if _, ok := t.allPaths[prev.event.QueryID]; ok {
+		return prev
+	}
+
+	for i := len(t.all) - 1; i >= 0; i-- {
+		event := t.all[i].event
+		if event.QueryID == qid || (event.Op == ExitOp && event.ParentID == qid) {
+			return t.all[i]
+		}
+	}
+
+	return prev
+}
+
+// updateRedoRule will link the node to the most recent expression in the parent
+// query. This represents a branch in the search.
+func (t *truth) updateRedoRule(n *truthNode) error {
+	qid := n.event.QueryID
+	byQuery := t.byQuery[n.event.ParentID]
+	if len(byQuery) == 0 {
+		return fmt.Errorf("cannot add %v to graph, parent not found", n)
+	}
+	byQuery[len(byQuery)-1].AddEdge(n)
+	t.byTime = n
+	t.byQuery[qid] = append(t.byQuery[qid], n)
+	return nil
+}
+
+// updateRedoExpr will link the node to the previous node in the query *before*
+// the restart, i.e., the previous expression or the previous enter/redo of the
+// rule/body. This represents a branch in the search.
+func (t *truth) updateRedoExpr(n *truthNode) error {
+
+	qid := n.event.QueryID
+	byQuery := t.byQuery[qid]
+	expr := n.event.Node.(*ast.Expr)
+
+	var prev *truthNode
+
+	if expr.Index == 0 {
+		prev = t.findQueryRestart(byQuery)
+	} else {
+		prev = t.findExprRestart(byQuery, expr.Index)
+	}
+
+	if prev == nil {
+		return fmt.Errorf("cannot add %v to graph, restart not found", n)
+	}
+
+	prev.AddEdge(n)
+	t.byTime = n
+	t.byQuery[qid] = append(byQuery, n)
+
+	return nil
+}
+
+func (t *truth) findQueryRestart(byQuery []*truthNode) *truthNode {
+	for i := len(byQuery) - 1; i >= 0; i-- {
+		_, isBody := byQuery[i].event.Node.(ast.Body)
+		_, isRule := byQuery[i].event.Node.(*ast.Rule)
+		if isBody || isRule {
+			return byQuery[i]
+		}
+	}
+	return nil
+}
+
+func (t *truth) findExprRestart(byQuery []*truthNode, index int) *truthNode {
+	for i := len(byQuery) - 1; i >= 0; i-- {
+		prev, ok := byQuery[i].event.Node.(*ast.Expr)
+		if ok && prev.Index == (index-1) {
+			return byQuery[i]
+		}
+	}
+	return nil
+}
+
+type truthNode struct {
+	event *Event
+	edges []*truthNode
+}
+
+func (n *truthNode) String() string {
+	return fmt.Sprintf("%v", n.event)
+}
+
+func (n *truthNode) AddEdge(other *truthNode) {
+	n.edges = append(n.edges, other)
+}
+
+type truthTraversal struct {
+	truth   *truth
+	visited map[*truthNode]struct{}
+}
+
+func newTruthTraversal(truth *truth) *truthTraversal {
+	return &truthTraversal{
+		truth:   truth,
+		visited: map[*truthNode]struct{}{},
+	}
+}
+
+func (t *truthTraversal) Edges(u util.T) []util.T {
+	un := u.(*truthNode)
+	r := make([]util.T, len(un.edges))
+	for i := range un.edges {
+		r[i] = un.edges[i]
+	}
+	return r
+}
+
+func (t *truthTraversal) Equals(u util.T, v util.T) bool {
+	un := u.(*truthNode)
+	vn := v.(*truthNode)
+	return un == vn
+}
+
+func (t *truthTraversal) Visited(u util.T) bool {
+	un := u.(*truthNode)
+	_, ok := t.visited[un]
+	if ok {
+		return true
+	}
+	t.visited[un] = struct{}{}
+	return false
+}
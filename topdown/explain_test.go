@@ -0,0 +1,263 @@
+// Copyright 2016 The OPA Authors.  All rights reserved.
+// Use of this source code is governed by an Apache2
+// license that can be found in the LICENSE file.
+
+package topdown
+
+import (
+	"testing"
+
+	"context"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/storage"
+)
+
+func TestExplainFull(t *testing.T) {
+	module := `
+	package test
+	p :- arr = [1,2,3], x = arr[_], x != 2
+	`
+	compiler, trace := runExplainQuery(module)
+	answer, err := Explain(compiler, trace, ExplainFull)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(answer) != len(trace) {
+		t.Fatalf("Expected %d events but got: %v", len(trace), len(answer))
+	}
+
+	for i := range trace {
+		if !trace[i].Equal(answer[i]) {
+			t.Errorf("Expected event %d to equal %v but got: %v", i, trace[i], answer[i])
+		}
+	}
+}
+
+func TestExplainTruthEval(t *testing.T) {
+	module := `
+	package test
+	p :- arr = [1,2,3], x = arr[_], x != 2
+	`
+	p := ast.MustParseRule(`p :- arr = [1,2,3], x = arr[_], x != 2`)
+	runExplainTruthTestCase(t, module, 8, map[int]*Event{
+		2: &Event{Op: EnterOp, Node: p, QueryID: 3, ParentID: 2},
+		3: &Event{Op: EvalOp, Node: parseExpr("arr = [1,2,3]", 0), QueryID: 3, ParentID: 2},
+		4: &Event{Op: RedoOp, Node: parseExpr("x = arr[_]", 1), QueryID: 3, ParentID: 2, Locals: parseBindings("{arr: [1,2,3]}")},
+		5: &Event{Op: EvalOp, Node: parseExpr("x != 2", 2), QueryID: 3, ParentID: 2, Locals: parseBindings("{x: 3}")},
+		6: &Event{Op: ExitOp, Node: p, QueryID: 3, ParentID: 2, Locals: parseBindings("{x: 3}")},
+	})
+}
+
+func TestExplainTruthNegation(t *testing.T) {
+	module := `
+	package test
+	p :- arr = [1,2,3,4], x = arr[_], not x = 2
+	`
+	runExplainTruthTestCase(t, module, 11, map[int]*Event{
+		4: &Event{Op: RedoOp, Node: parseExpr("x = arr[_]", 1), QueryID: 3, ParentID: 2},
+		5: &Event{Op: EvalOp, Node: parseExpr("not x = 2", 2), QueryID: 3, ParentID: 2, Locals: parseBindings("{x: 4}")},
+		6: &Event{Op: EnterOp, Node: ast.MustParseBody("x = 2"), QueryID: 7, ParentID: 3, Locals: parseBindings("{x: 4}")},
+		8: &Event{Op: FailOp, Node: parseExpr("x = 2", 0), QueryID: 7, ParentID: 3, Locals: parseBindings("{x: 4}")},
+	})
+}
+
+func TestExplainTruthCompleteDocs(t *testing.T) {
+	module := `
+	package test
+	p :- q[1] = "b"
+	q = ["a", "b", "c", "d"]
+	q = null :- false
+	`
+	q := ast.MustParseRule(`q = ["a", "b", "c", "d"] :- true`)
+	runExplainTruthTestCase(t, module, 9, map[int]*Event{
+		4: &Event{Op: EnterOp, Node: q, QueryID: 4, ParentID: 3},
+		5: &Event{Op: EvalOp, Node: parseExpr("true", 0), QueryID: 4, ParentID: 3},
+		6: &Event{Op: ExitOp, Node: q, QueryID: 4, ParentID: 3},
+		7: &Event{Op: ExitOp, Node: ast.MustParseRule(`p :- data.test.q[1] = "b"`), QueryID: 3, ParentID: 2},
+	})
+}
+
+func TestExplainTruthPartialSets(t *testing.T) {
+	module := `
+	package test
+	p :- q[x], x != 2, r[x], s[x]
+	q[y] :- arr = [1,2,3,4], y = arr[i]
+	r[z] :- z = data.a[i], z > 1
+	s[x] :- x = 3
+	s[y] :- y = 4
+	`
+
+	q := ast.MustParseRule(`q[y] :- arr = [1,2,3,4], y = arr[i]`)
+	r := ast.MustParseRule(`r[z] :- z = data.a[i], z > 1`)
+	sy := ast.MustParseRule(`s[y] :- y = 4`)
+
+	runExplainTruthTestCase(t, module, 20, map[int]*Event{
+		4:  &Event{Op: EnterOp, Node: q, QueryID: 4, ParentID: 3},
+		6:  &Event{Op: RedoOp, Node: parseExpr("y = arr[i]", 1), QueryID: 4, ParentID: 3},
+		7:  &Event{Op: ExitOp, Node: q, QueryID: 4, ParentID: 3, Locals: parseBindings("{y: 4}")},
+		10: &Event{Op: EnterOp, Node: r, QueryID: 9, ParentID: 3, Locals: parseBindings("{z: 4}")},
+		13: &Event{Op: ExitOp, Node: r, QueryID: 9, ParentID: 3, Locals: parseBindings("{z: 4}")},
+		15: &Event{Op: RedoOp, Node: sy, QueryID: 11, ParentID: 3, Locals: parseBindings("{y: 4}")},
+		17: &Event{Op: ExitOp, Node: sy, QueryID: 11, ParentID: 3, Locals: parseBindings("{y: 4}")},
+	})
+}
+
+func TestExplainTruthAllPaths(t *testing.T) {
+	tests := []struct {
+		note   string
+		module string
+	}{
+		{"partial objects", `
+		package test
+		p :- q = v, v["b"] != 0
+		q[k] = 1 :- ks = ["a","b","c"], k = ks[_]
+		q["x"] = 100 :- true
+		`},
+		{"comprehensions", `
+		package test
+		p :- m = 1, count([x | x = data.a[_], x > m], n), n = 3
+		`},
+	}
+
+	for _, tc := range tests {
+		compiler, trace := runExplainQuery(tc.module)
+		answer, err := Explain(compiler, trace, ExplainTruth)
+		if err != nil {
+			t.Errorf("%v: Unexpected error: %v", tc.note, err)
+			continue
+		}
+
+		if len(answer) != len(trace) {
+			t.Errorf("%v: Expected %d events but got: %v", tc.note, len(trace), len(answer))
+			continue
+		}
+
+		for i := range trace {
+			if !trace[i].Equal(answer[i]) {
+				t.Errorf("%v: Expected event %d to equal %v but got: %v", tc.note, i, trace[i], answer[i])
+			}
+		}
+	}
+}
+
+func TestExplainTruthUndefined(t *testing.T) {
+	module := `
+	package test
+	p :- a = [1,2,3], a[_] = 100
+	`
+	compiler, trace := runExplainQuery(module)
+
+	answer, err := Explain(compiler, trace, ExplainTruth)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if answer != nil {
+		t.Fatalf("Expected nil for undefined query but got: %v", answer)
+	}
+}
+
+func TestExplainTruthSameRuleNames(t *testing.T) {
+
+	// The complete document data.a.q must not cause all paths of the partial
+	// object data.b.q to be included.
+	a := `
+	package a
+	q = 2 :- true
+	`
+	b := `
+	package b
+	q[k] = v :- arr = [1,2,3], v = arr[k]
+	`
+	other := `
+	package a
+	r = 2 :- true
+	`
+
+	answer := func(module string) []*Event {
+		compiler, trace := runExplainQuery(`
+		package test
+		p :- data.b.q[x] = `+module, a, b, other)
+		answer, err := Explain(compiler, trace, ExplainTruth)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return answer
+	}
+
+	same, different := answer("data.a.q"), answer("data.a.r")
+
+	if len(same) == 0 || len(same) != len(different) {
+		t.Fatalf("Expected %d events but got %d:\n%v", len(different), len(same), same)
+	}
+}
+
+func TestExplainTruthBadTrace(t *testing.T) {
+
+	compiler, trace := runExplainQuery(`
+	package test
+	p :- arr = [1,2,3], x = arr[_], x != 2
+	`)
+
+	// Remove the events for the top-level query so that redo events cannot be
+	// linked to the graph.
+	_, err := Explain(compiler, trace[2:], ExplainTruth)
+	if err == nil {
+		t.Fatalf("Expected error for incomplete trace")
+	}
+}
+
+func runExplainTruthTestCase(t *testing.T, module string, n int, cases map[int]*Event) {
+
+	compiler, trace := runExplainQuery(module)
+	answer, err := Explain(compiler, trace, ExplainTruth)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(answer) != n {
+		t.Errorf("Expected %d events but got: %v\n%v", n, len(answer), answer)
+	}
+
+	for i, expected := range cases {
+		if len(answer) <= i {
+			continue
+		}
+		result := *answer[i]
+		bindings := ast.NewValueMap()
+		expected.Locals.Iter(func(k, _ ast.Value) bool {
+			if v := result.Locals.Get(k); v != nil {
+				bindings.Put(k, v)
+			}
+			return false
+		})
+		result.Locals = bindings
+		if !result.Equal(expected) {
+			t.Errorf("Expected event %d to equal %v but got: %v", i, expected, result)
+		}
+	}
+}
+
+func runExplainQuery(modules ...string) (*ast.Compiler, []*Event) {
+
+	ctx := context.Background()
+	compiler := compileModules(modules)
+	data := loadSmallTestData()
+	store := storage.New(storage.InMemoryWithJSONConfig(data))
+	txn := storage.NewTransactionOrDie(ctx, store)
+	defer store.Close(ctx, txn)
+
+	params := NewQueryParams(ctx, compiler, store, txn, nil, ast.MustParseRef("data.test.p"))
+	buf := NewBufferTracer()
+	params.Tracer = buf
+
+	qidFactory.Reset()
+
+	if _, err := Query(params); err != nil {
+		panic(err)
+	}
+
+	return compiler, *buf
+}
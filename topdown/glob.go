@@ -0,0 +1,43 @@
+// Copyright 2016 The OPA Authors.  All rights reserved.
+// Use of this source code is governed by an Apache2
+// license that can be found in the LICENSE file.
+
+package topdown
+
+import (
+	"path"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/pkg/errors"
+)
+
+func evalGlobMatchAny(t *Topdown, expr *ast.Expr, iter Iterator) error {
+	ops := expr.Terms.([]*ast.Term)
+
+	patterns, err := ValueToStrings(ops[1].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v: patterns must be array of strings", ast.GlobMatchAny.Name)
+	}
+
+	s, err := ValueToString(ops[2].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v: input value must be a string", ast.GlobMatchAny.Name)
+	}
+
+	result := ast.Boolean(false)
+
+	for _, pattern := range patterns {
+		matched, err := path.Match(pattern, s)
+		if err != nil {
+			return errors.Wrapf(err, "%v: invalid pattern %q", ast.GlobMatchAny.Name, pattern)
+		}
+		if matched {
+			result = true
+			break
+		}
+	}
+
+	undo, err := evalEqUnify(t, result, ops[3].Value, nil, iter)
+	t.Unbind(undo)
+	return err
+}
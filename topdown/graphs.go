@@ -0,0 +1,105 @@
+// Copyright 2016 The OPA Authors.  All rights reserved.
+// Use of this source code is governed by an Apache2
+// license that can be found in the LICENSE file.
+
+package topdown
+
+import (
+	"fmt"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/pkg/errors"
+)
+
+func evalEffectivePermissions(t *Topdown, expr *ast.Expr, iter Iterator) error {
+	ops := expr.Terms.([]*ast.Term)
+
+	graph, err := resolveObject(ast.EffectivePermissions, ops[1].Value, t)
+	if err != nil {
+		return err
+	}
+
+	perms, err := resolveObject(ast.EffectivePermissions, ops[2].Value, t)
+	if err != nil {
+		return err
+	}
+
+	assigned, err := ResolveRefs(ops[3].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v", ast.EffectivePermissions.Name)
+	}
+
+	roles, err := collectionElements(assigned)
+	if err != nil {
+		return &Error{
+			Code:    TypeErr,
+			Message: fmt.Sprintf("%v: assigned roles must be array or set not %T", ast.EffectivePermissions.Name, ops[3].Value),
+		}
+	}
+
+	reachable, err := graphReachable(ast.EffectivePermissions, graph, roles)
+	if err != nil {
+		return err
+	}
+
+	result := &ast.Set{}
+
+	for _, role := range *reachable {
+		term := perms.Get(role)
+		if term == nil {
+			continue
+		}
+		elems, err := collectionElements(term.Value)
+		if err != nil {
+			return &Error{
+				Code:    TypeErr,
+				Message: fmt.Sprintf("%v: permissions must be array or set not %T", ast.EffectivePermissions.Name, term.Value),
+			}
+		}
+		for _, x := range elems {
+			result.Add(x)
+		}
+	}
+
+	undo, err := evalEqUnify(t, result, ops[4].Value, nil, iter)
+	t.Unbind(undo)
+	return err
+}
+
+// graphReachable returns the set of nodes reachable from the start nodes
+// (including the start nodes themselves). The graph is an object that maps
+// each node to an array or set of its neighbours. Nodes that do not appear in
+// the graph have no neighbours. Cycles are allowed.
+func graphReachable(builtin *ast.Builtin, graph ast.Object, start []*ast.Term) (*ast.Set, error) {
+
+	reachable := &ast.Set{}
+	queue := append([]*ast.Term{}, start...)
+
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+
+		if reachable.Contains(node) {
+			continue
+		}
+
+		reachable.Add(node)
+
+		term := graph.Get(node)
+		if term == nil {
+			continue
+		}
+
+		neighbours, err := collectionElements(term.Value)
+		if err != nil {
+			return nil, &Error{
+				Code:    TypeErr,
+				Message: fmt.Sprintf("%v: graph values must be array or set not %T", builtin.Name, term.Value),
+			}
+		}
+
+		queue = append(queue, neighbours...)
+	}
+
+	return reachable, nil
+}
@@ -0,0 +1,81 @@
+// Copyright 2016 The OPA Authors.  All rights reserved.
+// Use of this source code is governed by an Apache2
+// license that can be found in the LICENSE file.
+
+package topdown
+
+import "time"
+
+// Names of the metrics collected during evaluation.
+const (
+	evalRules  = "eval_rules"
+	evalExprs  = "eval_exprs"
+	storeReads = "store_reads"
+	evalTime   = "eval_time"
+)
+
+// Metrics contains counters and timers that are updated during evaluation.
+// The following metrics are collected:
+//
+//	eval_rules (counter):  number of rule bodies evaluated
+//	eval_exprs (counter):  number of expressions evaluated
+//	store_reads (counter): number of reads from storage
+//	eval_time (timer):     total time spent in Query
+//
+// Metrics are not safe for concurrent use.
+type Metrics struct {
+	counters map[string]uint64
+	timers   map[string]time.Duration
+}
+
+// NewMetrics returns a new Metrics object.
+func NewMetrics() *Metrics {
+	return &Metrics{
+		counters: map[string]uint64{},
+		timers:   map[string]time.Duration{},
+	}
+}
+
+// Counter returns the value of the named counter.
+func (m *Metrics) Counter(name string) uint64 {
+	return m.counters[name]
+}
+
+// Timer returns the value of the named timer.
+func (m *Metrics) Timer(name string) time.Duration {
+	return m.timers[name]
+}
+
+// All returns a map of all counter and timer names to their values. Counter
+// values are uint64 and timer values are time.Duration.
+func (m *Metrics) All() map[string]interface{} {
+	result := make(map[string]interface{}, len(m.counters)+len(m.timers))
+	for name, value := range m.counters {
+		result[name] = value
+	}
+	for name, value := range m.timers {
+		result[name] = value
+	}
+	return result
+}
+
+// StartTimer returns a function that adds the time elapsed since StartTimer
+// was called to the named timer. If m is nil, the returned function is a
+// no-op.
+func (m *Metrics) StartTimer(name string) func() {
+	if m == nil {
+		return func() {}
+	}
+	start := time.Now()
+	return func() {
+		m.timers[name] += time.Since(start)
+	}
+}
+
+// incr increments the named counter. If m is nil, this is a no-op.
+func (m *Metrics) incr(name string) {
+	if m == nil {
+		return
+	}
+	m.counters[name]++
+}
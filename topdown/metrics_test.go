@@ -0,0 +1,56 @@
+// Copyright 2016 The OPA Authors.  All rights reserved.
+// Use of this source code is governed by an Apache2
+// license that can be found in the LICENSE file.
+
+package topdown
+
+import (
+	"context"
+	"testing"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/storage"
+)
+
+func TestMetrics(t *testing.T) {
+	module := `
+	package test
+	p :- q[x], plus(x, 1, n)
+	q[x] :- x = data.a[_]
+	`
+
+	ctx := context.Background()
+	compiler := compileModules([]string{module})
+	data := loadSmallTestData()
+	store := storage.New(storage.InMemoryWithJSONConfig(data))
+	txn := storage.NewTransactionOrDie(ctx, store)
+	defer store.Close(ctx, txn)
+
+	params := NewQueryParams(ctx, compiler, store, txn, nil, ast.MustParseRef("data.test.p"))
+	metrics := NewMetrics()
+	params.Metrics = metrics
+
+	_, err := Query(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// p is evaluated once and q is evaluated once (q[x] enumerates the set).
+	// The expressions are eq(data.test.p, _), q[x], eq(x, data.a[_]), and
+	// plus(x, 1, n) for each element of data.a.
+	if exp, result := uint64(2), metrics.Counter("eval_rules"); exp != result {
+		t.Errorf("Expected eval_rules to be %v but got: %v", exp, result)
+	}
+
+	if exp, result := uint64(7), metrics.Counter("eval_exprs"); exp != result {
+		t.Errorf("Expected eval_exprs to be %v but got: %v", exp, result)
+	}
+
+	if metrics.Counter("store_reads") == 0 {
+		t.Errorf("Expected store_reads to be non-zero")
+	}
+
+	if metrics.Timer("eval_time") == 0 {
+		t.Errorf("Expected eval_time to be non-zero")
+	}
+}
@@ -0,0 +1,511 @@
+// Copyright 2016 The OPA Authors.  All rights reserved.
+// Use of this source code is governed by an Apache2
+// license that can be found in the LICENSE file.
+
+package topdown
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/util"
+	"github.com/pkg/errors"
+)
+
+func evalObjectValuesSum(t *Topdown, expr *ast.Expr, iter Iterator) error {
+	ops := expr.Terms.([]*ast.Term)
+
+	obj, err := resolveObject(ast.ObjectValuesSum, ops[1].Value, t)
+	if err != nil {
+		return err
+	}
+
+	sum := big.NewFloat(0)
+	for _, item := range obj {
+		n, ok := item[1].Value.(ast.Number)
+		if !ok {
+			return fmt.Errorf("%v: object values must be numbers: illegal argument: %v", ast.ObjectValuesSum.Name, item[1])
+		}
+		sum = new(big.Float).Add(sum, jsonNumberToFloat(json.Number(n)))
+	}
+
+	undo, err := evalEqUnify(t, floatToASTNumber(sum), ops[2].Value, nil, iter)
+	t.Unbind(undo)
+	return err
+}
+
+func evalObjectGet(t *Topdown, expr *ast.Expr, iter Iterator) error {
+	ops := expr.Terms.([]*ast.Term)
+
+	v, err := ResolveRefs(ops[1].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v", ast.ObjectGet.Name)
+	}
+
+	obj, ok := v.(ast.Object)
+	if !ok {
+		return &Error{
+			Code:    TypeErr,
+			Message: fmt.Sprintf("%v: first argument must be an object not %T", ast.ObjectGet.Name, ops[1].Value),
+		}
+	}
+
+	key, err := ResolveRefs(ops[2].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v", ast.ObjectGet.Name)
+	}
+
+	var result ast.Value
+	if term := obj.Get(ast.NewTerm(key)); term != nil {
+		result = term.Value
+	} else {
+		result, err = ResolveRefs(ops[3].Value, t)
+		if err != nil {
+			return errors.Wrapf(err, "%v", ast.ObjectGet.Name)
+		}
+	}
+
+	undo, err := evalEqUnify(t, result, ops[4].Value, nil, iter)
+	t.Unbind(undo)
+	return err
+}
+
+func evalObjectKeySymDiff(t *Topdown, expr *ast.Expr, iter Iterator) error {
+	ops := expr.Terms.([]*ast.Term)
+
+	a, err := resolveObject(ast.ObjectKeySymDiff, ops[1].Value, t)
+	if err != nil {
+		return err
+	}
+
+	b, err := resolveObject(ast.ObjectKeySymDiff, ops[2].Value, t)
+	if err != nil {
+		return err
+	}
+
+	result := &ast.Set{}
+
+	for _, item := range a.Diff(b) {
+		result.Add(item[0])
+	}
+
+	for _, item := range b.Diff(a) {
+		result.Add(item[0])
+	}
+
+	undo, err := evalEqUnify(t, result, ops[3].Value, nil, iter)
+	t.Unbind(undo)
+	return err
+}
+
+// patternWildcard is the pattern value that matches any key, index, or value.
+var patternWildcard = ast.String("*")
+
+func evalCollectPath(t *Topdown, expr *ast.Expr, iter Iterator) error {
+	ops := expr.Terms.([]*ast.Term)
+
+	value, err := ResolveRefs(ops[1].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v", ast.CollectPath.Name)
+	}
+
+	p, err := ResolveRefs(ops[2].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v", ast.CollectPath.Name)
+	}
+
+	pattern, ok := p.(ast.Array)
+	if !ok {
+		return &Error{
+			Code:    TypeErr,
+			Message: fmt.Sprintf("%v: pattern must be array not %T", ast.CollectPath.Name, ops[2].Value),
+		}
+	}
+
+	for _, x := range pattern {
+		switch x.Value.(type) {
+		case ast.String, ast.Number, ast.Boolean, ast.Null:
+		default:
+			return &Error{
+				Code:    TypeErr,
+				Message: fmt.Sprintf("%v: pattern elements must be scalars not %T", ast.CollectPath.Name, x.Value),
+			}
+		}
+	}
+
+	result := &ast.Set{}
+	collectPath(value, pattern, result)
+
+	undo, err := evalEqUnify(t, result, ops[3].Value, nil, iter)
+	t.Unbind(undo)
+	return err
+}
+
+// collectPath adds the values in v at paths matching pattern to result.
+func collectPath(v ast.Value, pattern ast.Array, result *ast.Set) {
+
+	if len(pattern) == 0 {
+		result.Add(ast.NewTerm(v))
+		return
+	}
+
+	head, tail := pattern[0], pattern[1:]
+	wildcard := head.Value.Equal(patternWildcard)
+
+	switch v := v.(type) {
+	case ast.Object:
+		if wildcard {
+			for _, item := range v {
+				collectPath(item[1].Value, tail, result)
+			}
+		} else if term := v.Get(head); term != nil {
+			collectPath(term.Value, tail, result)
+		}
+	case ast.Array:
+		if wildcard {
+			for _, x := range v {
+				collectPath(x.Value, tail, result)
+			}
+		} else if n, ok := head.Value.(ast.Number); ok {
+			if i, err := json.Number(n).Int64(); err == nil && i >= 0 && i < int64(len(v)) {
+				collectPath(v[i].Value, tail, result)
+			}
+		}
+	case *ast.Set:
+		if wildcard {
+			for _, x := range *v {
+				collectPath(x.Value, tail, result)
+			}
+		} else if v.Contains(head) {
+			collectPath(head.Value, tail, result)
+		}
+	}
+}
+
+func evalObjectMatches(t *Topdown, expr *ast.Expr, iter Iterator) error {
+	ops := expr.Terms.([]*ast.Term)
+
+	value, err := ResolveRefs(ops[1].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v", ast.ObjectMatches.Name)
+	}
+
+	p, err := ResolveRefs(ops[2].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v", ast.ObjectMatches.Name)
+	}
+
+	pattern, ok := p.(ast.Object)
+	if !ok {
+		return &Error{
+			Code:    TypeErr,
+			Message: fmt.Sprintf("%v: pattern must be object not %T", ast.ObjectMatches.Name, ops[2].Value),
+		}
+	}
+
+	result := ast.Boolean(objectMatches(value, pattern))
+
+	undo, err := evalEqUnify(t, result, ops[3].Value, nil, iter)
+	t.Unbind(undo)
+	return err
+}
+
+// objectMatches returns true if v is an object with the same keys as pattern
+// and each value matches the corresponding pattern value.
+func objectMatches(v ast.Value, pattern ast.Object) bool {
+
+	obj, ok := v.(ast.Object)
+	if !ok || len(obj) != len(pattern) {
+		return false
+	}
+
+	for _, item := range pattern {
+		term := obj.Get(item[0])
+		if term == nil {
+			return false
+		}
+		switch p := item[1].Value.(type) {
+		case ast.Object:
+			if !objectMatches(term.Value, p) {
+				return false
+			}
+		default:
+			if !p.Equal(patternWildcard) && ast.Compare(term.Value, p) != 0 {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func evalKeysAbove(t *Topdown, expr *ast.Expr, iter Iterator) error {
+	ops := expr.Terms.([]*ast.Term)
+
+	obj, err := resolveObject(ast.KeysAbove, ops[1].Value, t)
+	if err != nil {
+		return err
+	}
+
+	n, err := ValueToJSONNumber(ops[2].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v: threshold must be a number", ast.KeysAbove.Name)
+	}
+
+	threshold := jsonNumberToFloat(n)
+	result := ast.Array{}
+
+	for _, item := range obj {
+		v, ok := item[1].Value.(ast.Number)
+		if !ok {
+			continue
+		}
+		if jsonNumberToFloat(json.Number(v)).Cmp(threshold) > 0 {
+			result = append(result, item[0])
+		}
+	}
+
+	sort.Sort(termSlice(result))
+
+	undo, err := evalEqUnify(t, result, ops[3].Value, nil, iter)
+	t.Unbind(undo)
+	return err
+}
+
+type termSlice []*ast.Term
+
+func (s termSlice) Less(i, j int) bool { return ast.Compare(s[i].Value, s[j].Value) < 0 }
+func (s termSlice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s termSlice) Len() int           { return len(s) }
+
+func evalPathAllowed(t *Topdown, expr *ast.Expr, iter Iterator) error {
+	ops := expr.Terms.([]*ast.Term)
+
+	p, err := ResolveRefs(ops[1].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v", ast.PathAllowed.Name)
+	}
+
+	path, ok := p.(ast.Array)
+	if !ok {
+		return &Error{
+			Code:    TypeErr,
+			Message: fmt.Sprintf("%v: path must be array not %T", ast.PathAllowed.Name, ops[1].Value),
+		}
+	}
+
+	a, err := ResolveRefs(ops[2].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v", ast.PathAllowed.Name)
+	}
+
+	allowed, err := collectionElements(a)
+	if err != nil {
+		return &Error{
+			Code:    TypeErr,
+			Message: fmt.Sprintf("%v: allowed keys must be array or set not %T", ast.PathAllowed.Name, ops[2].Value),
+		}
+	}
+
+	result := ast.Boolean(true)
+
+	for _, x := range path {
+		if _, ok := x.Value.(ast.Number); ok {
+			continue
+		}
+		if !arrayContains(allowed, x.Value) {
+			result = false
+			break
+		}
+	}
+
+	undo, err := evalEqUnify(t, result, ops[3].Value, nil, iter)
+	t.Unbind(undo)
+	return err
+}
+
+func evalEntriesSortedByValue(t *Topdown, expr *ast.Expr, iter Iterator) error {
+	ops := expr.Terms.([]*ast.Term)
+
+	obj, err := resolveObject(ast.EntriesSortedByValue, ops[1].Value, t)
+	if err != nil {
+		return err
+	}
+
+	entries := make(objectEntrySlice, len(obj))
+
+	for i, item := range obj {
+		k, err := ValueToInterface(item[0].Value, t)
+		if err != nil {
+			return errors.Wrapf(err, "%v", ast.EntriesSortedByValue.Name)
+		}
+		v, err := ValueToInterface(item[1].Value, t)
+		if err != nil {
+			return errors.Wrapf(err, "%v", ast.EntriesSortedByValue.Name)
+		}
+		entries[i] = objectEntry{k, v, ast.ArrayTerm(item[0], item[1])}
+	}
+
+	sort.Sort(entries)
+
+	result := make(ast.Array, len(entries))
+	for i := range entries {
+		result[i] = entries[i].term
+	}
+
+	undo, err := evalEqUnify(t, result, ops[2].Value, nil, iter)
+	t.Unbind(undo)
+	return err
+}
+
+type objectEntry struct {
+	key, value interface{}
+	term       *ast.Term
+}
+
+// objectEntrySlice orders entries by value and then by key.
+type objectEntrySlice []objectEntry
+
+func (s objectEntrySlice) Less(i, j int) bool {
+	if c := util.Compare(s[i].value, s[j].value); c != 0 {
+		return c < 0
+	}
+	return util.Compare(s[i].key, s[j].key) < 0
+}
+
+func (s objectEntrySlice) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s objectEntrySlice) Len() int      { return len(s) }
+
+func evalChangedValues(t *Topdown, expr *ast.Expr, iter Iterator) error {
+	ops := expr.Terms.([]*ast.Term)
+
+	a, err := resolveObject(ast.ChangedValues, ops[1].Value, t)
+	if err != nil {
+		return err
+	}
+
+	b, err := resolveObject(ast.ChangedValues, ops[2].Value, t)
+	if err != nil {
+		return err
+	}
+
+	result := ast.Object{}
+
+	for _, item := range a.Intersect(b) {
+		if ast.Compare(item[1].Value, item[2].Value) != 0 {
+			result = append(result, [2]*ast.Term{item[0], ast.ArrayTerm(item[1], item[2])})
+		}
+	}
+
+	undo, err := evalEqUnify(t, result, ops[3].Value, nil, iter)
+	t.Unbind(undo)
+	return err
+}
+
+func evalObjectUnion(t *Topdown, expr *ast.Expr, iter Iterator) error {
+	ops := expr.Terms.([]*ast.Term)
+
+	op1, err := ResolveRefs(ops[1].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v", ast.ObjectUnion.Name)
+	}
+
+	op2, err := ResolveRefs(ops[2].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v", ast.ObjectUnion.Name)
+	}
+
+	a, ok1 := op1.(ast.Object)
+	b, ok2 := op2.(ast.Object)
+	if !ok1 || !ok2 {
+		return &Error{
+			Code:    TypeErr,
+			Message: fmt.Sprintf("%v: both arguments must be objects", ast.ObjectUnion.Name),
+		}
+	}
+
+	undo, err := evalEqUnify(t, objectUnion(a, b), ops[3].Value, nil, iter)
+	t.Unbind(undo)
+	return err
+}
+
+func evalObjectRemove(t *Topdown, expr *ast.Expr, iter Iterator) error {
+	ops := expr.Terms.([]*ast.Term)
+
+	v, err := ResolveRefs(ops[1].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v", ast.ObjectRemove.Name)
+	}
+
+	obj, ok := v.(ast.Object)
+	if !ok {
+		return &Error{
+			Code:    TypeErr,
+			Message: fmt.Sprintf("%v: first argument must be an object not %T", ast.ObjectRemove.Name, ops[1].Value),
+		}
+	}
+
+	k, err := ResolveRefs(ops[2].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v", ast.ObjectRemove.Name)
+	}
+
+	keys, err := collectionElements(k)
+	if err != nil {
+		return &Error{
+			Code:    TypeErr,
+			Message: fmt.Sprintf("%v: keys must be array or set not %T", ast.ObjectRemove.Name, ops[2].Value),
+		}
+	}
+
+	result := ast.Object{}
+
+	for _, item := range obj {
+		if !arrayContains(keys, item[0].Value) {
+			result = append(result, item)
+		}
+	}
+
+	undo, err := evalEqUnify(t, result, ops[3].Value, nil, iter)
+	t.Unbind(undo)
+	return err
+}
+
+// objectUnion returns the deep merge of a and b. Values from b replace values
+// from a unless both values are objects.
+func objectUnion(a, b ast.Object) ast.Object {
+	r := ast.Object{}
+	r = append(r, a.Diff(b)...)
+	for _, item := range b {
+		if x := a.Get(item[0]); x != nil {
+			o1, ok1 := x.Value.(ast.Object)
+			o2, ok2 := item[1].Value.(ast.Object)
+			if ok1 && ok2 {
+				r = append(r, [2]*ast.Term{item[0], ast.NewTerm(objectUnion(o1, o2))})
+				continue
+			}
+		}
+		r = append(r, item)
+	}
+	return r
+}
+
+// resolveObject returns the object referred to by v. If v does not refer to an
+// object, a type error is returned.
+func resolveObject(builtin *ast.Builtin, v ast.Value, t *Topdown) (ast.Object, error) {
+	r, err := ResolveRefs(v, t)
+	if err != nil {
+		return nil, errors.Wrapf(err, "%v", builtin.Name)
+	}
+	obj, ok := r.(ast.Object)
+	if !ok {
+		return nil, &Error{
+			Code:    TypeErr,
+			Message: fmt.Sprintf("%v: input must be object not %T", builtin.Name, v),
+		}
+	}
+	return obj, nil
+}
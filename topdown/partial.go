@@ -0,0 +1,334 @@
+// Copyright 2016 The OPA Authors.  All rights reserved.
+// Use of this source code is governed by an Apache2
+// license that can be found in the LICENSE file.
+
+package topdown
+
+import (
+	"sort"
+
+	"github.com/open-policy-agent/opa/ast"
+)
+
+// PartialEval returns residual rules for the document referred to by the
+// params Path field. The residual rules are obtained by evaluating the
+// expressions in the rule bodies that do not depend on the request against
+// the data in the store. The remaining expressions are kept in the residual
+// rules along with equality expressions that bind variables to the values
+// produced by the evaluated expressions. If the residual rules replace the
+// original rules, evaluating the document yields the same result for any
+// request as long as the data does not change.
+//
+// Rules chained with the else keyword and default rules are returned
+// unchanged.
+func PartialEval(params *QueryParams) ([]*ast.Rule, error) {
+
+	p := &partialEvaluator{
+		params:  params,
+		unknown: requestDependentPaths(params.Compiler),
+	}
+
+	var result []*ast.Rule
+
+	for _, rule := range params.Compiler.GetRulesExact(params.Path) {
+		residual, err := p.evalRule(rule)
+		if err != nil {
+			return nil, err
+		}
+		for _, rule := range residual {
+			if !containsRule(result, rule) {
+				result = append(result, rule)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// containsRule returns true if rules contains a rule equal to rule. Evaluating
+// the known expressions can produce the same residual rule more than once,
+// e.g., when the bindings are not used by the residual rule.
+func containsRule(rules []*ast.Rule, rule *ast.Rule) bool {
+	for _, other := range rules {
+		if other.Equal(rule) {
+			return true
+		}
+	}
+	return false
+}
+
+type partialEvaluator struct {
+	params  *QueryParams
+	unknown []ast.Ref
+}
+
+var partialEvalVarVisitorParams = ast.VarVisitorParams{
+	SkipClosures:         true,
+	SkipBuiltinOperators: true,
+}
+
+func (p *partialEvaluator) evalRule(rule *ast.Rule) ([]*ast.Rule, error) {
+
+	if rule.Default || rule.Else != nil {
+		return []*ast.Rule{rule.Copy()}, nil
+	}
+
+	known, unknown := p.splitBody(rule.Body)
+
+	query := ast.NewBody(known...)
+	if len(query) == 0 {
+		query = ast.NewBody(ast.NewExpr(ast.BooleanTerm(true)))
+	}
+
+	compiled, err := p.params.Compiler.QueryCompiler().Compile(query)
+	if err != nil {
+		return []*ast.Rule{rule.Copy()}, nil
+	}
+
+	// Variables bound by the known expressions are substituted into the head
+	// and bound by equality expressions in the residual body.
+	vars := ast.NewVarSet()
+	for _, expr := range unknown {
+		vars.Update(expr.Vars(ast.VarVisitorParams{}))
+	}
+
+	var result []*ast.Rule
+
+	t := p.params.NewTopdown(compiled)
+
+	err = Eval(t, func(t *Topdown) error {
+
+		bindings := ast.NewValueMap()
+
+		all := rule.HeadVars()
+		all.Update(vars)
+
+		for _, v := range sortedVars(all) {
+			plugged := PlugValue(v, t.Binding)
+			if plugged.Equal(v) {
+				continue
+			}
+			value, err := ResolveRefs(plugged, t)
+			if err != nil {
+				return err
+			}
+			bindings.Put(v, value)
+		}
+
+		binding := func(v ast.Value) ast.Value {
+			return bindings.Get(v)
+		}
+
+		head := PlugHead(rule.Head(), binding)
+		residual := &ast.Rule{
+			Location: rule.Location,
+			Name:     rule.Name,
+			Key:      head.Key,
+			Value:    head.Value,
+		}
+
+		var body ast.Body
+
+		for _, v := range sortedVars(vars) {
+			if value := bindings.Get(v); value != nil {
+				body = append(body, ast.Equality.Expr(ast.NewTerm(v), ast.NewTerm(value)))
+			}
+		}
+
+		for _, expr := range unknown {
+			expr, err := p.resolveDataRefs(expr.Copy())
+			if err != nil {
+				return err
+			}
+			body = append(body, expr)
+		}
+
+		if len(body) == 0 {
+			body = append(body, ast.NewExpr(ast.BooleanTerm(true)))
+		}
+
+		residual.Body = ast.NewBody(body...)
+		result = append(result, residual)
+		return nil
+	})
+
+	return result, err
+}
+
+// splitBody returns the expressions in body that can be evaluated without the
+// request and the remaining expressions. Expressions that refer to the request
+// or to documents that depend on the request cannot be evaluated. Expressions
+// that contain variables that would only be bound by such expressions cannot
+// be evaluated either.
+func (p *partialEvaluator) splitBody(body ast.Body) (known, unknown []*ast.Expr) {
+
+	isKnown := make([]bool, len(body))
+	for i, expr := range body {
+		isKnown[i] = !p.dependsOnRequest(expr)
+	}
+
+	for {
+		safe := ast.ReservedVars.Copy()
+		ok := make([]bool, len(body))
+
+		for progress := true; progress; {
+			progress = false
+			for i, expr := range body {
+				if !isKnown[i] || ok[i] {
+					continue
+				}
+				safe.Update(expr.OutputVars(safe))
+				if len(expr.Vars(partialEvalVarVisitorParams).Diff(safe)) == 0 {
+					ok[i] = true
+					progress = true
+				}
+			}
+		}
+
+		done := true
+		for i := range body {
+			if isKnown[i] && !ok[i] {
+				isKnown[i] = false
+				done = false
+			}
+		}
+
+		if done {
+			break
+		}
+	}
+
+	for i, expr := range body {
+		if isKnown[i] {
+			known = append(known, expr.Copy())
+		} else {
+			unknown = append(unknown, expr)
+		}
+	}
+
+	return known, unknown
+}
+
+func (p *partialEvaluator) dependsOnRequest(expr *ast.Expr) bool {
+
+	if len(expr.With) > 0 {
+		return true
+	}
+
+	found := false
+
+	ast.WalkVars(expr, func(v ast.Var) bool {
+		if v.Equal(ast.RequestRootDocument.Value) {
+			found = true
+		}
+		return found
+	})
+
+	ast.WalkRefs(expr, func(ref ast.Ref) bool {
+		if !found && ref[0].Equal(ast.DefaultRootDocument) {
+			found = p.overlapsUnknown(ref)
+		}
+		return found
+	})
+
+	return found
+}
+
+func (p *partialEvaluator) overlapsUnknown(ref ast.Ref) bool {
+	prefix := ref.GroundPrefix()
+	for _, path := range p.unknown {
+		if prefix.HasPrefix(path) || path.HasPrefix(prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveDataRefs replaces ground references to data in expr with the values
+// they refer to. References that are undefined are not replaced.
+func (p *partialEvaluator) resolveDataRefs(expr *ast.Expr) (*ast.Expr, error) {
+
+	result, err := ast.TransformRefs(expr, func(ref ast.Ref) (ast.Value, error) {
+
+		if !ref[0].Equal(ast.DefaultRootDocument) || !ref.IsGround() || p.overlapsUnknown(ref) {
+			return ref, nil
+		}
+
+		var value ast.Value
+		query := ast.NewBody(ast.Equality.Expr(ast.NewTerm(ref), ast.Wildcard))
+		t := p.params.NewTopdown(query)
+
+		err := Eval(t, func(t *Topdown) error {
+			var err error
+			value, err = ResolveRefs(PlugValue(ast.Wildcard.Value, t.Binding), t)
+			return err
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		if value == nil {
+			return ref, nil
+		}
+
+		return value, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result.(*ast.Expr), nil
+}
+
+// requestDependentPaths returns the paths of rules that refer to the request
+// either directly or through other rules.
+func requestDependentPaths(compiler *ast.Compiler) []ast.Ref {
+
+	graph := compiler.DependencyGraph()
+	visited := map[string]struct{}{}
+	var result []ast.Ref
+	var queue []ast.Ref
+
+	for _, mod := range compiler.Modules {
+		for _, rule := range mod.Rules {
+			found := false
+			ast.WalkVars(rule, func(v ast.Var) bool {
+				if v.Equal(ast.RequestRootDocument.Value) {
+					found = true
+				}
+				return found
+			})
+			if found {
+				queue = append(queue, rule.Path(mod.Package.Path))
+			}
+		}
+	}
+
+	for len(queue) > 0 {
+		path := queue[0]
+		queue = queue[1:]
+		if _, ok := visited[path.String()]; ok {
+			continue
+		}
+		visited[path.String()] = struct{}{}
+		result = append(result, path)
+		queue = append(queue, graph.Dependents(path)...)
+	}
+
+	return result
+}
+
+func sortedVars(vs ast.VarSet) []ast.Var {
+	names := make([]string, 0, len(vs))
+	for v := range vs {
+		names = append(names, string(v))
+	}
+	sort.Strings(names)
+	result := make([]ast.Var, len(names))
+	for i := range names {
+		result[i] = ast.Var(names[i])
+	}
+	return result
+}
@@ -0,0 +1,156 @@
+// Copyright 2016 The OPA Authors.  All rights reserved.
+// Use of this source code is governed by an Apache2
+// license that can be found in the LICENSE file.
+
+package topdown
+
+import (
+	"context"
+	"testing"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/storage"
+	"github.com/open-policy-agent/opa/util"
+)
+
+func TestPartialEval(t *testing.T) {
+
+	bd := `
+        {
+            "servers": [
+                {"id": "s1", "name": "app", "protocols": ["https", "ssh"], "ports": ["p1", "p2", "p3"]},
+                {"id": "s2", "name": "db", "protocols": ["mysql"], "ports": ["p3"]},
+                {"id": "s3", "name": "cache", "protocols": ["memcache", "http"], "ports": ["p3"]},
+                {"id": "s4", "name": "dev", "protocols": ["http"], "ports": ["p1", "p2"]}
+            ],
+            "networks": [
+                {"id": "n1", "public": false},
+                {"id": "n2", "public": false},
+                {"id": "n3", "public": true}
+            ],
+            "ports": [
+                {"id": "p1", "networks": ["n1"]},
+                {"id": "p2", "networks": ["n3"]},
+                {"id": "p3", "networks": ["n2"]}
+            ]
+        }
+    `
+
+	vd := `
+        package opa.example
+
+        import data.servers
+        import data.networks
+        import data.ports
+
+        public_servers[server] :-
+            server = servers[_],
+            server.ports[_] = ports[i].id,
+            ports[i].networks[_] = networks[j].id,
+            networks[j].public = true
+
+        allowed[server] :-
+            public_servers[server],
+            server.name = request.name,
+            request.port = ports[0].id
+    `
+
+	var doc map[string]interface{}
+
+	if err := util.UnmarshalJSON([]byte(bd), &doc); err != nil {
+		panic(err)
+	}
+
+	compiler := compileModules([]string{vd})
+	store := storage.New(storage.InMemoryWithJSONConfig(doc))
+
+	tests := []struct {
+		note     string
+		path     []string
+		requests []string
+		expected []string
+	}{
+		{"public servers", []string{"opa", "example", "public_servers"}, []string{"{}"}, []string{`
+            [
+                {"id": "s1", "name": "app", "protocols": ["https", "ssh"], "ports": ["p1", "p2", "p3"]},
+                {"id": "s4", "name": "dev", "protocols": ["http"], "ports": ["p1", "p2"]}
+            ]
+        `}},
+		{"request", []string{"opa", "example", "allowed"},
+			[]string{`{"name": "dev", "port": "p1"}`, `{"name": "dev", "port": "p2"}`, `{"name": "db", "port": "p1"}`},
+			[]string{`[{"id": "s4", "name": "dev", "protocols": ["http"], "ports": ["p1", "p2"]}]`, `[]`, `[]`}},
+	}
+
+	for _, tc := range tests {
+
+		rules := partialEvalOrDie(compiler, store, tc.path)
+
+		// Residual rules must not refer to data.
+		for _, rule := range rules {
+			ast.WalkRefs(rule, func(ref ast.Ref) bool {
+				if ref[0].Equal(ast.DefaultRootDocument) {
+					t.Fatalf("%v: expected data references to be resolved but got: %v", tc.note, rule)
+				}
+				return false
+			})
+		}
+
+		residual := ast.NewCompiler()
+		mod := &ast.Module{
+			Package: ast.MustParsePackage("package opa.example"),
+			Rules:   rules,
+		}
+
+		if residual.Compile(map[string]*ast.Module{"residual": mod}); residual.Failed() {
+			t.Fatalf("%v: unexpected error: %v", tc.note, residual.Errors)
+		}
+
+		empty := storage.New(storage.InMemoryConfig())
+
+		for i := range tc.requests {
+			assertTopDown(t, compiler, store, tc.note+" (original)", tc.path, tc.requests[i], tc.expected[i])
+			assertTopDown(t, residual, empty, tc.note+" (residual)", tc.path, tc.requests[i], tc.expected[i])
+		}
+	}
+}
+
+func TestPartialEvalDuplicates(t *testing.T) {
+
+	compiler := compileModules([]string{`
+        package ex
+
+        p :- data.a[_] = x, request.y = 1
+    `})
+
+	store := storage.New(storage.InMemoryWithJSONConfig(map[string]interface{}{
+		"a": []interface{}{1, 2, 3},
+	}))
+
+	rules := partialEvalOrDie(compiler, store, []string{"ex", "p"})
+	expected := ast.MustParseRule(`p = true :- eq(request.y, 1)`)
+
+	if len(rules) != 1 || !rules[0].Equal(expected) {
+		t.Fatalf("Expected exactly one residual rule %v but got: %v", expected, rules)
+	}
+}
+
+func partialEvalOrDie(compiler *ast.Compiler, store *storage.Storage, path []string) []*ast.Rule {
+
+	ctx := context.Background()
+	txn := storage.NewTransactionOrDie(ctx, store)
+	defer store.Close(ctx, txn)
+
+	ref := ast.Ref{ast.DefaultRootDocument}
+	for _, x := range path {
+		ref = append(ref, ast.StringTerm(x))
+	}
+
+	params := NewQueryParams(ctx, compiler, store, txn, nil, ref)
+
+	rules, err := PartialEval(params)
+	if err != nil {
+		panic(err)
+	}
+
+	return rules
+}
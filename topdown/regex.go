@@ -12,9 +12,17 @@ import (
 	"github.com/pkg/errors"
 )
 
+// regexpCacheMaxSize is the maximum number of compiled patterns kept in the
+// cache. Patterns may be supplied by the caller so the cache must be bounded.
+const regexpCacheMaxSize = 1000
+
 var regexpCacheLock = sync.Mutex{}
 var regexpCache map[string]*regexp.Regexp
 
+// compileRegexp compiles patterns that are not in the cache. It is a variable
+// so that tests can observe compilation.
+var compileRegexp = regexp.Compile
+
 func evalRegexMatch(t *Topdown, expr *ast.Expr, iter Iterator) error {
 	ops := expr.Terms.([]*ast.Term)
 	pat, err := ValueToString(ops[1].Value, t)
@@ -27,7 +35,7 @@ func evalRegexMatch(t *Topdown, expr *ast.Expr, iter Iterator) error {
 	}
 	re, err := getRegexp(pat)
 	if err != nil {
-		return err
+		return errors.Wrapf(err, "re_match")
 	}
 	if re.Match([]byte(input)) {
 		return iter(t)
@@ -35,15 +43,70 @@ func evalRegexMatch(t *Topdown, expr *ast.Expr, iter Iterator) error {
 	return nil
 }
 
+func evalRegexFind(t *Topdown, expr *ast.Expr, iter Iterator) error {
+	ops := expr.Terms.([]*ast.Term)
+	pat, err := ValueToString(ops[1].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "re_find: pattern value must be a string")
+	}
+	input, err := ValueToString(ops[2].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "re_find: input value must be a string")
+	}
+	re, err := getRegexp(pat)
+	if err != nil {
+		return errors.Wrapf(err, "re_find")
+	}
+	result := ast.Array{}
+	for _, m := range re.FindStringSubmatch(input) {
+		result = append(result, ast.StringTerm(m))
+	}
+	undo, err := evalEqUnify(t, result, ops[3].Value, nil, iter)
+	t.Unbind(undo)
+	return err
+}
+
+func evalRegexReplace(t *Topdown, expr *ast.Expr, iter Iterator) error {
+	ops := expr.Terms.([]*ast.Term)
+	pat, err := ValueToString(ops[1].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "re_replace: pattern value must be a string")
+	}
+	input, err := ValueToString(ops[2].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "re_replace: input value must be a string")
+	}
+	repl, err := ValueToString(ops[3].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "re_replace: replacement value must be a string")
+	}
+	re, err := getRegexp(pat)
+	if err != nil {
+		return errors.Wrapf(err, "re_replace")
+	}
+	result := ast.String(re.ReplaceAllString(input, repl))
+	undo, err := evalEqUnify(t, result, ops[4].Value, nil, iter)
+	t.Unbind(undo)
+	return err
+}
+
 func getRegexp(pat string) (*regexp.Regexp, error) {
 	regexpCacheLock.Lock()
 	defer regexpCacheLock.Unlock()
 	re, ok := regexpCache[pat]
 	if !ok {
 		var err error
-		re, err = regexp.Compile(string(pat))
+		re, err = compileRegexp(string(pat))
 		if err != nil {
-			return nil, errors.Wrapf(err, "re_match")
+			return nil, err
+		}
+		if len(regexpCache) >= regexpCacheMaxSize {
+			// Evict an arbitrary pattern to make room. Map iteration order is
+			// unspecified so this behaves like random replacement.
+			for k := range regexpCache {
+				delete(regexpCache, k)
+				break
+			}
 		}
 		regexpCache[pat] = re
 	}
@@ -0,0 +1,158 @@
+// Copyright 2016 The OPA Authors.  All rights reserved.
+// Use of this source code is governed by an Apache2
+// license that can be found in the LICENSE file.
+
+package topdown
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/pkg/errors"
+)
+
+func evalWeightedSample(t *Topdown, expr *ast.Expr, iter Iterator) error {
+	ops := expr.Terms.([]*ast.Term)
+
+	op1, err := ResolveRefs(ops[1].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v", ast.WeightedSample.Name)
+	}
+
+	items, ok := op1.(ast.Array)
+	if !ok {
+		return &Error{
+			Code:    TypeErr,
+			Message: fmt.Sprintf("%v: items must be an array not %T", ast.WeightedSample.Name, ops[1].Value),
+		}
+	}
+
+	op2, err := ResolveRefs(ops[2].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v", ast.WeightedSample.Name)
+	}
+
+	arr, ok := op2.(ast.Array)
+	if !ok {
+		return &Error{
+			Code:    TypeErr,
+			Message: fmt.Sprintf("%v: weights must be an array not %T", ast.WeightedSample.Name, ops[2].Value),
+		}
+	}
+
+	if len(items) != len(arr) {
+		return fmt.Errorf("%v: items and weights must have the same length", ast.WeightedSample.Name)
+	}
+
+	weights := make([]float64, len(arr))
+	for i := range arr {
+		w, err := ValueToFloat64(arr[i].Value, t)
+		if err != nil {
+			return errors.Wrapf(err, "%v: weights must be numbers", ast.WeightedSample.Name)
+		}
+		if w < 0 {
+			return fmt.Errorf("%v: weights must not be negative", ast.WeightedSample.Name)
+		}
+		weights[i] = w
+	}
+
+	seed, err := ValueToInt(ops[3].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v: seed must be an integer", ast.WeightedSample.Name)
+	}
+
+	i, err := weightedSample(weights, seed)
+	if err != nil {
+		return errors.Wrapf(err, "%v", ast.WeightedSample.Name)
+	}
+
+	undo, err := evalEqUnify(t, items[i].Value, ops[4].Value, nil, iter)
+	t.Unbind(undo)
+	return err
+}
+
+func evalLabelBucket(t *Topdown, expr *ast.Expr, iter Iterator) error {
+	ops := expr.Terms.([]*ast.Term)
+
+	s, err := ValueToString(ops[1].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v: input must be a string", ast.LabelBucket.Name)
+	}
+
+	op2, err := ResolveRefs(ops[2].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v", ast.LabelBucket.Name)
+	}
+
+	labels, ok := op2.(ast.Array)
+	if !ok {
+		return &Error{
+			Code:    TypeErr,
+			Message: fmt.Sprintf("%v: labels must be an array not %T", ast.LabelBucket.Name, ops[2].Value),
+		}
+	}
+
+	if len(labels) == 0 {
+		return fmt.Errorf("%v: labels must not be empty", ast.LabelBucket.Name)
+	}
+
+	for i := range labels {
+		if _, ok := labels[i].Value.(ast.String); !ok {
+			return &Error{
+				Code:    TypeErr,
+				Message: fmt.Sprintf("%v: labels must be strings not %T", ast.LabelBucket.Name, labels[i].Value),
+			}
+		}
+	}
+
+	result := labels[labelBucket(s, len(labels))].Value
+
+	undo, err := evalEqUnify(t, result, ops[3].Value, nil, iter)
+	t.Unbind(undo)
+	return err
+}
+
+// labelBucket returns the index of the bucket that s is assigned to. The index
+// is computed from the FNV-1a hash of s so that it does not change between
+// evaluations or processes.
+func labelBucket(s string, n int) int {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return int(h.Sum64() % uint64(n))
+}
+
+// weightedSample returns the index of an element selected with probability
+// proportional to its weight. The random source is seeded with seed so that
+// the same weights and seed always produce the same index.
+func weightedSample(weights []float64, seed int64) (int, error) {
+
+	var total float64
+	for _, w := range weights {
+		total += w
+	}
+
+	if total <= 0 {
+		return 0, fmt.Errorf("weights must sum to a positive number")
+	}
+
+	target := rand.New(rand.NewSource(seed)).Float64() * total
+	last := 0
+
+	for i, w := range weights {
+		if w == 0 {
+			continue
+		}
+		if target < w {
+			return i, nil
+		}
+		target -= w
+		last = i
+	}
+
+	// Floating point error may leave a small remainder after subtracting all
+	// of the weights. In that case, select the last element with a non-zero
+	// weight.
+	return last, nil
+}
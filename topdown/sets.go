@@ -6,6 +6,7 @@ package topdown
 
 import (
 	"fmt"
+	"math/big"
 
 	"github.com/open-policy-agent/opa/ast"
 	"github.com/pkg/errors"
@@ -44,3 +45,85 @@ func evalSetDiff(t *Topdown, expr *ast.Expr, iter Iterator) (err error) {
 	t.Unbind(undo)
 	return err
 }
+
+func evalSetComplement(t *Topdown, expr *ast.Expr, iter Iterator) (err error) {
+	ops := expr.Terms.([]*ast.Term)
+	op1, err := ResolveRefs(ops[1].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "set_complement")
+	}
+
+	universe, ok := op1.(*ast.Set)
+	if !ok {
+		return &Error{
+			Code:    TypeErr,
+			Message: fmt.Sprintf("set_complement: universe must be set not %T", ops[1].Value),
+		}
+	}
+
+	op2, err := ResolveRefs(ops[2].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "set_complement")
+	}
+
+	s, ok := op2.(*ast.Set)
+	if !ok {
+		return &Error{
+			Code:    TypeErr,
+			Message: fmt.Sprintf("set_complement: second input argument must be set not %T", ops[2].Value),
+		}
+	}
+
+	undo, err := evalEqUnify(t, universe.Diff(s), ops[3].Value, nil, iter)
+	t.Unbind(undo)
+	return err
+}
+
+func evalJaccard(t *Topdown, expr *ast.Expr, iter Iterator) (err error) {
+	ops := expr.Terms.([]*ast.Term)
+	op1, err := ResolveRefs(ops[1].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "jaccard")
+	}
+
+	s1, ok := op1.(*ast.Set)
+	if !ok {
+		return &Error{
+			Code:    TypeErr,
+			Message: fmt.Sprintf("jaccard: first input argument must be set not %T", ops[1].Value),
+		}
+	}
+
+	op2, err := ResolveRefs(ops[2].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "jaccard")
+	}
+
+	s2, ok := op2.(*ast.Set)
+	if !ok {
+		return &Error{
+			Code:    TypeErr,
+			Message: fmt.Sprintf("jaccard: second input argument must be set not %T", ops[2].Value),
+		}
+	}
+
+	var intersection int
+	for _, x := range *s1 {
+		if s2.Contains(x) {
+			intersection++
+		}
+	}
+
+	union := len(*s1) + len(*s2) - intersection
+
+	// The similarity of two empty sets is defined to be 1 because the sets are
+	// identical.
+	similarity := big.NewFloat(1)
+	if union > 0 {
+		similarity.Quo(big.NewFloat(float64(intersection)), big.NewFloat(float64(union)))
+	}
+
+	undo, err := evalEqUnify(t, floatToASTNumber(similarity), ops[3].Value, nil, iter)
+	t.Unbind(undo)
+	return err
+}
@@ -5,7 +5,9 @@
 package topdown
 
 import (
+	"encoding/json"
 	"fmt"
+	"net"
 	"strings"
 
 	"github.com/open-policy-agent/opa/ast"
@@ -204,3 +206,225 @@ func evalUpper(t *Topdown, expr *ast.Expr, iter Iterator) error {
 	t.Unbind(undo)
 	return err
 }
+
+func evalReplace(t *Topdown, expr *ast.Expr, iter Iterator) error {
+	ops := expr.Terms.([]*ast.Term)
+
+	orig, err := ValueToString(ops[1].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v: original value must be a string", ast.Replace.Name)
+	}
+
+	old, err := ValueToString(ops[2].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v: old value must be a string", ast.Replace.Name)
+	}
+
+	new, err := ValueToString(ops[3].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v: new value must be a string", ast.Replace.Name)
+	}
+
+	s := ast.String(strings.Replace(orig, old, new, -1))
+
+	undo, err := evalEqUnify(t, s, ops[4].Value, nil, iter)
+	t.Unbind(undo)
+	return err
+}
+
+func evalTrim(t *Topdown, expr *ast.Expr, iter Iterator) error {
+	ops := expr.Terms.([]*ast.Term)
+
+	orig, err := ValueToString(ops[1].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v: original value must be a string", ast.Trim.Name)
+	}
+
+	cutset, err := ValueToString(ops[2].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v: cutset must be a string", ast.Trim.Name)
+	}
+
+	s := ast.String(strings.Trim(orig, cutset))
+
+	undo, err := evalEqUnify(t, s, ops[3].Value, nil, iter)
+	t.Unbind(undo)
+	return err
+}
+
+func evalTrimPrefixAny(t *Topdown, expr *ast.Expr, iter Iterator) error {
+	ops := expr.Terms.([]*ast.Term)
+
+	orig, err := ValueToString(ops[1].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v: original value must be a string", ast.TrimPrefixAny.Name)
+	}
+
+	prefixes, err := ValueToStrings(ops[2].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v: prefixes must be array of strings", ast.TrimPrefixAny.Name)
+	}
+
+	var longest string
+	for _, prefix := range prefixes {
+		if len(prefix) > len(longest) && strings.HasPrefix(orig, prefix) {
+			longest = prefix
+		}
+	}
+
+	s := ast.String(orig[len(longest):])
+
+	undo, err := evalEqUnify(t, s, ops[3].Value, nil, iter)
+	t.Unbind(undo)
+	return err
+}
+
+func evalSprintf(t *Topdown, expr *ast.Expr, iter Iterator) error {
+	ops := expr.Terms.([]*ast.Term)
+
+	format, err := ValueToString(ops[1].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v: format must be a string", ast.Sprintf.Name)
+	}
+
+	v, err := ResolveRefs(ops[2].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v", ast.Sprintf.Name)
+	}
+
+	arr, ok := v.(ast.Array)
+	if !ok {
+		return fmt.Errorf("%v: arguments must be an array: illegal argument: %v", ast.Sprintf.Name, v)
+	}
+
+	args := make([]interface{}, len(arr))
+
+	for i := range arr {
+		x, err := sprintfArg(arr[i].Value, t)
+		if err != nil {
+			return errors.Wrapf(err, "%v", ast.Sprintf.Name)
+		}
+		args[i] = x
+	}
+
+	s := ast.String(fmt.Sprintf(format, args...))
+
+	undo, err := evalEqUnify(t, s, ops[3].Value, nil, iter)
+	t.Unbind(undo)
+	return err
+}
+
+// sprintfArg returns the native Go value to substitute into a format string
+// for v. Integral numbers are converted to integers so that they can be used
+// with verbs such as %d and render without a fractional part. Composite values
+// and null are rendered as JSON.
+func sprintfArg(v ast.Value, resolver Resolver) (interface{}, error) {
+	x, err := ValueToInterface(v, resolver)
+	if err != nil {
+		return nil, err
+	}
+	switch x := x.(type) {
+	case string, bool:
+		return x, nil
+	case json.Number:
+		f := jsonNumberToFloat(x)
+		if f.IsInt() {
+			i, _ := f.Int(nil)
+			return i, nil
+		}
+		r, _ := f.Float64()
+		return r, nil
+	default:
+		bs, err := json.Marshal(x)
+		if err != nil {
+			return nil, err
+		}
+		return string(bs), nil
+	}
+}
+
+func evalCanonicalHost(t *Topdown, expr *ast.Expr, iter Iterator) error {
+	ops := expr.Terms.([]*ast.Term)
+
+	orig, err := ValueToString(ops[1].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v: host must be a string", ast.CanonicalHost.Name)
+	}
+
+	s := ast.String(canonicalHost(orig))
+
+	undo, err := evalEqUnify(t, s, ops[2].Value, nil, iter)
+	t.Unbind(undo)
+	return err
+}
+
+func canonicalHost(s string) string {
+	s = strings.ToLower(s)
+
+	host, port, err := net.SplitHostPort(s)
+	if err != nil {
+		// The host does not include a port.
+		return strings.TrimSuffix(s, ".")
+	}
+
+	host = strings.TrimSuffix(host, ".")
+
+	switch port {
+	case "80", "443":
+		if strings.Contains(host, ":") {
+			return "[" + host + "]"
+		}
+		return host
+	default:
+		return net.JoinHostPort(host, port)
+	}
+}
+
+func evalParseKV(builtin *ast.Builtin, skipMalformed bool) BuiltinFunc {
+	return func(t *Topdown, expr *ast.Expr, iter Iterator) error {
+		ops := expr.Terms.([]*ast.Term)
+
+		s, err := ValueToString(ops[1].Value, t)
+		if err != nil {
+			return errors.Wrapf(err, "%v: input value must be a string", builtin.Name)
+		}
+
+		pairSep, err := ValueToString(ops[2].Value, t)
+		if err != nil {
+			return errors.Wrapf(err, "%v: pair separator must be a string", builtin.Name)
+		}
+
+		kvSep, err := ValueToString(ops[3].Value, t)
+		if err != nil {
+			return errors.Wrapf(err, "%v: key/value separator must be a string", builtin.Name)
+		}
+
+		if pairSep == "" || kvSep == "" {
+			return fmt.Errorf("%v: separators must not be empty", builtin.Name)
+		}
+
+		obj := ast.Object{}
+
+		if s != "" {
+			for _, pair := range strings.Split(s, pairSep) {
+				kv := strings.SplitN(pair, kvSep, 2)
+				if len(kv) != 2 {
+					if skipMalformed {
+						continue
+					}
+					return fmt.Errorf("%v: malformed pair: %q", builtin.Name, pair)
+				}
+				k, v := ast.StringTerm(kv[0]), ast.StringTerm(kv[1])
+				if existing := obj.Get(k); existing != nil {
+					existing.Value = v.Value
+				} else {
+					obj = append(obj, ast.Item(k, v))
+				}
+			}
+		}
+
+		undo, err := evalEqUnify(t, obj, ops[4].Value, nil, iter)
+		t.Unbind(undo)
+		return err
+	}
+}
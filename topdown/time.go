@@ -0,0 +1,54 @@
+// Copyright 2016 The OPA Authors.  All rights reserved.
+// Use of this source code is governed by an Apache2
+// license that can be found in the LICENSE file.
+
+package topdown
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/pkg/errors"
+)
+
+func evalInBusinessHours(t *Topdown, expr *ast.Expr, iter Iterator) error {
+	ops := expr.Terms.([]*ast.Term)
+
+	ns, err := ValueToInt(ops[1].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v: timestamp must be an integer", ast.InBusinessHours.Name)
+	}
+
+	start, err := valueToHour(ops[2].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v: start hour", ast.InBusinessHours.Name)
+	}
+
+	end, err := valueToHour(ops[3].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v: end hour", ast.InBusinessHours.Name)
+	}
+
+	// Timestamps are always interpreted in UTC so that the result does not
+	// depend on the local time zone of the host.
+	hour := time.Unix(0, ns).UTC().Hour()
+	result := ast.Boolean(hour >= start && hour < end)
+
+	undo, err := evalEqUnify(t, result, ops[4].Value, nil, iter)
+	t.Unbind(undo)
+	return err
+}
+
+// valueToHour returns the hour represented by v. The hour must be an integer
+// in the range [0, 24].
+func valueToHour(v ast.Value, resolver Resolver) (int, error) {
+	h, err := ValueToInt(v, resolver)
+	if err != nil {
+		return 0, errors.Wrapf(err, "must be an integer")
+	}
+	if h < 0 || h > 24 {
+		return 0, fmt.Errorf("must be between 0 and 24: %v", h)
+	}
+	return int(h), nil
+}
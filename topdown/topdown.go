@@ -26,12 +26,22 @@ type Topdown struct {
 	Previous *Topdown
 	Store    *storage.Storage
 	Tracer   Tracer
+	Metrics  *Metrics
 	Context  context.Context
 
-	txn   storage.Transaction
-	cache *contextcache
-	qid   uint64
-	redos *redoStack
+	txn       storage.Transaction
+	cache     *contextcache
+	qid       uint64
+	redos     *redoStack
+	overrides []*override
+	evals     *uint64
+}
+
+// override represents a document under data that has been replaced by a with
+// modifier.
+type override struct {
+	path  ast.Ref
+	value ast.Value
 }
 
 // ResetQueryIDs resets the query ID generator. This is only for test purposes.
@@ -83,6 +93,7 @@ func New(ctx context.Context, query ast.Body, compiler *ast.Compiler, store *sto
 		cache:    newContextCache(),
 		qid:      qidFactory.Next(),
 		redos:    &redoStack{},
+		evals:    new(uint64),
 	}
 }
 
@@ -154,11 +165,16 @@ func (t *Topdown) Resolve(ref ast.Ref) (interface{}, error) {
 		ref = cpy
 	}
 
+	if len(t.overrides) > 0 {
+		return resolveOverrides(t, ref)
+	}
+
 	path, err := storage.NewPathForRef(ref)
 	if err != nil {
 		return nil, err
 	}
 
+	t.Metrics.incr(storeReads)
 	return t.Store.Read(t.Context, t.txn, path)
 }
 
@@ -169,6 +185,27 @@ func (t *Topdown) Step() *Topdown {
 	return &cpy
 }
 
+// cancelCheckInterval controls how often the context is checked for
+// cancellation during evaluation.
+const cancelCheckInterval = 128
+
+// checkCancel returns an error if the context has been cancelled. To keep the
+// overhead low, the context is only checked every cancelCheckInterval calls.
+func (t *Topdown) checkCancel() error {
+	if t.Context == nil || t.evals == nil {
+		return nil
+	}
+	n := *t.evals
+	*t.evals++
+	if n%cancelCheckInterval != 0 {
+		return nil
+	}
+	if err := t.Context.Err(); err != nil {
+		return cancelErr(err)
+	}
+	return nil
+}
+
 func (t *Topdown) traceEnter(node interface{}) {
 	if t.tracingEnabled() {
 		evt := t.makeEvent(EnterOp, node)
@@ -208,6 +245,14 @@ func (t *Topdown) traceFail(node interface{}) {
 	}
 }
 
+func (t *Topdown) traceNote(node interface{}) {
+	if t.tracingEnabled() {
+		evt := t.makeEvent(NoteOp, node)
+		t.flushRedos(evt)
+		t.Tracer.Trace(t, evt)
+	}
+}
+
 func (t *Topdown) tracingEnabled() bool {
 	return t.Tracer != nil && t.Tracer.Enabled()
 }
@@ -266,10 +311,11 @@ func (t *Topdown) flushRedos(evt *Event) {
 
 func (t *Topdown) makeEvent(op Op, node interface{}) *Event {
 	evt := Event{
-		Op:      op,
-		Node:    node,
-		QueryID: t.qid,
-		Locals:  t.Locals.Copy(),
+		Op:       op,
+		Node:     node,
+		QueryID:  t.qid,
+		Locals:   t.Locals.Copy(),
+		resolver: t,
 	}
 	if t.Previous != nil {
 		evt.ParentID = t.Previous.qid
@@ -280,7 +326,9 @@ func (t *Topdown) makeEvent(op Op, node interface{}) *Event {
 // contextcache stores the result of rule evaluation for a query. The contextcache
 // is inherited by child contexts. The cache is consulted when virtual document
 // references are evaluated. If a miss occurs, the virtual document is generated
-// and the cache is updated.
+// and the cache is updated. Because the cache is only shared by contexts
+// evaluating the same query with the same request, rule results can be reused
+// regardless of where the rule is referenced.
 type contextcache struct {
 	partialobjs map[*ast.Rule]map[ast.Value]ast.Value
 	complete    map[*ast.Rule]ast.Value
@@ -314,6 +362,10 @@ const (
 	// TypeErr indicates evaluation stopped because an expression was applied to
 	// a value of an inappropriate type.
 	TypeErr = iota
+
+	// CancelErr indicates evaluation stopped because the context passed to
+	// New or Query was cancelled or its deadline was exceeded.
+	CancelErr = iota
 )
 
 func (e *Error) Error() string {
@@ -327,6 +379,20 @@ func conflictErr(query interface{}, kind string, rule *ast.Rule) error {
 	}
 }
 
+func conflictErrObjectComprehension(key ast.Value) error {
+	return &Error{
+		Code:    ConflictErr,
+		Message: fmt.Sprintf("multiple values for %v: object comprehensions must produce exactly one value for each key", key),
+	}
+}
+
+func cancelErr(err error) error {
+	return &Error{
+		Code:    CancelErr,
+		Message: fmt.Sprintf("evaluation cancelled: %v", err),
+	}
+}
+
 func typeErrUnsupportedBuiltin(expr *ast.Expr) error {
 	return &Error{
 		Code:    TypeErr,
@@ -341,6 +407,13 @@ func typeErrObjectKey(rule *ast.Rule, v ast.Value) error {
 	}
 }
 
+func typeErrObjectComprehensionKey(comp *ast.ObjectComprehension, v ast.Value) error {
+	return &Error{
+		Code:    TypeErr,
+		Message: fmt.Sprintf("%v produced illegal object key type %T", comp, v),
+	}
+}
+
 func typeErrSetLookupDereference(rule *ast.Rule, ref ast.Ref, loc *ast.Location) error {
 	return &Error{
 		Code:    TypeErr,
@@ -449,7 +522,7 @@ func PlugTerm(term *ast.Term, binding Binding) *ast.Term {
 		plugged.Value = PlugValue(v, binding)
 		return &plugged
 
-	case *ast.ArrayComprehension:
+	case *ast.ArrayComprehension, *ast.ObjectComprehension, *ast.SetComprehension:
 		plugged := *term
 		plugged.Value = PlugValue(v, binding)
 		return &plugged
@@ -472,7 +545,7 @@ func PlugValue(v ast.Value, binding func(ast.Value) ast.Value) ast.Value {
 		}
 		return v
 
-	case *ast.ArrayComprehension:
+	case *ast.ArrayComprehension, *ast.ObjectComprehension, *ast.SetComprehension:
 		b := binding(v)
 		if b == nil {
 			return v
@@ -532,6 +605,7 @@ type QueryParams struct {
 	Transaction storage.Transaction
 	Request     ast.Value
 	Tracer      Tracer
+	Metrics     *Metrics
 	Path        ast.Ref
 }
 
@@ -552,6 +626,7 @@ func (q *QueryParams) NewTopdown(body ast.Body) *Topdown {
 	t := New(q.Context, body, q.Compiler, q.Store, q.Transaction)
 	t.Request = q.Request
 	t.Tracer = q.Tracer
+	t.Metrics = q.Metrics
 	return t
 }
 
@@ -582,15 +657,78 @@ func (qrs *QueryResultSet) Add(qr *QueryResult) {
 // the params' Request field contains values that are non-ground (i.e., they
 // contain variables), then the result may contain multiple entries.
 func Query(params *QueryParams) (QueryResultSet, error) {
-	return queryN(params)
+	defer params.Metrics.StartTimer(evalTime)()
+	qrs := QueryResultSet{}
+	err := queryN(params, newQueryBody(params.Path), func(qr *QueryResult) error {
+		qrs.Add(qr)
+		return nil
+	})
+	return qrs, err
+}
+
+// QueryStream is like Query except that the iterator is invoked with each
+// result as it is produced instead of accumulating the results in a set. If
+// the iterator returns an error, evaluation stops and the error is returned.
+func QueryStream(params *QueryParams, iter func(*QueryResult) error) error {
+	defer params.Metrics.StartTimer(evalTime)()
+	return queryN(params, newQueryBody(params.Path), iter)
+}
+
+// QueryMembers is like QueryStream except that the params Path field must
+// refer to a partial set and the iterator is invoked with each member of the
+// set as the result. The set is not built in memory. As a consequence, members
+// produced by more than one rule or variable binding are delivered more than
+// once and the iterator is not invoked if the set is empty.
+func QueryMembers(params *QueryParams, iter func(*QueryResult) error) error {
+	defer params.Metrics.StartTimer(evalTime)()
+	if rules := params.Compiler.GetRulesExact(params.Path); len(rules) == 0 || rules[0].DocKind() != ast.PartialSetDoc {
+		return fmt.Errorf("%v: not a partial set", params.Path)
+	}
+	return queryMembers(params, iter)
+}
+
+// PreparedQuery represents a query for a document that can be evaluated
+// repeatedly without rebuilding the query on each call. A PreparedQuery may be
+// evaluated concurrently by multiple goroutines as long as each evaluation uses
+// its own transaction.
+type PreparedQuery struct {
+	compiler *ast.Compiler
+	store    *storage.Storage
+	path     ast.Ref
+	query    ast.Body
+}
+
+// NewPreparedQuery returns a new PreparedQuery for the document referred to by
+// path.
+func NewPreparedQuery(compiler *ast.Compiler, store *storage.Storage, path ast.Ref) *PreparedQuery {
+	return &PreparedQuery{
+		compiler: compiler,
+		store:    store,
+		path:     path,
+		query:    newQueryBody(path),
+	}
+}
+
+// Eval returns the value of the prepared document. See Query for details on
+// how the request affects the result.
+func (pq *PreparedQuery) Eval(ctx context.Context, txn storage.Transaction, request ast.Value) (QueryResultSet, error) {
+	params := NewQueryParams(ctx, pq.compiler, pq.store, txn, request, pq.path)
+	qrs := QueryResultSet{}
+	err := queryN(params, pq.query, func(qr *QueryResult) error {
+		qrs.Add(qr)
+		return nil
+	})
+	return qrs, err
+}
+
+func newQueryBody(path ast.Ref) ast.Body {
+	return ast.NewBody(ast.Equality.Expr(ast.RefTerm(path...), ast.Wildcard))
 }
 
 // queryOne returns a QueryResultSet containing the value of the document
-// referred to by the params Path field. If the document is not defined, nil is
-// returned.
-func queryOne(params *QueryParams) (QueryResultSet, error) {
+// referred to by query. If the document is not defined, nil is returned.
+func queryOne(params *QueryParams, query ast.Body) (QueryResultSet, error) {
 
-	query := ast.NewBody(ast.Equality.Expr(ast.RefTerm(params.Path...), ast.Wildcard))
 	t := params.NewTopdown(query)
 	var result interface{} = struct{}{}
 	var err error
@@ -612,51 +750,94 @@ func queryOne(params *QueryParams) (QueryResultSet, error) {
 	return QueryResultSet{&QueryResult{result, nil}}, nil
 }
 
-// queryN returns a QueryResultSet containing the values of the document
-// referred to by the params Path field. There may be zero or more values
-// depending on the values of the params' Request field.
+// queryN invokes the iterator with the values of the document referred to by
+// the params Path field. There may be zero or more values depending on the
+// values of the params' Request field.
 //
 // For example, if the request refers to one or more undefined documents, the
-// set will be empty. On the other hand, if the request contain non-ground
-// references where there are multiple valid sets of bindings, the result set
-// may contain multiple values.
-func queryN(params *QueryParams) (QueryResultSet, error) {
-
-	qrs := QueryResultSet{}
-	vars := ast.NewVarSet()
-	resolver := resolver{params.Context, params.Store, params.Transaction}
-
-	vis := ast.NewVarVisitor().WithParams(ast.VarVisitorParams{
-		SkipRefHead:  true,
-		SkipClosures: true,
-	})
+// iterator will not be invoked. On the other hand, if the request contain
+// non-ground references where there are multiple valid sets of bindings, the
+// iterator may be invoked multiple times.
+func queryN(params *QueryParams, query ast.Body, iter func(*QueryResult) error) error {
 
-	ast.Walk(vis, params.Request)
-	vars = vis.Vars()
+	vars := requestVars(params.Request)
 
-	err := evalRequest(params, func(root *Topdown) error {
+	return evalRequest(params, func(root *Topdown) error {
 
 		params.Request = PlugValue(root.Request, root.Binding)
-		result, err := queryOne(params)
+		result, err := queryOne(params, query)
 
 		if err != nil || result.Undefined() {
 			return err
 		}
 
-		bindings := map[string]interface{}{}
-		for v := range vars {
-			binding, err := ValueToInterface(PlugValue(v, root.Binding), resolver)
+		bindings, err := requestBindings(params, vars, root)
+		if err != nil {
+			return err
+		}
+
+		return iter(&QueryResult{result[0].Result, bindings})
+	})
+}
+
+// queryMembers invokes the iterator with each member of the partial set
+// referred to by the params Path field. See queryN for how the params' Request
+// field affects evaluation.
+func queryMembers(params *QueryParams, iter func(*QueryResult) error) error {
+
+	vars := requestVars(params.Request)
+	query := ast.NewBody(ast.NewExpr(ast.RefTerm(append(params.Path.Copy(), ast.Wildcard)...)))
+
+	return evalRequest(params, func(root *Topdown) error {
+
+		params.Request = PlugValue(root.Request, root.Binding)
+		var bindings map[string]interface{}
+
+		return Eval(params.NewTopdown(query), func(t *Topdown) error {
+
+			member, err := ValueToInterface(PlugValue(ast.Wildcard.Value, t.Binding), t)
 			if err != nil {
 				return err
 			}
-			bindings[v.String()] = binding
-		}
 
-		qrs.Add(&QueryResult{result[0].Result, bindings})
-		return nil
+			if bindings == nil {
+				if bindings, err = requestBindings(params, vars, root); err != nil {
+					return err
+				}
+			}
+
+			return iter(&QueryResult{member, bindings})
+		})
 	})
+}
 
-	return qrs, err
+// requestVars returns the variables in the request that are bound by
+// evaluating it.
+func requestVars(request ast.Value) ast.VarSet {
+
+	vis := ast.NewVarVisitor().WithParams(ast.VarVisitorParams{
+		SkipRefHead:  true,
+		SkipClosures: true,
+	})
+
+	ast.Walk(vis, request)
+	return vis.Vars()
+}
+
+func requestBindings(params *QueryParams, vars ast.VarSet, root *Topdown) (map[string]interface{}, error) {
+
+	resolver := resolver{params.Context, params.Store, params.Transaction}
+	bindings := map[string]interface{}{}
+
+	for v := range vars {
+		binding, err := ValueToInterface(PlugValue(v, root.Binding), resolver)
+		if err != nil {
+			return nil, err
+		}
+		bindings[v.String()] = binding
+	}
+
+	return bindings, nil
 }
 
 // evalRequest evaluates the params' request field. The iterator is called with
@@ -851,6 +1032,10 @@ func ValueToStrings(v ast.Value, resolver Resolver) ([]string, error) {
 
 func eval(t *Topdown, iter Iterator) error {
 
+	if err := t.checkCancel(); err != nil {
+		return err
+	}
+
 	if t.Index >= len(t.Query) {
 		return iter(t)
 	}
@@ -859,7 +1044,12 @@ func eval(t *Topdown, iter Iterator) error {
 		return evalNegated(t, iter)
 	}
 
+	if len(t.Current().With) > 0 {
+		return evalWith(t, iter)
+	}
+
 	t.traceEval(t.Current())
+	t.Metrics.incr(evalExprs)
 
 	// isRedo indicates if the expression's terms are defined at least once. If
 	// any of the terms are undefined, then the closure below will not run (but
@@ -930,6 +1120,222 @@ func evalNegated(t *Topdown, iter Iterator) error {
 	return nil
 }
 
+// evalWith evaluates the current expression with the documents referred to by
+// the expression's with modifiers replaced. The replacements are only visible
+// while the expression itself is evaluated.
+func evalWith(t *Topdown, iter Iterator) error {
+
+	expr := t.Current()
+
+	values := make([]*ast.Term, len(expr.With))
+	for i := range expr.With {
+		values[i] = expr.With[i].Value
+	}
+
+	return evalTermsRec(t, func(t *Topdown) error {
+
+		request := t.Request
+		overrides := t.overrides
+
+		for _, with := range expr.With {
+
+			value, err := ResolveRefs(PlugValue(with.Value.Value, t.Binding), t)
+			if err != nil {
+				if storage.IsNotFound(err) {
+					return nil
+				}
+				return err
+			}
+
+			if !value.IsGround() {
+				return fmt.Errorf("unbound variable: %v", value)
+			}
+
+			var target ast.Ref
+			switch v := with.Target.Value.(type) {
+			case ast.Var:
+				target = ast.Ref{with.Target}
+			case ast.Ref:
+				target = v
+			}
+
+			if target.HasPrefix(ast.RequestRootRef) {
+				request, err = setValueAtPath(request, target[1:], value)
+				if err != nil {
+					return err
+				}
+			} else {
+				cpy := make([]*override, len(overrides), len(overrides)+1)
+				copy(cpy, overrides)
+				overrides = append(cpy, &override{path: target, value: value})
+			}
+		}
+
+		cpy := *expr
+		cpy.With = nil
+
+		// Evaluate the expression in a child context so that the replaced
+		// documents are restored once the expression has been evaluated. The
+		// child only inherits variable bindings because references bound in t
+		// may refer to documents that have been replaced. The child gets a new
+		// cache because results of rules evaluated with different documents
+		// cannot be reused.
+		locals := ast.NewValueMap()
+		t.Locals.Iter(func(k, v ast.Value) bool {
+			if _, ok := k.(ast.Var); ok {
+				locals.Put(k, v)
+			}
+			return false
+		})
+
+		child := t.Child(ast.NewBody(&cpy), locals)
+		child.Request = request
+		child.overrides = overrides
+		child.cache = newContextCache()
+
+		vars := cpy.Vars(ast.VarVisitorParams{
+			SkipRefHead:          true,
+			SkipClosures:         true,
+			SkipBuiltinOperators: true,
+		})
+
+		return Eval(child, func(child *Topdown) error {
+
+			// Copy bindings for variables in the expression back into t. The
+			// values are resolved inside the child so that they do not refer
+			// to documents that are only replaced inside the child.
+			var undo *Undo
+			for v := range vars {
+				if t.Binding(v) != nil {
+					continue
+				}
+				b := child.Binding(v)
+				if b == nil {
+					continue
+				}
+				value, err := ResolveRefs(PlugValue(b, child.Binding), child)
+				if err != nil {
+					t.Unbind(undo)
+					return err
+				}
+				undo = t.Bind(v, value, undo)
+			}
+
+			err := eval(t.Step(), iter)
+			t.Unbind(undo)
+			return err
+		})
+
+	}, values)
+}
+
+// resolveOverrides returns the native Go value referred to by ref taking into
+// account documents replaced by with modifiers.
+func resolveOverrides(t *Topdown, ref ast.Ref) (interface{}, error) {
+
+	// If the ref refers to a replaced document (or a document contained inside
+	// of a replaced document), the value is obtained from the replacement.
+	for i := len(t.overrides) - 1; i >= 0; i-- {
+		o := t.overrides[i]
+		if ref.HasPrefix(o.path) {
+			v, ok := valueAtPath(o.value, ast.Array(ref[len(o.path):]))
+			if !ok {
+				return nil, &storage.Error{
+					Code:    storage.NotFoundErr,
+					Message: fmt.Sprintf("bad path: %v, document does not exist", ref),
+				}
+			}
+			return ValueToInterface(v, t)
+		}
+	}
+
+	path, err := storage.NewPathForRef(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	t.Metrics.incr(storeReads)
+	doc, err := t.Store.Read(t.Context, t.txn, path)
+
+	// If the ref refers to a document that contains replaced documents, the
+	// replacements are applied to a copy of the document.
+	var result ast.Value
+
+	for _, o := range t.overrides {
+		if !o.path.HasPrefix(ref) {
+			continue
+		}
+		if result == nil {
+			if err != nil {
+				if !storage.IsNotFound(err) {
+					return nil, err
+				}
+				result = ast.Object{}
+			} else if result, err = ast.InterfaceToValue(doc); err != nil {
+				return nil, err
+			}
+		}
+		if result, err = setValueAtPath(result, o.path[len(ref):], o.value); err != nil {
+			return nil, err
+		}
+	}
+
+	if result == nil {
+		return doc, err
+	}
+
+	return ValueToInterface(result, t)
+}
+
+// setValueAtPath returns a copy of doc with the value at path replaced. Objects
+// are created for path elements that do not exist.
+func setValueAtPath(doc ast.Value, path ast.Ref, value ast.Value) (ast.Value, error) {
+
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	var obj ast.Object
+
+	switch v := doc.(type) {
+	case nil:
+		obj = ast.Object{}
+	case ast.Object:
+		obj = v
+	default:
+		return nil, fmt.Errorf("with target %v conflicts with non-object document", path)
+	}
+
+	key := path[0]
+	var child ast.Value
+	if term := obj.Get(key); term != nil {
+		child = term.Value
+	}
+
+	updated, err := setValueAtPath(child, path[1:], value)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(ast.Object, 0, len(obj)+1)
+	found := false
+
+	for _, item := range obj {
+		if item[0].Equal(key) {
+			result = append(result, ast.Item(key, ast.NewTerm(updated)))
+			found = true
+		} else {
+			result = append(result, item)
+		}
+	}
+
+	if !found {
+		result = append(result, ast.Item(key, ast.NewTerm(updated)))
+	}
+
+	return result, nil
+}
+
 func evalExpr(t *Topdown, iter Iterator) error {
 	expr := PlugExpr(t.Current(), t.Binding)
 	switch tt := expr.Terms.(type) {
@@ -1042,6 +1448,13 @@ func evalRefRec(t *Topdown, ref ast.Ref, iter Iterator) error {
 		return iter(t)
 	}
 
+	// Check if the prefix refers to a document replaced by a with modifier.
+	for i := len(t.overrides) - 1; i >= 0; i-- {
+		if o := t.overrides[i]; prefix.HasPrefix(o.path) {
+			return evalRefRuleResult(t, ref, ref[len(o.path):], o.value, iter)
+		}
+	}
+
 	// Check if the prefix refers to a virtual document.
 	var rules []*ast.Rule
 	path := prefix
@@ -1273,6 +1686,17 @@ func evalRefRule(t *Topdown, ref ast.Ref, path ast.Ref, rules []*ast.Rule, iter
 
 	suffix := ref[len(path):]
 
+	// Skip rules that cannot produce a value for the request. Partial documents
+	// are defined even if none of the rules apply so in that case all of the
+	// rules are evaluated.
+	if index := t.Compiler.GetRuleIndex(path); index != nil {
+		if indexed := index.Lookup(t.Request); len(indexed) > 0 {
+			rules = indexed
+		} else if rules[0].DocKind() == ast.CompleteDoc {
+			return nil
+		}
+	}
+
 	switch rules[0].DocKind() {
 
 	case ast.CompleteDoc:
@@ -1312,46 +1736,80 @@ func evalRefRuleCompleteDoc(t *Topdown, ref ast.Ref, suffix ast.Ref, rules []*as
 	var result ast.Value
 
 	// Check if we have cached the result of evaluating this rule set already.
+	// Undefined results are cached as nil.
 	for _, rule := range rules {
 		if doc, ok := t.cache.complete[rule]; ok {
+			if doc == nil {
+				return nil
+			}
 			return evalRefRuleResult(t, ref, suffix, doc, iter)
 		}
 	}
 
-	for i, rule := range rules {
+	var defaultRule *ast.Rule
+	var i int
 
-		bindings := ast.NewValueMap()
-		child := t.Child(rule.Body, bindings)
-		if i == 0 {
-			child.traceEnter(rule)
-		} else {
-			child.traceRedo(rule)
+	for _, rule := range rules {
+
+		// The default rule only applies if all other rules are undefined.
+		if rule.Default {
+			defaultRule = rule
+			continue
 		}
 
-		err := eval(child, func(child *Topdown) error {
-			if result == nil {
-				result = PlugValue(rule.Value.Value, child.Binding)
+		// Rules chained with the else keyword are evaluated in order. Once
+		// one of them produces a value, the remaining rules in the chain are
+		// not evaluated.
+		for curr := rule; curr != nil; curr = curr.Else {
+
+			bindings := ast.NewValueMap()
+			child := t.Child(curr.Body, bindings)
+			child.Metrics.incr(evalRules)
+			if i == 0 {
+				child.traceEnter(curr)
 			} else {
-				r := PlugValue(rule.Value.Value, child.Binding)
-				if !result.Equal(r) {
-					return conflictErr(ref, "complete documents", rule)
+				child.traceRedo(curr)
+			}
+			i++
+
+			defined := false
+
+			err := eval(child, func(child *Topdown) error {
+				defined = true
+				if result == nil {
+					result = PlugValue(curr.Value.Value, child.Binding)
+				} else {
+					r := PlugValue(curr.Value.Value, child.Binding)
+					if !result.Equal(r) {
+						return conflictErr(ref, "complete documents", curr)
+					}
 				}
+				child.traceExit(curr)
+				child.traceRedo(curr)
+				return nil
+			})
+
+			if err != nil {
+				return err
 			}
-			child.traceExit(rule)
-			child.traceRedo(rule)
-			return nil
-		})
 
-		if err != nil {
-			return err
+			if defined {
+				break
+			}
 		}
 	}
 
+	if result == nil && defaultRule != nil {
+		result = defaultRule.Value.Value
+	}
+
+	// Add the result to the cache. All of the rules have either produced the same value
+	// or only one of them has produced a value. As such, we can cache the result on any
+	// of them. If none of the rules produced a value, the document is undefined and
+	// the rules do not need to be evaluated again either.
+	t.cache.complete[rules[0]] = result
+
 	if result != nil {
-		// Add the result to the cache. All of the rules have either produced the same value
-		// or only one of them has produced a value. As such, we can cache the result on any
-		// of them.
-		t.cache.complete[rules[0]] = result
 		return evalRefRuleResult(t, ref, suffix, result, iter)
 	}
 
@@ -1376,6 +1834,7 @@ func evalRefRulePartialObjectDoc(t *Topdown, ref ast.Ref, path ast.Ref, rule *as
 	// unification is improved to handle namespacing, this can be revisited.
 	if !key.IsGround() {
 		child := t.Child(rule.Body, ast.NewValueMap())
+		child.Metrics.incr(evalRules)
 		if redo {
 			child.traceRedo(rule)
 		} else {
@@ -1438,6 +1897,7 @@ func evalRefRulePartialObjectDoc(t *Topdown, ref ast.Ref, path ast.Ref, rule *as
 	}
 
 	child := t.Child(rule.Body, ast.NewValueMap())
+	child.Metrics.incr(evalRules)
 
 	_, err := evalEqUnify(child, key, rule.Key.Value, nil, func(child *Topdown) error {
 
@@ -1502,6 +1962,7 @@ func evalRefRulePartialObjectDocFull(t *Topdown, ref ast.Ref, rules []*ast.Rule,
 
 		bindings := ast.NewValueMap()
 		child := t.Child(rule.Body, bindings)
+		child.Metrics.incr(evalRules)
 		if i == 0 {
 			child.traceEnter(rule)
 		} else {
@@ -1561,6 +2022,7 @@ func evalRefRulePartialSetDoc(t *Topdown, ref ast.Ref, path ast.Ref, rule *ast.R
 	// See comment in evalRefRulePartialObjectDoc about the two branches below.
 	if !key.IsGround() {
 		child := t.Child(rule.Body, ast.NewValueMap())
+		child.Metrics.incr(evalRules)
 
 		if redo {
 			child.traceRedo(rule)
@@ -1595,6 +2057,7 @@ func evalRefRulePartialSetDoc(t *Topdown, ref ast.Ref, path ast.Ref, rule *ast.R
 	}
 
 	child := t.Child(rule.Body, ast.NewValueMap())
+	child.Metrics.incr(evalRules)
 
 	_, err := evalEqUnify(child, key, rule.Key.Value, nil, func(child *Topdown) error {
 		if redo {
@@ -1624,6 +2087,7 @@ func evalRefRulePartialSetDocFull(t *Topdown, ref ast.Ref, rules []*ast.Rule, it
 
 		bindings := ast.NewValueMap()
 		child := t.Child(rule.Body, bindings)
+		child.Metrics.incr(evalRules)
 
 		if i == 0 {
 			child.traceEnter(rule)
@@ -1804,8 +2268,10 @@ func evalTerms(t *Topdown, iter Iterator) error {
 	// if this is an equality expression where one side is a non-ground,
 	// non-nested reference to a base document and the other side is a
 	// reference or some ground term. If indexing is available for the terms,
-	// the index is built lazily.
-	if expr.IsEquality() {
+	// the index is built lazily. Indexing is not used while documents are
+	// replaced by with modifiers because the index would refer to the
+	// original documents.
+	if expr.IsEquality() && len(t.overrides) == 0 {
 
 		// The terms must be plugged otherwise the index may yield bindings
 		// that would result in false positives.
@@ -1862,6 +2328,62 @@ func evalTermsComprehension(t *Topdown, comp ast.Value, iter Iterator) error {
 			return err
 		}
 		return Continue(t, comp, r, iter)
+	case *ast.ObjectComprehension:
+		r := ast.Object{}
+		keys := ast.NewValueMap()
+		c := t.Child(comp.Body, t.Locals)
+		err := Eval(c, func(c *Topdown) error {
+			key := PlugValue(comp.Key.Value, c.Binding)
+			if ref, ok := key.(ast.Ref); ok {
+				var err error
+				key, err = lookupValue(c, ref)
+				if err != nil {
+					if storage.IsNotFound(err) {
+						return nil
+					}
+					return err
+				}
+			}
+			if !ast.IsScalar(key) {
+				return typeErrObjectComprehensionKey(comp, key)
+			}
+			// Resolve references so that conflicting values are detected
+			// regardless of where they were obtained from.
+			value, err := ResolveRefs(PlugValue(comp.Value.Value, c.Binding), c)
+			if err != nil {
+				return err
+			}
+			if exist := keys.Get(key); exist != nil {
+				if !exist.Equal(value) {
+					return conflictErrObjectComprehension(key)
+				}
+				return nil
+			}
+			keys.Put(key, value)
+			r = append(r, ast.Item(&ast.Term{Value: key}, &ast.Term{Value: value}))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return Continue(t, comp, r, iter)
+	case *ast.SetComprehension:
+		r := &ast.Set{}
+		c := t.Child(comp.Body, t.Locals)
+		err := Eval(c, func(c *Topdown) error {
+			// Resolve references so that duplicate values are detected
+			// regardless of where they were obtained from.
+			v, err := ResolveRefs(PlugValue(comp.Term.Value, c.Binding), c)
+			if err != nil {
+				return err
+			}
+			r.Add(&ast.Term{Value: v})
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return Continue(t, comp, r, iter)
 	default:
 		panic(fmt.Sprintf("illegal argument: %v %v", t, comp))
 	}
@@ -1930,7 +2452,7 @@ func evalTermsRec(t *Topdown, iter Iterator, ts []*ast.Term) error {
 		return evalTermsRecObject(t, head, 0, rec)
 	case *ast.Set:
 		return evalTermsRecSet(t, head, 0, rec)
-	case *ast.ArrayComprehension:
+	case *ast.ArrayComprehension, *ast.ObjectComprehension, *ast.SetComprehension:
 		return evalTermsComprehension(t, head, rec)
 	default:
 		return evalTermsRec(t, iter, tail)
@@ -1955,7 +2477,7 @@ func evalTermsRecArray(t *Topdown, arr ast.Array, idx int, iter Iterator) error
 		return evalTermsRecObject(t, v, 0, rec)
 	case *ast.Set:
 		return evalTermsRecSet(t, v, 0, rec)
-	case *ast.ArrayComprehension:
+	case *ast.ArrayComprehension, *ast.ObjectComprehension, *ast.SetComprehension:
 		return evalTermsComprehension(t, v, rec)
 	default:
 		return evalTermsRecArray(t, arr, idx+1, iter)
@@ -1983,7 +2505,7 @@ func evalTermsRecObject(t *Topdown, obj ast.Object, idx int, iter Iterator) erro
 				return evalTermsRecObject(t, v, 0, rec)
 			case *ast.Set:
 				return evalTermsRecSet(t, v, 0, rec)
-			case *ast.ArrayComprehension:
+			case *ast.ArrayComprehension, *ast.ObjectComprehension, *ast.SetComprehension:
 				return evalTermsComprehension(t, v, rec)
 			default:
 				return evalTermsRecObject(t, obj, idx+1, iter)
@@ -1999,7 +2521,7 @@ func evalTermsRecObject(t *Topdown, obj ast.Object, idx int, iter Iterator) erro
 			return evalTermsRecObject(t, v, 0, rec)
 		case *ast.Set:
 			return evalTermsRecSet(t, v, 0, rec)
-		case *ast.ArrayComprehension:
+		case *ast.ArrayComprehension, *ast.ObjectComprehension, *ast.SetComprehension:
 			return evalTermsComprehension(t, v, rec)
 		default:
 			return evalTermsRecObject(t, obj, idx+1, iter)
@@ -2023,7 +2545,7 @@ func evalTermsRecSet(t *Topdown, set *ast.Set, idx int, iter Iterator) error {
 		return evalTermsRecArray(t, v, 0, rec)
 	case ast.Object:
 		return evalTermsRecObject(t, v, 0, rec)
-	case *ast.ArrayComprehension:
+	case *ast.ArrayComprehension, *ast.ObjectComprehension, *ast.SetComprehension:
 		return evalTermsComprehension(t, v, rec)
 	default:
 		return evalTermsRecSet(t, set, idx+1, iter)
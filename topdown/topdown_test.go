@@ -5,12 +5,16 @@
 package topdown
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"math"
 	"reflect"
+	"regexp"
 	"sort"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/open-policy-agent/opa/ast"
@@ -277,6 +281,57 @@ func TestTopDownCompleteDoc(t *testing.T) {
 	}
 }
 
+func TestTopDownDefaultKeyword(t *testing.T) {
+	tests := []struct {
+		note     string
+		rules    []string
+		expected interface{}
+	}{
+		{"undefined", []string{"default p = 1", "p = 2 :- false"}, "1"},
+		{"defined", []string{"default p = 1", "p = 2 :- true"}, "2"},
+		{"defined (multiple)", []string{"default p = 1", "p = 2 :- false", "p = 3 :- true"}, "3"},
+		{"default only", []string{`default p = {"a": [1, 2]}`}, `{"a": [1, 2]}`},
+		{"default false", []string{"default p = false", "p :- a[_] = 100"}, "false"},
+		{"dereference", []string{"default q = [1, 2]", "q = [3] :- false", "p = x :- q[1] = x"}, "2"},
+		{"conflict", []string{"default p = 1", "p = 2 :- true", "p = 3 :- true"},
+			fmt.Errorf("evaluation error (code: 1): multiple values for data.p: rules must produce exactly one value for complete documents: check rule definition(s): p")},
+	}
+
+	data := loadSmallTestData()
+
+	for _, tc := range tests {
+		runTopDownTestCase(t, data, tc.note, tc.rules, tc.expected)
+	}
+}
+
+func TestTopDownElseKeyword(t *testing.T) {
+	tests := []struct {
+		note     string
+		rules    []string
+		expected interface{}
+	}{
+		{"first branch", []string{"p = 1 :- true else = 2 :- true"}, "1"},
+		{"first branch short-circuit", []string{"p = 1 :- true else = x :- a[_] = x"}, "1"},
+		{"else branch", []string{"p = 1 :- false else = 2 :- true"}, "2"},
+		{"else branch vars", []string{"p = 1 :- false else = x :- a[i] = x, i = 2"}, "3"},
+		{"else without body", []string{`p = "allow" :- a[_] = 100 else = "deny"`}, `"deny"`},
+		{"else without value", []string{"p = false :- a[_] = 100 else :- a[_] = 1"}, "true"},
+		{"chain", []string{"p = 1 :- false else = 2 :- false else = 3 :- true else = 4 :- true"}, "3"},
+		{"undefined", []string{"p = 1 :- false else = 2 :- false"}, ""},
+		{"default", []string{"default p = 0", "p = 1 :- false else = 2 :- false"}, "0"},
+		{"default else branch", []string{"default p = 0", "p = 1 :- false else = 2 :- true"}, "2"},
+		{"multiple rules", []string{"p = 1 :- false else = 2 :- true", "p = 2 :- true"}, "2"},
+		{"conflict", []string{"p = 1 :- false else = 2 :- true", "p = 3 :- true"},
+			fmt.Errorf("evaluation error (code: 1): multiple values for data.p: rules must produce exactly one value for complete documents: check rule definition(s): p")},
+	}
+
+	data := loadSmallTestData()
+
+	for _, tc := range tests {
+		runTopDownTestCase(t, data, tc.note, tc.rules, tc.expected)
+	}
+}
+
 func TestTopDownPartialSetDoc(t *testing.T) {
 
 	tests := []struct {
@@ -445,6 +500,31 @@ func TestTopDownEqExpr(t *testing.T) {
 	}
 }
 
+func TestTopDownEqExprUndo(t *testing.T) {
+
+	// Bindings made while unifying a composite value must be undone when a
+	// later element fails to unify so that the next candidate starts fresh.
+	tests := []struct {
+		note     string
+		rule     string
+		expected interface{}
+	}{
+		{"array/array", `p[x] :- y = [[1, 1], [3, 2]], [x, 2] = y[_]`, `[3]`},
+		{"array/ref", `p[x] :- [x, 2] = data.arr[_]`, `[3]`},
+		{"object/object", `p[x] :- y = [{"a": 1, "b": 1}, {"a": 3, "b": 2}], {"a": x, "b": 2} = y[_]`, `[3]`},
+		{"object/ref", `p[x] :- {"a": x, "b": 2} = data.objs[_]`, `[3]`},
+	}
+
+	var data map[string]interface{}
+	if err := util.UnmarshalJSON([]byte(`{"arr": [[1, 1], [3, 2]], "objs": [{"a": 1, "b": 1}, {"a": 3, "b": 2}]}`), &data); err != nil {
+		panic(err)
+	}
+
+	for _, tc := range tests {
+		runTopDownTestCase(t, data, tc.note, []string{tc.rule}, tc.expected)
+	}
+}
+
 func TestTopDownIneqExpr(t *testing.T) {
 
 	tests := []struct {
@@ -930,6 +1010,21 @@ func TestTopDownComprehensions(t *testing.T) {
 			"p[x] :- q.a[2][i] = x",
 			`q[k] = v :- k = "a", v = [y | i[_] = _, i = y, i = [ z | z = a[_]] ]`,
 		}, "[1,2,3,4]"},
+		{"object simple", []string{`p = y :- y = {k: v | b[k] = v}`}, `{"v1": "hello", "v2": "goodbye"}`},
+		{"object computed", []string{`p = y :- y = {x: true | x = d.e[_]}`}, `{"bar": true, "baz": true}`},
+		{"object nested", []string{`p = y :- y = {x: n | x = d.e[_], n = {k: x | b[k] = _}}`}, `{"bar": {"v1": "bar", "v2": "bar"}, "baz": {"v1": "baz", "v2": "baz"}}`},
+		{"object closure", []string{`p = y :- s = "x", y = {k: s | k = d.e[_]}`}, `{"bar": "x", "baz": "x"}`},
+		{"object empty", []string{`p = y :- y = {k: v | b[k] = v, v = "x"}`}, `{}`},
+		{"object same value", []string{`p = y :- y = {"k": v | a[_] = x, v = 1}`}, `{"k": 1}`},
+		{"object same value ref", []string{`p = y :- y = {"k": v | v = g.c[i], i < 3}`}, `{"k": 0}`},
+		{"object key conflict", []string{`p = y :- y = {"k": x | a[_] = x}`}, fmt.Errorf(`evaluation error (code: 1): multiple values for "k": object comprehensions must produce exactly one value for each key`)},
+		{"set simple", []string{`p = s :- s = {x | x = a[_]}`}, `[1, 2, 3, 4]`},
+		{"set dedup", []string{`p :- s = {x | x = g[_][_]}, s = {0, 1, 2, 4}`}, "true"},
+		{"set dedup composite", []string{`p = s :- s = {[x] | x = g[_][_], x = 0}`}, `[[0]]`},
+		{"set closure", []string{`p = s :- y = 2, s = {x | x = a[_], x > y}`}, `[3, 4]`},
+		{"set empty", []string{`p = s :- s = {x | x = a[_], x > 4}`}, `[]`},
+		{"set count", []string{`p = n :- count({x | x = g[_][_]}, n)`}, `4`},
+		{"set nested", []string{`p = s :- s = {k | h[_] = z, k = {x | x = z[_], x > 1}}`}, `[[2, 3], [2, 3, 4]]`},
 	}
 
 	data := loadSmallTestData()
@@ -951,14 +1046,82 @@ func TestTopDownAggregates(t *testing.T) {
 		{"count keys", []string{"p[x] :- count(b, x)"}, "[2]"},
 		{"count keys virtual", []string{"p[x] :- count([k | q[k] = _], x)", "q[k] = v :- b[k] = v"}, "[2]"},
 		{"count set", []string{"p = x :- count(q, x)", "q[x] :- x = a[_]"}, "4"},
+		{"count number", []string{"p = x :- count(5, x)"}, fmt.Errorf("count: input must be array, object, set, or string: illegal argument: 5")},
+		{"count boolean", []string{"p = x :- count(true, x)"}, fmt.Errorf("count: input must be array, object, set, or string: illegal argument: true")},
+		{"count null", []string{"p = x :- count(null, x)"}, fmt.Errorf("count: input must be array, object, set, or string: illegal argument: null")},
 		{"sum", []string{"p[x] :- sum([1,2,3,4], x)"}, "[10]"},
 		{"sum set", []string{"p = x :- sum({1,2,3,4}, x)"}, "10"},
 		{"sum virtual", []string{"p[x] :- sum([y | q[y]], x)", "q[x] :- a[_] = x"}, "[10]"},
 		{"sum virtual set", []string{"p = x :- sum(q, x)", "q[x] :- a[_] = x"}, "10"},
+		{"product", []string{"p[x] :- product([1,2,3,4], x)"}, "[24]"},
+		{"product set", []string{"p = x :- product({1,2,3,4}, x)"}, "24"},
+		{"product virtual", []string{"p[x] :- product([y | q[y]], x)", "q[x] :- a[_] = x"}, "[24]"},
+		{"product virtual set", []string{"p = x :- product(q, x)", "q[x] :- a[_] = x"}, "24"},
+		{"product empty", []string{"p = x :- product([], x)"}, "1"},
+		{"product float", []string{"p = x :- product([0.1, 0.2], x)"}, "0.02"},
+		{"product non-numeric", []string{`p = x :- product([1, "a"], x)`}, fmt.Errorf("product: input value must be a number: illegal argument: a")},
+		{"product ref dest", []string{"p :- product([2,2], a[3])"}, "true"},
+		{"product ref dest (2)", []string{"p :- not product([2,3], a[3])"}, "true"},
 		{"max", []string{"p[x] :- max([1,2,3,4], x)"}, "[4]"},
 		{"max set", []string{"p = x :- max({1,2,3,4}, x)"}, "4"},
 		{"max virtual", []string{"p[x] :- max([y | q[y]], x)", "q[x] :- a[_] = x"}, "[4]"},
 		{"max virtual set", []string{"p = x :- max(q, x)", "q[x] :- a[_] = x"}, "4"},
+		{"min", []string{"p[x] :- min([4,2,1,3], x)"}, "[1]"},
+		{"min set", []string{"p = x :- min({4,2,1,3}, x)"}, "1"},
+		{"min virtual", []string{"p[x] :- min([y | q[y]], x)", "q[x] :- a[_] = x"}, "[1]"},
+		{"min virtual set", []string{"p = x :- min(q, x)", "q[x] :- a[_] = x"}, "1"},
+		{"min mixed", []string{`p = x :- min(["a", 2, false, [1]], x)`}, "false"},
+		{"min empty", []string{"p = x :- min([], x)"}, ""},
+		{"min empty set", []string{"p = x :- min(q, x)", "q[x] :- a[_] = x, x > 10"}, ""},
+		{"min ref dest", []string{"p :- min([4,2,3,1], a[0])"}, "true"},
+		{"min ref dest (2)", []string{"p :- not min([4,2,3], a[0])"}, "true"},
+		{"max_by", []string{`p = x :- max_by(l, ["b"], x)`}, `{"a": "alice", "b": 1, "c": [2,3,4,5], "d": null}`},
+		{"max_by tie", []string{`p = x :- max_by([{"n": "x", "v": 1}, {"n": "y", "v": 2}, {"n": "z", "v": 2}], ["v"], x)`}, `{"n": "y", "v": 2}`},
+		{"max_by nested", []string{`p = x :- max_by(l, ["c", 0], y), x = y.a`}, `"alice"`},
+		{"max_by missing", []string{`p = x :- max_by([{"v": 1}, {"w": 3}, 5], ["v"], x)`}, `{"v": 1}`},
+		{"max_by virtual", []string{`p = x :- max_by(q, ["b"], y), x = y.a`, "q[x] :- x = l[_]"}, `"alice"`},
+		{"max_by empty", []string{`p = x :- max_by([], ["b"], x)`}, ""},
+		{"max_by non-collection", []string{`p = x :- max_by("a", ["b"], x)`}, fmt.Errorf("max_by: input must be an array or set")},
+		{"max_by bad keypath", []string{`p = x :- max_by(l, "b", x)`}, fmt.Errorf("max_by: keypath must be an array")},
+		{"min_by", []string{`p = x :- min_by(l, ["b"], y), x = y.a`}, `"bob"`},
+		{"min_by tie", []string{`p = x :- min_by({{"n": "c", "v": 0}, {"n": "a", "v": 1}, {"n": "b", "v": 0}}, ["v"], x)`}, `{"n": "b", "v": 0}`},
+		{"min_by virtual", []string{`p = x :- min_by(q, ["b"], y), x = y.a`, "q[x] :- x = l[_]"}, `"bob"`},
+		{"min_by missing", []string{`p = x :- min_by(l, ["d"], y), x = y.a`}, `"alice"`},
+		{"median", []string{"p = x :- median([3, 1, 2], x)"}, "2"},
+		{"median even", []string{"p = x :- median([4, 1, 3, 2], x)"}, "2.5"},
+		{"median set", []string{"p = x :- median({10, 1.5, 7, 2}, x)"}, "4.5"},
+		{"median virtual", []string{"p = x :- median(q, x)", "q[x] :- a[_] = x"}, "2.5"},
+		{"median empty", []string{"p = x :- median([], x)"}, ""},
+		{"median non-numeric", []string{`p = x :- median([1, "a"], x)`}, fmt.Errorf("median: input elements must be numbers")},
+		{"median non-collection", []string{`p = x :- median("a", x)`}, fmt.Errorf("median: source must be array")},
+		{"sort", []string{"p :- sort([4,1,3,2], [1,2,3,4])"}, "true"},
+		{"sort set", []string{"p :- sort({3,1,2}, [1,2,3])"}, "true"},
+		{"sort virtual", []string{"p :- sort([y | q[y]], [1,2,3,4])", "q[x] :- a[_] = x"}, "true"},
+		{"sort virtual set", []string{`p :- sort(q, ["bar", "baz"])`, "q[x] :- d.e[_] = x"}, "true"},
+		{"sort sorted", []string{`p :- sort(a, [1,2,3,4])`}, "true"},
+		{"sort mixed", []string{`p :- sort(["a", {"a": 1}, 1, [1], null, true, 0.5], [null, true, 0.5, 1, "a", [1], {"a": 1}])`}, "true"},
+		{"sort empty", []string{"p = x :- sort([], x)"}, "[]"},
+		{"sort undefined", []string{"p :- sort([2,1], [2,1])"}, ""},
+		{"sort ref dest", []string{"p :- sort([3,2,1,4], a)"}, "true"},
+		{"sort ref dest (2)", []string{"p :- not sort([4,3,2,1], h[0])"}, "true"},
+		{"sort non-collection", []string{`p = x :- sort("a", x)`}, fmt.Errorf("sort: source must be array")},
+		{"sort_by", []string{`p :- sort_by(l, ["a"], y), [n | n = y[_].a] = ["alice", "bob"]`}, "true"},
+		{"sort_by stable", []string{`p :- sort_by([{"k": "b", "i": 0}, {"k": "a", "i": 1}, {"k": "b", "i": 2}, {"k": "a", "i": 3}], ["k"], y), [i | i = y[_].i] = [1, 3, 0, 2]`}, "true"},
+		{"sort_by virtual", []string{`p :- sort_by(q, ["b"], y), [n | n = y[_].a] = ["bob", "alice"]`, "q[x] :- x = l[_]"}, "true"},
+		{"sort_by missing", []string{`p :- sort_by([{"v": 2}, {"w": 1}, {"v": 1}], ["v"], [{"w": 1}, {"v": 1}, {"v": 2}])`}, "true"},
+		{"sort_by empty", []string{`p = x :- sort_by([], ["a"], x)`}, "[]"},
+		{"sort_by non-collection", []string{`p = x :- sort_by({"a": 1}, ["a"], x)`}, fmt.Errorf("sort_by: input must be an array or set")},
+		{"histogram", []string{"p :- histogram([1, 2, 2.5, 5, 6, 9, 10], [0, 3, 6, 10], [3, 1, 3])"}, "true"},
+		{"histogram set", []string{"p = x :- histogram({1, 4, 7}, [0, 3, 6, 10], x)"}, "[1, 1, 1]"},
+		{"histogram virtual", []string{"p :- histogram(q, [1, 2, 3, 4], [1, 1, 2])", "q[x] :- a[_] = x"}, "true"},
+		{"histogram out of range", []string{"p = x :- histogram([-1, 0, 10, 11, 12], [0, 5, 10], x)"}, "[1, 1]"},
+		{"histogram undefined", []string{"p :- histogram([1, 7], [0, 5, 10], [2, 0])"}, ""},
+		{"histogram clamped", []string{"p :- histogram_clamped([-1, 0, 10, 11, 12], [0, 5, 10], [2, 3])"}, "true"},
+		{"histogram empty", []string{"p = x :- histogram([], [0, 5, 10], x)"}, "[0, 0]"},
+		{"histogram unsorted edges", []string{"p = x :- histogram([1], [0, 5, 5], x)"}, fmt.Errorf("histogram: bin edges must be sorted in increasing order")},
+		{"histogram too few edges", []string{"p = x :- histogram([1], [0], x)"}, fmt.Errorf("histogram: bin edges must contain at least two values")},
+		{"histogram non-numeric input", []string{`p = x :- histogram([1, "a"], [0, 5], x)`}, fmt.Errorf("histogram: input must be array of numbers: illegal argument: a")},
+		{"histogram non-numeric edges", []string{`p = x :- histogram([1], [0, "a"], x)`}, fmt.Errorf("histogram: bin edges must be array of numbers: illegal argument: a")},
 		{"reduce ref dest", []string{"p :- max([1,2,3,4], a[3])"}, "true"},
 		{"reduce ref dest (2)", []string{"p :- not max([1,2,3,4,5], a[3])"}, "true"},
 	}
@@ -970,6 +1133,45 @@ func TestTopDownAggregates(t *testing.T) {
 	}
 }
 
+func TestTopDownBooleanAggregates(t *testing.T) {
+
+	tests := []struct {
+		note     string
+		rules    []string
+		expected interface{}
+	}{
+		{"all", []string{"p = x :- all([true, true], x)"}, "true"},
+		{"all false", []string{"p = x :- all([true, false, true], x)"}, "false"},
+		{"all empty", []string{"p = x :- all([], x)"}, "true"},
+		{"all mixed", []string{`p = x :- all([1, "a", null, [], {}, 0], x)`}, "true"},
+		{"all set", []string{"p = x :- all({true, false}, x)"}, "false"},
+		{"all empty set", []string{"p = x :- all(set(), x)"}, "true"},
+		{"all virtual", []string{"p = x :- all(q, x)", "q[x] :- x = a[_]"}, "true"},
+		{"all base doc", []string{"p = x :- all(c[0].x, x)"}, "false"},
+		{"all comprehension", []string{"p = x :- all([v | c[0].z[k] = v, k = \"p\"], x)"}, "true"},
+		{"all non-collection", []string{`p = x :- all({"a": true}, x)`}, fmt.Errorf("all: source must be array or set")},
+		{"all ref dest", []string{"p :- all([true], c[0].x[0])"}, "true"},
+		{"all ref dest (2)", []string{"p :- not all([false], c[0].x[0])"}, "true"},
+		{"any", []string{"p = x :- any([false, true], x)"}, "true"},
+		{"any false", []string{"p = x :- any([false, false], x)"}, "false"},
+		{"any empty", []string{"p = x :- any([], x)"}, "false"},
+		{"any mixed", []string{`p = x :- any([false, 0], x)`}, "true"},
+		{"any set", []string{"p = x :- any({false}, x)"}, "false"},
+		{"any empty set", []string{"p = x :- any(set(), x)"}, "false"},
+		{"any base doc", []string{"p = x :- any(c[0].x, x)"}, "true"},
+		{"any comprehension", []string{"p = x :- any([v | c[0].z[k] = v, k = \"q\"], x)"}, "false"},
+		{"any non-collection", []string{`p = x :- any("a", x)`}, fmt.Errorf("any: source must be array or set")},
+		{"any ref dest", []string{"p :- any([false], c[0].x[1])"}, "true"},
+		{"any ref dest (2)", []string{"p :- not any([true], c[0].x[1])"}, "true"},
+	}
+
+	data := loadSmallTestData()
+
+	for _, tc := range tests {
+		runTopDownTestCase(t, data, tc.note, tc.rules, tc.expected)
+	}
+}
+
 func TestTopDownArithmetic(t *testing.T) {
 	tests := []struct {
 		note     string
@@ -986,6 +1188,77 @@ func TestTopDownArithmetic(t *testing.T) {
 		{"arity 1 ref dest (2)", []string{"p :- not abs(-5, a[3])"}, "true"},
 		{"arity 2 ref dest", []string{"p :- plus(1, 2, a[2])"}, "true"},
 		{"arity 2 ref dest (2)", []string{"p :- not plus(2, 3, a[2])"}, "true"},
+		{"euclidean_distance", []string{"p = x :- euclidean_distance([0, 0], [3, 4], x)"}, "5"},
+		{"euclidean_distance: identical", []string{"p = x :- euclidean_distance(a, a, x)"}, "0"},
+		{"euclidean_distance: fractional", []string{"p = x :- euclidean_distance([1.5, -2], [0, 0], x)"}, "2.5"},
+		{"euclidean_distance: ref dest", []string{"p :- euclidean_distance([1], [5], a[3])"}, "true"},
+		{"euclidean_distance: ref dest (2)", []string{"p :- not euclidean_distance([1], [4], a[3])"}, "true"},
+		{"euclidean_distance: length mismatch", []string{"p = x :- euclidean_distance([1, 2], [1], x)"}, fmt.Errorf("euclidean_distance: inputs must have the same length")},
+		{"euclidean_distance: error 1", []string{`p = x :- euclidean_distance([1, "a"], [1, 2], x)`}, fmt.Errorf(`euclidean_distance: first input must be an array of numbers: illegal argument: a`)},
+		{"euclidean_distance: error 2", []string{`p = x :- euclidean_distance([1, 2], "b", x)`}, fmt.Errorf(`euclidean_distance: second input must be an array of numbers: illegal argument: b`)},
+		{"floor", []string{"p = x :- floor(2.5, x)"}, "2"},
+		{"floor: negative", []string{"p = x :- floor(-2.5, x)"}, "-3"},
+		{"floor: integer", []string{"p[x] :- floor(3, y), floor(-3, z), floor(3.0, w), x = [y, z, w]"}, "[[3, -3, 3]]"},
+		{"floor: large", []string{"p = x :- floor(123456789012345.75, x)"}, "123456789012345"},
+		{"floor: ref dest", []string{"p :- floor(4.9, a[3])"}, "true"},
+		{"floor: ref dest (2)", []string{"p :- not floor(3.9, a[3])"}, "true"},
+		{"floor: error", []string{`p = x :- floor("2.5", x)`}, fmt.Errorf(`floor: input must be a number: illegal argument: "2.5"`)},
+		{"ceil", []string{"p = x :- ceil(2.5, x)"}, "3"},
+		{"ceil: negative", []string{"p = x :- ceil(-2.5, x)"}, "-2"},
+		{"ceil: integer", []string{"p[x] :- ceil(3, y), ceil(-3, z), ceil(-3.0, w), x = [y, z, w]"}, "[[3, -3, -3]]"},
+		{"ceil: small fraction", []string{"p = x :- ceil(0.0001, x)"}, "1"},
+		{"ceil: ref dest", []string{"p :- ceil(3.1, a[3])"}, "true"},
+		{"ceil: ref dest (2)", []string{"p :- not ceil(4.1, a[3])"}, "true"},
+		{"ceil: error", []string{`p = x :- ceil(null, x)`}, fmt.Errorf(`ceil: input must be a number: illegal argument: null`)},
+		{"rem", []string{"p[x] :- rem(7, 3, y), rem(8, 3, z), x = [y, z]"}, "[[1, 2]]"},
+		{"rem: exact", []string{"p = x :- rem(9, 3, x)"}, "0"},
+		{"rem: negative", []string{"p[x] :- rem(-7, 3, y), rem(7, -3, z), rem(-7, -3, w), x = [y, z, w]"}, "[[-1, 1, -1]]"},
+		{"rem: integral float", []string{"p = x :- rem(7.0, 2, x)"}, "1"},
+		{"rem: refs", []string{"p[x] :- a[_] = x, rem(x, 2, 0)"}, "[2, 4]"},
+		{"rem: ref dest", []string{"p :- rem(10, 7, a[2])"}, "true"},
+		{"rem: ref dest (2)", []string{"p :- not rem(10, 6, a[2])"}, "true"},
+		{"rem: by zero", []string{"p = x :- rem(7, 0, x)"}, fmt.Errorf("rem: modulo by zero")},
+		{"rem: non-integer", []string{"p = x :- rem(7.5, 2, x)"}, fmt.Errorf("rem: operands must be integers: illegal argument: 7.5")},
+		{"rem: non-number", []string{`p = x :- rem(7, "2", x)`}, fmt.Errorf(`rem: operands must be integers: illegal argument: "2"`)},
+		{"numbers_range", []string{"p :- numbers_range(1, 4, [1, 2, 3, 4])"}, "true"},
+		{"numbers_range: descending", []string{"p :- numbers_range(2, -1, [2, 1, 0, -1])"}, "true"},
+		{"numbers_range: single", []string{"p = x :- numbers_range(3, 3, x)"}, "[3]"},
+		{"numbers_range: integral float", []string{"p :- numbers_range(1.0, 3, [1, 2, 3])"}, "true"},
+		{"numbers_range: refs", []string{"p = x :- numbers_range(a[0], three, x)"}, "[1, 2, 3]"},
+		{"numbers_range: iteration", []string{"p[x] :- numbers_range(1, 3, r), x = r[_]"}, "[1, 2, 3]"},
+		{"numbers_range: ref dest", []string{"p :- numbers_range(1, 4, a)"}, "true"},
+		{"numbers_range: undefined", []string{"p :- numbers_range(1, 4, [4, 3, 2, 1])"}, ""},
+		{"numbers_range: non-integer", []string{"p = x :- numbers_range(1, 2.5, x)"}, fmt.Errorf("numbers_range: operands must be integers: illegal argument: 2.5")},
+		{"numbers_range: non-number", []string{`p = x :- numbers_range("1", 2, x)`}, fmt.Errorf(`numbers_range: operands must be integers: illegal argument: "1"`)},
+		{"numbers_range: too large", []string{"p = x :- numbers_range(0, 1e12, x)"}, fmt.Errorf("numbers_range: range must not contain more than 1000000 integers")},
+		{"numbers_range: too large descending", []string{"p = x :- numbers_range(1000000, 0, x)"}, fmt.Errorf("numbers_range: range must not contain more than 1000000 integers")},
+		{"numbers_range: largest", []string{"p = x :- numbers_range(1, 1000000, r), count(r, x)"}, "1000000"},
+		{"pow", []string{"p = x :- pow(2, 10, x)"}, "1024"},
+		{"pow: zero exponent", []string{"p = x :- pow(7, 0, x)"}, "1"},
+		{"pow: negative base", []string{"p = x :- pow(-3, 3, x)"}, "-27"},
+		{"pow: large", []string{"p = x :- pow(10, 20, x)"}, "100000000000000000000"},
+		{"pow: fractional exponent", []string{"p = x :- pow(2, 0.5, x)"}, "1.4142135623730951"},
+		{"pow: fractional base", []string{"p = x :- pow(2.5, 2, x)"}, "6.25"},
+		{"pow: negative exponent", []string{"p = x :- pow(2, -2, x)"}, "0.25"},
+		{"pow: ref dest", []string{"p :- pow(2, 2, a[3])"}, "true"},
+		{"pow: ref dest (2)", []string{"p :- not pow(2, 3, a[3])"}, "true"},
+		{"pow: not finite", []string{"p = x :- pow(-8, 0.5, x)"}, fmt.Errorf("pow: result must be a finite number")},
+		{"pow: huge exponent", []string{"p = x :- pow(10, 100000000, x)"}, fmt.Errorf("pow: result must be a finite number")},
+		{"pow: huge exponent of one", []string{"p = x :- pow(1, 100000000, x)"}, "1"},
+		{"pow: huge exponent of minus one", []string{"p = x :- pow(-1, 100000001, x)"}, "-1"},
+		{"pow: largest exact", []string{"p = x :- pow(2, 32768, y), rem(y, 10, x)"}, "6"},
+		{"pow: error", []string{`p = x :- pow(2, "3", x)`}, fmt.Errorf(`pow: operands must be numbers: illegal argument: "3"`)},
+		{"sqrt", []string{"p = x :- sqrt(9, x)"}, "3"},
+		{"sqrt: zero", []string{"p = x :- sqrt(0, x)"}, "0"},
+		{"sqrt: irrational", []string{"p = x :- sqrt(2, x)"}, "1.4142135623730951"},
+		{"sqrt: fractional", []string{"p = x :- sqrt(2.25, x)"}, "1.5"},
+		{"sqrt: ref", []string{"p = x :- sqrt(a[3], x)"}, "2"},
+		{"sqrt: ref dest", []string{"p :- sqrt(16, a[3])"}, "true"},
+		{"sqrt: ref dest (2)", []string{"p :- not sqrt(9, a[3])"}, "true"},
+		{"sqrt: large perfect square", []string{"p = x :- sqrt(1524157875019052100, x)"}, "1234567890"},
+		{"sqrt: large non-square", []string{"p = x :- sqrt(1524157875019052101, x)"}, "1.23456789e+09"},
+		{"sqrt: negative", []string{"p = x :- sqrt(-9, x)"}, fmt.Errorf("sqrt: operand must be non-negative")},
+		{"sqrt: error", []string{`p = x :- sqrt("9", x)`}, fmt.Errorf(`sqrt: operand must be a number: illegal argument: "9"`)},
 	}
 
 	data := loadSmallTestData()
@@ -993,6 +1266,11 @@ func TestTopDownArithmetic(t *testing.T) {
 	for _, tc := range tests {
 		runTopDownTestCase(t, data, tc.note, tc.rules, tc.expected)
 	}
+
+	// Numbers that cannot be represented as floats are only available via
+	// data.
+	huge := map[string]interface{}{"x": json.Number("1e400")}
+	runTopDownTestCase(t, huge, "sqrt: infinite", []string{"p = x :- sqrt(data.x, x)"}, fmt.Errorf("sqrt: result must be a finite number"))
 }
 
 func TestTopDownCasts(t *testing.T) {
@@ -1004,6 +1282,357 @@ func TestTopDownCasts(t *testing.T) {
 		{"to_number", []string{`p[x] :- to_number("-42.0", y), to_number(false, z), x = [y, z]`}, "[[-42.0, 0]]"},
 		{"to_number ref dest", []string{`p :- to_number("3", a[2])`}, "true"},
 		{"to_number ref dest", []string{`p :- not to_number("-1", a[2])`}, "true"},
+		{"parse_int_auto: decimal", []string{`p[x] :- parse_int_auto("42", y), parse_int_auto("-42", z), parse_int_auto("+7", w), x = [y, z, w]`}, "[[42, -42, 7]]"},
+		{"parse_int_auto: hex", []string{`p[x] :- parse_int_auto("0xFF", y), parse_int_auto("-0x1a", z), x = [y, z]`}, "[[255, -26]]"},
+		{"parse_int_auto: octal", []string{`p[x] :- parse_int_auto("0o17", y), parse_int_auto("017", z), parse_int_auto("-0O10", w), x = [y, z, w]`}, "[[15, 15, -8]]"},
+		{"parse_int_auto: binary", []string{`p[x] :- parse_int_auto("0b101", y), parse_int_auto("-0B11", z), x = [y, z]`}, "[[5, -3]]"},
+		{"parse_int_auto: zero", []string{`p = x :- parse_int_auto("0", x)`}, "0"},
+		{"parse_int_auto: large", []string{`p = x :- parse_int_auto("0xffffffffffffffffff", x)`}, "4722366482869645213695"},
+		{"parse_int_auto: ref dest", []string{`p :- parse_int_auto("0x3", a[2])`}, "true"},
+		{"parse_int_auto: invalid digits", []string{`p = x :- parse_int_auto("0b102", x)`}, fmt.Errorf(`parse_int_auto: invalid integer: "0b102"`)},
+		{"parse_int_auto: invalid octal", []string{`p = x :- parse_int_auto("09", x)`}, fmt.Errorf(`parse_int_auto: invalid integer: "09"`)},
+		{"parse_int_auto: missing digits", []string{`p = x :- parse_int_auto("0x", x)`}, fmt.Errorf(`parse_int_auto: invalid integer: "0x"`)},
+		{"parse_int_auto: double sign", []string{`p = x :- parse_int_auto("--1", x)`}, fmt.Errorf(`parse_int_auto: invalid integer: "--1"`)},
+		{"parse_int_auto: float", []string{`p = x :- parse_int_auto("1.5", x)`}, fmt.Errorf(`parse_int_auto: invalid integer: "1.5"`)},
+		{"parse_int_auto: non-string", []string{`p = x :- parse_int_auto(1, x)`}, fmt.Errorf(`parse_int_auto: input must be a string: illegal argument: 1`)},
+		{"to_boolean", []string{`p[x] :- to_boolean(true, y), to_boolean(false, z), x = [y, z]`}, "[[true, false]]"},
+		{"to_boolean: strings", []string{`p[x] :- to_boolean("true", y), to_boolean("false", z), x = [y, z]`}, "[[true, false]]"},
+		{"to_boolean: numbers", []string{`p[x] :- to_boolean(1, y), to_boolean(0, z), to_boolean(1.0, w), x = [y, z, w]`}, "[[true, false, true]]"},
+		{"to_boolean: ref", []string{`p = x :- to_boolean(c[0].x[0], x)`}, "true"},
+		{"to_boolean: undefined", []string{`p :- to_boolean("true", false)`}, ""},
+		{"to_boolean: bad string", []string{`p = x :- to_boolean("yes", x)`}, fmt.Errorf(`to_boolean: string must be "true" or "false": "yes"`)},
+		{"to_boolean: bad case", []string{`p = x :- to_boolean("True", x)`}, fmt.Errorf(`to_boolean: string must be "true" or "false": "True"`)},
+		{"to_boolean: bad number", []string{`p = x :- to_boolean(2, x)`}, fmt.Errorf(`to_boolean: number must be 1 or 0: 2`)},
+		{"to_boolean: bad type", []string{`p = x :- to_boolean(null, x)`}, fmt.Errorf(`to_boolean: source must be a boolean, string, or number: ast.Null`)},
+		{"to_string: float", []string{`p = x :- to_string(-42.5, x)`}, `"-42.5"`},
+		{"to_string: integer", []string{`p = x :- to_string(7, x)`}, `"7"`},
+		{"to_string: bool", []string{`p[x] :- to_string(true, y), to_string(false, z), x = [y, z]`}, `[["true", "false"]]`},
+		{"to_string: null", []string{`p = x :- to_string(null, x)`}, `"null"`},
+		{"to_string: string", []string{`p = x :- to_string("hello", x)`}, `"hello"`},
+		{"to_string: ref", []string{`p = x :- to_string(a[0], x)`}, `"1"`},
+		{"to_string: ref dest", []string{`p :- to_string(1, numbers[0])`}, "true"},
+		{"to_string: ref dest (2)", []string{`p :- not to_string(4, numbers[0])`}, "true"},
+		{"to_string: undefined", []string{`p :- to_string(1, "1.0")`}, ""},
+		{"to_string: array", []string{`p = x :- to_string([1], x)`}, fmt.Errorf("to_string: unsupported value type")},
+		{"to_string: object", []string{`p = x :- to_string({"a": 1}, x)`}, fmt.Errorf("to_string: unsupported value type")},
+		{"to_string: set", []string{`p = x :- to_string({1}, x)`}, fmt.Errorf("to_string: unsupported value type")},
+	}
+
+	data := loadSmallTestData()
+
+	for _, tc := range tests {
+		runTopDownTestCase(t, data, tc.note, tc.rules, tc.expected)
+	}
+}
+
+func TestTopDownArrays(t *testing.T) {
+	tests := []struct {
+		note     string
+		rules    []string
+		expected interface{}
+	}{
+		{"duplicates", []string{`p = x :- duplicates(["a", "b", "a", "c", "b", "a"], x)`}, `["a", "b"]`},
+		{"duplicates: none", []string{`p = x :- duplicates(a, x)`}, `[]`},
+		{"duplicates: composite", []string{`p :- duplicates([{"id": 1}, [1, 2], {"id": 2}, {"id": 1}, [1, 2], [2, 1]], {[1, 2], {"id": 1}})`}, "true"},
+		{"duplicates: refs", []string{`p = x :- duplicates([a[0], g.a[0], a[1]], x)`}, `[1]`},
+		{"duplicates: ground output", []string{`p :- duplicates([1, 2, 2], {2})`}, "true"},
+		{"duplicates: undefined", []string{`p :- duplicates([1, 2, 2], {1})`}, ""},
+		{"duplicates: bad input", []string{`p = x :- duplicates({1, 2}, x)`}, fmt.Errorf("evaluation error (code: 2): duplicates: input must be array not *ast.Set")},
+		{"count_eq", []string{`p = x :- count_eq(g.c, 0, x)`}, "3"},
+		{"count_eq: data", []string{`p = x :- count_eq(a, 3, x)`}, "1"},
+		{"count_eq: none", []string{`p = x :- count_eq(a, 5, x)`}, "0"},
+		{"count_eq: numeric equality", []string{`p = x :- count_eq([1, 1.0, 2], 1, x)`}, "2"},
+		{"count_eq: composite", []string{`p = x :- count_eq([{"a": [1]}, {"a": [2]}, [1], {"a": [1]}], {"a": [1]}, x)`}, "2"},
+		{"count_eq: ref value", []string{`p[x] :- count_eq(g[x], a[1], 1)`}, `["b"]`},
+		{"count_eq: ref dest", []string{`p :- count_eq([1, 1, 1], 1, a[2])`}, "true"},
+		{"count_eq: ref dest (2)", []string{`p :- not count_eq([1, 1], 1, a[2])`}, "true"},
+		{"count_eq: bad input", []string{`p = x :- count_eq({1, 2}, 1, x)`}, fmt.Errorf("evaluation error (code: 2): count_eq: input must be array not *ast.Set")},
+		{"rotate", []string{`p :- rotate([1, 2, 3, 4], 1, [2, 3, 4, 1])`}, "true"},
+		{"rotate: negative", []string{`p :- rotate([1, 2, 3, 4], -1, [4, 1, 2, 3])`}, "true"},
+		{"rotate: over length", []string{`p :- rotate([1, 2, 3, 4], 6, [3, 4, 1, 2])`}, "true"},
+		{"rotate: negative over length", []string{`p :- rotate([1, 2, 3, 4], -5, [4, 1, 2, 3])`}, "true"},
+		{"rotate: zero", []string{`p :- rotate(a, 0, [1, 2, 3, 4])`}, "true"},
+		{"rotate: full", []string{`p :- rotate(a, 4, [1, 2, 3, 4])`}, "true"},
+		{"rotate: empty", []string{`p = x :- rotate([], 3, x)`}, "[]"},
+		{"rotate: undefined", []string{`p :- rotate([1, 2], 1, [1, 2])`}, ""},
+		{"rotate: ref dest", []string{`p :- rotate([3, 1, 2], 1, h[0])`}, "true"},
+		{"rotate: ref dest (2)", []string{`p :- not rotate([1, 2, 3], 1, h[0])`}, "true"},
+		{"rotate: bad input", []string{`p = x :- rotate({1, 2}, 1, x)`}, fmt.Errorf("evaluation error (code: 2): rotate: input must be array not *ast.Set")},
+		{"rotate: bad n", []string{`p = x :- rotate([1, 2], "1", x)`}, fmt.Errorf(`rotate: n must be an integer: illegal argument: "1"`)},
+		{"rotate: non-integer n", []string{`p = x :- rotate([1, 2], 1.5, x)`}, fmt.Errorf(`rotate: n must be an integer: strconv.ParseInt: parsing "1.5": invalid syntax`)},
+		{"at_most_one: none", []string{`p = x :- at_most_one([false, false, false], x)`}, "true"},
+		{"at_most_one: one", []string{`p = x :- at_most_one([false, true, false], x)`}, "true"},
+		{"at_most_one: multiple", []string{`p = x :- at_most_one([true, false, true], x)`}, "false"},
+		{"at_most_one: empty", []string{`p = x :- at_most_one([], x)`}, "true"},
+		{"at_most_one: refs", []string{`p = x :- at_most_one([c[0].z.p, c[0].z.q, c[0].x[0]], x)`}, "false"},
+		{"at_most_one: undefined", []string{`p :- at_most_one([true], false)`}, ""},
+		{"at_most_one: non-boolean", []string{`p = x :- at_most_one([true, 1], x)`}, fmt.Errorf("at_most_one: input elements must be booleans: illegal argument: 1")},
+		{"at_most_one: bad input", []string{`p = x :- at_most_one(true, x)`}, fmt.Errorf("evaluation error (code: 2): at_most_one: input must be array not ast.Boolean")},
+		{"find_index", []string{`p = x :- find_index(a, 3, x)`}, "2"},
+		{"find_index: first occurrence", []string{`p = x :- find_index(g.c, 0, x)`}, "0"},
+		{"find_index: absent", []string{`p = x :- find_index(a, 5, x)`}, ""},
+		{"find_index: composite", []string{`p = x :- find_index([{"a": 1}, [1, 2], {"a": [1]}, {"a": [1]}], {"a": [1]}, x)`}, "2"},
+		{"find_index: ref value", []string{`p = x :- find_index(h[1], a[2], x)`}, "1"},
+		{"find_index: ref dest", []string{`p :- find_index([0, 0, 0, 1], 1, a[2])`}, "true"},
+		{"find_index: ref dest (2)", []string{`p :- not find_index([0, 1], 1, a[2])`}, "true"},
+		{"find_index: bad input", []string{`p = x :- find_index("abc", "b", x)`}, fmt.Errorf("evaluation error (code: 2): find_index: input must be array not ast.String")},
+		{"common_elements", []string{`p = x :- common_elements([["read", "write", "admin"], ["write", "read"], ["audit", "read"]], x)`}, `["read"]`},
+		{"common_elements: none", []string{`p = x :- common_elements([[1, 2], [3, 4], [2, 3]], x)`}, `[]`},
+		{"common_elements: single", []string{`p = x :- common_elements([[1, 2, 2]], x)`}, `[1, 2]`},
+		{"common_elements: empty", []string{`p = x :- common_elements([], x)`}, `[]`},
+		{"common_elements: numeric equality", []string{`p :- common_elements([[1.0, 2], [1]], x), count(x, 1)`}, "true"},
+		{"common_elements: composite", []string{`p :- common_elements([[{"a": [1]}, [1, 2]], [[1, 2], {"a": [1]}], [{"a": [1]}]], {{"a": [1]}})`}, "true"},
+		{"common_elements: refs", []string{`p = x :- common_elements([a, h[0], h[1]], x)`}, `[2, 3]`},
+		{"common_elements: undefined", []string{`p :- common_elements([[1, 2], [2]], {1})`}, ""},
+		{"common_elements: bad input", []string{`p = x :- common_elements({[1]}, x)`}, fmt.Errorf("evaluation error (code: 2): common_elements: input must be array not *ast.Set")},
+		{"common_elements: bad element", []string{`p = x :- common_elements([[1], {1}], x)`}, fmt.Errorf("evaluation error (code: 2): common_elements: input elements must be arrays not *ast.Set")},
+		{"cummax: ascending", []string{`p :- cummax([1, 2, 3, 4], [1, 2, 3, 4])`}, "true"},
+		{"cummax: non-monotonic", []string{`p :- cummax([3, 1, 4, 1, 5, 2], [3, 3, 4, 4, 5, 5])`}, "true"},
+		{"cummax: descending", []string{`p :- cummax([3, 2, 1], [3, 3, 3])`}, "true"},
+		{"cummax: strings", []string{`p :- cummax(["b", "a", "c"], ["b", "b", "c"])`}, "true"},
+		{"cummax: mixed types", []string{`p :- cummax([1, "a", null, 2], [1, "a", "a", "a"])`}, "true"},
+		{"cummax: empty", []string{`p = x :- cummax([], x)`}, "[]"},
+		{"cummax: refs", []string{`p :- cummax(g.b, [0, 2, 2, 2])`}, "true"},
+		{"cummax: violation", []string{`p[i] :- cummax(q, y), q[i] != y[i]`, `q = [1, 3, 2, 5, 4] :- true`}, "[2, 4]"},
+		{"cummax: undefined", []string{`p :- cummax([2, 1], [2, 1])`}, ""},
+		{"cummax: bad input", []string{`p = x :- cummax({1, 2}, x)`}, fmt.Errorf("evaluation error (code: 2): cummax: input must be array not *ast.Set")},
+		{"distinct", []string{`p :- distinct([3, 1, 3, 2, 1], [3, 1, 2])`}, "true"},
+		{"distinct: composite", []string{`p :- distinct([{"id": 1}, [1, 2], {"id": 1}, {"id": 2}, [1, 2]], [{"id": 1}, [1, 2], {"id": 2}])`}, "true"},
+		{"distinct: already distinct", []string{`p :- distinct(a, [1, 2, 3, 4])`}, "true"},
+		{"distinct: numeric equality", []string{`p :- distinct([1, 1.0], x), count(x, 1)`}, "true"},
+		{"distinct: empty", []string{`p = x :- distinct([], x)`}, "[]"},
+		{"distinct: count", []string{`p = x :- distinct(g.c, y), count(y, x)`}, "2"},
+		{"distinct: ref dest", []string{`p :- distinct([1, 1, 2, 3, 4, 3], a)`}, "true"},
+		{"distinct: ref dest (2)", []string{`p :- not distinct([1, 2, 3, 4, 1], h[0])`}, "true"},
+		{"distinct: bad input", []string{`p = x :- distinct({1, 2}, x)`}, fmt.Errorf("evaluation error (code: 2): distinct: input must be an array not *ast.Set")},
+		{"flatten", []string{`p :- flatten([1, [2, [3, [4]]], 5], [1, 2, 3, 4, 5])`}, "true"},
+		{"flatten: mixed", []string{`p :- flatten(["a", [1, 2], true, [], [null]], ["a", 1, 2, true, null])`}, "true"},
+		{"flatten: leaves", []string{`p :- flatten([[{"a": [1]}], [{[2]}]], [{"a": [1]}, {[2]}])`}, "true"},
+		{"flatten: flat", []string{`p :- flatten(a, [1, 2, 3, 4])`}, "true"},
+		{"flatten: empty", []string{`p = x :- flatten([], x)`}, "[]"},
+		{"flatten: empty nested", []string{`p = x :- flatten([[], [[]]], x)`}, "[]"},
+		{"flatten: refs", []string{`p :- flatten(h, [1, 2, 3, 2, 3, 4])`}, "true"},
+		{"flatten: ref dest", []string{`p :- flatten([[1, 2], [3, [4]]], a)`}, "true"},
+		{"flatten: ref dest (2)", []string{`p :- not flatten([[1, 2], [4, [3]]], a)`}, "true"},
+		{"flatten: bad input", []string{`p = x :- flatten({1, 2}, x)`}, fmt.Errorf("evaluation error (code: 2): flatten: input must be an array not *ast.Set")},
+	}
+
+	data := loadSmallTestData()
+
+	for _, tc := range tests {
+		runTopDownTestCase(t, data, tc.note, tc.rules, tc.expected)
+	}
+}
+
+func TestTopDownCollections(t *testing.T) {
+	tests := []struct {
+		note     string
+		rules    []string
+		expected interface{}
+	}{
+		{"is_one_of", []string{`p = x :- is_one_of("GET", ["GET", "HEAD"], x)`}, "true"},
+		{"is_one_of: not allowed", []string{`p = x :- is_one_of("POST", ["GET", "HEAD"], x)`}, "false"},
+		{"is_one_of: set", []string{`p = x :- is_one_of(3, {1, 2, 3}, x)`}, "true"},
+		{"is_one_of: numeric equality", []string{`p = x :- is_one_of(1.0, [1], x)`}, "true"},
+		{"is_one_of: composite", []string{`p = x :- is_one_of({"a": [1, 2]}, [{"a": [1]}, {"a": [1, 2]}], x)`}, "true"},
+		{"is_one_of: composite not allowed", []string{`p = x :- is_one_of([1, 2], [[2, 1], [1]], x)`}, "false"},
+		{"is_one_of: refs", []string{`p[x] :- is_one_of(x, a, true), x = b[_]`, `p[x] :- is_one_of(a[x], [2, 4], true)`}, "[1, 3]"},
+		{"is_one_of: virtual", []string{`p :- is_one_of(2, q, true)`, `q[x] :- a[_] = x`}, "true"},
+		{"is_one_of: undefined", []string{`p :- is_one_of(2, [2], false)`}, ""},
+		{"is_one_of: bad allowed", []string{`p = x :- is_one_of(1, {"a": 1}, x)`}, fmt.Errorf("evaluation error (code: 2): is_one_of: allowed values must be array or set not ast.Object")},
+	}
+
+	data := loadSmallTestData()
+
+	for _, tc := range tests {
+		runTopDownTestCase(t, data, tc.note, tc.rules, tc.expected)
+	}
+}
+
+func TestTopDownGraphs(t *testing.T) {
+
+	graph := `q = {"admin": ["editor", "auditor"], "editor": ["viewer"], "auditor": {"viewer"}, "viewer": []} :- true`
+	perms := `r = {"admin": ["users:write"], "editor": ["docs:write"], "auditor": {"logs:read"}, "viewer": ["docs:read"]} :- true`
+
+	tests := []struct {
+		note     string
+		rules    []string
+		expected interface{}
+	}{
+		{"effective_permissions", []string{`p :- effective_permissions(q, r, ["admin"], {"docs:read", "docs:write", "logs:read", "users:write"})`, graph, perms}, "true"},
+		{"effective_permissions: single level", []string{`p :- effective_permissions(q, r, ["editor"], {"docs:read", "docs:write"})`, graph, perms}, "true"},
+		{"effective_permissions: leaf", []string{`p = x :- effective_permissions(q, r, {"viewer"}, x)`, graph, perms}, `["docs:read"]`},
+		{"effective_permissions: multiple roles", []string{`p :- effective_permissions(q, r, ["auditor", "editor"], {"docs:read", "docs:write", "logs:read"})`, graph, perms}, "true"},
+		{"effective_permissions: no roles", []string{`p = x :- effective_permissions(q, r, [], x)`, graph, perms}, `[]`},
+		{"effective_permissions: unknown role", []string{`p = x :- effective_permissions(q, r, ["guest"], x)`, graph, perms}, `[]`},
+		{"effective_permissions: cycle", []string{`p :- effective_permissions({"a": ["b"], "b": ["c"], "c": ["a"]}, {"a": [1], "c": [3]}, ["b"], {1, 3})`}, "true"},
+		{"effective_permissions: self loop", []string{`p = x :- effective_permissions({"a": ["a"]}, {"a": [1]}, ["a"], x)`}, `[1]`},
+		{"effective_permissions: ground output", []string{`p :- effective_permissions(q, r, ["editor"], {"docs:write", "docs:read"})`, graph, perms}, "true"},
+		{"effective_permissions: undefined", []string{`p :- effective_permissions(q, r, ["editor"], {"docs:read"})`, graph, perms}, ""},
+		{"effective_permissions: bad graph", []string{`p = x :- effective_permissions([], {}, [], x)`}, fmt.Errorf("evaluation error (code: 2): effective_permissions: input must be object not ast.Array")},
+		{"effective_permissions: bad graph value", []string{`p = x :- effective_permissions({"a": "b"}, {}, ["a"], x)`}, fmt.Errorf("evaluation error (code: 2): effective_permissions: graph values must be array or set not ast.String")},
+		{"effective_permissions: bad permissions", []string{`p = x :- effective_permissions({}, {"a": "read"}, ["a"], x)`}, fmt.Errorf("evaluation error (code: 2): effective_permissions: permissions must be array or set not ast.String")},
+		{"effective_permissions: bad roles", []string{`p = x :- effective_permissions({}, {}, "a", x)`}, fmt.Errorf("evaluation error (code: 2): effective_permissions: assigned roles must be array or set not ast.String")},
+	}
+
+	data := loadSmallTestData()
+
+	for _, tc := range tests {
+		runTopDownTestCase(t, data, tc.note, tc.rules, tc.expected)
+	}
+}
+
+func TestTopDownMembership(t *testing.T) {
+	tests := []struct {
+		note     string
+		rules    []string
+		expected interface{}
+	}{
+		{"member: array", []string{`p = x :- member(3, a, x)`}, "true"},
+		{"member: array absent", []string{`p = x :- member(5, a, x)`}, "false"},
+		{"member: array composite", []string{`p = x :- member({"a": [1]}, [{"a": [2]}, {"a": [1]}], x)`}, "true"},
+		{"member: set", []string{`p = x :- member([1, 2], {[1, 2], [3, 4]}, x)`}, "true"},
+		{"member: set absent", []string{`p = x :- member([2, 1], {[1, 2], [3, 4]}, x)`}, "false"},
+		{"member: set virtual", []string{`p = x :- member("c", q, x)`, `q[k] :- b[k] = _`, `q["c"] :- true`}, "true"},
+		{"member: object", []string{`p = x :- member("hello", b, x)`}, "true"},
+		{"member: object key", []string{`p = x :- member("v1", b, x)`}, "false"},
+		{"member: object composite", []string{`p = x :- member({"p": true, "q": false}, c[0], x)`}, "true"},
+		{"member: empty", []string{`p = x :- member(1, [], x)`}, "false"},
+		{"member: refs", []string{`p[x] :- a[_] = x, member(x, h[1], true)`}, "[2, 3, 4]"},
+		{"member: not", []string{`p[x] :- a[_] = x, not member(x, h[0], true)`}, "[4]"},
+		{"member: ground output", []string{`p :- member(1, a, true)`}, "true"},
+		{"member: undefined", []string{`p :- member(1, a, false)`}, ""},
+		{"member: ref dest", []string{`p :- member(1, a, c[0].x[0])`}, "true"},
+		{"member: ref dest (2)", []string{`p :- not member(1, a, c[0].x[1])`}, "true"},
+		{"member: bad collection", []string{`p = x :- member(1, "abc", x)`}, fmt.Errorf("evaluation error (code: 2): member: collection must be array, set, or object not ast.String")},
+	}
+
+	data := loadSmallTestData()
+
+	for _, tc := range tests {
+		runTopDownTestCase(t, data, tc.note, tc.rules, tc.expected)
+	}
+}
+
+func TestTopDownObjects(t *testing.T) {
+	tests := []struct {
+		note     string
+		rules    []string
+		expected interface{}
+	}{
+		{"object_values_sum", []string{`p = x :- object_values_sum({"a": 1, "b": 2.5, "c": -0.5}, x)`}, "3"},
+		{"object_values_sum: data", []string{`p = x :- object_values_sum(strings, x)`}, "6"},
+		{"object_values_sum: empty", []string{`p = x :- object_values_sum({}, x)`}, "0"},
+		{"object_values_sum: virtual", []string{`p = x :- object_values_sum(q, x)`, `q[k] = v :- strings[k] = v, v > 1`}, "5"},
+		{"object_values_sum: ref dest", []string{`p :- object_values_sum({"x": 1, "y": 3}, a[3])`}, "true"},
+		{"object_values_sum: ref dest (2)", []string{`p :- not object_values_sum({"x": 1, "y": 2}, a[3])`}, "true"},
+		{"object_values_sum: non-numeric", []string{`p = x :- object_values_sum({"a": 1, "b": "hello"}, x)`}, fmt.Errorf(`object_values_sum: object values must be numbers: illegal argument: "hello"`)},
+		{"object_values_sum: bad input", []string{`p = x :- object_values_sum([1, 2], x)`}, fmt.Errorf("evaluation error (code: 2): object_values_sum: input must be object not ast.Array")},
+		{"object_key_symdiff", []string{`p = x :- object_key_symdiff({"a": 1, "b": 2, "c": 3}, {"b": 0, "c": 3, "d": 4}, x)`}, `["a", "d"]`},
+		{"object_key_symdiff: added", []string{`p = x :- object_key_symdiff({"a": 1}, {"a": 2, "b": 2}, x)`}, `["b"]`},
+		{"object_key_symdiff: removed", []string{`p = x :- object_key_symdiff({"a": 1, "b": 2}, {"a": 1}, x)`}, `["b"]`},
+		{"object_key_symdiff: common", []string{`p = x :- object_key_symdiff(b, {"v1": 1, "v2": 2}, x)`}, `[]`},
+		{"object_key_symdiff: virtual", []string{`p = x :- object_key_symdiff(strings, q, x)`, `q[k] = v :- strings[k] = v, v > 1`}, `["foo"]`},
+		{"object_key_symdiff: ground output", []string{`p :- object_key_symdiff({"a": 1}, {"b": 1}, {"a", "b"})`}, "true"},
+		{"object_key_symdiff: bad input", []string{`p = x :- object_key_symdiff({"a": 1}, ["a"], x)`}, fmt.Errorf("evaluation error (code: 2): object_key_symdiff: input must be object not ast.Array")},
+		{"object_get", []string{`p = x :- object_get({"tls": true}, "tls", false, x)`}, "true"},
+		{"object_get: missing key", []string{`p = x :- object_get({"port": 80}, "tls", false, x)`}, "false"},
+		{"object_get: composite default", []string{`p = x :- object_get({}, "ports", [{"port": 80}], x)`}, `[{"port": 80}]`},
+		{"object_get: composite value", []string{`p = x :- object_get(c[0], "z", null, x)`}, `{"p": true, "q": false}`},
+		{"object_get: ref key", []string{`p = x :- object_get(strings, d.e[0], 0, x)`}, "2"},
+		{"object_get: ref default", []string{`p = x :- object_get(strings, "qux", b.v1, x)`}, `"hello"`},
+		{"object_get: virtual", []string{`p = x :- object_get(q, "foo", 0, x)`, `q[k] = v :- strings[k] = v`}, "1"},
+		{"object_get: undefined", []string{`p :- object_get({"a": 1}, "a", 1, 2)`}, ""},
+		{"object_get: bad input", []string{`p = x :- object_get([1], 0, 0, x)`}, fmt.Errorf("evaluation error (code: 2): object_get: first argument must be an object not ast.Array")},
+		{"object_get: ref dest", []string{`p :- object_get({"x": 3}, "x", 0, a[2])`}, "true"},
+		{"object_get: ref dest (2)", []string{`p :- not object_get({"x": 3}, "y", 0, a[2])`}, "true"},
+		{"collect_path", []string{`p = x :- collect_path(q, ["servers", "*", "id"], x)`, `q = {"servers": [{"id": "s1", "ports": ["p1", "p2"]}, {"id": "s2", "ports": ["p2"]}, {"name": "s3"}]} :- true`}, `["s1", "s2"]`},
+		{"collect_path: multiple wildcards", []string{`p = x :- collect_path(q, ["servers", "*", "ports", "*"], x)`, `q = {"servers": [{"id": "s1", "ports": ["p1", "p2"]}, {"id": "s2", "ports": ["p2"]}, {"name": "s3"}]} :- true`}, `["p1", "p2"]`},
+		{"collect_path: object wildcard", []string{`p :- collect_path(c, [0, "*", 0], {true, null})`}, "true"},
+		{"collect_path: literal", []string{`p = x :- collect_path(c[0], ["z", "q"], x)`}, `[false]`},
+		{"collect_path: index", []string{`p = x :- collect_path(h, ["*", 2], x)`}, `[3, 4]`},
+		{"collect_path: set", []string{`p = x :- collect_path({"a": {"x", "y"}}, ["a", "*"], x)`}, `["x", "y"]`},
+		{"collect_path: empty pattern", []string{`p = x :- collect_path(a, [], x)`}, `[[1, 2, 3, 4]]`},
+		{"collect_path: no match", []string{`p = x :- collect_path(c, ["*", "missing"], x)`}, `[]`},
+		{"collect_path: out of range", []string{`p = x :- collect_path(a, [4], x)`}, `[]`},
+		{"collect_path: ref pattern", []string{`p :- y = "a", collect_path(g, [y, "*"], {0, 1})`}, "true"},
+		{"collect_path: ground output", []string{`p :- collect_path(h, ["*", 0], {1, 2})`}, "true"},
+		{"collect_path: bad pattern", []string{`p = x :- collect_path(a, "*", x)`}, fmt.Errorf("evaluation error (code: 2): collect_path: pattern must be array not ast.String")},
+		{"collect_path: bad pattern element", []string{`p = x :- collect_path(a, [[0]], x)`}, fmt.Errorf("evaluation error (code: 2): collect_path: pattern elements must be scalars not ast.Array")},
+		{"object_matches", []string{`p = x :- object_matches({"method": "GET", "path": "/users"}, {"method": "GET", "path": "*"}, x)`}, "true"},
+		{"object_matches: literal", []string{`p = x :- object_matches({"method": "GET", "path": "/users"}, {"method": "GET", "path": "/users"}, x)`}, "true"},
+		{"object_matches: mismatch", []string{`p = x :- object_matches({"method": "POST", "path": "/users"}, {"method": "GET", "path": "*"}, x)`}, "false"},
+		{"object_matches: missing key", []string{`p = x :- object_matches({"method": "GET"}, {"method": "GET", "path": "*"}, x)`}, "false"},
+		{"object_matches: extra key", []string{`p = x :- object_matches({"method": "GET", "path": "/", "body": ""}, {"method": "*", "path": "*"}, x)`}, "false"},
+		{"object_matches: composite wildcard", []string{`p = x :- object_matches({"a": [1, 2], "b": {"c": 1}}, {"a": "*", "b": "*"}, x)`}, "true"},
+		{"object_matches: nested", []string{`p = x :- object_matches({"user": {"name": "bob", "roles": ["admin"]}}, {"user": {"name": "*", "roles": ["admin"]}}, x)`}, "true"},
+		{"object_matches: nested mismatch", []string{`p = x :- object_matches({"user": {"name": "bob"}}, {"user": {"name": "*", "roles": "*"}}, x)`}, "false"},
+		{"object_matches: nested non-object", []string{`p = x :- object_matches({"user": "bob"}, {"user": {"name": "*"}}, x)`}, "false"},
+		{"object_matches: numeric equality", []string{`p = x :- object_matches({"port": 80.0}, {"port": 80}, x)`}, "true"},
+		{"object_matches: non-object", []string{`p = x :- object_matches([1], {"a": "*"}, x)`}, "false"},
+		{"object_matches: refs", []string{`p = x :- object_matches(c[0], {"x": "*", "y": "*", "z": {"p": true, "q": "*"}}, x)`}, "true"},
+		{"object_matches: undefined", []string{`p :- object_matches({"a": 1}, {"a": "*"}, false)`}, ""},
+		{"object_matches: bad pattern", []string{`p = x :- object_matches({"a": 1}, ["a"], x)`}, fmt.Errorf("evaluation error (code: 2): object_matches: pattern must be object not ast.Array")},
+		{"keys_above", []string{`p :- keys_above({"c": 7, "a": 10, "b": 2, "d": 5}, 4, ["a", "c", "d"])`}, "true"},
+		{"keys_above: exclusive", []string{`p :- keys_above({"a": 5, "b": 6}, 5, ["b"])`}, "true"},
+		{"keys_above: fractional", []string{`p :- keys_above({"a": 0.5, "b": 0.25}, 0.3, ["a"])`}, "true"},
+		{"keys_above: none", []string{`p = x :- keys_above({"a": 1}, 1, x)`}, "[]"},
+		{"keys_above: data", []string{`p :- keys_above(strings, 1, ["bar", "baz"])`}, "true"},
+		{"keys_above: counters", []string{`p :- keys_above({"x": g.a[0], "y": g.b[1], "z": g.c[3]}, 1, ["y", "z"])`}, "true"},
+		{"keys_above: non-numeric skipped", []string{`p :- keys_above({"a": "100", "b": 3, "c": null, "d": [10]}, 1, ["b"])`}, "true"},
+		{"keys_above: ref threshold", []string{`p :- keys_above(strings, three, [])`}, "true"},
+		{"keys_above: undefined", []string{`p :- keys_above({"a": 2}, 1, [])`}, ""},
+		{"keys_above: bad input", []string{`p = x :- keys_above(a, 1, x)`}, fmt.Errorf("evaluation error (code: 2): keys_above: input must be object not ast.Ref")},
+		{"keys_above: bad threshold", []string{`p = x :- keys_above({"a": 1}, "1", x)`}, fmt.Errorf(`keys_above: threshold must be a number: illegal argument: "1"`)},
+		{"path_allowed", []string{`p = x :- path_allowed(["users", "profile", "name"], {"users", "profile", "name", "email"}, x)`}, "true"},
+		{"path_allowed: disallowed", []string{`p = x :- path_allowed(["users", "password"], {"users", "profile", "name"}, x)`}, "false"},
+		{"path_allowed: indices", []string{`p = x :- path_allowed(["users", 0, "name"], ["users", "name"], x)`}, "true"},
+		{"path_allowed: empty", []string{`p = x :- path_allowed([], [], x)`}, "true"},
+		{"path_allowed: non-string segment", []string{`p = x :- path_allowed(["a", true], ["a"], x)`}, "false"},
+		{"path_allowed: refs", []string{`p = x :- path_allowed(d.e, ["bar", "baz"], x)`}, "true"},
+		{"path_allowed: virtual", []string{`p[x] :- q[x] = path, path_allowed(path, ["a", "b"], true)`, `q = {"x": ["a", 1, "b"], "y": ["a", "c"]} :- true`}, `["x"]`},
+		{"path_allowed: undefined", []string{`p :- path_allowed(["a"], ["a"], false)`}, ""},
+		{"path_allowed: bad path", []string{`p = x :- path_allowed("a.b", ["a"], x)`}, fmt.Errorf("evaluation error (code: 2): path_allowed: path must be array not ast.String")},
+		{"path_allowed: bad allowed keys", []string{`p = x :- path_allowed(["a"], {"a": 1}, x)`}, fmt.Errorf("evaluation error (code: 2): path_allowed: allowed keys must be array or set not ast.Object")},
+		{"entries_sorted_by_value", []string{`p :- entries_sorted_by_value({"a": 3, "b": 1, "c": 2}, [["b", 1], ["c", 2], ["a", 3]])`}, "true"},
+		{"entries_sorted_by_value: ties", []string{`p :- entries_sorted_by_value({"d": 1, "b": 2, "c": 1, "a": 2}, [["c", 1], ["d", 1], ["a", 2], ["b", 2]])`}, "true"},
+		{"entries_sorted_by_value: data", []string{`p :- entries_sorted_by_value(strings, [["foo", 1], ["bar", 2], ["baz", 3]])`}, "true"},
+		{"entries_sorted_by_value: mixed types", []string{`p :- entries_sorted_by_value({"a": "x", "b": 1, "c": null, "d": [1]}, [["c", null], ["b", 1], ["a", "x"], ["d", [1]]])`}, "true"},
+		{"entries_sorted_by_value: empty", []string{`p = x :- entries_sorted_by_value({}, x)`}, "[]"},
+		{"entries_sorted_by_value: rank", []string{`p = x :- entries_sorted_by_value({"s1": 10, "s2": 30, "s3": 20}, y), y[2] = [x, _]`}, `"s2"`},
+		{"entries_sorted_by_value: undefined", []string{`p :- entries_sorted_by_value({"a": 1, "b": 1}, [["b", 1], ["a", 1]])`}, ""},
+		{"entries_sorted_by_value: bad input", []string{`p = x :- entries_sorted_by_value([1], x)`}, fmt.Errorf("evaluation error (code: 2): entries_sorted_by_value: input must be object not ast.Array")},
+		{"changed_values", []string{`p = x :- changed_values({"a": 1, "b": 2, "c": 3}, {"a": 1, "b": 5, "d": 4}, x)`}, `{"b": [2, 5]}`},
+		{"changed_values: unchanged", []string{`p = x :- changed_values({"a": 1, "b": [1, 2]}, {"b": [1, 2], "a": 1.0}, x)`}, `{}`},
+		{"changed_values: added and removed", []string{`p = x :- changed_values({"a": 1}, {"b": 1}, x)`}, `{}`},
+		{"changed_values: nested", []string{`p = x :- changed_values({"spec": {"replicas": 1, "image": "nginx"}}, {"spec": {"replicas": 3, "image": "nginx"}}, x)`}, `{"spec": [{"replicas": 1, "image": "nginx"}, {"replicas": 3, "image": "nginx"}]}`},
+		{"changed_values: type change", []string{`p = x :- changed_values({"a": "1"}, {"a": 1}, x)`}, `{"a": ["1", 1]}`},
+		{"changed_values: refs", []string{`p = x :- changed_values(b, {"v1": "hello", "v2": "world"}, x)`}, `{"v2": ["goodbye", "world"]}`},
+		{"changed_values: ground output", []string{`p :- changed_values({"a": 1, "b": 2}, {"a": 2, "b": 2}, {"a": [1, 2]})`}, "true"},
+		{"changed_values: undefined", []string{`p :- changed_values({"a": 1}, {"a": 2}, {})`}, ""},
+		{"changed_values: bad input", []string{`p = x :- changed_values({}, [], x)`}, fmt.Errorf("evaluation error (code: 2): changed_values: input must be object not ast.Array")},
+		{"object_union: disjoint", []string{`p = x :- object_union({"a": 1}, {"b": 2}, x)`}, `{"a": 1, "b": 2}`},
+		{"object_union: conflict", []string{`p = x :- object_union({"a": 1, "b": 2}, {"b": 3}, x)`}, `{"a": 1, "b": 3}`},
+		{"object_union: nested", []string{`p = x :- object_union({"tls": {"enabled": false, "port": 443}, "debug": false}, {"tls": {"enabled": true}}, x)`}, `{"tls": {"enabled": true, "port": 443}, "debug": false}`},
+		{"object_union: deeply nested", []string{`p = x :- object_union({"a": {"b": {"c": 1, "d": 2}}}, {"a": {"b": {"d": 3, "e": 4}}}, x)`}, `{"a": {"b": {"c": 1, "d": 3, "e": 4}}}`},
+		{"object_union: arrays", []string{`p = x :- object_union({"ports": [80, 443]}, {"ports": [8080]}, x)`}, `{"ports": [8080]}`},
+		{"object_union: object replaced", []string{`p = x :- object_union({"a": {"b": 1}}, {"a": 2}, x)`}, `{"a": 2}`},
+		{"object_union: refs", []string{`p = x :- object_union(b, {"v2": "hi"}, x)`}, `{"v1": "hello", "v2": "hi"}`},
+		{"object_union: empty", []string{`p = x :- object_union({}, {}, x)`}, `{}`},
+		{"object_union: ref dest", []string{`p :- object_union({"v1": "hello"}, {"v2": "goodbye"}, b)`}, "true"},
+		{"object_union: ref dest (2)", []string{`p :- not object_union({"v1": "hello"}, {"v2": "hello"}, b)`}, "true"},
+		{"object_union: bad input", []string{`p = x :- object_union({}, [], x)`}, fmt.Errorf("evaluation error (code: 2): object_union: both arguments must be objects")},
+		{"object_union: bad input (2)", []string{`p = x :- object_union(1, {}, x)`}, fmt.Errorf("evaluation error (code: 2): object_union: both arguments must be objects")},
+		{"object_remove", []string{`p = x :- object_remove({"user": "bob", "password": "secret", "token": "abc"}, ["password", "token"], x)`}, `{"user": "bob"}`},
+		{"object_remove: set", []string{`p = x :- object_remove({"a": 1, "b": 2}, {"b"}, x)`}, `{"a": 1}`},
+		{"object_remove: absent", []string{`p = x :- object_remove({"a": 1, "b": 2}, ["c"], x)`}, `{"a": 1, "b": 2}`},
+		{"object_remove: all", []string{`p = x :- object_remove({"a": 1, "b": 2}, ["a", "b"], x)`}, `{}`},
+		{"object_remove: none", []string{`p = x :- object_remove({"a": 1}, [], x)`}, `{"a": 1}`},
+		{"object_remove: refs", []string{`p = x :- object_remove(strings, d.e, x)`}, `{"foo": 1}`},
+		{"object_remove: ref dest", []string{`p :- object_remove({"v1": "hello", "v2": "goodbye", "v3": "x"}, ["v3"], b)`}, "true"},
+		{"object_remove: ref dest (2)", []string{`p :- not object_remove({"v1": "hello", "v2": "goodbye"}, ["v2"], b)`}, "true"},
+		{"object_remove: bad object", []string{`p = x :- object_remove(["a"], ["a"], x)`}, fmt.Errorf("evaluation error (code: 2): object_remove: first argument must be an object not ast.Array")},
+		{"object_remove: bad keys", []string{`p = x :- object_remove({"a": 1}, "a", x)`}, fmt.Errorf("evaluation error (code: 2): object_remove: keys must be array or set not ast.String")},
 	}
 
 	data := loadSmallTestData()
@@ -1023,6 +1652,194 @@ func TestTopDownRegex(t *testing.T) {
 		{"re_match: undefined", []string{`p :- re_match("^[a-z]+\\[[0-9]+\\]$", "foo[\"bar\"]")`}, ""},
 		{"re_match: bad pattern err", []string{`p :- re_match("][", "foo[\"bar\"]")`}, fmt.Errorf("re_match: error parsing regexp: missing closing ]: `[`")},
 		{"re_match: ref", []string{`p[x] :- re_match("^b.*$", d.e[x])`}, "[0,1]"},
+		{"re_find", []string{`p :- re_find("([a-z]+)\\[([0-9]+)\\]", "x = foo[1] + bar[2]", ["foo[1]", "foo", "1"])`}, "true"},
+		{"re_find: no groups", []string{`p = x :- re_find("[0-9]+", "abc123def456", x)`}, `["123"]`},
+		{"re_find: optional group", []string{`p :- re_find("a(b)?(c)", "ac", ["ac", "", "c"])`}, "true"},
+		{"re_find: no match", []string{`p = x :- re_find("([a-z]+)\\[([0-9]+)\\]", "foo", x)`}, `[]`},
+		{"re_find: ref", []string{`p :- re_find("^b(.*)$", d.e[1], ["baz", "az"])`}, "true"},
+		{"re_find: ref dest", []string{`p :- re_find("b(a)(r)", "bar", q)`, `q = ["bar", "a", "r"] :- true`}, "true"},
+		{"re_find: undefined", []string{`p :- re_find("a", "b", ["a"])`}, ""},
+		{"re_find: bad pattern err", []string{`p = x :- re_find("][", "foo", x)`}, fmt.Errorf("re_find: error parsing regexp: missing closing ]: `[`")},
+		{"re_find: bad input", []string{`p = x :- re_find("a", 1, x)`}, fmt.Errorf("re_find: input value must be a string: illegal argument: 1")},
+		{"re_replace", []string{`p = x :- re_replace("[0-9]+", "a1b22c333", "#", x)`}, `"a#b#c#"`},
+		{"re_replace: groups", []string{`p = x :- re_replace("([a-z]+)@([a-z]+)\\.com", "bob@example.com, alice@test.com", "$2/$1", x)`}, `"example/bob, test/alice"`},
+		{"re_replace: named group", []string{`p = x :- re_replace("(?P<k>[a-z]+)=(?P<v>[0-9]+)", "a=1", "${v}=${k}", x)`}, `"1=a"`},
+		{"re_replace: no match", []string{`p = x :- re_replace("[0-9]+", "abc", "#", x)`}, `"abc"`},
+		{"re_replace: ref", []string{`p = x :- re_replace("^b", d.e[0], "c", x)`}, `"car"`},
+		{"re_replace: ref dest", []string{`p :- re_replace("o", "bao", "r", d.e[0])`}, "true"},
+		{"re_replace: undefined", []string{`p :- re_replace("o", "foo", "0", "foo")`}, ""},
+		{"re_replace: bad pattern err", []string{`p = x :- re_replace("][", "foo", "", x)`}, fmt.Errorf("re_replace: error parsing regexp: missing closing ]: `[`")},
+		{"re_replace: bad pattern", []string{`p = x :- re_replace(1, "foo", "", x)`}, fmt.Errorf("re_replace: pattern value must be a string: illegal argument: 1")},
+		{"re_replace: bad input", []string{`p = x :- re_replace("a", null, "", x)`}, fmt.Errorf("re_replace: input value must be a string: illegal argument: null")},
+		{"re_replace: bad replacement", []string{`p = x :- re_replace("a", "a", ["b"], x)`}, fmt.Errorf(`re_replace: replacement value must be a string: illegal argument: ["b"]`)},
+	}
+
+	data := loadSmallTestData()
+
+	for _, tc := range tests {
+		runTopDownTestCase(t, data, tc.note, tc.rules, tc.expected)
+	}
+}
+
+func TestRegexpCache(t *testing.T) {
+
+	// Patterns compiled by other tests (or previous runs) must not be counted.
+	regexpCacheLock.Lock()
+	regexpCache = map[string]*regexp.Regexp{}
+	regexpCacheLock.Unlock()
+
+	var compiled int
+	compile := compileRegexp
+	compileRegexp = func(pat string) (*regexp.Regexp, error) {
+		compiled++
+		return compile(pat)
+	}
+	defer func() {
+		compileRegexp = compile
+	}()
+
+	data := loadSmallTestData()
+	rules := []string{
+		`p = n :- count(q, n)`,
+		`q[x] :- numbers_range(1, 500, r), x = r[_], re_match("^cache-test-[0-9]+$", "cache-test-1")`,
+	}
+
+	runTopDownTestCase(t, data, "re_match: cached", rules, "500")
+
+	if compiled != 1 {
+		t.Fatalf("Expected pattern to be compiled once but got: %v", compiled)
+	}
+
+	runTopDownTestCase(t, data, "re_match: bad pattern", []string{`p :- re_match("][", "x")`}, fmt.Errorf("re_match: error parsing regexp: missing closing ]: `[`"))
+
+	for i := 0; i < regexpCacheMaxSize+10; i++ {
+		if _, err := getRegexp(fmt.Sprintf("^%d$", i)); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	regexpCacheLock.Lock()
+	n := len(regexpCache)
+	regexpCacheLock.Unlock()
+
+	if n > regexpCacheMaxSize {
+		t.Fatalf("Expected cache size to be at most %v but got: %v", regexpCacheMaxSize, n)
+	}
+}
+
+func TestTopDownCrypto(t *testing.T) {
+	tests := []struct {
+		note     string
+		rules    []string
+		expected interface{}
+	}{
+		{"sha256", []string{`p = x :- sha256("", x)`}, `"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"`},
+		{"sha256: abc", []string{`p = x :- sha256("abc", x)`}, `"ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"`},
+		{"sha256: utf-8", []string{`p = x :- sha256("\u00e9", x)`}, `"4a99557e4033c3539de2eb65472017cad5f9557f7a0625a09f1c3f6e2ba69c4c"`},
+		{"sha256: undefined", []string{`p :- sha256("", "abc")`}, ""},
+		{"sha256: ref dest", []string{`p :- sha256("abc", q[0])`, `q = ["ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"] :- true`}, "true"},
+		{"sha256: ref dest (2)", []string{`p :- not sha256("abcd", q[0])`, `q = ["ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"] :- true`}, "true"},
+		{"sha256: error", []string{`p = x :- sha256(1, x)`}, fmt.Errorf("sha256: input must be a string: illegal argument: 1")},
+		{"sha1", []string{`p = x :- sha1("", x)`}, `"da39a3ee5e6b4b0d3255bfef95601890afd80709"`},
+		{"sha1: error", []string{`p = x :- sha1(null, x)`}, fmt.Errorf("sha1: input must be a string: illegal argument: null")},
+		{"md5", []string{`p = x :- md5("", x)`}, `"d41d8cd98f00b204e9800998ecf8427e"`},
+		{"md5: ref", []string{`p = x :- md5(b.v1, x)`}, `"5d41402abc4b2a76b9719d911017c592"`},
+		{"md5: error", []string{`p = x :- md5([], x)`}, fmt.Errorf("md5: input must be a string: illegal argument: []")},
+	}
+
+	data := loadSmallTestData()
+
+	for _, tc := range tests {
+		runTopDownTestCase(t, data, tc.note, tc.rules, tc.expected)
+	}
+}
+
+func TestTopDownEncoding(t *testing.T) {
+	tests := []struct {
+		note     string
+		rules    []string
+		expected interface{}
+	}{
+		{"json_marshal", []string{`p = x :- json_marshal({"b": [1, 2.5, "x"], "a": {"c": null, "d": true}}, x)`}, `"{\"a\":{\"c\":null,\"d\":true},\"b\":[1,2.5,\"x\"]}"`},
+		{"json_marshal: set", []string{`p = x :- json_marshal({{1, 2}, {2, 3}}, x)`}, `"[[1,2],[2,3]]"`},
+		{"json_marshal: scalar", []string{`p = x :- json_marshal("foo", x)`}, `"\"foo\""`},
+		{"json_marshal: ref", []string{`p = x :- json_marshal(c[0].z, x)`}, `"{\"p\":true,\"q\":false}"`},
+		{"json_marshal: undefined", []string{`p :- json_marshal(1, "2")`}, ""},
+		{"json_unmarshal", []string{`p = x :- json_unmarshal("{\"a\": [1, {\"b\": null}], \"c\": 3.5}", x)`}, `{"a": [1, {"b": null}], "c": 3.5}`},
+		{"json_unmarshal: scalar", []string{`p = x :- json_unmarshal("true", x)`}, "true"},
+		{"json_unmarshal: ref dest", []string{`p :- json_unmarshal("[1, 2, 3]", h[0])`}, "true"},
+		{"json_unmarshal: ref dest (2)", []string{`p :- not json_unmarshal("[1, 2]", h[0])`}, "true"},
+		{"json_unmarshal: invalid", []string{`p = x :- json_unmarshal("{\"a\":", x)`}, fmt.Errorf("json_unmarshal: invalid JSON: unexpected EOF")},
+		{"json_unmarshal: non-string", []string{`p = x :- json_unmarshal(1, x)`}, fmt.Errorf("json_unmarshal: input must be a string: illegal argument: 1")},
+		{"round trip", []string{`p :- x = {"a": [1, {"b": [true, null]}], "c": "d"}, json_marshal(x, s), json_unmarshal(s, y), x = y`}, "true"},
+		{"value_size_bytes", []string{`p = x :- value_size_bytes({"b": [1, 2.5, "x"], "a": {"c": null, "d": true}}, x)`}, "41"},
+		{"value_size_bytes: scalars", []string{`p[x] :- value_size_bytes(1, y), value_size_bytes("foo", z), value_size_bytes(null, w), x = [y, z, w]`}, "[[1, 5, 4]]"},
+		{"value_size_bytes: empty", []string{`p[x] :- value_size_bytes([], y), value_size_bytes({}, z), x = [y, z]`}, "[[2, 2]]"},
+		{"value_size_bytes: utf-8", []string{`p = x :- value_size_bytes("\u00e9", x)`}, "4"},
+		{"value_size_bytes: compare", []string{`p :- value_size_bytes(c, x), value_size_bytes(c[0].z, y), y < x`}, "true"},
+		{"value_size_bytes: matches json_marshal", []string{`p :- json_marshal(g, s), count(s, n), value_size_bytes(g, n)`}, "true"},
+		{"value_size_bytes: limit", []string{`p :- value_size_bytes(a, x), x <= 9`}, "true"},
+		{"value_size_bytes: undefined", []string{`p :- value_size_bytes(a, 10)`}, ""},
+	}
+
+	data := loadSmallTestData()
+
+	for _, tc := range tests {
+		runTopDownTestCase(t, data, tc.note, tc.rules, tc.expected)
+	}
+}
+
+func TestTopDownGlobs(t *testing.T) {
+	tests := []struct {
+		note     string
+		rules    []string
+		expected interface{}
+	}{
+		{"glob_match_any", []string{`p = x :- glob_match_any(["/api/*/users", "/health"], "/api/v1/users", x)`}, "true"},
+		{"glob_match_any: no match", []string{`p = x :- glob_match_any(["/api/*/users", "/health"], "/api/v1/groups", x)`}, "false"},
+		{"glob_match_any: separator", []string{`p = x :- glob_match_any(["/api/*"], "/api/v1/users", x)`}, "false"},
+		{"glob_match_any: set", []string{`p = x :- glob_match_any({"b?r", "f[a-z]o"}, "foo", x)`}, "true"},
+		{"glob_match_any: empty", []string{`p = x :- glob_match_any([], "foo", x)`}, "false"},
+		{"glob_match_any: refs", []string{`p[x] :- glob_match_any(["ba*"], d.e[x], true)`}, "[0, 1]"},
+		{"glob_match_any: undefined", []string{`p :- glob_match_any(["*"], "foo", false)`}, ""},
+		{"glob_match_any: bad pattern", []string{`p = x :- glob_match_any(["[", "foo"], "foo", x)`}, fmt.Errorf(`glob_match_any: invalid pattern "[": syntax error in pattern`)},
+		{"glob_match_any: error 1", []string{`p = x :- glob_match_any("foo", "foo", x)`}, fmt.Errorf(`glob_match_any: patterns must be array of strings: illegal argument: foo`)},
+		{"glob_match_any: error 2", []string{`p = x :- glob_match_any(["foo"], 1, x)`}, fmt.Errorf("glob_match_any: input value must be a string: illegal argument: 1")},
+	}
+
+	data := loadSmallTestData()
+
+	for _, tc := range tests {
+		runTopDownTestCase(t, data, tc.note, tc.rules, tc.expected)
+	}
+}
+
+func TestTopDownSampling(t *testing.T) {
+	tests := []struct {
+		note     string
+		rules    []string
+		expected interface{}
+	}{
+		{"weighted_sample", []string{`p = x :- weighted_sample(["a", "b", "c"], [1, 0, 0], 7, x)`}, `"a"`},
+		{"weighted_sample: composite", []string{`p = x :- weighted_sample([{"v": 1}, [2]], [0, 3], 1, x)`}, `[2]`},
+		{"weighted_sample: deterministic", []string{`p :- weighted_sample(a, a, 42, x), weighted_sample(a, a, 42, y), x = y`}, "true"},
+		{"weighted_sample: ref dest", []string{`p :- weighted_sample([1, 2, 3, 4], [0, 0, 0, 1], 0, a[3])`}, "true"},
+		{"weighted_sample: ref dest (2)", []string{`p :- not weighted_sample([1, 2, 3, 4], [0, 0, 1, 0], 0, a[3])`}, "true"},
+		{"weighted_sample: bad items", []string{`p = x :- weighted_sample({1, 2}, [1, 1], 0, x)`}, fmt.Errorf("evaluation error (code: 2): weighted_sample: items must be an array not *ast.Set")},
+		{"weighted_sample: length mismatch", []string{`p = x :- weighted_sample([1, 2], [1], 0, x)`}, fmt.Errorf("weighted_sample: items and weights must have the same length")},
+		{"weighted_sample: non-numeric weight", []string{`p = x :- weighted_sample([1, 2], [1, "x"], 0, x)`}, fmt.Errorf(`weighted_sample: weights must be numbers: illegal argument: "x"`)},
+		{"weighted_sample: negative weight", []string{`p = x :- weighted_sample([1, 2], [1, -1], 0, x)`}, fmt.Errorf("weighted_sample: weights must not be negative")},
+		{"weighted_sample: zero total", []string{`p = x :- weighted_sample([1, 2], [0, 0], 0, x)`}, fmt.Errorf("weighted_sample: weights must sum to a positive number")},
+		{"weighted_sample: bad seed", []string{`p = x :- weighted_sample([1, 2], [1, 1], "x", x)`}, fmt.Errorf(`weighted_sample: seed must be an integer: illegal argument: "x"`)},
+		{"label_bucket", []string{`p = x :- label_bucket("alice", ["red", "green", "blue"], x)`}, `"blue"`},
+		{"label_bucket: deterministic", []string{`p :- label_bucket("bob", ["red", "green", "blue"], x), label_bucket("bob", ["red", "green", "blue"], y), x = y`}, "true"},
+		{"label_bucket: single", []string{`p = x :- label_bucket("alice", ["red"], x)`}, `"red"`},
+		{"label_bucket: ref", []string{`p = x :- label_bucket(b.v1, q, x)`, `q = ["red", "green", "blue"] :- true`}, `"red"`},
+		{"label_bucket: undefined", []string{`p :- label_bucket("alice", ["red", "green", "blue"], "red")`}, ""},
+		{"label_bucket: bad input", []string{`p = x :- label_bucket(1, ["red"], x)`}, fmt.Errorf("label_bucket: input must be a string: illegal argument: 1")},
+		{"label_bucket: empty labels", []string{`p = x :- label_bucket("alice", [], x)`}, fmt.Errorf("label_bucket: labels must not be empty")},
+		{"label_bucket: bad labels", []string{`p = x :- label_bucket("alice", {"red"}, x)`}, fmt.Errorf("evaluation error (code: 2): label_bucket: labels must be an array not *ast.Set")},
+		{"label_bucket: bad label", []string{`p = x :- label_bucket("alice", ["red", 1], x)`}, fmt.Errorf("evaluation error (code: 2): label_bucket: labels must be strings not ast.Number")},
 	}
 
 	data := loadSmallTestData()
@@ -1032,6 +1849,31 @@ func TestTopDownRegex(t *testing.T) {
 	}
 }
 
+func TestWeightedSampleProportional(t *testing.T) {
+	weights := []float64{1, 0, 3, 6}
+	counts := make([]int, len(weights))
+	n := 10000
+
+	for seed := 0; seed < n; seed++ {
+		i, err := weightedSample(weights, int64(seed))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		counts[i]++
+	}
+
+	if counts[1] != 0 {
+		t.Fatalf("Expected zero weight element to never be selected but got: %v", counts)
+	}
+
+	for i, w := range weights {
+		expected := float64(n) * w / 10
+		if math.Abs(float64(counts[i])-expected) > float64(n)*0.02 {
+			t.Errorf("Expected roughly %v selections of element %d but got: %v", expected, i, counts)
+		}
+	}
+}
+
 func TestTopDownSets(t *testing.T) {
 	tests := []struct {
 		note     string
@@ -1044,6 +1886,24 @@ func TestTopDownSets(t *testing.T) {
 		{"set_diff: bad input", []string{"p = x :- s1 = {1,2,3}, s2 = [1,2], set_diff(s1, s2, x)"}, fmt.Errorf("evaluation error (code: 2): set_diff: second input argument must be set not ast.Array")},
 		{"set_diff: ground output", []string{"p :- set_diff({1,2,3}, {2,3}, {1})"}, "true"},
 		{"set_diff: virt docs", []string{"p = x :- set_diff(s1, s2, x)", "s1[1] :- true", "s1[2] :- true", `s1["c"] :- true`, `s2 = {"c", 1} :- true`}, "[2]"},
+		{"set_complement", []string{`p = x :- set_complement({"a", "b", "c", "d"}, {"b", "d"}, x)`}, `["a", "c"]`},
+		{"set_complement: extra elements", []string{"p = x :- set_complement({1, 2, 3}, {2, 4, 5}, x)"}, "[1, 3]"},
+		{"set_complement: empty", []string{"p = x :- set_complement({1, 2}, {1, 2, 3}, x)"}, "[]"},
+		{"set_complement: virt docs", []string{"p = x :- set_complement(s1, s2, x)", "s1[x] :- a[_] = x", "s2[x] :- x = 2", "s2[x] :- x = 7"}, "[1, 3, 4]"},
+		{"set_complement: ground output", []string{"p :- set_complement({1, 2, 3}, {2, 3}, {1})"}, "true"},
+		{"set_complement: bad universe", []string{"p = x :- set_complement([1, 2], {1}, x)"}, fmt.Errorf("evaluation error (code: 2): set_complement: universe must be set not ast.Array")},
+		{"set_complement: bad input", []string{"p = x :- set_complement({1, 2}, [1], x)"}, fmt.Errorf("evaluation error (code: 2): set_complement: second input argument must be set not ast.Array")},
+		{"jaccard: identical", []string{`p = x :- jaccard({"a", "b"}, {"b", "a"}, x)`}, "1"},
+		{"jaccard: disjoint", []string{`p = x :- jaccard({"a", "b"}, {"c"}, x)`}, "0"},
+		{"jaccard: overlapping", []string{`p = x :- jaccard({"a", "b", "c"}, {"b", "c", "d"}, x)`}, "0.5"},
+		{"jaccard: fraction", []string{`p = x :- jaccard({1, 2, 3}, {3}, x)`}, "0.3333333333"},
+		{"jaccard: empty", []string{"p = x :- jaccard(s, s, x)", "s[x] :- a[_] = x, x > 4"}, "1"},
+		{"jaccard: one empty", []string{"p = x :- jaccard({1}, s, x)", "s[x] :- a[_] = x, x > 4"}, "0"},
+		{"jaccard: virt docs", []string{"p = x :- jaccard(s1, s2, x)", "s1[x] :- a[_] = x", "s2[x] :- x = 2", "s2[x] :- x = 7"}, "0.2"},
+		{"jaccard: ground output", []string{"p :- jaccard({1, 2}, {2, 3, 4, 1}, 0.5)"}, "true"},
+		{"jaccard: undefined", []string{"p :- jaccard({1, 2}, {2}, 1)"}, ""},
+		{"jaccard: bad input", []string{"p = x :- jaccard([1], {1}, x)"}, fmt.Errorf("evaluation error (code: 2): jaccard: first input argument must be set not ast.Array")},
+		{"jaccard: bad input (2)", []string{"p = x :- jaccard({1}, [1], x)"}, fmt.Errorf("evaluation error (code: 2): jaccard: second input argument must be set not ast.Array")},
 	}
 
 	data := loadSmallTestData()
@@ -1094,6 +1954,66 @@ func TestTopDownStrings(t *testing.T) {
 		{"lower error", []string{`p = x :- lower(true, x)`}, fmt.Errorf("lower: original value must be a string: illegal argument: true")},
 		{"upper", []string{`p = x :- upper("AbCdEf", x)`}, `"ABCDEF"`},
 		{"upper error", []string{`p = x :- upper(true, x)`}, fmt.Errorf("upper: original value must be a string: illegal argument: true")},
+		{"replace", []string{`p = x :- replace("/foo//bar//baz", "//", "/", x)`}, `"/foo/bar/baz"`},
+		{"replace: not found", []string{`p = x :- replace("abc", "x", "y", x)`}, `"abc"`},
+		{"replace: empty old", []string{`p = x :- replace("abc", "", "-", x)`}, `"-a-b-c-"`},
+		{"replace: overlapping", []string{`p = x :- replace("aaaa", "aaa", "b", x)`}, `"ba"`},
+		{"replace: undefined", []string{`p :- replace("abc", "b", "x", "abc")`}, ""},
+		{"replace: ref dest", []string{`p :- replace("boo", "b", "f", c[0].x[2])`}, "true"},
+		{"replace: ref dest (2)", []string{`p :- not replace("bar", "b", "f", c[0].x[2])`}, "true"},
+		{"replace: error 1", []string{`p = x :- replace(1, "a", "b", x)`}, fmt.Errorf("replace: original value must be a string: illegal argument: 1")},
+		{"replace: error 2", []string{`p = x :- replace("abc", 1, "b", x)`}, fmt.Errorf("replace: old value must be a string: illegal argument: 1")},
+		{"replace: error 3", []string{`p = x :- replace("abc", "a", 1, x)`}, fmt.Errorf("replace: new value must be a string: illegal argument: 1")},
+		{"trim", []string{`p = x :- trim("  /foo/  ", " /", x)`}, `"foo"`},
+		{"trim: entire string", []string{`p = x :- trim("aabbaa", "ab", x)`}, `""`},
+		{"trim: no-op", []string{`p = x :- trim("foo", "xyz", x)`}, `"foo"`},
+		{"trim: undefined", []string{`p :- trim("  foo  ", " ", "  foo")`}, ""},
+		{"trim: ref dest", []string{`p :- trim("..foo..", ".", c[0].x[2])`}, "true"},
+		{"trim: ref dest (2)", []string{`p :- not trim("..bar..", ".", c[0].x[2])`}, "true"},
+		{"trim: error 1", []string{`p = x :- trim(1, " ", x)`}, fmt.Errorf("trim: original value must be a string: illegal argument: 1")},
+		{"trim: error 2", []string{`p = x :- trim("foo", 1, x)`}, fmt.Errorf("trim: cutset must be a string: illegal argument: 1")},
+		{"trim_prefix_any", []string{`p = x :- trim_prefix_any("kubernetes.io/name", ["k8s.io/", "kubernetes.io/"], x)`}, `"name"`},
+		{"trim_prefix_any: longest", []string{`p = x :- trim_prefix_any("app.example.com/tier", ["app.", "app.example.com/"], x)`}, `"tier"`},
+		{"trim_prefix_any: set", []string{`p = x :- trim_prefix_any("foo/bar", {"f", "foo/"}, x)`}, `"bar"`},
+		{"trim_prefix_any: no match", []string{`p = x :- trim_prefix_any("foo", ["bar", "baz"], x)`}, `"foo"`},
+		{"trim_prefix_any: undefined", []string{`p :- trim_prefix_any("foo", ["f"], "foo")`}, ""},
+		{"trim_prefix_any: ref dest", []string{`p :- trim_prefix_any("xfoo", ["x"], c[0].x[2])`}, "true"},
+		{"trim_prefix_any: ref dest (2)", []string{`p :- not trim_prefix_any("xbar", ["x"], c[0].x[2])`}, "true"},
+		{"trim_prefix_any: error 1", []string{`p = x :- trim_prefix_any(1, ["x"], x)`}, fmt.Errorf("trim_prefix_any: original value must be a string: illegal argument: 1")},
+		{"trim_prefix_any: error 2", []string{`p = x :- trim_prefix_any("foo", ["x", 1], x)`}, fmt.Errorf("trim_prefix_any: prefixes must be array of strings: illegal argument: 1")},
+		{"sprintf", []string{`p = x :- sprintf("user %v denied on %v", ["bob", "/foo"], x)`}, `"user bob denied on /foo"`},
+		{"sprintf: %d", []string{`p = x :- sprintf("%d items (%03d)", [3.0, 7], x)`}, `"3 items (007)"`},
+		{"sprintf: %s", []string{`p = x :- sprintf("[%s] [%5s]", ["a", "b"], x)`}, `"[a] [    b]"`},
+		{"sprintf: %v", []string{`p = x :- sprintf("%v %v %v %v %v", [1.5, 1e3, true, null, {"a": [1, "b"]}], x)`}, `"1.5 1000 true null {\"a\":[1,\"b\"]}"`},
+		{"sprintf: refs", []string{`p = x :- sprintf("%v-%v", [b.v1, a[1]], x)`}, `"hello-2"`},
+		{"sprintf: arity mismatch", []string{`p = x :- sprintf("%v %v", ["a"], x)`}, `"a %!v(MISSING)"`},
+		{"sprintf: undefined", []string{`p :- sprintf("%v", [1], "2")`}, ""},
+		{"sprintf: ref dest", []string{`p :- sprintf("%so", ["fo"], c[0].x[2])`}, "true"},
+		{"sprintf: ref dest (2)", []string{`p :- not sprintf("%sr", ["ba"], c[0].x[2])`}, "true"},
+		{"sprintf: error 1", []string{`p = x :- sprintf(1, [], x)`}, fmt.Errorf("sprintf: format must be a string: illegal argument: 1")},
+		{"sprintf: error 2", []string{`p = x :- sprintf("%v", "a", x)`}, fmt.Errorf(`sprintf: arguments must be an array: illegal argument: "a"`)},
+		{"canonical_host", []string{`p = x :- canonical_host("WWW.Example.COM", x)`}, `"www.example.com"`},
+		{"canonical_host: trailing dot", []string{`p = x :- canonical_host("example.com.", x)`}, `"example.com"`},
+		{"canonical_host: default port", []string{`p = x :- canonical_host("Example.com:443", x)`}, `"example.com"`},
+		{"canonical_host: default port (2)", []string{`p = x :- canonical_host("example.com.:80", x)`}, `"example.com"`},
+		{"canonical_host: other port", []string{`p = x :- canonical_host("Example.com.:8443", x)`}, `"example.com:8443"`},
+		{"canonical_host: ipv6", []string{`p = x :- canonical_host("[FE80::1]:443", x)`}, `"[fe80::1]"`},
+		{"canonical_host: ipv6 other port", []string{`p = x :- canonical_host("[FE80::1]:8080", x)`}, `"[fe80::1]:8080"`},
+		{"canonical_host: undefined", []string{`p :- canonical_host("Example.com", "Example.com")`}, ""},
+		{"canonical_host: ref dest", []string{`p :- canonical_host("FOO.", c[0].x[2])`}, "true"},
+		{"canonical_host: ref dest (2)", []string{`p :- not canonical_host("BAR.", c[0].x[2])`}, "true"},
+		{"canonical_host: error", []string{`p = x :- canonical_host(1, x)`}, fmt.Errorf("canonical_host: host must be a string: illegal argument: 1")},
+		{"parse_kv", []string{`p = x :- parse_kv("app=web,tier=frontend,env=prod", ",", "=", x)`}, `{"app": "web", "tier": "frontend", "env": "prod"}`},
+		{"parse_kv: empty", []string{`p = x :- parse_kv("", ",", "=", x)`}, `{}`},
+		{"parse_kv: value contains separator", []string{`p = x :- parse_kv("a=b=c;d=", ";", "=", x)`}, `{"a": "b=c", "d": ""}`},
+		{"parse_kv: duplicate key", []string{`p = x :- parse_kv("a=1,a=2", ",", "=", x)`}, `{"a": "2"}`},
+		{"parse_kv: undefined", []string{`p :- parse_kv("a=1", ",", "=", {"a": "2"})`}, ""},
+		{"parse_kv: malformed", []string{`p = x :- parse_kv("a=1,b,c=3", ",", "=", x)`}, fmt.Errorf(`parse_kv: malformed pair: "b"`)},
+		{"parse_kv: empty separator", []string{`p = x :- parse_kv("a=1", "", "=", x)`}, fmt.Errorf("parse_kv: separators must not be empty")},
+		{"parse_kv: error 1", []string{`p = x :- parse_kv(1, ",", "=", x)`}, fmt.Errorf("parse_kv: input value must be a string: illegal argument: 1")},
+		{"parse_kv: error 2", []string{`p = x :- parse_kv("a=1", 1, "=", x)`}, fmt.Errorf("parse_kv: pair separator must be a string: illegal argument: 1")},
+		{"parse_kv: error 3", []string{`p = x :- parse_kv("a=1", ",", 1, x)`}, fmt.Errorf("parse_kv: key/value separator must be a string: illegal argument: 1")},
+		{"parse_kv_lenient", []string{`p = x :- parse_kv_lenient("a=1,b,,c=3", ",", "=", x)`}, `{"a": "1", "c": "3"}`},
 	}
 
 	data := loadSmallTestData()
@@ -1103,29 +2023,182 @@ func TestTopDownStrings(t *testing.T) {
 	}
 }
 
-func TestTopDownEmbeddedVirtualDoc(t *testing.T) {
+func TestTopDownTime(t *testing.T) {
+	tests := []struct {
+		note     string
+		rules    []string
+		expected interface{}
+	}{
+		// 1483228800000000000 is 2017-01-01T00:00:00Z.
+		{"in_business_hours", []string{`p = x :- in_business_hours(1483262400000000000, 9, 17, x)`}, "true"},
+		{"in_business_hours: start", []string{`p = x :- in_business_hours(1483261200000000000, 9, 17, x)`}, "true"},
+		{"in_business_hours: before start", []string{`p = x :- in_business_hours(1483261199999999999, 9, 17, x)`}, "false"},
+		{"in_business_hours: last hour", []string{`p = x :- in_business_hours(1483289999999999999, 9, 17, x)`}, "true"},
+		{"in_business_hours: end", []string{`p = x :- in_business_hours(1483290000000000000, 9, 17, x)`}, "false"},
+		{"in_business_hours: outside", []string{`p = x :- in_business_hours(1483228800000000000, 9, 17, x)`}, "false"},
+		{"in_business_hours: all day", []string{`p = x :- in_business_hours(1483315199000000000, 0, 24, x)`}, "true"},
+		{"in_business_hours: empty window", []string{`p = x :- in_business_hours(1483262400000000000, 12, 12, x)`}, "false"},
+		{"in_business_hours: ref", []string{`p = x :- in_business_hours(1483262400000000000, a[0], three, x)`}, "false"},
+		{"in_business_hours: undefined", []string{`p :- in_business_hours(1483262400000000000, 9, 17, false)`}, ""},
+		{"in_business_hours: bad timestamp", []string{`p = x :- in_business_hours("now", 9, 17, x)`}, fmt.Errorf(`in_business_hours: timestamp must be an integer: illegal argument: "now"`)},
+		{"in_business_hours: bad start", []string{`p = x :- in_business_hours(0, "9", 17, x)`}, fmt.Errorf(`in_business_hours: start hour: must be an integer: illegal argument: "9"`)},
+		{"in_business_hours: fractional end", []string{`p = x :- in_business_hours(0, 9, 17.5, x)`}, fmt.Errorf(`in_business_hours: end hour: must be an integer: strconv.ParseInt: parsing "17.5": invalid syntax`)},
+		{"in_business_hours: start out of range", []string{`p = x :- in_business_hours(0, -1, 17, x)`}, fmt.Errorf(`in_business_hours: start hour: must be between 0 and 24: -1`)},
+		{"in_business_hours: end out of range", []string{`p = x :- in_business_hours(0, 9, 25, x)`}, fmt.Errorf(`in_business_hours: end hour: must be between 0 and 24: 25`)},
+	}
 
-	compiler := compileModules([]string{
-		`package b.c.d
+	data := loadSmallTestData()
 
-         import data.a
-         import data.g
+	for _, tc := range tests {
+		runTopDownTestCase(t, data, tc.note, tc.rules, tc.expected)
+	}
+}
 
-         p[x] :- a[i] = x, q[x]
-         q[x] :- g[j][k] = x`})
+func TestTopDownTypeBuiltins(t *testing.T) {
+	tests := []struct {
+		note     string
+		rules    []string
+		expected interface{}
+	}{
+		{"is_string", []string{`p[x] :- is_string(d.e[x])`}, "[0, 1]"},
+		{"is_string: undefined", []string{`p :- is_string(1)`}, ""},
+		{"is_number", []string{`p[x] :- c[0].y[x] = y, is_number(y)`}, "[1]"},
+		{"is_number: integer", []string{`p :- is_number(a[0])`}, "true"},
+		{"is_number: undefined", []string{`p :- is_number("1")`}, ""},
+		{"is_boolean", []string{`p[x] :- c[0].x[x] = y, is_boolean(y)`}, "[0, 1]"},
+		{"is_boolean: undefined", []string{`p :- is_boolean("true")`}, ""},
+		{"is_array", []string{`p :- is_array(a)`}, "true"},
+		{"is_array: set", []string{`p :- is_array({1, 2})`}, ""},
+		{"is_array: comprehension", []string{`p :- is_array([x | x = a[_]])`}, "true"},
+		{"is_set", []string{`p :- is_set({1, 2})`}, "true"},
+		{"is_set: virtual", []string{`p :- is_set(q)`, `q[x] :- a[_] = x`}, "true"},
+		{"is_set: array", []string{`p :- is_set([1, 2])`}, ""},
+		{"is_object", []string{`p :- is_object(b)`}, "true"},
+		{"is_object: undefined", []string{`p :- is_object([])`}, ""},
+		{"is_null", []string{`p[x] :- l[x].d = y, is_null(y)`}, "[1]"},
+		{"is_null: undefined", []string{`p :- is_null(false)`}, ""},
+		{"not is_number", []string{`p[x] :- c[0].x[x] = y, not is_number(y)`}, "[0, 1, 2]"},
+		{"type_name: null", []string{`p = x :- type_name(null, x)`}, `"null"`},
+		{"type_name: boolean", []string{`p = x :- type_name(c[0].x[0], x)`}, `"boolean"`},
+		{"type_name: number", []string{`p = x :- type_name(3.14, x)`}, `"number"`},
+		{"type_name: string", []string{`p = x :- type_name(b.v1, x)`}, `"string"`},
+		{"type_name: array", []string{`p = x :- type_name(a, x)`}, `"array"`},
+		{"type_name: object", []string{`p = x :- type_name({"a": 1}, x)`}, `"object"`},
+		{"type_name: set", []string{`p = x :- type_name({1, 2}, x)`}, `"set"`},
+		{"type_name: virtual set", []string{`p = x :- type_name(q, x)`, `q[x] :- a[_] = x`}, `"set"`},
+		{"type_name: empty set", []string{`p = x :- type_name(set(), x)`}, `"set"`},
+		{"type_name: undefined", []string{`p :- type_name([1], "set")`}, ""},
+		{"type_name: ref dest", []string{`p :- type_name("x", d.e[2])`}, ""},
+		{"type_name: ref dest (2)", []string{`p :- type_name({1}, q[0])`, `q = ["set"] :- true`}, "true"},
+		{"validate_all", []string{`p :- validate_all({"spec": {"replicas": "3", "ports": [80]}}, [[["spec", "replicas"], "number"], [["spec", "image"], "string"], [["spec", "ports", 0], "number"], [["metadata"], "object"]], ["[\"spec\", \"replicas\"]: expected number but got string", "[\"spec\", \"image\"]: missing", "[\"metadata\"]: missing"])`}, "true"},
+		{"validate_all: valid", []string{`p = x :- validate_all(c, [[[0, "x"], "array"], [[0, "z", "p"], "boolean"], [[0, "y", 0], "null"]], x)`}, `[]`},
+		{"validate_all: no rules", []string{`p = x :- validate_all({}, [], x)`}, `[]`},
+		{"validate_all: root", []string{`p = x :- validate_all([1], [[[], "object"]], x)`}, `["[]: expected object but got array"]`},
+		{"validate_all: index out of range", []string{`p = x :- validate_all(a, [[[4], "number"]], x)`}, `["[4]: missing"]`},
+		{"validate_all: ground output", []string{`p :- validate_all({"a": 1}, [[["a"], "number"]], [])`}, "true"},
+		{"validate_all: bad rules", []string{`p = x :- validate_all({}, {}, x)`}, fmt.Errorf("evaluation error (code: 2): validate_all: rules must be array not ast.Object")},
+		{"validate_all: bad rule", []string{`p = x :- validate_all({}, [["a", "string"]], x)`}, fmt.Errorf(`evaluation error (code: 2): validate_all: rules must be [path, type name] pairs: ["a", "string"]`)},
+	}
 
-	store := storage.New(storage.InMemoryWithJSONConfig(loadSmallTestData()))
+	data := loadSmallTestData()
 
-	assertTopDown(t, compiler, store, "deep embedded vdoc", []string{"b", "c", "d", "p"}, "{}", "[1, 2, 4]")
+	for _, tc := range tests {
+		runTopDownTestCase(t, data, tc.note, tc.rules, tc.expected)
+	}
 }
 
-func TestTopDownRequestValues(t *testing.T) {
-	compiler := compileModules([]string{
-		`package z
-		 import data.a
-		 import request.req1
-		 import request.req2 as req2as
-		 import request.req3.a.b
+func TestTopDownValues(t *testing.T) {
+	tests := []struct {
+		note     string
+		rules    []string
+		expected interface{}
+	}{
+		{"max_depth: scalar", []string{`p[x] :- max_depth(1, y), max_depth("a", z), max_depth(null, w), x = [y, z, w]`}, "[[0, 0, 0]]"},
+		{"max_depth: empty", []string{`p[x] :- max_depth([], y), max_depth({}, z), x = [y, z]`}, "[[1, 1]]"},
+		{"max_depth: flat", []string{`p = x :- max_depth(a, x)`}, "1"},
+		{"max_depth: nested", []string{`p = x :- max_depth(h, x)`}, "2"},
+		{"max_depth: mixed", []string{`p = x :- max_depth(c, x)`}, "3"},
+		{"max_depth: deep", []string{`p = x :- max_depth([1, {"a": [{"b": {[1]}}]}, 2], x)`}, "6"},
+		{"max_depth: object keys", []string{`p = x :- max_depth({"a": 1}, x)`}, "1"},
+		{"max_depth: limit", []string{`p :- max_depth(d, x), x <= 2`}, "true"},
+		{"max_depth: undefined", []string{`p :- max_depth(a, 2)`}, ""},
+		{"max_depth: ref dest", []string{`p :- max_depth(g, a[1])`}, "true"},
+		{"max_depth: ref dest (2)", []string{`p :- not max_depth(g, a[2])`}, "true"},
+		{"leaf_count: scalar", []string{`p[x] :- leaf_count(1, y), leaf_count(null, z), x = [y, z]`}, "[[1, 1]]"},
+		{"leaf_count: empty", []string{`p[x] :- leaf_count([], y), leaf_count({}, z), x = [y, z]`}, "[[0, 0]]"},
+		{"leaf_count: flat", []string{`p = x :- leaf_count(a, x)`}, "4"},
+		{"leaf_count: nested arrays", []string{`p = x :- leaf_count(h, x)`}, "6"},
+		{"leaf_count: nested objects", []string{`p = x :- leaf_count(c, x)`}, "7"},
+		{"leaf_count: mixed", []string{`p = x :- leaf_count({"a": [1, {"b": {2, 3}}], "c": {"d": null}}, x)`}, "4"},
+		{"leaf_count: limit", []string{`p :- leaf_count(g, x), x < 100`}, "true"},
+		{"leaf_count: undefined", []string{`p :- leaf_count(a, 5)`}, ""},
+		{"leaf_count: ref dest", []string{`p :- leaf_count([[1], [2, [3, 4]]], a[3])`}, "true"},
+		{"leaf_count: ref dest (2)", []string{`p :- not leaf_count([1, 2], a[3])`}, "true"},
+	}
+
+	data := loadSmallTestData()
+
+	for _, tc := range tests {
+		runTopDownTestCase(t, data, tc.note, tc.rules, tc.expected)
+	}
+}
+
+func TestTopDownWalk(t *testing.T) {
+	tests := []struct {
+		note     string
+		rules    []string
+		expected interface{}
+	}{
+		{"scalar", []string{`p[x] :- walk(1, x)`}, `[[[], 1]]`},
+		{"nested", []string{`p[x] :- walk({"a": [1, {"b": true}], "c": {"x"}}, x)`}, `[
+			[[], {"a": [1, {"b": true}], "c": ["x"]}],
+			[["a"], [1, {"b": true}]],
+			[["a", 0], 1],
+			[["a", 1], {"b": true}],
+			[["a", 1, "b"], true],
+			[["c"], ["x"]],
+			[["c", "x"], "x"]
+		]`},
+		{"base doc", []string{`p[path] :- walk(c, [path, false])`}, `[[0, "x", 1], [0, "z", "q"]]`},
+		{"paths", []string{`p[k] :- walk(d, [path, "baz"]), k = path[_]`}, `["e", 1]`},
+		{"object pattern", []string{`p[x] :- walk(c, [_, {"p": x, "q": _}])`}, `[true]`},
+		{"virtual doc", []string{`p[path] :- walk(q, [path, 2])`, `q[x] = y :- x = "a", y = [1, 2]`}, `[["a", 1]]`},
+		{"ground", []string{`p :- walk(l, [[1, "d"], null])`}, "true"},
+		{"undefined", []string{`p :- walk(a, [[], 1])`}, ""},
+		{"ref dest", []string{`p[x] :- walk([1, 2], [[x], a[x]])`}, "[0, 1]"},
+		{"ref dest (2)", []string{`p[x] :- walk([2, 3], [[x], a[x]])`}, "[]"},
+	}
+
+	data := loadSmallTestData()
+
+	for _, tc := range tests {
+		runTopDownTestCase(t, data, tc.note, tc.rules, tc.expected)
+	}
+}
+
+func TestTopDownEmbeddedVirtualDoc(t *testing.T) {
+
+	compiler := compileModules([]string{
+		`package b.c.d
+
+         import data.a
+         import data.g
+
+         p[x] :- a[i] = x, q[x]
+         q[x] :- g[j][k] = x`})
+
+	store := storage.New(storage.InMemoryWithJSONConfig(loadSmallTestData()))
+
+	assertTopDown(t, compiler, store, "deep embedded vdoc", []string{"b", "c", "d", "p"}, "{}", "[1, 2, 4]")
+}
+
+func TestTopDownRequestValues(t *testing.T) {
+	compiler := compileModules([]string{
+		`package z
+		 import data.a
+		 import request.req1
+		 import request.req2 as req2as
+		 import request.req3.a.b
 		 import request.req4.a.b as req4as
 		 p = true :- a[i] = x, req1.foo = x, req2as.bar = x, q[x]
 		 q[x] :- req1.foo = x, req2as.bar = x, r[x]
@@ -1215,6 +2288,58 @@ func TestTopDownRequestValues(t *testing.T) {
 	})
 }
 
+func TestTopDownWithKeyword(t *testing.T) {
+	compiler := compileModules([]string{
+		`package ex
+		 loopback = request
+		 foo = x :- request.foo = x
+		 gt5 :- request.foo > 5
+		 r = 1 :- true
+		 q = x :- r = x
+		 s = x :- data.a[0] = x
+		 u = x :- data.b = x
+		 t[i] :- data.a[i] = 9
+
+		 request_override = x :- foo = x with request as {"foo": 7}
+		 request_subpath = x :- loopback = x with request.bar as 2
+		 request_scoped = {"inner": x, "outer": y} :- foo = x with request.foo as 7, foo = y
+		 request_vars = x :- y = 3, foo = x with request.foo as y
+		 request_negated :- not gt5 with request.foo as 1
+		 request_backtrack[x] :- data.a[_] = y, foo = x with request.foo as y, x > 2
+		 request_multiple = x :- loopback = x with request.foo as 2 with request.bar.baz as 3
+		 data_virtual = {"inner": x, "outer": y} :- q = x with data.ex.r as 10, q = y
+		 data_base = x :- s = x with data.a as [9]
+		 data_indexed[i] :- t[i] with data.a as [9, 8, 9]
+		 data_subpath = x :- u = x with data.b.v1 as "hi"
+		 `})
+
+	store := storage.New(storage.InMemoryWithJSONConfig(loadSmallTestData()))
+
+	tests := []struct {
+		note     string
+		path     string
+		request  string
+		expected interface{}
+	}{
+		{"request", "request_override", `{"foo": 1}`, "7"},
+		{"request undefined", "request_override", "", "7"},
+		{"request sub-path", "request_subpath", `{"foo": 1}`, `{"foo": 1, "bar": 2}`},
+		{"request scoped", "request_scoped", `{"foo": 1}`, `{"inner": 7, "outer": 1}`},
+		{"request vars", "request_vars", "", "3"},
+		{"request negated", "request_negated", `{"foo": 10}`, "true"},
+		{"request backtracking", "request_backtrack", `{"foo": 1}`, "[3, 4]"},
+		{"request multiple", "request_multiple", `{"foo": 1}`, `{"foo": 2, "bar": {"baz": 3}}`},
+		{"data virtual doc", "data_virtual", "", `{"inner": 10, "outer": 1}`},
+		{"data base doc", "data_base", "", "9"},
+		{"data indexed", "data_indexed", "", "[0, 2]"},
+		{"data sub-path", "data_subpath", "", `{"v1": "hi", "v2": "goodbye"}`},
+	}
+
+	for _, tc := range tests {
+		assertTopDown(t, compiler, store, tc.note, []string{"ex", tc.path}, tc.request, tc.expected)
+	}
+}
+
 func TestTopDownCaching(t *testing.T) {
 	compiler := compileModules([]string{`
 	package topdown.caching
@@ -1239,6 +2364,104 @@ func TestTopDownCaching(t *testing.T) {
 	assertTopDown(t, compiler, store, "unhandled error", []string{"topdown", "caching", "err_obj"}, "{}", illegalObjectKeyMsg)
 }
 
+func TestTopDownCompleteDocCaching(t *testing.T) {
+
+	compiler := compileModules([]string{`
+	package topdown.caching
+
+	p :- q, q, r[_] = q, not s, not s, request.x = q
+
+	q = x :- count(data.a, n), request.x = n, x = n
+	s :- data.a[_] = 100
+	r[k] = v :- k = data.a[_], q = v
+	`})
+
+	ctx := context.Background()
+	store := storage.New(storage.InMemoryWithJSONConfig(loadSmallTestData()))
+	txn := storage.NewTransactionOrDie(ctx, store)
+	defer store.Close(ctx, txn)
+
+	params := NewQueryParams(ctx, compiler, store, txn, ast.MustParseTerm(`{"x": 4}`).Value, ast.MustParseRef("data.topdown.caching.p"))
+	params.Metrics = NewMetrics()
+
+	qrs, err := Query(params)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if qrs.Undefined() || qrs[0].Result != true {
+		t.Fatalf("Expected true but got: %v", qrs)
+	}
+
+	// Each rule body is evaluated once regardless of the number of references
+	// to the rule and regardless of whether the rule is defined.
+	if exp, result := uint64(4), params.Metrics.Counter(evalRules); result != exp {
+		t.Fatalf("Expected %v rule evaluations but got: %v", exp, result)
+	}
+}
+
+func TestTopDownRuleIndexing(t *testing.T) {
+
+	module := []string{"package topdown.indexing"}
+	for i := 0; i < 50; i++ {
+		module = append(module, fmt.Sprintf(`allow :- request.method = "M%d", request.user != "bob"`, i))
+	}
+	module = append(module, `allow :- request.user = "admin"`)
+
+	compiler := compileModules([]string{strings.Join(module, "\n")})
+	store := storage.New(storage.InMemoryConfig())
+
+	tests := []struct {
+		note     string
+		request  string
+		expected string
+		exprs    uint64
+	}{
+		{"match", `{"method": "M10", "user": "alice"}`, "true", 5},
+		{"match unguarded", `{"method": "M99", "user": "admin"}`, "true", 3},
+		{"no match", `{"method": "M99", "user": "alice"}`, "", 3},
+	}
+
+	for _, tc := range tests {
+		testutil.Subtest(t, tc.note, func(t *testing.T) {
+
+			ctx := context.Background()
+			txn := storage.NewTransactionOrDie(ctx, store)
+			defer store.Close(ctx, txn)
+
+			params := NewQueryParams(ctx, compiler, store, txn, ast.MustParseTerm(tc.request).Value, ast.MustParseRef("data.topdown.indexing.allow"))
+			params.Metrics = NewMetrics()
+
+			qrs, err := Query(params)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if tc.expected == "" {
+				if !qrs.Undefined() {
+					t.Fatalf("Expected undefined result but got: %v", qrs)
+				}
+			} else if qrs.Undefined() || !reflect.DeepEqual(qrs[0].Result, parseJSON(tc.expected)) {
+				t.Fatalf("Expected %v but got: %v", tc.expected, qrs)
+			}
+
+			// Only the expressions of the request, the query, and the rules
+			// that apply to the request are evaluated.
+			if result := params.Metrics.Counter(evalExprs); result != tc.exprs {
+				t.Fatalf("Expected %v expressions to be evaluated but got: %v", tc.exprs, result)
+			}
+		})
+	}
+
+	// Requests replaced by the with modifier are indexed.
+	compiler = compileModules([]string{strings.Join(module, "\n"), `
+		package topdown.indexing.with
+		p :- data.topdown.indexing.allow with request.method as "M7"
+	`})
+
+	assertTopDown(t, compiler, store, "with", []string{"topdown", "indexing", "with", "p"}, `{"user": "alice"}`, "true")
+}
+
 func TestTopDownStoragePlugin(t *testing.T) {
 
 	compiler := compileModules([]string{`
@@ -1364,6 +2587,44 @@ func TestTopDownUnsupportedBuiltin(t *testing.T) {
 
 }
 
+func TestTopDownMultipleResultBuiltin(t *testing.T) {
+
+	ast.RegisterBuiltin(&ast.Builtin{
+		Name:      ast.Var("test_yield_three"),
+		NumArgs:   1,
+		TargetPos: []int{0},
+	})
+
+	RegisterBuiltinFunc(ast.Var("test_yield_three"), func(t *Topdown, expr *ast.Expr, iter Iterator) error {
+		output := expr.Terms.([]*ast.Term)[1].Value
+		for _, x := range []string{`[1, "a"]`, `[2, "b"]`, `[3, "c"]`} {
+			if err := Unify(t, ast.MustParseTerm(x).Value, output, iter); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	tests := []struct {
+		note     string
+		rules    []string
+		expected interface{}
+	}{
+		{"all", []string{`p[x] :- test_yield_three(x)`}, `[[1, "a"], [2, "b"], [3, "c"]]`},
+		{"partial match", []string{`p[x] :- test_yield_three([x, "b"])`}, `[2]`},
+		{"backtracking", []string{`p[x] :- test_yield_three([x, y]), y != "a"`}, `[2, 3]`},
+		{"undefined", []string{`p[x] :- test_yield_three([x, "d"])`}, `[]`},
+		{"cross product", []string{`p[[x, y]] :- test_yield_three([x, "a"]), test_yield_three([y, _]), y > x`}, `[[1, 2], [1, 3]]`},
+		{"negation", []string{`p :- not test_yield_three([4, "a"])`}, "true"},
+	}
+
+	data := loadSmallTestData()
+
+	for _, tc := range tests {
+		runTopDownTestCase(t, data, tc.note, tc.rules, tc.expected)
+	}
+}
+
 type contextPropagationMock struct{}
 
 // contextPropagationStore will accumulate values from the contexts provided to
@@ -1423,6 +2684,412 @@ func TestTopDownContextPropagation(t *testing.T) {
 	}
 }
 
+func TestTopDownQueryStream(t *testing.T) {
+
+	compiler := compileModules([]string{`
+		package ex
+		p :- request.x > 0
+	`})
+
+	ctx := context.Background()
+	store := storage.New(storage.InMemoryWithJSONConfig(loadSmallTestData()))
+	txn := storage.NewTransactionOrDie(ctx, store)
+	defer store.Close(ctx, txn)
+
+	request := ast.MustParseTerm(`{"x": data.a[i]}`).Value
+	params := NewQueryParams(ctx, compiler, store, txn, request, ast.MustParseRef("data.ex.p"))
+	tracer := NewBufferTracer()
+	params.Tracer = tracer
+
+	// Record the number of trace events emitted when each result is produced
+	// to check that results are delivered before evaluation finishes.
+	var results []*QueryResult
+	var events []int
+
+	err := QueryStream(params, func(qr *QueryResult) error {
+		results = append(results, qr)
+		events = append(events, len(*tracer))
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := parseQueryResultSetJSON([][2]string{
+		{`true`, `{"i": 0}`},
+		{`true`, `{"i": 1}`},
+		{`true`, `{"i": 2}`},
+		{`true`, `{"i": 3}`},
+	})
+
+	if !reflect.DeepEqual(QueryResultSet(results), expected) {
+		t.Fatalf("Expected %v but got: %v", expected, results)
+	}
+
+	for i := 1; i < len(events); i++ {
+		if events[i-1] >= events[i] {
+			t.Fatalf("Expected results to be produced incrementally but got events: %v", events)
+		}
+	}
+
+	if events[0] >= len(*tracer) {
+		t.Fatalf("Expected results to be produced before evaluation finished but got events: %v (total: %v)", events, len(*tracer))
+	}
+
+	// Errors returned by the iterator halt evaluation.
+	params = NewQueryParams(ctx, compiler, store, txn, request, ast.MustParseRef("data.ex.p"))
+	stop := fmt.Errorf("stop")
+	count := 0
+
+	err = QueryStream(params, func(qr *QueryResult) error {
+		count++
+		if count == 2 {
+			return stop
+		}
+		return nil
+	})
+
+	if err != stop {
+		t.Fatalf("Expected %v but got: %v", stop, err)
+	}
+
+	if count != 2 {
+		t.Fatalf("Expected evaluation to stop after 2 results but got: %v", count)
+	}
+}
+
+func TestTopDownQueryMembers(t *testing.T) {
+
+	compiler := compileModules([]string{`
+		package ex
+		p[x] :- numbers_range(1, 1000, r), x = r[_]
+		q[x] :- x = data.a[_], x > request.min
+		dup[x] :- x = data.g.a[_]
+		empty[x] :- x = data.a[_], x > 100
+		doc = 1
+	`})
+
+	ctx := context.Background()
+	store := storage.New(storage.InMemoryWithJSONConfig(loadSmallTestData()))
+	txn := storage.NewTransactionOrDie(ctx, store)
+	defer store.Close(ctx, txn)
+
+	// QueryStream produces the same results as Query for partial sets, i.e.,
+	// the set is delivered as a single result and empty sets are defined.
+	for _, path := range []string{"data.ex.dup", "data.ex.empty"} {
+		params := NewQueryParams(ctx, compiler, store, txn, nil, ast.MustParseRef(path))
+		expected, err := Query(params)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		var results []*QueryResult
+		params = NewQueryParams(ctx, compiler, store, txn, nil, ast.MustParseRef(path))
+		err = QueryStream(params, func(qr *QueryResult) error {
+			results = append(results, qr)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if expected.Undefined() || !reflect.DeepEqual(QueryResultSet(results), expected) {
+			t.Fatalf("%v: expected %v but got: %v", path, expected, results)
+		}
+	}
+
+	// Documents other than partial sets cannot be queried for members.
+	params := NewQueryParams(ctx, compiler, store, txn, nil, ast.MustParseRef("data.ex.doc"))
+	err := QueryMembers(params, func(qr *QueryResult) error {
+		return nil
+	})
+
+	if err == nil || err.Error() != "data.ex.doc: not a partial set" {
+		t.Fatalf("Expected partial set error but got: %v", err)
+	}
+
+	params = NewQueryParams(ctx, compiler, store, txn, nil, ast.MustParseRef("data.ex.p"))
+	tracer := NewBufferTracer()
+	params.Tracer = tracer
+
+	// Members are delivered individually as they are produced rather than as a
+	// single set.
+	var members []interface{}
+	var events []int
+
+	err = QueryMembers(params, func(qr *QueryResult) error {
+		members = append(members, qr.Result)
+		events = append(events, len(*tracer))
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(members) != 1000 || members[0] != json.Number("1") || members[999] != json.Number("1000") {
+		t.Fatalf("Expected members 1 to 1000 but got %v members: %v", len(members), members)
+	}
+
+	if events[0] >= events[1] || events[1] >= len(*tracer) {
+		t.Fatalf("Expected members to be produced incrementally but got events: %v (total: %v)", events[:2], len(*tracer))
+	}
+
+	// Errors returned by the iterator halt evaluation.
+	params = NewQueryParams(ctx, compiler, store, txn, nil, ast.MustParseRef("data.ex.p"))
+	stop := fmt.Errorf("stop")
+	count := 0
+
+	err = QueryMembers(params, func(qr *QueryResult) error {
+		count++
+		if count == 3 {
+			return stop
+		}
+		return nil
+	})
+
+	if err != stop || count != 3 {
+		t.Fatalf("Expected evaluation to stop after 3 members but got: %v (err: %v)", count, err)
+	}
+
+	// Members are delivered with the bindings of the request that produced them.
+	request := ast.MustParseTerm(`{"min": data.a[i]}`).Value
+	params = NewQueryParams(ctx, compiler, store, txn, request, ast.MustParseRef("data.ex.q"))
+	var results []*QueryResult
+
+	err = QueryMembers(params, func(qr *QueryResult) error {
+		results = append(results, qr)
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := parseQueryResultSetJSON([][2]string{
+		{`2`, `{"i": 0}`},
+		{`3`, `{"i": 0}`},
+		{`4`, `{"i": 0}`},
+		{`3`, `{"i": 1}`},
+		{`4`, `{"i": 1}`},
+		{`4`, `{"i": 2}`},
+	})
+
+	if !reflect.DeepEqual(QueryResultSet(results), expected) {
+		t.Fatalf("Expected %v but got: %v", expected, results)
+	}
+}
+
+func TestTopDownPreparedQuery(t *testing.T) {
+
+	compiler := compileModules([]string{`
+		package ex
+		p = y :- plus(request.x, 1, y)
+	`})
+
+	ctx := context.Background()
+	store := storage.New(storage.InMemoryWithJSONConfig(loadSmallTestData()))
+	prepared := NewPreparedQuery(compiler, store, ast.MustParseRef("data.ex.p"))
+
+	eval := func(request string) (QueryResultSet, error) {
+		txn := storage.NewTransactionOrDie(ctx, store)
+		defer store.Close(ctx, txn)
+		return prepared.Eval(ctx, txn, ast.MustParseTerm(request).Value)
+	}
+
+	tests := []struct {
+		request  string
+		expected [][2]string
+	}{
+		{`{"x": 1}`, [][2]string{{`2`, `{}`}}},
+		{`{"x": 2}`, [][2]string{{`3`, `{}`}}},
+		{`{"x": data.a[i]}`, [][2]string{{`2`, `{"i": 0}`}, {`3`, `{"i": 1}`}, {`4`, `{"i": 2}`}, {`5`, `{"i": 3}`}}},
+		{`{"y": 1}`, nil},
+	}
+
+	for _, tc := range tests {
+		result, err := eval(tc.request)
+		if err != nil {
+			t.Fatalf("Unexpected error for %v: %v", tc.request, err)
+		}
+		if len(tc.expected) == 0 {
+			if !result.Undefined() {
+				t.Fatalf("Expected undefined for %v but got: %v", tc.request, result)
+			}
+			continue
+		}
+		expected := parseQueryResultSetJSON(tc.expected)
+		if !reflect.DeepEqual(result, expected) {
+			t.Fatalf("Expected %v for %v but got: %v", expected, tc.request, result)
+		}
+	}
+
+	// Prepared queries can be evaluated concurrently.
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			result, err := eval(fmt.Sprintf(`{"x": %d}`, i))
+			if err != nil {
+				errs <- err
+			} else if !reflect.DeepEqual(result, parseQueryResultSetJSON([][2]string{{fmt.Sprint(i + 1), `{}`}})) {
+				errs <- fmt.Errorf("expected %v for %v but got: %v", i+1, i, result)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Fatal(err)
+	}
+}
+
+// cancelTracer cancels the context after a fixed number of events and counts
+// the events received after cancellation.
+type cancelTracer struct {
+	cancel func()
+	after  int
+	events int
+	extra  int
+}
+
+func (c *cancelTracer) Enabled() bool {
+	return true
+}
+
+func (c *cancelTracer) Trace(t *Topdown, evt *Event) {
+	c.events++
+	if c.events == c.after {
+		c.cancel()
+	} else if c.events > c.after {
+		c.extra++
+	}
+}
+
+func TestTopDownContextCancellation(t *testing.T) {
+
+	arr := make([]interface{}, 100000)
+	for i := range arr {
+		arr[i] = json.Number(fmt.Sprint(i))
+	}
+	data := map[string]interface{}{"a": arr}
+
+	compiler := compileModules([]string{`
+		package ex
+		p[x] :- data.a[i] = x, x >= 0
+	`})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	store := storage.New(storage.InMemoryWithJSONConfig(data))
+	txn := storage.NewTransactionOrDie(ctx, store)
+	defer store.Close(ctx, txn)
+
+	params := NewQueryParams(ctx, compiler, store, txn, nil, ast.MustParseRef("data.ex.p"))
+	tracer := &cancelTracer{cancel: cancel, after: 1000}
+	params.Tracer = tracer
+
+	_, err := Query(params)
+
+	if e, ok := err.(*Error); !ok || e.Code != CancelErr {
+		t.Fatalf("Expected cancel error but got: %v", err)
+	}
+
+	// The context is checked periodically so a bounded number of events may
+	// be emitted after cancellation.
+	if tracer.extra > 4*cancelCheckInterval {
+		t.Fatalf("Expected evaluation to stop promptly but got %v events after cancellation", tracer.extra)
+	}
+
+	// Queries on contexts that are already done fail immediately.
+	params.Tracer = nil
+	_, err = Query(params)
+
+	if e, ok := err.(*Error); !ok || e.Code != CancelErr {
+		t.Fatalf("Expected cancel error but got: %v", err)
+	}
+}
+
+func TestTopDownElementReads(t *testing.T) {
+
+	// Reads of single elements are resolved inside the store so the number of
+	// allocations must not depend on the size of the enclosing document.
+	small := elementReadAllocs(t, 10)
+	large := elementReadAllocs(t, 100000)
+
+	for i := range small {
+		if large[i] > small[i] {
+			t.Errorf("Expected element read #%d to allocate at most %v times but got: %v", i+1, small[i], large[i])
+		}
+	}
+}
+
+func BenchmarkTopDownElementRead(b *testing.B) {
+
+	params, done := setupElementReadQuery(100000, "data.ex.p")
+	defer done()
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := Query(params); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func elementReadAllocs(t *testing.T, n int) []float64 {
+
+	var result []float64
+
+	for _, path := range []string{"data.ex.p", "data.ex.q", "data.ex.r"} {
+		params, done := setupElementReadQuery(n, path)
+		allocs := testing.AllocsPerRun(10, func() {
+			qrs, err := Query(params)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			} else if !reflect.DeepEqual(qrs[0].Result, json.Number("3")) {
+				t.Fatalf("Expected 3 but got: %v", qrs[0].Result)
+			}
+		})
+		done()
+		result = append(result, allocs)
+	}
+
+	return result
+}
+
+func setupElementReadQuery(n int, path string) (*QueryParams, func()) {
+
+	arr := make([]interface{}, n)
+	for i := range arr {
+		arr[i] = json.Number(fmt.Sprint(i))
+	}
+
+	compiler := compileModules([]string{`
+		package ex
+		p = x :- data.a[3] = x
+		q = x :- i = 3, data.a[i] = x
+		r = x :- data.plugin.b[3] = x
+	`})
+
+	ctx := context.Background()
+	store := storage.New(storage.InMemoryWithJSONConfig(map[string]interface{}{"a": arr}))
+	plugin := storage.NewDataStoreFromJSONObject(map[string]interface{}{"b": arr})
+
+	if err := store.Mount(plugin, storage.MustParsePath("/plugin")); err != nil {
+		panic(err)
+	}
+
+	txn := storage.NewTransactionOrDie(ctx, store)
+	params := NewQueryParams(ctx, compiler, store, txn, nil, ast.MustParseRef(path))
+
+	return params, func() { store.Close(ctx, txn) }
+}
+
 func TestTopDownTracingEval(t *testing.T) {
 	module := `
 	package test
@@ -1430,26 +3097,97 @@ func TestTopDownTracingEval(t *testing.T) {
 	`
 	p := ast.MustParseRule(`p :- arr = [1,2,3], x = arr[_], x != 2`)
 	runTopDownTracingTestCase(t, module, 15, map[int]*Event{
-		6:  &Event{ExitOp, p, 3, 2, parseBindings("{x: 1}")},
-		7:  &Event{RedoOp, p, 3, 2, nil},
-		8:  &Event{RedoOp, parseExpr("x = arr[_]", 1), 3, 2, nil},
-		9:  &Event{EvalOp, parseExpr("x != 2", 2), 3, 2, parseBindings("{x: 2}")},
-		10: &Event{FailOp, parseExpr("x != 2", 2), 3, 2, parseBindings("{x: 2}")},
-		11: &Event{RedoOp, parseExpr("x = arr[_]", 1), 3, 2, parseBindings("{arr: [1,2,3]}")},
-		12: &Event{EvalOp, parseExpr("x != 2", 2), 3, 2, parseBindings("{x: 3}")},
-		13: &Event{ExitOp, p, 3, 2, parseBindings("{x: 3}")},
+		6:  &Event{Op: ExitOp, Node: p, QueryID: 3, ParentID: 2, Locals: parseBindings("{x: 1}")},
+		7:  &Event{Op: RedoOp, Node: p, QueryID: 3, ParentID: 2},
+		8:  &Event{Op: RedoOp, Node: parseExpr("x = arr[_]", 1), QueryID: 3, ParentID: 2},
+		9:  &Event{Op: EvalOp, Node: parseExpr("x != 2", 2), QueryID: 3, ParentID: 2, Locals: parseBindings("{x: 2}")},
+		10: &Event{Op: FailOp, Node: parseExpr("x != 2", 2), QueryID: 3, ParentID: 2, Locals: parseBindings("{x: 2}")},
+		11: &Event{Op: RedoOp, Node: parseExpr("x = arr[_]", 1), QueryID: 3, ParentID: 2, Locals: parseBindings("{arr: [1,2,3]}")},
+		12: &Event{Op: EvalOp, Node: parseExpr("x != 2", 2), QueryID: 3, ParentID: 2, Locals: parseBindings("{x: 3}")},
+		13: &Event{Op: ExitOp, Node: p, QueryID: 3, ParentID: 2, Locals: parseBindings("{x: 3}")},
 	})
 }
 
+func TestTopDownTracingNote(t *testing.T) {
+
+	compiler := compileModules([]string{`
+	package test
+	p :- x = "hello", trace(x), q
+	q :- trace("world")
+	`})
+
+	ctx := context.Background()
+	store := storage.New(storage.InMemoryConfig())
+	txn := storage.NewTransactionOrDie(ctx, store)
+	defer store.Close(ctx, txn)
+
+	params := NewQueryParams(ctx, compiler, store, txn, nil, ast.MustParseRef("data.test.p"))
+	tracer := NewBufferTracer()
+	params.Tracer = tracer
+
+	qrs, err := Query(params)
+	if err != nil || qrs.Undefined() {
+		t.Fatalf("Expected true but got: %v (err: %v)", qrs, err)
+	}
+
+	var notes []*Event
+	for _, evt := range *tracer {
+		if evt.Op == NoteOp {
+			notes = append(notes, evt)
+		}
+	}
+
+	if len(notes) != 2 {
+		t.Fatalf("Expected 2 note events but got: %v", notes)
+	}
+
+	for i, msg := range []string{"hello", "world"} {
+		expr := notes[i].Node.(*ast.Expr)
+		if !expr.Terms.([]*ast.Term)[1].Equal(ast.StringTerm(msg)) {
+			t.Errorf("Expected note %d to contain %q but got: %v", i, msg, expr)
+		}
+		if expr.Location == nil {
+			t.Errorf("Expected note %d to have a location", i)
+		}
+	}
+
+	// Notes have the same depth as the expressions in the query they belong to.
+	expected := `Enter eq(data.test.p, _)
+| Eval eq(data.test.p, _)
+| Enter p = true :- eq(x, "hello"), trace(x), data.test.q
+| | Eval eq(x, "hello")
+| | Eval trace(x) {x: "hello"}
+| | Note trace("hello")
+| | Eval data.test.q
+| | Enter q = true :- trace("world")
+| | | Eval trace("world")
+| | | Note trace("world")
+| | | Exit q = true :- trace("world")
+| | Exit p = true :- eq(x, "hello"), trace(x), data.test.q
+| Exit eq(data.test.p, _)
+`
+
+	var buf bytes.Buffer
+	PrettyTrace(&buf, *tracer)
+
+	if buf.String() != expected {
+		t.Fatalf("Expected:\n%v\nGot:\n%v", expected, buf.String())
+	}
+
+	// Without a tracer, trace has no effect.
+	runTopDownTestCase(t, loadSmallTestData(), "no tracer", []string{`p :- trace("hello")`}, "true")
+	runTopDownTestCase(t, loadSmallTestData(), "non-string", []string{`p :- trace(1)`}, &Error{Code: TypeErr, Message: "trace: message must be a string not ast.Number"})
+}
+
 func TestTopDownTracingNegation(t *testing.T) {
 	module := `
 	package test
 	p :- arr = [1,2,3,4], x = arr[_], not x = 2
 	`
 	runTopDownTracingTestCase(t, module, 31, map[int]*Event{
-		5:  &Event{EvalOp, parseExpr("not x = 2", 2), 3, 2, parseBindings("{x: 1}")},
-		6:  &Event{EnterOp, ast.MustParseBody("x = 2"), 4, 3, parseBindings("{x: 1}")},
-		16: &Event{FailOp, parseExpr("not x = 2", 2), 3, 2, parseBindings("{x: 2}")},
+		5:  &Event{Op: EvalOp, Node: parseExpr("not x = 2", 2), QueryID: 3, ParentID: 2, Locals: parseBindings("{x: 1}")},
+		6:  &Event{Op: EnterOp, Node: ast.MustParseBody("x = 2"), QueryID: 4, ParentID: 3, Locals: parseBindings("{x: 1}")},
+		16: &Event{Op: FailOp, Node: parseExpr("not x = 2", 2), QueryID: 3, ParentID: 2, Locals: parseBindings("{x: 2}")},
 	})
 }
 
@@ -1461,10 +3199,10 @@ func TestTopDownTracingCompleteDocs(t *testing.T) {
 	q = null :- false
 	`
 	runTopDownTracingTestCase(t, module, 12, map[int]*Event{
-		4: &Event{EnterOp, ast.MustParseRule(`q = ["a", "b", "c", "d"] :- true`), 4, 3, nil},
-		6: &Event{ExitOp, ast.MustParseRule(`q = ["a", "b", "c", "d"] :- true`), 4, 3, nil},
-		7: &Event{RedoOp, ast.MustParseRule(`q = null :- false`), 5, 3, nil},
-		9: &Event{FailOp, parseExpr("false", 0), 5, 3, nil},
+		4: &Event{Op: EnterOp, Node: ast.MustParseRule(`q = ["a", "b", "c", "d"] :- true`), QueryID: 4, ParentID: 3},
+		6: &Event{Op: ExitOp, Node: ast.MustParseRule(`q = ["a", "b", "c", "d"] :- true`), QueryID: 4, ParentID: 3},
+		7: &Event{Op: RedoOp, Node: ast.MustParseRule(`q = null :- false`), QueryID: 5, ParentID: 3},
+		9: &Event{Op: FailOp, Node: parseExpr("false", 0), QueryID: 5, ParentID: 3},
 	})
 }
 
@@ -1484,17 +3222,17 @@ func TestTopDownTracingPartialSets(t *testing.T) {
 	sy := ast.MustParseRule(`s[y] :- y = 4`)
 
 	runTopDownTracingTestCase(t, module, 60, map[int]*Event{
-		4:  &Event{EnterOp, q, 4, 3, nil},
-		7:  &Event{ExitOp, q, 4, 3, parseBindings("{y: 1}")},
-		10: &Event{EnterOp, r, 5, 3, parseBindings("{z: 1}")},
-		16: &Event{RedoOp, q, 4, 3, nil},
-		17: &Event{RedoOp, parseExpr("y = arr[i]", 1), 4, 3, nil},
-		18: &Event{ExitOp, q, 4, 3, parseBindings("{y: 2}")},
-		30: &Event{ExitOp, r, 6, 3, parseBindings("{z: 3}")},
-		32: &Event{EnterOp, sx, 7, 3, parseBindings("{x: 3}")},
-		34: &Event{ExitOp, sx, 7, 3, parseBindings("{x: 3}")},
-		38: &Event{RedoOp, sy, 8, 3, parseBindings("{y: 3}")},
-		40: &Event{FailOp, parseExpr("y = 4", 0), 8, 3, parseBindings("{y: 3}")},
+		4:  &Event{Op: EnterOp, Node: q, QueryID: 4, ParentID: 3},
+		7:  &Event{Op: ExitOp, Node: q, QueryID: 4, ParentID: 3, Locals: parseBindings("{y: 1}")},
+		10: &Event{Op: EnterOp, Node: r, QueryID: 5, ParentID: 3, Locals: parseBindings("{z: 1}")},
+		16: &Event{Op: RedoOp, Node: q, QueryID: 4, ParentID: 3},
+		17: &Event{Op: RedoOp, Node: parseExpr("y = arr[i]", 1), QueryID: 4, ParentID: 3},
+		18: &Event{Op: ExitOp, Node: q, QueryID: 4, ParentID: 3, Locals: parseBindings("{y: 2}")},
+		30: &Event{Op: ExitOp, Node: r, QueryID: 6, ParentID: 3, Locals: parseBindings("{z: 3}")},
+		32: &Event{Op: EnterOp, Node: sx, QueryID: 7, ParentID: 3, Locals: parseBindings("{x: 3}")},
+		34: &Event{Op: ExitOp, Node: sx, QueryID: 7, ParentID: 3, Locals: parseBindings("{x: 3}")},
+		38: &Event{Op: RedoOp, Node: sy, QueryID: 8, ParentID: 3, Locals: parseBindings("{y: 3}")},
+		40: &Event{Op: FailOp, Node: parseExpr("y = 4", 0), QueryID: 8, ParentID: 3, Locals: parseBindings("{y: 3}")},
 	})
 }
 
@@ -1512,14 +3250,14 @@ func TestTopDownTracingPartialObjects(t *testing.T) {
 	rc := ast.MustParseRule(`r["c"] = 4 :- true`)
 
 	runTopDownTracingTestCase(t, module, 39, map[int]*Event{
-		4:  &Event{EnterOp, q, 4, 3, nil},
-		7:  &Event{ExitOp, q, 4, 3, parseBindings(`{k: "a", v: 1}`)},
-		10: &Event{EnterOp, ra, 5, 3, nil},
-		15: &Event{RedoOp, q, 4, 3, nil},
-		16: &Event{RedoOp, parseExpr("obj[k] = v", 1), 4, 3, nil},
-		17: &Event{ExitOp, q, 4, 3, parseBindings(`{k: "b", v: 2}`)},
-		26: &Event{RedoOp, rc, 8, 3, nil},
-		28: &Event{ExitOp, rc, 8, 3, nil},
+		4:  &Event{Op: EnterOp, Node: q, QueryID: 4, ParentID: 3},
+		7:  &Event{Op: ExitOp, Node: q, QueryID: 4, ParentID: 3, Locals: parseBindings(`{k: "a", v: 1}`)},
+		10: &Event{Op: EnterOp, Node: ra, QueryID: 5, ParentID: 3},
+		15: &Event{Op: RedoOp, Node: q, QueryID: 4, ParentID: 3},
+		16: &Event{Op: RedoOp, Node: parseExpr("obj[k] = v", 1), QueryID: 4, ParentID: 3},
+		17: &Event{Op: ExitOp, Node: q, QueryID: 4, ParentID: 3, Locals: parseBindings(`{k: "b", v: 2}`)},
+		26: &Event{Op: RedoOp, Node: rc, QueryID: 8, ParentID: 3},
+		28: &Event{Op: ExitOp, Node: rc, QueryID: 8, ParentID: 3},
 	})
 }
 
@@ -1535,14 +3273,14 @@ func TestTopDownTracingPartialObjectsFull(t *testing.T) {
 	qx := ast.MustParseRule(`q["x"] = 100 :- true`)
 
 	runTopDownTracingTestCase(t, module, 20, map[int]*Event{
-		4:  &Event{EnterOp, q, 4, 3, nil},
-		7:  &Event{ExitOp, q, 4, 3, parseBindings(`{k: "a"}`)},
-		8:  &Event{RedoOp, q, 4, 3, nil},
-		10: &Event{ExitOp, q, 4, 3, parseBindings(`{k: "b"}`)},
-		11: &Event{RedoOp, q, 4, 3, nil},
-		13: &Event{ExitOp, q, 4, 3, parseBindings(`{k: "c"}`)},
-		14: &Event{RedoOp, qx, 5, 3, nil},
-		16: &Event{ExitOp, qx, 5, 3, nil},
+		4:  &Event{Op: EnterOp, Node: q, QueryID: 4, ParentID: 3},
+		7:  &Event{Op: ExitOp, Node: q, QueryID: 4, ParentID: 3, Locals: parseBindings(`{k: "a"}`)},
+		8:  &Event{Op: RedoOp, Node: q, QueryID: 4, ParentID: 3},
+		10: &Event{Op: ExitOp, Node: q, QueryID: 4, ParentID: 3, Locals: parseBindings(`{k: "b"}`)},
+		11: &Event{Op: RedoOp, Node: q, QueryID: 4, ParentID: 3},
+		13: &Event{Op: ExitOp, Node: q, QueryID: 4, ParentID: 3, Locals: parseBindings(`{k: "c"}`)},
+		14: &Event{Op: RedoOp, Node: qx, QueryID: 5, ParentID: 3},
+		16: &Event{Op: ExitOp, Node: qx, QueryID: 5, ParentID: 3},
 	})
 }
 
@@ -1555,12 +3293,12 @@ func TestTopDownTracingComprehensions(t *testing.T) {
 	compr := ast.MustParseBody(`x = data.a[_], x > m`)
 
 	runTopDownTracingTestCase(t, module, 23, map[int]*Event{
-		5:  &Event{EnterOp, compr, 4, 3, parseBindings(`{m: 1}`)},
-		11: &Event{ExitOp, compr, 4, 3, parseBindings(`{m: 1, x: data.a[1]}`)},
-		12: &Event{RedoOp, compr, 4, 3, parseBindings(`{m: 1}`)},
-		15: &Event{ExitOp, compr, 4, 3, parseBindings(`{m: 1, x: data.a[2]}`)},
-		16: &Event{RedoOp, compr, 4, 3, parseBindings(`{m: 1}`)},
-		19: &Event{ExitOp, compr, 4, 3, parseBindings(`{m: 1, x: data.a[3]}`)},
+		5:  &Event{Op: EnterOp, Node: compr, QueryID: 4, ParentID: 3, Locals: parseBindings(`{m: 1}`)},
+		11: &Event{Op: ExitOp, Node: compr, QueryID: 4, ParentID: 3, Locals: parseBindings(`{m: 1, x: data.a[1]}`)},
+		12: &Event{Op: RedoOp, Node: compr, QueryID: 4, ParentID: 3, Locals: parseBindings(`{m: 1}`)},
+		15: &Event{Op: ExitOp, Node: compr, QueryID: 4, ParentID: 3, Locals: parseBindings(`{m: 1, x: data.a[2]}`)},
+		16: &Event{Op: RedoOp, Node: compr, QueryID: 4, ParentID: 3, Locals: parseBindings(`{m: 1}`)},
+		19: &Event{Op: ExitOp, Node: compr, QueryID: 4, ParentID: 3, Locals: parseBindings(`{m: 1, x: data.a[3]}`)},
 	})
 }
 
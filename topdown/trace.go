@@ -7,6 +7,7 @@ package topdown
 import (
 	"fmt"
 	"io"
+	"sort"
 	"strings"
 
 	"github.com/open-policy-agent/opa/ast"
@@ -30,6 +31,10 @@ const (
 
 	// FailOp is emitted when an expression evaluates to false.
 	FailOp Op = "Fail"
+
+	// NoteOp is emitted when the trace built-in function is evaluated. The
+	// event's node is the trace expression with the message plugged in.
+	NoteOp Op = "Note"
 )
 
 // Event contains state associated with a tracing event.
@@ -39,6 +44,10 @@ type Event struct {
 	QueryID  uint64        // Identifies the query this event belongs to.
 	ParentID uint64        // Identifies the parent query this event belongs to.
 	Locals   *ast.ValueMap // Contains local variable bindings from the query context.
+
+	// resolver is used to look up the values of references bound to local
+	// variables when the event is formatted.
+	resolver Resolver
 }
 
 // HasRule returns true if the Event contains an ast.Rule.
@@ -80,6 +89,25 @@ func (evt *Event) String() string {
 	return fmt.Sprintf("%v %v %v (qid=%v, pqid=%v)", evt.Op, evt.Node, evt.Locals, evt.QueryID, evt.ParentID)
 }
 
+// resolve returns the value with references replaced by the values they refer
+// to. If the references cannot be resolved, the value is returned unchanged.
+func (evt *Event) resolve(v ast.Value) ast.Value {
+	if evt.resolver == nil {
+		return v
+	}
+	result, err := ast.TransformRefs(v, func(r ast.Ref) (ast.Value, error) {
+		doc, err := evt.resolver.Resolve(r)
+		if err != nil {
+			return nil, err
+		}
+		return ast.InterfaceToValue(doc)
+	})
+	if err != nil {
+		return v
+	}
+	return result.(ast.Value)
+}
+
 func (evt *Event) equalNodes(other *Event) bool {
 	switch a := evt.Node.(type) {
 	case ast.Body:
@@ -100,6 +128,28 @@ func (evt *Event) equalNodes(other *Event) bool {
 	return false
 }
 
+func evalTrace(t *Topdown, expr *ast.Expr, iter Iterator) error {
+	ops := expr.Terms.([]*ast.Term)
+
+	msg, err := ResolveRefs(ops[1].Value, t)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := msg.(ast.String); !ok {
+		return &Error{
+			Code:    TypeErr,
+			Message: fmt.Sprintf("%v: message must be a string not %T", ast.Trace.Name, msg),
+		}
+	}
+
+	note := *expr
+	note.Terms = []*ast.Term{ops[0], ast.NewTerm(msg)}
+	t.traceNote(&note)
+
+	return iter(t)
+}
+
 // Tracer defines the interface for tracing in the top-down evaluation engine.
 type Tracer interface {
 	Enabled() bool
@@ -125,7 +175,9 @@ func (b *BufferTracer) Trace(t *Topdown, evt *Event) {
 	*b = append(*b, evt)
 }
 
-// PrettyTrace pretty prints the trace to the writer.
+// PrettyTrace pretty prints the trace to the writer. Each event is indented by
+// the depth of the query it belongs to and includes the bindings of variables
+// that appear in the event's node.
 func PrettyTrace(w io.Writer, trace []*Event) {
 	depths := depths{}
 	for _, event := range trace {
@@ -136,9 +188,53 @@ func PrettyTrace(w io.Writer, trace []*Event) {
 
 func formatEvent(event *Event, depth int) string {
 	padding := formatEventPadding(event, depth)
+	if locals := formatEventLocals(event); locals != "" {
+		return fmt.Sprintf("%v%v %v %v", padding, event.Op, event.Node, locals)
+	}
 	return fmt.Sprintf("%v%v %v", padding, event.Op, event.Node)
 }
 
+// formatEventLocals returns the bindings of variables that appear in the
+// event's node. For rules, only variables in the head are included. Wildcards
+// are not included.
+func formatEventLocals(event *Event) string {
+
+	if event.Locals == nil {
+		return ""
+	}
+
+	params := ast.VarVisitorParams{SkipClosures: true}
+	var vars ast.VarSet
+
+	switch node := event.Node.(type) {
+	case *ast.Rule:
+		vars = node.HeadVars()
+	case ast.Body:
+		vars = node.Vars(params)
+	case *ast.Expr:
+		vars = node.Vars(params)
+	default:
+		return ""
+	}
+
+	var buf []string
+	for v := range vars {
+		if v.IsWildcard() || v.Equal(ast.Wildcard.Value) {
+			continue
+		}
+		if b := event.Locals.Get(v); b != nil {
+			buf = append(buf, fmt.Sprintf("%v: %v", v, event.resolve(b)))
+		}
+	}
+
+	if len(buf) == 0 {
+		return ""
+	}
+
+	sort.Strings(buf)
+	return "{" + strings.Join(buf, ", ") + "}"
+}
+
 func formatEventPadding(event *Event, depth int) string {
 	spaces := formatEventSpaces(event, depth)
 	padding := ""
@@ -80,29 +80,29 @@ func TestPrettyTrace(t *testing.T) {
 | | Eval data.test.q[x]
 | | Enter q[x] :- eq(x, data.a[_])
 | | | Eval eq(x, data.a[_])
-| | | Exit q[x] :- eq(x, data.a[_])
-| | Eval plus(x, 1, n)
+| | | Exit q[x] :- eq(x, data.a[_]) {x: 1}
+| | Eval plus(x, 1, n) {x: 1}
 | | Exit p = true :- data.test.q[x], plus(x, 1, n)
 | Redo p = true :- data.test.q[x], plus(x, 1, n)
-| | Redo data.test.q[x]
-| | Redo q[x] :- eq(x, data.a[_])
+| | Redo data.test.q[x] {x: 1}
+| | Redo q[x] :- eq(x, data.a[_]) {x: 1}
 | | | Redo eq(x, data.a[_])
-| | | Exit q[x] :- eq(x, data.a[_])
-| | Eval plus(x, 1, n)
+| | | Exit q[x] :- eq(x, data.a[_]) {x: 2}
+| | Eval plus(x, 1, n) {x: 2}
 | | Exit p = true :- data.test.q[x], plus(x, 1, n)
 | Redo p = true :- data.test.q[x], plus(x, 1, n)
-| | Redo data.test.q[x]
-| | Redo q[x] :- eq(x, data.a[_])
+| | Redo data.test.q[x] {x: 2}
+| | Redo q[x] :- eq(x, data.a[_]) {x: 2}
 | | | Redo eq(x, data.a[_])
-| | | Exit q[x] :- eq(x, data.a[_])
-| | Eval plus(x, 1, n)
+| | | Exit q[x] :- eq(x, data.a[_]) {x: 3}
+| | Eval plus(x, 1, n) {x: 3}
 | | Exit p = true :- data.test.q[x], plus(x, 1, n)
 | Redo p = true :- data.test.q[x], plus(x, 1, n)
-| | Redo data.test.q[x]
-| | Redo q[x] :- eq(x, data.a[_])
+| | Redo data.test.q[x] {x: 3}
+| | Redo q[x] :- eq(x, data.a[_]) {x: 3}
 | | | Redo eq(x, data.a[_])
-| | | Exit q[x] :- eq(x, data.a[_])
-| | Eval plus(x, 1, n)
+| | | Exit q[x] :- eq(x, data.a[_]) {x: 4}
+| | Eval plus(x, 1, n) {x: 4}
 | | Exit p = true :- data.test.q[x], plus(x, 1, n)
 | Exit eq(data.test.p, _)
 `
@@ -129,3 +129,40 @@ func TestPrettyTrace(t *testing.T) {
 		t.Fatalf("Missing lines in trace:\n%v", strings.Join(a[min:], "\n"))
 	}
 }
+
+func TestPrettyTraceBuffer(t *testing.T) {
+
+	query := ast.MustParseBody("data.test.p = x")
+	rule := ast.MustParseRule("p = y :- y = 1")
+	expr := rule.Body[0]
+
+	bindings := ast.NewValueMap()
+	bindings.Put(ast.Var("y"), ast.Number("1"))
+
+	parent := ast.NewValueMap()
+	parent.Put(ast.Var("x"), ast.Number("1"))
+
+	trace := []*Event{
+		{Op: EnterOp, Node: query, QueryID: 1, ParentID: 0, Locals: ast.NewValueMap()},
+		{Op: EvalOp, Node: query[0], QueryID: 1, ParentID: 0, Locals: ast.NewValueMap()},
+		{Op: EnterOp, Node: rule, QueryID: 2, ParentID: 1, Locals: ast.NewValueMap()},
+		{Op: EvalOp, Node: expr, QueryID: 2, ParentID: 1, Locals: ast.NewValueMap()},
+		{Op: ExitOp, Node: rule, QueryID: 2, ParentID: 1, Locals: bindings},
+		{Op: ExitOp, Node: query, QueryID: 1, ParentID: 0, Locals: parent},
+	}
+
+	expected := `Enter eq(data.test.p, x)
+| Eval eq(data.test.p, x)
+| Enter p = y :- eq(y, 1)
+| | Eval eq(y, 1)
+| | Exit p = y :- eq(y, 1) {y: 1}
+| Exit eq(data.test.p, x) {x: 1}
+`
+
+	var buf bytes.Buffer
+	PrettyTrace(&buf, trace)
+
+	if buf.String() != expected {
+		t.Fatalf("Expected trace to be exactly:\n%v\nBut got:\n%v", expected, buf.String())
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright 2016 The OPA Authors.  All rights reserved.
+// Use of this source code is governed by an Apache2
+// license that can be found in the LICENSE file.
+
+package topdown
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/pkg/errors"
+)
+
+type typeCheck func(v ast.Value) bool
+
+func isString(v ast.Value) bool {
+	_, ok := v.(ast.String)
+	return ok
+}
+
+func isNumber(v ast.Value) bool {
+	_, ok := v.(ast.Number)
+	return ok
+}
+
+func isBoolean(v ast.Value) bool {
+	_, ok := v.(ast.Boolean)
+	return ok
+}
+
+func isArray(v ast.Value) bool {
+	_, ok := v.(ast.Array)
+	return ok
+}
+
+func isSet(v ast.Value) bool {
+	_, ok := v.(*ast.Set)
+	return ok
+}
+
+func isObject(v ast.Value) bool {
+	_, ok := v.(ast.Object)
+	return ok
+}
+
+func isNull(v ast.Value) bool {
+	_, ok := v.(ast.Null)
+	return ok
+}
+
+func evalTypeCheck(builtin *ast.Builtin, f typeCheck) BuiltinFunc {
+	return func(t *Topdown, expr *ast.Expr, iter Iterator) error {
+		ops := expr.Terms.([]*ast.Term)
+
+		v, err := ResolveRefs(ops[1].Value, t)
+		if err != nil {
+			return errors.Wrapf(err, "%v", builtin.Name)
+		}
+
+		if f(v) {
+			return iter(t)
+		}
+
+		return nil
+	}
+}
+
+func evalTypeName(t *Topdown, expr *ast.Expr, iter Iterator) error {
+	ops := expr.Terms.([]*ast.Term)
+
+	v, err := ResolveRefs(ops[1].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v", ast.TypeNameBuiltin.Name)
+	}
+
+	name, ok := valueTypeName(v)
+	if !ok {
+		return fmt.Errorf("%v: illegal argument: %v", ast.TypeNameBuiltin.Name, v)
+	}
+
+	undo, err := evalEqUnify(t, ast.String(name), ops[2].Value, nil, iter)
+	t.Unbind(undo)
+	return err
+}
+
+func evalValidateAll(t *Topdown, expr *ast.Expr, iter Iterator) error {
+	ops := expr.Terms.([]*ast.Term)
+
+	v, err := ResolveRefs(ops[1].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v", ast.ValidateAll.Name)
+	}
+
+	r, err := ResolveRefs(ops[2].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v", ast.ValidateAll.Name)
+	}
+
+	rules, ok := r.(ast.Array)
+	if !ok {
+		return &Error{
+			Code:    TypeErr,
+			Message: fmt.Sprintf("%v: rules must be array not %T", ast.ValidateAll.Name, ops[2].Value),
+		}
+	}
+
+	violations := ast.Array{}
+
+	for _, rule := range rules {
+		path, typeName, ok := validationRule(rule.Value)
+		if !ok {
+			return &Error{
+				Code:    TypeErr,
+				Message: fmt.Sprintf("%v: rules must be [path, type name] pairs: %v", ast.ValidateAll.Name, rule),
+			}
+		}
+		x, ok := valueAtPath(v, path)
+		if !ok {
+			violations = append(violations, ast.StringTerm(fmt.Sprintf("%v: missing", path)))
+			continue
+		}
+		if name, _ := valueTypeName(x); name != typeName {
+			violations = append(violations, ast.StringTerm(fmt.Sprintf("%v: expected %v but got %v", path, typeName, name)))
+		}
+	}
+
+	undo, err := evalEqUnify(t, violations, ops[3].Value, nil, iter)
+	t.Unbind(undo)
+	return err
+}
+
+// validationRule returns the path and type name of a validation rule.
+func validationRule(v ast.Value) (ast.Array, string, bool) {
+	rule, ok := v.(ast.Array)
+	if !ok || len(rule) != 2 {
+		return nil, "", false
+	}
+	path, ok := rule[0].Value.(ast.Array)
+	if !ok {
+		return nil, "", false
+	}
+	typeName, ok := rule[1].Value.(ast.String)
+	if !ok {
+		return nil, "", false
+	}
+	return path, string(typeName), true
+}
+
+// valueAtPath returns the value in v at path. The path elements are object keys
+// or array indices.
+func valueAtPath(v ast.Value, path ast.Array) (ast.Value, bool) {
+	for _, x := range path {
+		switch curr := v.(type) {
+		case ast.Object:
+			term := curr.Get(x)
+			if term == nil {
+				return nil, false
+			}
+			v = term.Value
+		case ast.Array:
+			n, ok := x.Value.(ast.Number)
+			if !ok {
+				return nil, false
+			}
+			i, err := json.Number(n).Int64()
+			if err != nil || i < 0 || i >= int64(len(curr)) {
+				return nil, false
+			}
+			v = curr[i].Value
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// valueTypeName returns the name of the type of v.
+func valueTypeName(v ast.Value) (string, bool) {
+	switch v.(type) {
+	case ast.Null:
+		return ast.NullTypeName, true
+	case ast.Boolean:
+		return ast.BooleanTypeName, true
+	case ast.Number:
+		return ast.NumberTypeName, true
+	case ast.String:
+		return ast.StringTypeName, true
+	case ast.Array:
+		return ast.ArrayTypeName, true
+	case ast.Object:
+		return ast.ObjectTypeName, true
+	case *ast.Set:
+		return ast.SetTypeName, true
+	}
+	return "", false
+}
@@ -0,0 +1,142 @@
+// Copyright 2016 The OPA Authors.  All rights reserved.
+// Use of this source code is governed by an Apache2
+// license that can be found in the LICENSE file.
+
+package topdown
+
+import (
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/pkg/errors"
+)
+
+func evalMaxDepth(t *Topdown, expr *ast.Expr, iter Iterator) error {
+	ops := expr.Terms.([]*ast.Term)
+
+	v, err := ResolveRefs(ops[1].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v", ast.MaxDepth.Name)
+	}
+
+	result := ast.IntNumberTerm(maxDepth(v)).Value
+
+	undo, err := evalEqUnify(t, result, ops[2].Value, nil, iter)
+	t.Unbind(undo)
+	return err
+}
+
+func evalLeafCount(t *Topdown, expr *ast.Expr, iter Iterator) error {
+	ops := expr.Terms.([]*ast.Term)
+
+	v, err := ResolveRefs(ops[1].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v", ast.LeafCount.Name)
+	}
+
+	var n int
+	walkLeaves(v, func(ast.Value) {
+		n++
+	})
+
+	undo, err := evalEqUnify(t, ast.IntNumberTerm(n).Value, ops[2].Value, nil, iter)
+	t.Unbind(undo)
+	return err
+}
+
+func evalWalk(t *Topdown, expr *ast.Expr, iter Iterator) error {
+	ops := expr.Terms.([]*ast.Term)
+
+	v, err := ResolveRefs(ops[1].Value, t)
+	if err != nil {
+		return errors.Wrapf(err, "%v", ast.WalkBuiltin.Name)
+	}
+
+	return walkNodes(ast.Array{}, v, func(path ast.Array, node ast.Value) error {
+		result := ast.Array{ast.NewTerm(path), ast.NewTerm(node)}
+		return Unify(t, result, ops[2].Value, iter)
+	})
+}
+
+// walkNodes invokes f for v and each value nested inside v along with the path
+// to the value. Object keys are visited as part of the path only.
+func walkNodes(path ast.Array, v ast.Value, f func(ast.Array, ast.Value) error) error {
+
+	if err := f(path, v); err != nil {
+		return err
+	}
+
+	child := func(key *ast.Term, x ast.Value) error {
+		cpy := make(ast.Array, len(path), len(path)+1)
+		copy(cpy, path)
+		return walkNodes(append(cpy, key), x, f)
+	}
+
+	switch v := v.(type) {
+	case ast.Array:
+		for i := range v {
+			if err := child(ast.IntNumberTerm(i), v[i].Value); err != nil {
+				return err
+			}
+		}
+	case ast.Object:
+		for _, item := range v {
+			if err := child(item[0], item[1].Value); err != nil {
+				return err
+			}
+		}
+	case *ast.Set:
+		for _, x := range *v {
+			if err := child(x, x.Value); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// maxDepth returns the maximum nesting depth of v. Object keys do not
+// contribute to the depth.
+func maxDepth(v ast.Value) int {
+	children, ok := valueChildren(v)
+	if !ok {
+		return 0
+	}
+	var max int
+	for _, x := range children {
+		if d := maxDepth(x.Value); d > max {
+			max = d
+		}
+	}
+	return max + 1
+}
+
+// walkLeaves invokes f for each scalar value nested inside v. If v is a scalar,
+// f is invoked with v. Object keys are not visited.
+func walkLeaves(v ast.Value, f func(ast.Value)) {
+	children, ok := valueChildren(v)
+	if !ok {
+		f(v)
+		return
+	}
+	for _, x := range children {
+		walkLeaves(x.Value, f)
+	}
+}
+
+// valueChildren returns the elements of an array or set or the values of an
+// object. If v is not a composite value, valueChildren returns false.
+func valueChildren(v ast.Value) ([]*ast.Term, bool) {
+	switch v := v.(type) {
+	case ast.Array:
+		return v, true
+	case *ast.Set:
+		return *v, true
+	case ast.Object:
+		children := make([]*ast.Term, len(v))
+		for i, item := range v {
+			children[i] = item[1]
+		}
+		return children, true
+	}
+	return nil, false
+}